@@ -0,0 +1,73 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+// workoutETag is a strong validator for the workout as displayed in system:
+// the same version renders differently per unit system.
+func workoutETag(version int, system string) string {
+	return fmt.Sprintf(`"%d-%s"`, version, system)
+}
+
+// matchesETag reports whether a list header such as If-Match holds "*" or
+// etag. The comparison is strong, so weak tags never match.
+func matchesETag(header string, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIfMatch writes the error response itself and returns false when the
+// request's If-Match names neither version as displayed in system nor "*".
+// Without the header any version is accepted.
+func checkIfMatch(w http.ResponseWriter, r *http.Request, version int, system string) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" || matchesETag(header, workoutETag(version, system)) {
+		return true
+	}
+	_ = utils.WriteJson(w, http.StatusPreconditionFailed, utils.Envelope{"error": "workout has been modified; reload it and try again"})
+	return false
+}
+
+// notModified answers a read whose If-None-Match already holds the current
+// representation with 304 and returns true.
+func notModified(w http.ResponseWriter, r *http.Request, version int, system string) bool {
+	w.Header().Set("ETag", workoutETag(version, system))
+	header := r.Header.Get("If-None-Match")
+	if header == "" {
+		return false
+	}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == "*" || tag == workoutETag(version, system) {
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+	return false
+}
+
+// writeVersionError answers a write that lost a race after its precondition
+// was checked: 412 when the client sent If-Match, 409 otherwise.
+func writeVersionError(w http.ResponseWriter, r *http.Request, err error) bool {
+	if !errors.Is(err, store.ErrVersionConflict) {
+		return false
+	}
+	status := http.StatusConflict
+	if r.Header.Get("If-Match") != "" {
+		status = http.StatusPreconditionFailed
+	}
+	_ = utils.WriteJson(w, status, utils.Envelope{"error": err.Error()})
+	return true
+}
@@ -0,0 +1,117 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkoutETag(t *testing.T) {
+	assert.Equal(t, `"3-metric"`, workoutETag(3, "metric"))
+	assert.NotEqual(t, workoutETag(3, "metric"), workoutETag(3, "imperial"))
+}
+
+func TestMatchesETag(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"same tag", `"3-metric"`, true},
+		{"any", `*`, true},
+		{"in a list", `"2-metric", "3-metric"`, true},
+		{"older version", `"2-metric"`, false},
+		{"other system", `"3-imperial"`, false},
+		{"weak tag", `W/"3-metric"`, false},
+		{"unquoted", `3-metric`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, matchesETag(tt.header, `"3-metric"`))
+		})
+	}
+}
+
+func TestCheckIfMatch(t *testing.T) {
+	tests := []struct {
+		name       string
+		header     string
+		wantOK     bool
+		wantStatus int
+	}{
+		{"no header", "", true, http.StatusOK},
+		{"current", `"3-metric"`, true, http.StatusOK},
+		{"stale", `"2-metric"`, false, http.StatusPreconditionFailed},
+		{"other system", `"3-imperial"`, false, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/workouts/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+			assert.Equal(t, tt.wantOK, checkIfMatch(w, r, 3, "metric"))
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   bool
+	}{
+		{"no header", "", false},
+		{"current", `"3-metric"`, true},
+		{"weak current", `W/"3-metric"`, true},
+		{"any", `*`, true},
+		{"in a list", `"1-metric", "3-metric"`, true},
+		{"stale", `"2-metric"`, false},
+		{"other system", `"3-imperial"`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/workouts/1", nil)
+			if tt.header != "" {
+				r.Header.Set("If-None-Match", tt.header)
+			}
+			w := httptest.NewRecorder()
+			assert.Equal(t, tt.want, notModified(w, r, 3, "metric"))
+			assert.Equal(t, `"3-metric"`, w.Header().Get("ETag"))
+			if tt.want {
+				assert.Equal(t, http.StatusNotModified, w.Code)
+			}
+		})
+	}
+}
+
+func TestWriteVersionError(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		ifMatch    string
+		wantOK     bool
+		wantStatus int
+	}{
+		{"other error", fmt.Errorf("boom"), "", false, http.StatusOK},
+		{"conflict without If-Match", store.ErrVersionConflict, "", true, http.StatusConflict},
+		{"conflict with If-Match", fmt.Errorf("saving: %w", store.ErrVersionConflict), `"3-metric"`, true, http.StatusPreconditionFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPut, "/workouts/1", nil)
+			if tt.ifMatch != "" {
+				r.Header.Set("If-Match", tt.ifMatch)
+			}
+			w := httptest.NewRecorder()
+			assert.Equal(t, tt.wantOK, writeVersionError(w, r, tt.err))
+			assert.Equal(t, tt.wantStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+// EventPublisher pushes a change to every connected client of a user.
+type EventPublisher interface {
+	Publish(userID int, eventType string, data any) error
+}
+
+func publishEvent(events EventPublisher, logger *log.Logger, userID int, eventType string, data any) {
+	if err := events.Publish(userID, eventType, data); err != nil {
+		logger.Printf("ERROR: publishing %s: %v", eventType, err)
+	}
+}
+
+// copyEntries copies entries and their set logs so they can be displayed
+// without touching the originals.
+func copyEntries(entries []store.WorkoutEntry) []store.WorkoutEntry {
+	if entries == nil {
+		return nil
+	}
+	copied := make([]store.WorkoutEntry, len(entries))
+	for i, entry := range entries {
+		entry.SetLog = append([]store.WorkoutSet(nil), entry.SetLog...)
+		copied[i] = entry
+	}
+	return copied
+}
+
+// publishWorkout publishes the workout in the user's preferred units, so every
+// client gets the same payload whatever ?units= the writing request used. It
+// must be called before the workout is displayed for the response.
+func publishWorkout(events EventPublisher, logger *log.Logger, user *store.User, eventType string, workout *store.Workout) {
+	copied := *workout
+	copied.Entries = copyEntries(workout.Entries)
+	displayWorkout(&copied, inputSystem(user))
+	publishEvent(events, logger, user.Id, eventType, &copied)
+}
+
+type EventHandler struct {
+	hub    *realtime.Hub
+	logger *log.Logger
+}
+
+func NewEventHandler(hub *realtime.Hub, logger *log.Logger) *EventHandler {
+	return &EventHandler{
+		hub:    hub,
+		logger: logger,
+	}
+}
+
+// HandleStream streams the user's workout and session changes over a
+// WebSocket when the request asks for an upgrade and as Server-Sent Events
+// otherwise. Clients resume with Last-Event-ID or ?last_event_id=; a "reset"
+// event means events were lost and state has to be reloaded.
+func (eh *EventHandler) HandleStream(w http.ResponseWriter, r *http.Request) {
+	lastEventID, err := realtime.LastEventID(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid last event id"})
+		return
+	}
+	sub, backlog, err := eh.hub.Subscribe(middleware.GetUser(r).Id, lastEventID)
+	gap := errors.Is(err, realtime.ErrHistoryGap)
+	defer sub.Close()
+	// Write errors only mean the client went away.
+	if realtime.IsWebSocketRequest(r) {
+		_ = realtime.ServeWebSocket(w, r, sub, backlog, gap)
+		return
+	}
+	_ = realtime.ServeSSE(w, r, sub, backlog, gap)
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+type ExerciseHandler struct {
+	exerciseStore store.ExerciseStore
+	workoutStore  store.WorkoutStore
+	logger        *log.Logger
+}
+
+func NewExerciseHandler(exerciseStore store.ExerciseStore, workoutStore store.WorkoutStore, logger *log.Logger) *ExerciseHandler {
+	return &ExerciseHandler{
+		exerciseStore: exerciseStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+func (eh *ExerciseHandler) HandleListExercises(w http.ResponseWriter, r *http.Request) {
+	exercises, err := eh.exerciseStore.ListExercises()
+	if err != nil {
+		eh.logger.Printf("ERROR: ListExercises: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"exercises": exercises})
+}
+
+func (eh *ExerciseHandler) HandleGetExerciseById(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+	exercise, err := eh.exerciseStore.GetExerciseById(id)
+	if err != nil {
+		eh.logger.Printf("ERROR: GetExerciseById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if exercise == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"exercise": exercise})
+}
+
+// HandleGetRecommendation suggests the weight and reps for the user's next
+// session of the exercise. The strategy and its settings come from the query,
+// e.g. ?strategy=rpe&rep_min=5&rep_max=5&target_rpe=8.
+func (eh *ExerciseHandler) HandleGetRecommendation(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid exercise id"})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	if system == units.Original {
+		system = inputSystem(currentUser)
+	}
+	weightUnit := units.WeightUnit(system)
+	config, err := readProgressionConfig(r, weightUnit, 0, 0)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	exercise, err := eh.exerciseStore.GetExerciseById(id)
+	if err != nil {
+		eh.logger.Printf("ERROR: GetExerciseById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if exercise == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise not found"})
+		return
+	}
+	recommendation, err := recommend(eh.workoutStore, currentUser.Id, exercise.Name, weightUnit, config)
+	if err != nil {
+		eh.logger.Printf("ERROR: ListRecentPerformances: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if recommendation == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise has no weighted sets logged yet"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"recommendation": recommendation})
+}
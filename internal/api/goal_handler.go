@@ -0,0 +1,309 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/goals"
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/programs"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+// goalTrendDays is how much history the projected completion of lift and
+// bodyweight goals is fitted to.
+const goalTrendDays = 90
+
+type GoalHandler struct {
+	goalStore store.GoalStore
+	logger    *log.Logger
+}
+
+func NewGoalHandler(goalStore store.GoalStore, logger *log.Logger) *GoalHandler {
+	return &GoalHandler{
+		goalStore: goalStore,
+		logger:    logger,
+	}
+}
+
+// goalRequest takes weights in Unit, kg or lb, defaulting to the user's
+// preference. Deadline is a YYYY-MM-DD date.
+type goalRequest struct {
+	Title        string  `json:"title"`
+	Kind         string  `json:"kind"`
+	ExerciseName string  `json:"exercise_name"`
+	Target       float64 `json:"target"`
+	Unit         string  `json:"unit"`
+	Period       string  `json:"period"`
+	Deadline     string  `json:"deadline"`
+}
+
+// goalValues are the goal's values in Unit: a weight unit, or "workouts" for
+// frequency goals.
+type goalValues struct {
+	Unit    string  `json:"unit"`
+	Start   float64 `json:"start"`
+	Current float64 `json:"current"`
+	Target  float64 `json:"target"`
+}
+
+type goalView struct {
+	*store.Goal
+	Display  goalValues     `json:"display"`
+	Progress goals.Progress `json:"progress"`
+}
+
+func (req *goalRequest) validate(user *store.User) (*store.Goal, error) {
+	req.Title = strings.TrimSpace(req.Title)
+	req.ExerciseName = strings.TrimSpace(req.ExerciseName)
+	if !goals.ValidKind(req.Kind) {
+		return nil, errors.New("kind must be lift, frequency, volume or bodyweight")
+	}
+	if req.Target <= 0 {
+		return nil, errors.New("target must be positive")
+	}
+	switch req.Kind {
+	case goals.KindLift:
+		if req.ExerciseName == "" {
+			return nil, errors.New("exercise_name is required for lift goals")
+		}
+	case goals.KindFrequency, goals.KindBodyweight:
+		if req.ExerciseName != "" {
+			return nil, errors.New("exercise_name is only allowed for lift and volume goals")
+		}
+	}
+	if goals.Periodic(req.Kind) {
+		if req.Period == "" {
+			req.Period = goals.PeriodWeek
+		}
+		if !goals.ValidPeriod(req.Period) {
+			return nil, errors.New("period must be week or month")
+		}
+	} else if req.Period != "" {
+		return nil, errors.New("period is only allowed for frequency and volume goals")
+	}
+	goal := &store.Goal{
+		UserId:       user.Id,
+		Title:        req.Title,
+		Kind:         req.Kind,
+		ExerciseName: req.ExerciseName,
+		Period:       req.Period,
+		TargetValue:  req.Target,
+	}
+	if req.Kind != goals.KindFrequency {
+		if req.Unit == "" {
+			req.Unit = units.WeightUnit(inputSystem(user))
+		}
+		convert, err := toKilograms(req.Unit)
+		if err != nil {
+			return nil, errors.New("unit must be kg or lb")
+		}
+		goal.TargetValue = convert(req.Target)
+	}
+	if req.Deadline != "" {
+		deadline, err := time.Parse(programs.DateLayout, req.Deadline)
+		if err != nil {
+			return nil, errors.New("deadline must be formatted as YYYY-MM-DD")
+		}
+		goal.Deadline = &deadline
+	}
+	if goal.Title == "" {
+		goal.Title = defaultGoalTitle(req)
+	}
+	if len(goal.Title) > 255 {
+		return nil, errors.New("title must be at most 255 characters")
+	}
+	return goal, nil
+}
+
+func defaultGoalTitle(req *goalRequest) string {
+	switch req.Kind {
+	case goals.KindLift:
+		return req.ExerciseName + " goal"
+	case goals.KindFrequency:
+		return "Workouts per " + req.Period
+	case goals.KindVolume:
+		if req.ExerciseName != "" {
+			return req.ExerciseName + " volume per " + req.Period
+		}
+		return "Volume per " + req.Period
+	}
+	return "Body weight goal"
+}
+
+// view evaluates the goal's progress and converts its values to system.
+func (gh *GoalHandler) view(goal *store.Goal, system string, now time.Time, loc *time.Location) (goalView, error) {
+	history, err := gh.goalStore.GetGoalHistory(goal, now.AddDate(0, 0, -goalTrendDays))
+	if err != nil {
+		return goalView{}, err
+	}
+	target := goals.Target{
+		Kind:       goal.Kind,
+		Period:     goal.Period,
+		Start:      goal.StartValue,
+		Current:    goal.CurrentValue,
+		Target:     goal.TargetValue,
+		Deadline:   goal.Deadline,
+		AchievedAt: goal.AchievedAt,
+		History:    history,
+	}
+	display := goalValues{Unit: "workouts", Start: goal.StartValue, Current: goal.CurrentValue, Target: goal.TargetValue}
+	if goal.Kind != goals.KindFrequency {
+		display.Unit = units.WeightUnit(system)
+		display.Start = units.FromKilograms(goal.StartValue, display.Unit)
+		display.Current = units.FromKilograms(goal.CurrentValue, display.Unit)
+		display.Target = units.FromKilograms(goal.TargetValue, display.Unit)
+	}
+	return goalView{Goal: goal, Display: display, Progress: target.Progress(now, loc)}, nil
+}
+
+// readGoalContext writes the error response itself and returns false when the
+// ?units= or ?tz= overrides are invalid. Goals have no original units.
+func readGoalContext(w http.ResponseWriter, r *http.Request) (string, *time.Location, bool) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return "", nil, false
+	}
+	if system == units.Original {
+		system = inputSystem(middleware.GetUser(r))
+	}
+	loc, err := userLocation(r, middleware.GetUser(r))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return "", nil, false
+	}
+	return system, loc, true
+}
+
+// authorizeGoal writes the error response itself and returns false when the
+// current user does not own the goal.
+func (gh *GoalHandler) authorizeGoal(w http.ResponseWriter, r *http.Request, id int64) bool {
+	owner, err := gh.goalStore.GetGoalOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "goal not found"})
+			return false
+		}
+		gh.logger.Printf("ERROR: GetGoalOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if owner != middleware.GetUser(r).Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this goal"})
+		return false
+	}
+	return true
+}
+
+func (gh *GoalHandler) HandleCreateGoal(w http.ResponseWriter, r *http.Request) {
+	system, loc, ok := readGoalContext(w, r)
+	if !ok {
+		return
+	}
+	var request goalRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	goal, err := request.validate(middleware.GetUser(r))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	created, err := gh.goalStore.CreateGoal(goal)
+	if err != nil {
+		gh.logger.Printf("ERROR: CreateGoal: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create goal"})
+		return
+	}
+	view, err := gh.view(created, system, time.Now(), loc)
+	if err != nil {
+		gh.logger.Printf("ERROR: GetGoalHistory: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"goal": view})
+}
+
+// HandleListGoals returns the user's goals with their progress, open goals
+// first.
+func (gh *GoalHandler) HandleListGoals(w http.ResponseWriter, r *http.Request) {
+	system, loc, ok := readGoalContext(w, r)
+	if !ok {
+		return
+	}
+	list, err := gh.goalStore.ListGoals(middleware.GetUser(r).Id)
+	if err != nil {
+		gh.logger.Printf("ERROR: ListGoals: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	now := time.Now()
+	views := make([]goalView, 0, len(list))
+	for i := range list {
+		view, err := gh.view(&list[i], system, now, loc)
+		if err != nil {
+			gh.logger.Printf("ERROR: GetGoalHistory: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		views = append(views, view)
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"goals": views})
+}
+
+func (gh *GoalHandler) HandleGetGoalById(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid goal id"})
+		return
+	}
+	if !gh.authorizeGoal(w, r, id) {
+		return
+	}
+	system, loc, ok := readGoalContext(w, r)
+	if !ok {
+		return
+	}
+	goal, err := gh.goalStore.GetGoalById(id)
+	if err != nil {
+		gh.logger.Printf("ERROR: GetGoalById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if goal == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "goal not found"})
+		return
+	}
+	view, err := gh.view(goal, system, time.Now(), loc)
+	if err != nil {
+		gh.logger.Printf("ERROR: GetGoalHistory: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"goal": view})
+}
+
+func (gh *GoalHandler) HandleDeleteGoal(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid goal id"})
+		return
+	}
+	if !gh.authorizeGoal(w, r, id) {
+		return
+	}
+	if err := gh.goalStore.DeleteGoal(id); err != nil {
+		gh.logger.Printf("ERROR: DeleteGoal: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete goal"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
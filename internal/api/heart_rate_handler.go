@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/heartrate"
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/programs"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	maxHeartRateUploadBytes = 10 << 20
+	maxHeartRateSamples     = 200_000
+)
+
+// readHeartRateSamples accepts either a JSON body {"samples": [{"time", "bpm"}]}
+// or a CSV body with "timestamp,bpm" rows and an optional header.
+func readHeartRateSamples(r *http.Request) ([]heartrate.Sample, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "text/csv") {
+		reader := csv.NewReader(r.Body)
+		reader.FieldsPerRecord = 2
+		var samples []heartrate.Sample
+		for line := 1; ; line++ {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return samples, nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			ts, err := time.Parse(time.RFC3339, strings.TrimSpace(record[0]))
+			if err != nil {
+				if line == 1 {
+					continue
+				}
+				return nil, fmt.Errorf("line %d: invalid timestamp", line)
+			}
+			bpm, err := strconv.Atoi(strings.TrimSpace(record[1]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid bpm", line)
+			}
+			samples = append(samples, heartrate.Sample{Time: ts, BPM: bpm})
+		}
+	}
+	var req struct {
+		Samples []heartrate.Sample `json:"samples"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, err
+	}
+	return req.Samples, nil
+}
+
+func (wh *WorkOutHandler) HandleUploadHeartRate(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxHeartRateUploadBytes)
+	samples, err := readHeartRateSamples(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if len(samples) == 0 || len(samples) > maxHeartRateSamples {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("between 1 and %d samples are required", maxHeartRateSamples)})
+		return
+	}
+	for _, sample := range samples {
+		if sample.BPM < 20 || sample.BPM > 250 || sample.Time.IsZero() {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "samples need a time and a bpm between 20 and 250"})
+			return
+		}
+	}
+	if err := wh.workoutStore.SaveHeartRate(id, samples); err != nil {
+		wh.logger.Printf("ERROR: SaveHeartRate: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to store heart rate"})
+		return
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i].Time.Before(samples[j].Time) })
+	analysis := heartrate.Analyze(samples, middleware.GetUser(r).HeartRateSettings())
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"heart_rate": analysis})
+}
+
+func (h *UserHandler) HandleUpdateHeartRateSettings(w http.ResponseWriter, r *http.Request) {
+	var settings heartrate.Settings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if settings.ZoneModel == "" {
+		settings.ZoneModel = heartrate.ModePercentMax
+	}
+	if err := validateHeartRateSettings(settings); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	if err := h.store.UpdateHeartRateSettings(currentUser.Id, settings); err != nil {
+		h.logger.Printf("ERROR: UpdateHeartRateSettings: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"heart_rate_settings": settings, "zones": settings.Zones()})
+}
+
+func validateHeartRateSettings(settings heartrate.Settings) error {
+	if settings.MaxHeartRate < 100 || settings.MaxHeartRate > 250 {
+		return errors.New("max_heart_rate must be between 100 and 250")
+	}
+	if settings.RestingHeartRate < 25 || settings.RestingHeartRate > 120 {
+		return errors.New("resting_heart_rate must be between 25 and 120")
+	}
+	if settings.RestingHeartRate >= settings.MaxHeartRate {
+		return errors.New("resting_heart_rate must be below max_heart_rate")
+	}
+	if settings.ZoneModel != heartrate.ModePercentMax && settings.ZoneModel != heartrate.ModeKarvonen {
+		return errors.New("zone_model must be percent_max or karvonen")
+	}
+	return nil
+}
+
+// HandleGetSummary totals the workouts of a period, including time in each
+// heart-rate zone and accumulated training load for workouts with samples.
+func (sh *StatsHandler) HandleGetSummary(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	loc, err := userLocation(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	today := programs.CivilDate(time.Now(), loc)
+	from, err := readDateQuery(r, "from", today.AddDate(0, 0, -6))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	to, err := readDateQuery(r, "to", today)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if to.Before(from) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid date range"})
+		return
+	}
+	rangeStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	rangeEnd := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	summary, err := sh.workoutStore.GetPeriodSummary(currentUser.Id, currentUser.HeartRateSettings(), rangeStart, rangeEnd)
+	if err != nil {
+		sh.logger.Printf("ERROR: GetPeriodSummary: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"summary": summary})
+}
@@ -0,0 +1,438 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/measurements"
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/programs"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	defaultMeasurementDays = 90
+	// Upper bounds, in canonical units, well above any real body and well
+	// within what the columns can hold.
+	maxWeightKg = 1000
+	maxLengthCm = 500
+)
+
+type MeasurementHandler struct {
+	measurementStore store.MeasurementStore
+	workoutStore     store.WorkoutStore
+	logger           *log.Logger
+}
+
+func NewMeasurementHandler(measurementStore store.MeasurementStore, workoutStore store.WorkoutStore, logger *log.Logger) *MeasurementHandler {
+	return &MeasurementHandler{
+		measurementStore: measurementStore,
+		workoutStore:     workoutStore,
+		logger:           logger,
+	}
+}
+
+// measurementValues are expressed in the unit system named by Unit: kg and cm
+// for metric, lb and in for imperial.
+type measurementValues struct {
+	Unit           string   `json:"unit"`
+	Weight         *float64 `json:"weight"`
+	BodyFatPercent *float64 `json:"body_fat_percent"`
+	Neck           *float64 `json:"neck"`
+	Chest          *float64 `json:"chest"`
+	Waist          *float64 `json:"waist"`
+	Hips           *float64 `json:"hips"`
+	Arms           *float64 `json:"arms"`
+	Thighs         *float64 `json:"thighs"`
+}
+
+// measurementRequest leaves omitted values unchanged on update.
+type measurementRequest struct {
+	MeasuredAt *time.Time `json:"measured_at"`
+	measurementValues
+	Notes *string `json:"notes"`
+}
+
+// measurementView adds the values in the caller's display units to the
+// canonical kg/cm record.
+type measurementView struct {
+	*store.BodyMeasurement
+	Display measurementValues `json:"display"`
+}
+
+// apply validates the request and copies it onto measurement in canonical
+// units. Values without a unit are taken to be in defaultSystem.
+func (req *measurementRequest) apply(measurement *store.BodyMeasurement, defaultSystem string) error {
+	if req.Unit == "" {
+		req.Unit = defaultSystem
+	}
+	if !units.ValidSystem(req.Unit) {
+		return errors.New("unit must be metric or imperial")
+	}
+	weightUnit, lengthUnit := units.WeightUnit(req.Unit), units.LengthUnit(req.Unit)
+	convert := func(name string, value *float64, toCanonical func(float64, string) (float64, error), unit string, limit float64, canonicalUnit string, target **float64) error {
+		if value == nil {
+			return nil
+		}
+		if *value <= 0 {
+			return fmt.Errorf("%s must be positive", name)
+		}
+		converted, err := toCanonical(*value, unit)
+		if err != nil {
+			return err
+		}
+		if converted > limit {
+			return fmt.Errorf("%s must be at most %g %s", name, limit, canonicalUnit)
+		}
+		*target = &converted
+		return nil
+	}
+	if req.BodyFatPercent != nil && (*req.BodyFatPercent < 1 || *req.BodyFatPercent > 75) {
+		return errors.New("body_fat_percent must be between 1 and 75")
+	}
+	if req.BodyFatPercent != nil {
+		measurement.BodyFatPercent = req.BodyFatPercent
+	}
+	if err := errors.Join(
+		convert("weight", req.Weight, units.ToKilograms, weightUnit, maxWeightKg, units.Kilogram, &measurement.WeightKg),
+		convert("neck", req.Neck, units.ToCentimeters, lengthUnit, maxLengthCm, units.Centimeter, &measurement.NeckCm),
+		convert("chest", req.Chest, units.ToCentimeters, lengthUnit, maxLengthCm, units.Centimeter, &measurement.ChestCm),
+		convert("waist", req.Waist, units.ToCentimeters, lengthUnit, maxLengthCm, units.Centimeter, &measurement.WaistCm),
+		convert("hips", req.Hips, units.ToCentimeters, lengthUnit, maxLengthCm, units.Centimeter, &measurement.HipsCm),
+		convert("arms", req.Arms, units.ToCentimeters, lengthUnit, maxLengthCm, units.Centimeter, &measurement.ArmsCm),
+		convert("thighs", req.Thighs, units.ToCentimeters, lengthUnit, maxLengthCm, units.Centimeter, &measurement.ThighsCm),
+	); err != nil {
+		return err
+	}
+	if req.MeasuredAt != nil {
+		measurement.MeasuredAt = *req.MeasuredAt
+	}
+	if req.Notes != nil {
+		measurement.Notes = *req.Notes
+	}
+	measurement.Unit = req.Unit
+	return nil
+}
+
+func displayMeasurement(m *store.BodyMeasurement, system string) measurementView {
+	if system == units.Original {
+		system = m.Unit
+	}
+	weightUnit, lengthUnit := units.WeightUnit(system), units.LengthUnit(system)
+	length := func(cm *float64) *float64 {
+		return convertWeight(cm, func(v float64) float64 { return units.FromCentimeters(v, lengthUnit) })
+	}
+	return measurementView{
+		BodyMeasurement: m,
+		Display: measurementValues{
+			Unit:           system,
+			Weight:         convertWeight(m.WeightKg, func(v float64) float64 { return units.FromKilograms(v, weightUnit) }),
+			BodyFatPercent: m.BodyFatPercent,
+			Neck:           length(m.NeckCm),
+			Chest:          length(m.ChestCm),
+			Waist:          length(m.WaistCm),
+			Hips:           length(m.HipsCm),
+			Arms:           length(m.ArmsCm),
+			Thighs:         length(m.ThighsCm),
+		},
+	}
+}
+
+func hasAnyMeasurement(m *store.BodyMeasurement) bool {
+	return m.WeightKg != nil || m.BodyFatPercent != nil || m.NeckCm != nil || m.ChestCm != nil ||
+		m.WaistCm != nil || m.HipsCm != nil || m.ArmsCm != nil || m.ThighsCm != nil
+}
+
+// authorizeMeasurement writes the error response itself and returns false when
+// the current user does not own the measurement.
+func (mh *MeasurementHandler) authorizeMeasurement(w http.ResponseWriter, r *http.Request, id int64) bool {
+	owner, err := mh.measurementStore.GetMeasurementOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "measurement not found"})
+			return false
+		}
+		mh.logger.Printf("ERROR: GetMeasurementOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if owner != middleware.GetUser(r).Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this measurement"})
+		return false
+	}
+	return true
+}
+
+func (mh *MeasurementHandler) HandleCreateMeasurement(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	var request measurementRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	measurement := &store.BodyMeasurement{
+		UserId:     middleware.GetUser(r).Id,
+		MeasuredAt: time.Now(),
+	}
+	if err := request.apply(measurement, inputSystem(middleware.GetUser(r))); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if !hasAnyMeasurement(measurement) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "at least one measurement is required"})
+		return
+	}
+	created, err := mh.measurementStore.CreateMeasurement(measurement)
+	if err != nil {
+		mh.logger.Printf("ERROR: CreateMeasurement: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create measurement"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"measurement": displayMeasurement(created, system)})
+}
+
+func (mh *MeasurementHandler) HandleGetMeasurementById(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid measurement id"})
+		return
+	}
+	if !mh.authorizeMeasurement(w, r, id) {
+		return
+	}
+	measurement, err := mh.measurementStore.GetMeasurementById(id)
+	if err != nil {
+		mh.logger.Printf("ERROR: GetMeasurementById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if measurement == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "measurement not found"})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"measurement": displayMeasurement(measurement, system)})
+}
+
+func (mh *MeasurementHandler) HandleUpdateMeasurement(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid measurement id"})
+		return
+	}
+	if !mh.authorizeMeasurement(w, r, id) {
+		return
+	}
+	measurement, err := mh.measurementStore.GetMeasurementById(id)
+	if err != nil || measurement == nil {
+		mh.logger.Printf("ERROR: GetMeasurementById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	var request measurementRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	if err := request.apply(measurement, measurement.Unit); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err := mh.measurementStore.UpdateMeasurement(measurement); err != nil {
+		mh.logger.Printf("ERROR: UpdateMeasurement: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update measurement"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"measurement": displayMeasurement(measurement, system)})
+}
+
+func (mh *MeasurementHandler) HandleDeleteMeasurement(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid measurement id"})
+		return
+	}
+	if !mh.authorizeMeasurement(w, r, id) {
+		return
+	}
+	if err := mh.measurementStore.DeleteMeasurement(id); err != nil {
+		mh.logger.Printf("ERROR: DeleteMeasurement: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete measurement"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// measurementRange reads ?from= and ?to= as calendar dates in the user's time
+// zone and returns the matching half-open time range.
+func measurementRange(r *http.Request, user *store.User) (time.Time, time.Time, *time.Location, error) {
+	loc, err := userLocation(r, user)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+	today := programs.CivilDate(time.Now(), loc)
+	from, err := readDateQuery(r, "from", today.AddDate(0, 0, 1-defaultMeasurementDays))
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+	to, err := readDateQuery(r, "to", today)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+	if to.Before(from) {
+		return time.Time{}, time.Time{}, nil, errors.New("invalid date range")
+	}
+	rangeStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	rangeEnd := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	return rangeStart, rangeEnd, loc, nil
+}
+
+func (mh *MeasurementHandler) HandleListMeasurements(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	from, to, _, err := measurementRange(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	list, err := mh.measurementStore.ListMeasurements(currentUser.Id, from, to)
+	if err != nil {
+		mh.logger.Printf("ERROR: ListMeasurements: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	views := make([]measurementView, 0, len(list))
+	for i := range list {
+		views = append(views, displayMeasurement(&list[i], system))
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"measurements": views})
+}
+
+// HandleGetMeasurementTrend returns the daily values of one metric with a
+// trailing moving average, e.g. ?metric=weight_kg&window=7.
+func (mh *MeasurementHandler) HandleGetMeasurementTrend(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	metric := r.URL.Query().Get("metric")
+	if metric == "" {
+		metric = store.MetricWeightKg
+	}
+	if !store.IsMeasurementMetric(metric) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "unknown metric"})
+		return
+	}
+	window, err := utils.ReadIntQuery(r, "window", 7)
+	if err != nil || window < 1 || window > 90 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "window must be between 1 and 90 days"})
+		return
+	}
+	from, to, loc, err := measurementRange(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	if system == units.Original {
+		system = inputSystem(currentUser)
+	}
+	// Load the window before the range too so the first averages are complete.
+	list, err := mh.measurementStore.ListMeasurements(currentUser.Id, from.AddDate(0, 0, 1-window), to)
+	if err != nil {
+		mh.logger.Printf("ERROR: ListMeasurements: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	var points []measurements.Point
+	for i := range list {
+		if value := list[i].Metric(metric); value != nil {
+			points = append(points, measurements.Point{Time: list[i].MeasuredAt, Value: *value})
+		}
+	}
+	trend := measurements.Compute(points, window, loc)
+	first := from.Format(measurements.DateLayout)
+	for len(trend.Points) > 0 && trend.Points[0].Date < first {
+		trend.Points = trend.Points[1:]
+	}
+	unit := "%"
+	switch metric {
+	case store.MetricBodyFatPercent:
+	case store.MetricWeightKg:
+		unit = units.WeightUnit(system)
+		trend.Scale(func(v float64) float64 { return units.FromKilograms(v, unit) })
+	default:
+		unit = units.LengthUnit(system)
+		trend.Scale(func(v float64) float64 { return units.FromCentimeters(v, unit) })
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"metric": metric, "unit": unit, "trend": trend})
+}
+
+type relativeStrength struct {
+	store.StrengthRecord
+	WeightUnit         string   `json:"weight_unit"`
+	BodyweightMultiple *float64 `json:"bodyweight_multiple"`
+}
+
+// HandleGetRelativeStrength reports each lift's best estimated one-rep max as
+// a multiple of the user's latest body weight.
+func (mh *MeasurementHandler) HandleGetRelativeStrength(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	if system == units.Original {
+		system = inputSystem(currentUser)
+	}
+	weightUnit := units.WeightUnit(system)
+	bodyWeight, err := mh.measurementStore.GetLatestBodyWeight(currentUser.Id)
+	if err != nil {
+		mh.logger.Printf("ERROR: GetLatestBodyWeight: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	records, err := mh.workoutStore.ListStrengthRecords(currentUser.Id)
+	if err != nil {
+		mh.logger.Printf("ERROR: ListStrengthRecords: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	lifts := make([]relativeStrength, 0, len(records))
+	for _, record := range records {
+		lift := relativeStrength{StrengthRecord: record, WeightUnit: weightUnit}
+		if bodyWeight != nil {
+			multiple := math.Round(record.EstimatedOneRepMax / *bodyWeight * 100) / 100
+			lift.BodyweightMultiple = &multiple
+		}
+		lift.MaxWeight = units.FromKilograms(record.MaxWeight, weightUnit)
+		lift.EstimatedOneRepMax = units.FromKilograms(record.EstimatedOneRepMax, weightUnit)
+		lifts = append(lifts, lift)
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"body_weight": convertWeight(bodyWeight, func(kg float64) float64 { return units.FromKilograms(kg, weightUnit) }),
+		"weight_unit": weightUnit,
+		"lifts":       lifts,
+	})
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/programs"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const maxScheduleDays = 366
+
+type createProgramRequest struct {
+	Title           string                 `json:"title"`
+	Description     string                 `json:"description"`
+	DurationWeeks   int                    `json:"duration_weeks"`
+	WeeklyIncrement float64                `json:"weekly_increment"`
+	WeightUnit      string                 `json:"weight_unit"`
+	DeloadPercent   *float64               `json:"deload_percent"`
+	IsPublic        bool                   `json:"is_public"`
+	Weeks           []store.ProgramWeek    `json:"weeks"`
+	Sessions        []store.ProgramSession `json:"sessions"`
+}
+
+type ProgramHandler struct {
+	programStore  store.ProgramStore
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	logger        *log.Logger
+}
+
+func NewProgramHandler(programStore store.ProgramStore, templateStore store.TemplateStore, workoutStore store.WorkoutStore, logger *log.Logger) *ProgramHandler {
+	return &ProgramHandler{
+		programStore:  programStore,
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		logger:        logger,
+	}
+}
+
+// validateProgram checks the request; owners maps the ids of the templates it
+// schedules to their owners, as GetTemplateOwners returns them.
+func validateProgram(req *createProgramRequest, userID int, owners map[int64]int) error {
+	if req.Title == "" {
+		return errors.New("title cannot be empty")
+	}
+	if len(req.Title) > 100 {
+		return errors.New("title is too long")
+	}
+	if req.DurationWeeks <= 0 || req.DurationWeeks > 104 {
+		return errors.New("duration_weeks must be between 1 and 104")
+	}
+	if req.DeloadPercent != nil && (*req.DeloadPercent <= 0 || *req.DeloadPercent > 100) {
+		return errors.New("deload_percent must be between 0 and 100")
+	}
+	seen := map[int]bool{}
+	for _, week := range req.Weeks {
+		if week.WeekNumber < 1 || week.WeekNumber > req.DurationWeeks {
+			return fmt.Errorf("week %d is outside the program", week.WeekNumber)
+		}
+		if seen[week.WeekNumber] {
+			return fmt.Errorf("week %d is defined twice", week.WeekNumber)
+		}
+		seen[week.WeekNumber] = true
+		if week.IntensityPercent != nil && *week.IntensityPercent <= 0 {
+			return errors.New("intensity_percent must be positive")
+		}
+	}
+	if len(req.Sessions) == 0 {
+		return errors.New("a program needs at least one session")
+	}
+	for _, session := range req.Sessions {
+		if session.DayNumber < 1 || session.DayNumber > 7 {
+			return errors.New("day_number must be between 1 and 7")
+		}
+		if session.WeekNumber != nil && (*session.WeekNumber < 1 || *session.WeekNumber > req.DurationWeeks) {
+			return fmt.Errorf("session week %d is outside the program", *session.WeekNumber)
+		}
+		if owner, ok := owners[int64(session.TemplateId)]; !ok || owner != userID {
+			return fmt.Errorf("template %d does not exist", session.TemplateId)
+		}
+	}
+	return nil
+}
+
+func (ph *ProgramHandler) HandleCreateProgram(w http.ResponseWriter, r *http.Request) {
+	var req createProgramRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		ph.logger.Printf("ERROR: decoding program request: %v", err)
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	templateIds := make([]int64, 0, len(req.Sessions))
+	for _, session := range req.Sessions {
+		templateIds = append(templateIds, int64(session.TemplateId))
+	}
+	owners, err := ph.templateStore.GetTemplateOwners(templateIds)
+	if err != nil {
+		ph.logger.Printf("ERROR: GetTemplateOwners: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if err := validateProgram(&req, currentUser.Id, owners); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if req.WeightUnit == "" {
+		req.WeightUnit = units.WeightUnit(inputSystem(currentUser))
+	}
+	toKg, err := toKilograms(req.WeightUnit)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	program := &store.Program{
+		UserId:          currentUser.Id,
+		Title:           req.Title,
+		Description:     req.Description,
+		DurationWeeks:   req.DurationWeeks,
+		WeeklyIncrement: toKg(req.WeeklyIncrement),
+		WeightUnit:      req.WeightUnit,
+		DeloadPercent:   60,
+		IsPublic:        req.IsPublic,
+		Weeks:           req.Weeks,
+		Sessions:        req.Sessions,
+	}
+	if req.DeloadPercent != nil {
+		program.DeloadPercent = *req.DeloadPercent
+	}
+	created, err := ph.programStore.CreateProgram(program)
+	if err != nil {
+		ph.logger.Printf("ERROR: CreateProgram: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create program"})
+		return
+	}
+	displayProgram(created, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"program": created})
+}
+
+func (ph *ProgramHandler) HandleListPrograms(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	list, err := ph.programStore.ListPrograms(middleware.GetUser(r).Id)
+	if err != nil {
+		ph.logger.Printf("ERROR: ListPrograms: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	for i := range list {
+		displayProgram(&list[i], system)
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"programs": list})
+}
+
+// loadVisibleProgram fetches a program the current user owns or that has been
+// published, writing the error response itself when it returns nil.
+func (ph *ProgramHandler) loadVisibleProgram(w http.ResponseWriter, r *http.Request) *store.Program {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return nil
+	}
+	program, err := ph.programStore.GetProgramById(id)
+	if err != nil {
+		ph.logger.Printf("ERROR: GetProgramById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	if program == nil || (!program.IsPublic && program.UserId != middleware.GetUser(r).Id) {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "program not found"})
+		return nil
+	}
+	return program
+}
+
+func (ph *ProgramHandler) HandleGetProgramById(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	program := ph.loadVisibleProgram(w, r)
+	if program == nil {
+		return
+	}
+	displayProgram(program, system)
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"program": program})
+}
+
+func (ph *ProgramHandler) HandleDeleteProgram(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid program id"})
+		return
+	}
+	owner, err := ph.programStore.GetProgramOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "program not found"})
+			return
+		}
+		ph.logger.Printf("ERROR: GetProgramOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if owner != middleware.GetUser(r).Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to delete this program"})
+		return
+	}
+	if err := ph.programStore.DeleteProgram(id); err != nil {
+		ph.logger.Printf("ERROR: DeleteProgram: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete program"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (ph *ProgramHandler) HandleEnrol(w http.ResponseWriter, r *http.Request) {
+	program := ph.loadVisibleProgram(w, r)
+	if program == nil {
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	loc, err := userLocation(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	var req struct {
+		StartDate string `json:"start_date"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	startDate := programs.CivilDate(time.Now(), loc)
+	if req.StartDate != "" {
+		if startDate, err = time.Parse(programs.DateLayout, req.StartDate); err != nil {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "start_date must be formatted as YYYY-MM-DD"})
+			return
+		}
+	}
+	enrolment, err := ph.programStore.CreateEnrolment(&store.ProgramEnrolment{
+		UserId:    currentUser.Id,
+		ProgramId: program.Id,
+		StartDate: startDate,
+	})
+	if err != nil {
+		ph.logger.Printf("ERROR: CreateEnrolment: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to enrol"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"enrolment": enrolment})
+}
+
+func readDateQuery(r *http.Request, key string, defaultValue time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	date, err := time.Parse(programs.DateLayout, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be formatted as YYYY-MM-DD", key)
+	}
+	return date, nil
+}
+
+// HandleGetSchedule resolves every active enrolment into concrete sessions per
+// calendar day and reports how many of the due sessions were actually logged.
+func (ph *ProgramHandler) HandleGetSchedule(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	loc, err := userLocation(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	today := programs.CivilDate(time.Now(), loc)
+	from, err := readDateQuery(r, "from", today.AddDate(0, 0, -6))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	to, err := readDateQuery(r, "to", today.AddDate(0, 0, 7))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if to.Before(from) || to.Sub(from) > maxScheduleDays*24*time.Hour {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid date range"})
+		return
+	}
+
+	enrolments, err := ph.programStore.ListActiveEnrolments(currentUser.Id)
+	if err != nil {
+		ph.logger.Printf("ERROR: ListActiveEnrolments: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	templates := map[int]*store.WorkoutTemplate{}
+	oneRepMax := map[string]float64{}
+	var sessions []programs.PlannedSession
+	for _, enrolment := range enrolments {
+		program, err := ph.programStore.GetProgramById(int64(enrolment.ProgramId))
+		if err != nil {
+			ph.logger.Printf("ERROR: GetProgramById: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if program == nil {
+			continue
+		}
+		if err := ph.loadTemplates(program, currentUser.Id, templates, oneRepMax); err != nil {
+			ph.logger.Printf("ERROR: loading program templates: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		sessions = append(sessions, programs.Plan(program, enrolment, templates, oneRepMax, from, to)...)
+	}
+
+	rangeStart := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+	rangeEnd := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+	logged, err := ph.workoutStore.ListWorkoutActivity(currentUser.Id, rangeStart, rangeEnd)
+	if err != nil {
+		ph.logger.Printf("ERROR: ListWorkoutActivity: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	adherence := programs.Link(sessions, logged, loc, today)
+	for i := range sessions {
+		displayEntries(sessions[i].Entries, system)
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"timezone":  loc.String(),
+		"days":      programs.GroupByDay(sessions, from, to),
+		"adherence": adherence,
+	})
+}
+
+func (ph *ProgramHandler) loadTemplates(program *store.Program, userID int, templates map[int]*store.WorkoutTemplate, oneRepMax map[string]float64) error {
+	var ids []int64
+	for _, session := range program.Sessions {
+		if _, ok := templates[session.TemplateId]; !ok {
+			ids = append(ids, int64(session.TemplateId))
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	loaded, err := ph.templateStore.GetTemplatesByIds(ids)
+	if err != nil {
+		return err
+	}
+	for id, template := range loaded {
+		templates[int(id)] = template
+		for _, entry := range template.Entries {
+			name := strings.ToLower(entry.ExerciseName)
+			if _, ok := oneRepMax[name]; entry.TargetPercent1RM == nil || ok {
+				continue
+			}
+			estimate, err := ph.workoutStore.GetEstimatedOneRepMax(userID, entry.ExerciseName)
+			if err != nil {
+				return err
+			}
+			if estimate != nil {
+				oneRepMax[name] = *estimate
+			}
+		}
+	}
+	return nil
+}
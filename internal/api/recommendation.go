@@ -0,0 +1,106 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/Numeez/go-zenith/internal/progression"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+// recommendationHistory is how many recent entries of an exercise feed a
+// recommendation; enough to spot a stall before a deload.
+const recommendationHistory = 8
+
+type recommendationView struct {
+	ExerciseName string `json:"exercise_name"`
+	WeightUnit   string `json:"weight_unit"`
+	*progression.Recommendation
+}
+
+// defaultIncrement is the smallest usual plate jump in the weight unit.
+func defaultIncrement(weightUnit string) float64 {
+	if weightUnit == units.Pound {
+		return 5
+	}
+	return 2.5
+}
+
+// readProgressionConfig reads the strategy settings from the query. The rep
+// range defaults to repMin and repMax, which may be zero to use the strategy
+// defaults, and the increment is in weightUnit.
+func readProgressionConfig(r *http.Request, weightUnit string, repMin, repMax int) (progression.Config, error) {
+	config := progression.Config{Strategy: r.URL.Query().Get("strategy")}
+	var errs [6]error
+	config.RepMin, errs[0] = utils.ReadIntQuery(r, "rep_min", repMin)
+	config.RepMax, errs[1] = utils.ReadIntQuery(r, "rep_max", repMax)
+	config.TargetRPE, errs[2] = utils.ReadFloatQuery(r, "target_rpe", 0)
+	config.Increment, errs[3] = utils.ReadFloatQuery(r, "increment", defaultIncrement(weightUnit))
+	config.DeloadAfter, errs[4] = utils.ReadIntQuery(r, "deload_after", 0)
+	config.DeloadPercent, errs[5] = utils.ReadFloatQuery(r, "deload_percent", 0)
+	for i, key := range []string{"rep_min", "rep_max", "target_rpe", "increment", "deload_after", "deload_percent"} {
+		if errs[i] != nil {
+			return config, fmt.Errorf("%s must be a number", key)
+		}
+	}
+	return config, config.Validate()
+}
+
+// progressionSessions turns recent entries, newest first, into the working
+// sets of each workout with weights in weightUnit. Entries without a set log
+// count as Sets identical sets.
+func progressionSessions(performances []store.LastPerformance, weightUnit string) []progression.Session {
+	var sessions []progression.Session
+	byWorkout := map[int]int{}
+	for _, performance := range performances {
+		var sets []progression.Set
+		entry := performance.Entry
+		for _, set := range entry.SetLog {
+			if set.SetType == store.SetTypeWarmup || set.Reps == nil || set.Weight == nil {
+				continue
+			}
+			rpe := set.RPE
+			if rpe == nil && set.RIR != nil {
+				fromRIR := float64(max(10-*set.RIR, 1))
+				rpe = &fromRIR
+			}
+			sets = append(sets, progression.Set{
+				Reps:   *set.Reps,
+				Weight: units.FromKilograms(*set.Weight, weightUnit),
+				RPE:    rpe,
+				Failed: set.Completed != nil && !*set.Completed,
+			})
+		}
+		if len(entry.SetLog) == 0 && entry.Reps != nil && entry.Weight != nil {
+			for range entry.Sets {
+				sets = append(sets, progression.Set{Reps: *entry.Reps, Weight: units.FromKilograms(*entry.Weight, weightUnit)})
+			}
+		}
+		if len(sets) == 0 {
+			continue
+		}
+		if i, ok := byWorkout[performance.WorkoutId]; ok {
+			sessions[i].Sets = append(sessions[i].Sets, sets...)
+			continue
+		}
+		byWorkout[performance.WorkoutId] = len(sessions)
+		sessions = append(sessions, progression.Session{PerformedAt: performance.PerformedAt, Sets: sets})
+	}
+	return sessions
+}
+
+// recommend returns nil when the user has no weighted history of the
+// exercise.
+func recommend(workoutStore store.WorkoutStore, userID int, exerciseName, weightUnit string, config progression.Config) (*recommendationView, error) {
+	performances, err := workoutStore.ListRecentPerformances(userID, exerciseName, recommendationHistory)
+	if err != nil {
+		return nil, err
+	}
+	recommendation := progression.Recommend(progressionSessions(performances, weightUnit), config)
+	if recommendation == nil {
+		return nil, nil
+	}
+	return &recommendationView{ExerciseName: exerciseName, WeightUnit: weightUnit, Recommendation: recommendation}, nil
+}
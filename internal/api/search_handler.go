@@ -0,0 +1,49 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const maxSearchLength = 200
+
+// HandleSearch runs a full-text search over the user's workouts and entries.
+// It accepts the same tag, date and paging filters as the workout listing.
+func (wh *WorkOutHandler) HandleSearch(w http.ResponseWriter, r *http.Request) {
+	q := strings.TrimSpace(r.URL.Query().Get("q"))
+	if q == "" {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "q is required"})
+		return
+	}
+	if len(q) > maxSearchLength {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "q is too long"})
+		return
+	}
+	filter, err := readWorkoutFilter(r, middleware.GetUser(r))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	results, err := wh.workoutStore.SearchWorkouts(filter, q)
+	if errors.Is(err, store.ErrEmptySearch) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: SearchWorkouts: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"query":   q,
+		"results": results.Hits,
+		"total":   results.Total,
+		"limit":   filter.Limit,
+		"offset":  filter.Offset,
+	})
+}
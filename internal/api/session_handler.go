@@ -0,0 +1,409 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/sessions"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+type SessionHandler struct {
+	sessionStore     store.SessionStore
+	templateStore    store.TemplateStore
+	exerciseStore    store.ExerciseStore
+	measurementStore store.MeasurementStore
+	events           EventPublisher
+	logger           *log.Logger
+}
+
+func NewSessionHandler(sessionStore store.SessionStore, templateStore store.TemplateStore, exerciseStore store.ExerciseStore, measurementStore store.MeasurementStore, events EventPublisher, logger *log.Logger) *SessionHandler {
+	return &SessionHandler{
+		sessionStore:     sessionStore,
+		templateStore:    templateStore,
+		exerciseStore:    exerciseStore,
+		measurementStore: measurementStore,
+		events:           events,
+		logger:           logger,
+	}
+}
+
+// sessionView adds the live timers, computed at response time, to a session.
+type sessionView struct {
+	*store.Session
+	ElapsedSeconds int                  `json:"elapsed_seconds"`
+	Rest           *sessions.RestStatus `json:"rest,omitempty"`
+}
+
+func newSessionView(session *store.Session, system string, now time.Time) sessionView {
+	displayEntries(session.Entries, system)
+	view := sessionView{Session: session}
+	end := now
+	if session.EndedAt != nil {
+		end = *session.EndedAt
+	}
+	view.ElapsedSeconds = int(session.Clock().Elapsed(end) / time.Second)
+	if rest := session.Rest(); rest != nil && session.Status == sessions.StatusActive {
+		status := rest.Status(now)
+		view.Rest = &status
+	}
+	return view
+}
+
+// authorizeSession writes the error response itself and returns false when
+// the current user does not own the session.
+func (sh *SessionHandler) authorizeSession(w http.ResponseWriter, r *http.Request, id int64) bool {
+	owner, err := sh.sessionStore.GetSessionOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "session not found"})
+			return false
+		}
+		sh.logger.Printf("ERROR: GetSessionOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if owner != middleware.GetUser(r).Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this session"})
+		return false
+	}
+	return true
+}
+
+// writeSessionError maps the errors of session changes to responses.
+func (sh *SessionHandler) writeSessionError(w http.ResponseWriter, op string, err error) {
+	switch {
+	case errors.Is(err, sessions.ErrInvalidTransition), errors.Is(err, store.ErrSessionChanged):
+		_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": err.Error()})
+	case errors.Is(err, store.ErrUnknownEntry):
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+	case errors.Is(err, sql.ErrNoRows):
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "session not found"})
+	default:
+		sh.logger.Printf("ERROR: %s: %v", op, err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+	}
+}
+
+// publishSession publishes the session in the user's preferred units. It must
+// be called before the session is displayed for the response.
+func (sh *SessionHandler) publishSession(user *store.User, eventType string, session *store.Session, now time.Time) {
+	copied := *session
+	copied.Entries = copyEntries(session.Entries)
+	publishEvent(sh.events, sh.logger, user.Id, eventType, newSessionView(&copied, inputSystem(user), now))
+}
+
+// writeSession responds with the current state of the session and, unless
+// eventType is empty, publishes it.
+func (sh *SessionHandler) writeSession(w http.ResponseWriter, r *http.Request, id int64, status int, system string, eventType string) {
+	session, err := sh.sessionStore.GetSessionById(id)
+	if err != nil || session == nil {
+		sh.logger.Printf("ERROR: GetSessionById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	now := time.Now()
+	if eventType != "" {
+		sh.publishSession(middleware.GetUser(r), eventType, session, now)
+	}
+	_ = utils.WriteJson(w, status, utils.Envelope{"session": newSessionView(session, system, now)})
+}
+
+// readSession reads the session id and display units and checks ownership.
+func (sh *SessionHandler) readSession(w http.ResponseWriter, r *http.Request) (int64, string, bool) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid session id"})
+		return 0, "", false
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok || !sh.authorizeSession(w, r, id) {
+		return 0, "", false
+	}
+	return id, system, true
+}
+
+func (sh *SessionHandler) HandleStartSession(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Title       string `json:"title"`
+		Description string `json:"description"`
+		TemplateId  *int   `json:"template_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	session := &store.Session{
+		UserId:      currentUser.Id,
+		Title:       strings.TrimSpace(request.Title),
+		Description: request.Description,
+		TemplateId:  request.TemplateId,
+	}
+	if request.TemplateId != nil {
+		template, err := sh.templateStore.GetTemplateById(int64(*request.TemplateId))
+		if err != nil {
+			sh.logger.Printf("ERROR: GetTemplateById: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if template == nil || template.UserId != currentUser.Id {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "unknown template"})
+			return
+		}
+		if session.Title == "" {
+			session.Title = template.Title
+		}
+	}
+	if session.Title == "" {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "title is required"})
+		return
+	}
+	// The title becomes the workout's when the session is finished.
+	if err := validateTitle(session.Title); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	created, err := sh.sessionStore.CreateSession(session)
+	if isUniqueViolation(err) {
+		_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "another session is already in progress"})
+		return
+	}
+	if err != nil {
+		sh.logger.Printf("ERROR: CreateSession: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to start session"})
+		return
+	}
+	now := time.Now()
+	sh.publishSession(currentUser, realtime.EventSessionStarted, created, now)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"session": newSessionView(created, system, now)})
+}
+
+// HandleGetCurrentSession returns the user's open session so a client can
+// pick it up again after a restart.
+func (sh *SessionHandler) HandleGetCurrentSession(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	session, err := sh.sessionStore.GetOpenSession(middleware.GetUser(r).Id)
+	if err != nil {
+		sh.logger.Printf("ERROR: GetOpenSession: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if session == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "no session in progress"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"session": newSessionView(session, system, time.Now())})
+}
+
+func (sh *SessionHandler) HandleGetSessionById(w http.ResponseWriter, r *http.Request) {
+	id, system, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	sh.writeSession(w, r, id, http.StatusOK, system, "")
+}
+
+func (sh *SessionHandler) HandleAddSessionEntry(w http.ResponseWriter, r *http.Request) {
+	id, system, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		ExerciseName string `json:"exercise_name"`
+		Notes        string `json:"notes"`
+		WeightUnit   string `json:"weight_unit"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	entry := &store.WorkoutEntry{
+		ExerciseName: strings.TrimSpace(request.ExerciseName),
+		Notes:        request.Notes,
+		WeightUnit:   request.WeightUnit,
+	}
+	if entry.ExerciseName == "" {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "exercise_name is required"})
+		return
+	}
+	if entry.WeightUnit == "" {
+		entry.WeightUnit = units.WeightUnit(inputSystem(middleware.GetUser(r)))
+	}
+	if !units.ValidWeightUnit(entry.WeightUnit) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "weight_unit must be kg or lb"})
+		return
+	}
+	if err := sh.sessionStore.AddSessionEntry(id, entry); err != nil {
+		sh.writeSessionError(w, "AddSessionEntry", err)
+		return
+	}
+	sh.writeSession(w, r, id, http.StatusCreated, system, realtime.EventSessionUpdated)
+}
+
+// HandleAddSessionSet logs a set as it is performed. The set goes to entry_id
+// or, without one, to the current exercise when exercise_name matches it and
+// to a new entry otherwise. rest_seconds is the target of the rest timer that
+// starts after the set.
+func (sh *SessionHandler) HandleAddSessionSet(w http.ResponseWriter, r *http.Request) {
+	id, system, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		store.WorkoutSet
+		EntryId      int    `json:"entry_id"`
+		ExerciseName string `json:"exercise_name"`
+		WeightUnit   string `json:"weight_unit"`
+		RestSeconds  *int   `json:"rest_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	entry := &store.WorkoutEntry{Id: request.EntryId, ExerciseName: strings.TrimSpace(request.ExerciseName), WeightUnit: request.WeightUnit}
+	if entry.Id == 0 && entry.ExerciseName == "" {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "entry_id or exercise_name is required"})
+		return
+	}
+	if request.RestSeconds != nil && *request.RestSeconds < 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "rest_seconds cannot be negative"})
+		return
+	}
+	set := request.WorkoutSet
+	set.Completed, set.RestSeconds = nil, nil
+	entry.SetLog = []store.WorkoutSet{set}
+	if err := store.ValidateSetLogs([]store.WorkoutEntry{*entry}); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if entry.WeightUnit == "" {
+		entry.WeightUnit = units.WeightUnit(inputSystem(middleware.GetUser(r)))
+	}
+	toKg, err := toKilograms(entry.WeightUnit)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	set.Weight = convertWeight(set.Weight, toKg)
+	entry.SetLog = nil
+	if err := sh.sessionStore.AddSessionSet(id, entry, &set, request.RestSeconds); err != nil {
+		sh.writeSessionError(w, "AddSessionSet", err)
+		return
+	}
+	sh.writeSession(w, r, id, http.StatusCreated, system, realtime.EventSessionUpdated)
+}
+
+// HandleStartRest restarts the rest timer by hand, e.g. after a long warm-up.
+func (sh *SessionHandler) HandleStartRest(w http.ResponseWriter, r *http.Request) {
+	id, system, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		Seconds *int `json:"seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if request.Seconds != nil && *request.Seconds < 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "seconds cannot be negative"})
+		return
+	}
+	if err := sh.sessionStore.StartRest(id, request.Seconds); err != nil {
+		sh.writeSessionError(w, "StartRest", err)
+		return
+	}
+	sh.writeSession(w, r, id, http.StatusOK, system, realtime.EventSessionUpdated)
+}
+
+func (sh *SessionHandler) transition(w http.ResponseWriter, r *http.Request, action string) {
+	id, system, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	if err := sh.sessionStore.TransitionSession(id, action); err != nil {
+		sh.writeSessionError(w, "TransitionSession", err)
+		return
+	}
+	sh.writeSession(w, r, id, http.StatusOK, system, realtime.EventSessionUpdated)
+}
+
+func (sh *SessionHandler) HandlePauseSession(w http.ResponseWriter, r *http.Request) {
+	sh.transition(w, r, sessions.ActionPause)
+}
+
+func (sh *SessionHandler) HandleResumeSession(w http.ResponseWriter, r *http.Request) {
+	sh.transition(w, r, sessions.ActionResume)
+}
+
+// HandleFinishSession turns the session into a regular workout whose duration
+// is the time trained, pauses excluded.
+func (sh *SessionHandler) HandleFinishSession(w http.ResponseWriter, r *http.Request) {
+	id, system, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	session, err := sh.sessionStore.GetSessionById(id)
+	if err != nil || session == nil {
+		sh.logger.Printf("ERROR: GetSessionById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !sessions.Open(session.Status) {
+		_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "session is already " + session.Status})
+		return
+	}
+	endedAt := time.Now()
+	workout := session.Workout(endedAt)
+	if len(workout.Entries) == 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "log at least one set before finishing"})
+		return
+	}
+	if err := estimateCalories(sh.exerciseStore, sh.measurementStore, workout, middleware.GetUser(r)); err != nil {
+		sh.logger.Printf("ERROR: estimating calories: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to finish session"})
+		return
+	}
+	if err := sh.sessionStore.FinishSession(session, workout, endedAt); err != nil {
+		sh.writeSessionError(w, "FinishSession", err)
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	publishEvent(sh.events, sh.logger, currentUser.Id, realtime.EventSessionFinished, utils.Envelope{"id": session.Id, "workout_id": workout.Id})
+	publishWorkout(sh.events, sh.logger, currentUser, realtime.EventWorkoutCreated, workout)
+	displayWorkout(workout, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": workout})
+}
+
+// HandleDeleteSession discards a session. A finished session's workout is
+// kept.
+func (sh *SessionHandler) HandleDeleteSession(w http.ResponseWriter, r *http.Request) {
+	id, _, ok := sh.readSession(w, r)
+	if !ok {
+		return
+	}
+	if err := sh.sessionStore.DeleteSession(id); err != nil {
+		sh.writeSessionError(w, "DeleteSession", err)
+		return
+	}
+	publishEvent(sh.events, sh.logger, middleware.GetUser(r).Id, realtime.EventSessionDeleted, utils.Envelope{"id": id})
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakePublisher struct {
+	events []string
+}
+
+func (fp *fakePublisher) Publish(userID int, eventType string, data any) error {
+	fp.events = append(fp.events, eventType)
+	return nil
+}
+
+type fakeSessionStore struct {
+	store.SessionStore
+	created []*store.Session
+}
+
+func (fs *fakeSessionStore) CreateSession(session *store.Session) (*store.Session, error) {
+	session.Id = len(fs.created) + 1
+	fs.created = append(fs.created, session)
+	return session, nil
+}
+
+func TestHandleStartSessionTitleLength(t *testing.T) {
+	tests := []struct {
+		name       string
+		title      string
+		wantStatus int
+	}{
+		{"fifty characters", strings.Repeat("é", 50), http.StatusCreated},
+		{"fifty one characters", strings.Repeat("a", 51), http.StatusBadRequest},
+		{"surrounding space is trimmed", " " + strings.Repeat("a", 50) + " ", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sessions := &fakeSessionStore{}
+			handler := NewSessionHandler(sessions, nil, nil, nil, &fakePublisher{}, log.New(io.Discard, "", 0))
+			body := `{"title":"` + tt.title + `"}`
+			r := httptest.NewRequest(http.MethodPost, "/sessions", strings.NewReader(body))
+			r = middleware.SetUser(r, &store.User{Id: 1})
+			w := httptest.NewRecorder()
+
+			handler.HandleStartSession(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus == http.StatusCreated {
+				assert.Len(t, sessions.created, 1)
+			} else {
+				assert.Empty(t, sessions.created)
+			}
+		})
+	}
+}
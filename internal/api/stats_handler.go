@@ -0,0 +1,106 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/streaks"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+type StatsHandler struct {
+	workoutStore store.WorkoutStore
+	logger       *log.Logger
+}
+
+func NewStatsHandler(workoutStore store.WorkoutStore, logger *log.Logger) *StatsHandler {
+	return &StatsHandler{
+		workoutStore: workoutStore,
+		logger:       logger,
+	}
+}
+
+// userLocation resolves the time zone used for day boundaries: the ?tz=
+// override wins over the zone stored on the user's profile.
+func userLocation(r *http.Request, user *store.User) (*time.Location, error) {
+	name := r.URL.Query().Get("tz")
+	if name == "" {
+		name = user.Timezone
+	}
+	if name == "" {
+		return time.UTC, nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("invalid timezone")
+	}
+	return loc, nil
+}
+
+func (sh *StatsHandler) HandleGetStreaks(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	loc, err := userLocation(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	opts := streaks.Options{Location: loc}
+	if opts.RestDays, err = utils.ReadIntQuery(r, "rest_days", 0); err != nil || opts.RestDays < 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "rest_days must be a non-negative integer"})
+		return
+	}
+	if opts.WeeklyTarget, err = utils.ReadIntQuery(r, "weekly_target", 0); err != nil || opts.WeeklyTarget < 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "weekly_target must be a non-negative integer"})
+		return
+	}
+	now := time.Now()
+	activity, err := sh.workoutStore.ListWorkoutActivity(currentUser.Id, time.Time{}, now.Add(24*time.Hour))
+	if err != nil {
+		sh.logger.Printf("ERROR: ListWorkoutActivity: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	timestamps := make([]time.Time, 0, len(activity))
+	for _, a := range activity {
+		timestamps = append(timestamps, a.CreatedAt)
+	}
+	result := streaks.Compute(timestamps, now, opts)
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"streaks": result, "timezone": loc.String()})
+}
+
+func (sh *StatsHandler) HandleGetCalendar(w http.ResponseWriter, r *http.Request) {
+	currentUser := middleware.GetUser(r)
+	loc, err := userLocation(r, currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	year, err := utils.ReadIntQuery(r, "year", time.Now().In(loc).Year())
+	if err != nil || year < 1970 || year > 9999 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid year"})
+		return
+	}
+	// Widen the window by a day on both ends so workouts near midnight are
+	// assigned to the right local day.
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, loc).Add(-24 * time.Hour)
+	to := time.Date(year+1, time.January, 1, 0, 0, 0, 0, loc).Add(24 * time.Hour)
+	activity, err := sh.workoutStore.ListWorkoutActivity(currentUser.Id, from, to)
+	if err != nil {
+		sh.logger.Printf("ERROR: ListWorkoutActivity: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	days := make([]streaks.Activity, 0, len(activity))
+	for _, a := range activity {
+		days = append(days, streaks.Activity{At: a.CreatedAt, Minutes: a.DurationMinutes})
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"year":     year,
+		"timezone": loc.String(),
+		"days":     streaks.Calendar(year, days, loc),
+	})
+}
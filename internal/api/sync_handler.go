@@ -0,0 +1,520 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	syncServerWins     = "server_wins"
+	syncLastWriterWins = "last_writer_wins"
+
+	syncApplied  = "applied"
+	syncConflict = "conflict"
+	syncRejected = "rejected"
+
+	defaultSyncPageSize = 100
+	maxSyncPageSize     = 500
+	maxSyncChanges      = 500
+	maxSyncBytes        = 10 << 20
+)
+
+// errInvalidChange wraps the reasons a pushed change cannot be applied as
+// sent.
+var errInvalidChange = errors.New("invalid change")
+
+func invalidChange(err error) error {
+	return fmt.Errorf("%w: %v", errInvalidChange, err)
+}
+
+type SyncHandler struct {
+	syncStore        store.SyncStore
+	workoutStore     store.WorkoutStore
+	templateStore    store.TemplateStore
+	measurementStore store.MeasurementStore
+	exerciseStore    store.ExerciseStore
+	events           EventPublisher
+	logger           *log.Logger
+}
+
+func NewSyncHandler(syncStore store.SyncStore, workoutStore store.WorkoutStore, templateStore store.TemplateStore, measurementStore store.MeasurementStore, exerciseStore store.ExerciseStore, events EventPublisher, logger *log.Logger) *SyncHandler {
+	return &SyncHandler{
+		syncStore:        syncStore,
+		workoutStore:     workoutStore,
+		templateStore:    templateStore,
+		measurementStore: measurementStore,
+		exerciseStore:    exerciseStore,
+		events:           events,
+		logger:           logger,
+	}
+}
+
+// syncRecord is one record of the change feed. Data is absent for
+// tombstones.
+type syncRecord struct {
+	store.SyncChange
+	Data any `json:"data,omitempty"`
+}
+
+// syncChange is a change a client made offline. Id is 0 for a record the
+// client created; BaseSeq is the seq of the record when the client last saw
+// it. Data holds only the fields the client changed.
+type syncChange struct {
+	ClientId string          `json:"client_id"`
+	Entity   string          `json:"entity"`
+	Id       int64           `json:"id,omitempty"`
+	BaseSeq  int64           `json:"base_seq"`
+	Deleted  bool            `json:"deleted,omitempty"`
+	Data     json.RawMessage `json:"data,omitempty"`
+}
+
+// syncResult reports what became of a pushed change along with the record as
+// the server now has it. Merged is set when the change was applied over
+// server changes the client had not seen.
+type syncResult struct {
+	ClientId string `json:"client_id"`
+	Entity   string `json:"entity"`
+	Id       int64  `json:"id,omitempty"`
+	Status   string `json:"status"`
+	Merged   bool   `json:"merged,omitempty"`
+	Seq      int64  `json:"seq,omitempty"`
+	Deleted  bool   `json:"deleted,omitempty"`
+	Data     any    `json:"data,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// templateChange leaves omitted fields of the template unchanged; entries,
+// when sent, replace all of them.
+type templateChange struct {
+	Title       *string               `json:"title"`
+	Description *string               `json:"description"`
+	Entries     []store.TemplateEntry `json:"entries"`
+}
+
+func syncEntity(entity string) bool {
+	return entity == store.SyncWorkout || entity == store.SyncTemplate || entity == store.SyncMeasurement
+}
+
+// loadRecords returns the live records among changes as shown by their own
+// endpoints, by entity and id, loading each kind of record with one call.
+// Records deleted since their change was read are missing.
+func (sh *SyncHandler) loadRecords(changes []store.SyncChange, system string) (map[string]map[int64]any, error) {
+	ids := map[string][]int64{}
+	for _, change := range changes {
+		if !change.Deleted {
+			ids[change.Entity] = append(ids[change.Entity], change.EntityId)
+		}
+	}
+	records := map[string]map[int64]any{store.SyncWorkout: {}, store.SyncTemplate: {}, store.SyncMeasurement: {}}
+	if len(ids[store.SyncWorkout]) > 0 {
+		workouts, err := sh.workoutStore.GetWorkoutsByIds(ids[store.SyncWorkout])
+		if err != nil {
+			return nil, err
+		}
+		for id, workout := range workouts {
+			displayWorkout(workout, system)
+			records[store.SyncWorkout][id] = workout
+		}
+	}
+	if len(ids[store.SyncTemplate]) > 0 {
+		templates, err := sh.templateStore.GetTemplatesByIds(ids[store.SyncTemplate])
+		if err != nil {
+			return nil, err
+		}
+		for id, template := range templates {
+			displayTemplate(template, system)
+			records[store.SyncTemplate][id] = template
+		}
+	}
+	if len(ids[store.SyncMeasurement]) > 0 {
+		measurements, err := sh.measurementStore.GetMeasurementsByIds(ids[store.SyncMeasurement])
+		if err != nil {
+			return nil, err
+		}
+		for id, measurement := range measurements {
+			records[store.SyncMeasurement][id] = displayMeasurement(measurement, system)
+		}
+	}
+	return records, nil
+}
+
+// HandleGetChanges returns the user's records that changed after the cursor
+// ?since=, oldest change first, each once in its latest state; deleted
+// records come as tombstones. Clients start from 0 and pass the returned
+// cursor on the next call, repeating while has_more is set.
+func (sh *SyncHandler) HandleGetChanges(w http.ResponseWriter, r *http.Request) {
+	since, err := utils.ReadIntQuery(r, "since", 0)
+	if err != nil || since < 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "since must be a cursor returned by an earlier sync"})
+		return
+	}
+	limit, err := utils.ReadIntQuery(r, "limit", defaultSyncPageSize)
+	if err != nil || limit < 1 || limit > maxSyncPageSize {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("limit must be between 1 and %d", maxSyncPageSize)})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	changes, err := sh.syncStore.ListChanges(middleware.GetUser(r).Id, int64(since), limit+1)
+	if err != nil {
+		sh.logger.Printf("ERROR: ListChanges: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	hasMore := len(changes) > limit
+	if hasMore {
+		changes = changes[:limit]
+	}
+	loaded, err := sh.loadRecords(changes, system)
+	if err != nil {
+		sh.logger.Printf("ERROR: loading changed records: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	cursor := int64(since)
+	records := make([]syncRecord, 0, len(changes))
+	for _, change := range changes {
+		record := syncRecord{SyncChange: change}
+		if !change.Deleted {
+			record.Data = loaded[change.Entity][change.EntityId]
+			// Deleted since the feed was read; its tombstone comes later.
+			record.Deleted = record.Data == nil
+		}
+		records = append(records, record)
+		cursor = change.Seq
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"changes": records, "cursor": cursor, "has_more": hasMore})
+}
+
+// writeWorkout returns the id of the workout and, when the write changed it,
+// a func that publishes the change once it has committed.
+func (sh *SyncHandler) writeWorkout(tx store.SyncTx, user *store.User, change syncChange) (int64, func(), error) {
+	if change.Deleted {
+		if err := tx.DeleteWorkout(change.Id); err != nil {
+			return 0, nil, err
+		}
+		return change.Id, func() {
+			publishEvent(sh.events, sh.logger, user.Id, realtime.EventWorkoutDeleted, utils.Envelope{"id": change.Id})
+		}, nil
+	}
+	if change.Id == 0 {
+		var request workoutCreateRequest
+		if err := json.Unmarshal(change.Data, &request); err != nil {
+			return 0, nil, invalidChange(errors.New("data must be a workout object"))
+		}
+		workout, err := request.workout(user)
+		if err != nil {
+			return 0, nil, invalidChange(err)
+		}
+		if request.CaloriesBurned == nil {
+			if err := estimateCalories(sh.exerciseStore, sh.measurementStore, workout, user); err != nil {
+				return 0, nil, err
+			}
+		}
+		if err := tx.CreateWorkout(workout); err != nil {
+			return 0, nil, err
+		}
+		return int64(workout.Id), func() {
+			publishWorkout(sh.events, sh.logger, user, realtime.EventWorkoutCreated, workout)
+		}, nil
+	}
+	workout, err := tx.GetWorkout(change.Id)
+	if err != nil {
+		return 0, nil, err
+	}
+	if workout == nil {
+		return 0, nil, sql.ErrNoRows
+	}
+	var request workoutUpdateRequest
+	if err := json.Unmarshal(change.Data, &request); err != nil {
+		return 0, nil, invalidChange(errors.New("data must be a workout object"))
+	}
+	if err := request.apply(workout, inputSystem(user)); err != nil {
+		return 0, nil, invalidChange(err)
+	}
+	if request.estimatesCalories(workout) {
+		if err := estimateCalories(sh.exerciseStore, sh.measurementStore, workout, user); err != nil {
+			return 0, nil, err
+		}
+	}
+	if err := tx.UpdateWorkout(workout); err != nil {
+		return 0, nil, err
+	}
+	return change.Id, func() {
+		publishWorkout(sh.events, sh.logger, user, realtime.EventWorkoutUpdated, workout)
+	}, nil
+}
+
+func (sh *SyncHandler) writeTemplate(tx store.SyncTx, user *store.User, change syncChange) (int64, error) {
+	if change.Deleted {
+		return change.Id, tx.DeleteTemplate(change.Id)
+	}
+	var req templateRequest
+	if change.Id != 0 {
+		current, err := tx.GetTemplate(change.Id)
+		if err != nil {
+			return 0, err
+		}
+		if current == nil {
+			return 0, sql.ErrNoRows
+		}
+		req = templateRequest{Title: current.Title, Description: current.Description, Entries: current.Entries}
+	}
+	var sent templateChange
+	if err := json.Unmarshal(change.Data, &sent); err != nil {
+		return 0, invalidChange(errors.New("data must be a template object"))
+	}
+	if sent.Title != nil {
+		req.Title = *sent.Title
+	}
+	if sent.Description != nil {
+		req.Description = *sent.Description
+	}
+	if sent.Entries != nil {
+		req.Entries = sent.Entries
+	}
+	if err := validateTemplate(&req); err != nil {
+		return 0, invalidChange(err)
+	}
+	// Stored entries are already in kilograms.
+	if err := normalizeTemplateEntries(sent.Entries, inputSystem(user)); err != nil {
+		return 0, invalidChange(err)
+	}
+	template := &store.WorkoutTemplate{
+		Id:          int(change.Id),
+		UserId:      user.Id,
+		Title:       req.Title,
+		Description: req.Description,
+		Entries:     req.Entries,
+	}
+	if change.Id == 0 {
+		if err := tx.CreateTemplate(template); err != nil {
+			return 0, err
+		}
+		return int64(template.Id), nil
+	}
+	return change.Id, tx.UpdateTemplate(template)
+}
+
+func (sh *SyncHandler) writeMeasurement(tx store.SyncTx, user *store.User, change syncChange) (int64, error) {
+	if change.Deleted {
+		return change.Id, tx.DeleteMeasurement(change.Id)
+	}
+	measurement := &store.BodyMeasurement{UserId: user.Id, MeasuredAt: time.Now()}
+	defaultSystem := inputSystem(user)
+	if change.Id != 0 {
+		current, err := tx.GetMeasurement(change.Id)
+		if err != nil {
+			return 0, err
+		}
+		if current == nil {
+			return 0, sql.ErrNoRows
+		}
+		measurement, defaultSystem = current, current.Unit
+	}
+	var request measurementRequest
+	if err := json.Unmarshal(change.Data, &request); err != nil {
+		return 0, invalidChange(errors.New("data must be a measurement object"))
+	}
+	if err := request.apply(measurement, defaultSystem); err != nil {
+		return 0, invalidChange(err)
+	}
+	if !hasAnyMeasurement(measurement) {
+		return 0, invalidChange(errors.New("at least one measurement is required"))
+	}
+	if change.Id == 0 {
+		if err := tx.CreateMeasurement(measurement); err != nil {
+			return 0, err
+		}
+		return int64(measurement.Id), nil
+	}
+	return change.Id, tx.UpdateMeasurement(measurement)
+}
+
+// describe fills in the result with the record as the server now has it.
+func (sh *SyncHandler) describe(result *syncResult, system string) error {
+	current, err := sh.syncStore.GetChange(result.Entity, result.Id)
+	if err != nil || current == nil {
+		return err
+	}
+	result.Seq, result.Deleted = current.Seq, current.Deleted
+	if current.Deleted {
+		return nil
+	}
+	records, err := sh.loadRecords([]store.SyncChange{*current}, system)
+	if err != nil {
+		return err
+	}
+	result.Data = records[result.Entity][result.Id]
+	result.Deleted = result.Data == nil
+	return nil
+}
+
+// resolveChange decides what becomes of a change to a record whose latest
+// change on the server is current: the status to report, whether the change
+// is merged over server changes the client has not seen, and whether to
+// write it at all.
+func resolveChange(policy string, current *store.SyncChange, change syncChange) (status string, merged, write bool) {
+	stale := current.Seq > change.BaseSeq
+	switch {
+	case current.Deleted && change.Deleted:
+		return syncApplied, false, false
+	case current.Deleted:
+		return syncConflict, false, false
+	case stale && policy == syncServerWins:
+		return syncConflict, false, false
+	}
+	return syncApplied, stale, true
+}
+
+// errChangeNotFound stops a sync transaction for a record the user cannot
+// see.
+var errChangeNotFound = errors.New("record not found")
+
+func (sh *SyncHandler) pushChange(user *store.User, policy string, change syncChange, system string) syncResult {
+	result := syncResult{ClientId: change.ClientId, Entity: change.Entity, Id: change.Id}
+	reject := func(message string) syncResult {
+		result.Status, result.Error = syncRejected, message
+		return result
+	}
+	report := func(status string) syncResult {
+		result.Status = status
+		if err := sh.describe(&result, system); err != nil {
+			sh.logger.Printf("ERROR: describing %s %d: %v", result.Entity, result.Id, err)
+			return reject("internal server error")
+		}
+		return result
+	}
+	switch {
+	case !syncEntity(change.Entity):
+		return reject("entity must be workout, template or measurement")
+	case change.Id < 0:
+		return reject("id must be positive")
+	case change.Id == 0 && change.Deleted:
+		return reject("a delete needs the id of the record")
+	case !change.Deleted && len(change.Data) == 0:
+		return reject("data is required")
+	}
+
+	// The check against base_seq and the write share a transaction that
+	// holds the user's feed lock, so no other write can land between them.
+	status := syncApplied
+	var id int64
+	var publish func()
+	err := sh.syncStore.RunSync(user.Id, func(tx store.SyncTx) error {
+		if change.Id != 0 {
+			current, err := tx.GetChange(change.Entity, change.Id)
+			if err != nil {
+				return err
+			}
+			if current == nil || current.UserId != user.Id {
+				return errChangeNotFound
+			}
+			var write bool
+			status, result.Merged, write = resolveChange(policy, current, change)
+			if !write {
+				return nil
+			}
+		}
+		var err error
+		switch change.Entity {
+		case store.SyncWorkout:
+			id, publish, err = sh.writeWorkout(tx, user, change)
+		case store.SyncTemplate:
+			id, err = sh.writeTemplate(tx, user, change)
+		case store.SyncMeasurement:
+			id, err = sh.writeMeasurement(tx, user, change)
+		}
+		return err
+	})
+	switch {
+	case errors.Is(err, errChangeNotFound):
+		return reject(change.Entity + " not found")
+	case errors.Is(err, errInvalidChange), errors.Is(err, store.ErrInvalidEntry), errors.Is(err, store.ErrTemplateInUse):
+		return reject(err.Error())
+	case errors.Is(err, store.ErrUnknownTag):
+		return reject("tag_ids contains unknown tags")
+	case errors.Is(err, store.ErrVersionConflict), errors.Is(err, sql.ErrNoRows):
+		// The record is gone although its change is not a tombstone.
+		result.Merged = false
+		return report(syncConflict)
+	case err != nil:
+		sh.logger.Printf("ERROR: syncing %s %d: %v", change.Entity, change.Id, err)
+		return reject("internal server error")
+	}
+	if id != 0 {
+		result.Id = id
+	}
+	if publish != nil {
+		publish()
+	}
+	return report(status)
+}
+
+// HandlePushChanges applies changes a client made offline, in the order
+// sent, and reports on each with the record as the server now has it. A
+// change conflicts when the record changed on the server after base_seq; the
+// policy decides what happens then:
+//
+//   - "server_wins", the default, leaves the record alone and reports the
+//     change as a conflict with the server's copy, for the client to resolve
+//     and push again with that copy's seq.
+//   - "last_writer_wins" applies the change anyway, field by field: the
+//     fields the client sent win and the others keep their server values;
+//     a record's entries count as one field. Such results are marked merged.
+//
+// Whatever the policy, an edit to a record deleted on the server is a
+// conflict, and deleting a record that is already gone succeeds.
+func (sh *SyncHandler) HandlePushChanges(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Policy  string       `json:"policy"`
+		Changes []syncChange `json:"changes"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxSyncBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if req.Policy == "" {
+		req.Policy = syncServerWins
+	}
+	if req.Policy != syncServerWins && req.Policy != syncLastWriterWins {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "policy must be server_wins or last_writer_wins"})
+		return
+	}
+	if len(req.Changes) > maxSyncChanges {
+		_ = utils.WriteJson(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": fmt.Sprintf("a sync may push at most %d changes", maxSyncChanges)})
+		return
+	}
+	clientIds := map[string]bool{}
+	for _, change := range req.Changes {
+		if change.ClientId == "" || clientIds[change.ClientId] {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "every change needs a unique client_id"})
+			return
+		}
+		clientIds[change.ClientId] = true
+	}
+
+	currentUser := middleware.GetUser(r)
+	results := make([]syncResult, 0, len(req.Changes))
+	for _, change := range req.Changes {
+		results = append(results, sh.pushChange(currentUser, req.Policy, change, system))
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"policy": req.Policy, "results": results})
+}
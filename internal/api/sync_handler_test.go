@@ -0,0 +1,214 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSyncStore keeps the feed and the measurements it covers in memory;
+// every write takes the next seq, as recordChange does.
+type fakeSyncStore struct {
+	store.SyncStore
+	store.MeasurementStore
+	seq          int64
+	changes      map[int64]*store.SyncChange
+	measurements map[int64]*store.BodyMeasurement
+}
+
+func newFakeSyncStore(measurements ...*store.BodyMeasurement) *fakeSyncStore {
+	fs := &fakeSyncStore{changes: map[int64]*store.SyncChange{}, measurements: map[int64]*store.BodyMeasurement{}}
+	for _, measurement := range measurements {
+		fs.measurements[int64(measurement.Id)] = measurement
+		fs.record(measurement.UserId, int64(measurement.Id), false)
+	}
+	return fs
+}
+
+func (fs *fakeSyncStore) record(userID int, id int64, deleted bool) {
+	fs.seq++
+	fs.changes[id] = &store.SyncChange{Entity: store.SyncMeasurement, EntityId: id, UserId: userID, Seq: fs.seq, Deleted: deleted}
+}
+
+func (fs *fakeSyncStore) GetChange(entity string, id int64) (*store.SyncChange, error) {
+	change, ok := fs.changes[id]
+	if !ok || entity != store.SyncMeasurement {
+		return nil, nil
+	}
+	copied := *change
+	return &copied, nil
+}
+
+func (fs *fakeSyncStore) RunSync(userID int, fn func(tx store.SyncTx) error) error {
+	return fn(&fakeSyncTx{fs: fs})
+}
+
+func (fs *fakeSyncStore) GetMeasurementsByIds(ids []int64) (map[int64]*store.BodyMeasurement, error) {
+	found := map[int64]*store.BodyMeasurement{}
+	for _, id := range ids {
+		if measurement, ok := fs.measurements[id]; ok {
+			copied := *measurement
+			found[id] = &copied
+		}
+	}
+	return found, nil
+}
+
+type fakeSyncTx struct {
+	store.SyncTx
+	fs *fakeSyncStore
+}
+
+func (tx *fakeSyncTx) GetChange(entity string, id int64) (*store.SyncChange, error) {
+	return tx.fs.GetChange(entity, id)
+}
+
+func (tx *fakeSyncTx) GetMeasurement(id int64) (*store.BodyMeasurement, error) {
+	measurement, ok := tx.fs.measurements[id]
+	if !ok {
+		return nil, nil
+	}
+	copied := *measurement
+	return &copied, nil
+}
+
+func (tx *fakeSyncTx) UpdateMeasurement(measurement *store.BodyMeasurement) error {
+	copied := *measurement
+	tx.fs.measurements[int64(measurement.Id)] = &copied
+	tx.fs.record(measurement.UserId, int64(measurement.Id), false)
+	return nil
+}
+
+func (tx *fakeSyncTx) DeleteMeasurement(id int64) error {
+	measurement := tx.fs.measurements[id]
+	delete(tx.fs.measurements, id)
+	tx.fs.record(measurement.UserId, id, true)
+	return nil
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func TestResolveChange(t *testing.T) {
+	live := &store.SyncChange{Seq: 10}
+	gone := &store.SyncChange{Seq: 10, Deleted: true}
+	tests := []struct {
+		name        string
+		policy      string
+		current     *store.SyncChange
+		change      syncChange
+		wantStatus  string
+		wantMerged  bool
+		wantWritten bool
+	}{
+		{"server wins, up to date", syncServerWins, live, syncChange{BaseSeq: 10}, syncApplied, false, true},
+		{"server wins, stale", syncServerWins, live, syncChange{BaseSeq: 9}, syncConflict, false, false},
+		{"server wins, stale delete", syncServerWins, live, syncChange{BaseSeq: 9, Deleted: true}, syncConflict, false, false},
+		{"last writer wins, up to date", syncLastWriterWins, live, syncChange{BaseSeq: 10}, syncApplied, false, true},
+		{"last writer wins, stale", syncLastWriterWins, live, syncChange{BaseSeq: 9}, syncApplied, true, true},
+		{"edit of a deleted record", syncLastWriterWins, gone, syncChange{BaseSeq: 10}, syncConflict, false, false},
+		{"delete of a deleted record", syncServerWins, gone, syncChange{BaseSeq: 3, Deleted: true}, syncApplied, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, merged, written := resolveChange(tt.policy, tt.current, tt.change)
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantMerged, merged)
+			assert.Equal(t, tt.wantWritten, written)
+		})
+	}
+}
+
+func TestHandlePushChanges(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		change     string
+		wantStatus string
+		wantMerged bool
+		wantWeight float64
+		wantSeq    int64
+	}{
+		{"server wins, up to date", syncServerWins, `{"client_id":"a","entity":"measurement","id":5,"base_seq":1,"data":{"weight":81}}`, syncApplied, false, 81, 2},
+		{"server wins, stale", syncServerWins, `{"client_id":"a","entity":"measurement","id":5,"base_seq":0,"data":{"weight":81}}`, syncConflict, false, 80, 1},
+		{"last writer wins, up to date", syncLastWriterWins, `{"client_id":"a","entity":"measurement","id":5,"base_seq":1,"data":{"weight":81}}`, syncApplied, false, 81, 2},
+		{"last writer wins, stale", syncLastWriterWins, `{"client_id":"a","entity":"measurement","id":5,"base_seq":0,"data":{"weight":81}}`, syncApplied, true, 81, 2},
+		{"another user's record", syncLastWriterWins, `{"client_id":"a","entity":"measurement","id":6,"base_seq":1,"data":{"weight":81}}`, syncRejected, false, 80, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := newFakeSyncStore(
+				&store.BodyMeasurement{Id: 5, UserId: 1, Unit: units.Metric, WeightKg: floatPtr(80)},
+				&store.BodyMeasurement{Id: 6, UserId: 2, Unit: units.Metric, WeightKg: floatPtr(70)},
+			)
+			feed.changes[5].Seq, feed.seq = 1, 1
+			handler := NewSyncHandler(feed, nil, nil, feed, nil, &fakePublisher{}, log.New(io.Discard, "", 0))
+			body := `{"policy":"` + tt.policy + `","changes":[` + tt.change + `]}`
+			r := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body))
+			r = middleware.SetUser(r, &store.User{Id: 1})
+			w := httptest.NewRecorder()
+
+			handler.HandlePushChanges(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var response struct {
+				Results []syncResult `json:"results"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Results, 1)
+			result := response.Results[0]
+			assert.Equal(t, tt.wantStatus, result.Status, result.Error)
+			assert.Equal(t, tt.wantMerged, result.Merged)
+			assert.Equal(t, tt.wantSeq, result.Seq)
+			assert.Equal(t, tt.wantWeight, *feed.measurements[5].WeightKg)
+		})
+	}
+}
+
+func TestHandlePushChangesDeletes(t *testing.T) {
+	tests := []struct {
+		name       string
+		policy     string
+		baseSeq    string
+		wantStatus string
+		wantGone   bool
+	}{
+		{"server wins, up to date", syncServerWins, "1", syncApplied, true},
+		{"server wins, stale", syncServerWins, "0", syncConflict, false},
+		{"last writer wins, stale", syncLastWriterWins, "0", syncApplied, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := newFakeSyncStore(&store.BodyMeasurement{Id: 5, UserId: 1, Unit: units.Metric, WeightKg: floatPtr(80)})
+			handler := NewSyncHandler(feed, nil, nil, feed, nil, &fakePublisher{}, log.New(io.Discard, "", 0))
+			body := `{"policy":"` + tt.policy + `","changes":[{"client_id":"a","entity":"measurement","id":5,"base_seq":` + tt.baseSeq + `,"deleted":true}]}`
+			r := httptest.NewRequest(http.MethodPost, "/sync", strings.NewReader(body))
+			r = middleware.SetUser(r, &store.User{Id: 1})
+			w := httptest.NewRecorder()
+
+			handler.HandlePushChanges(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			var response struct {
+				Results []syncResult `json:"results"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			require.Len(t, response.Results, 1)
+			assert.Equal(t, tt.wantStatus, response.Results[0].Status)
+			assert.Equal(t, tt.wantGone, response.Results[0].Deleted)
+			_, kept := feed.measurements[5]
+			assert.Equal(t, !tt.wantGone, kept)
+		})
+	}
+}
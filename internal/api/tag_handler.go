@@ -0,0 +1,193 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+const defaultTagColor = "#808080"
+
+var tagColorRegex = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+type tagRequest struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+type TagHandler struct {
+	tagStore store.TagStore
+	logger   *log.Logger
+}
+
+func NewTagHandler(tagStore store.TagStore, logger *log.Logger) *TagHandler {
+	return &TagHandler{
+		tagStore: tagStore,
+		logger:   logger,
+	}
+}
+
+func validateTag(req *tagRequest) error {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return errors.New("name is required")
+	}
+	if len(req.Name) > 50 {
+		return errors.New("name is too long")
+	}
+	if req.Color == "" {
+		req.Color = defaultTagColor
+	}
+	if !tagColorRegex.MatchString(req.Color) {
+		return errors.New("color must be a hex color like #1e90ff")
+	}
+	return nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
+
+// authorizeTag writes the error response itself and returns false when the
+// current user does not own the tag.
+func (th *TagHandler) authorizeTag(w http.ResponseWriter, r *http.Request, id int64) bool {
+	owner, err := th.tagStore.GetTagOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "tag not found"})
+			return false
+		}
+		th.logger.Printf("ERROR: GetTagOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if owner != middleware.GetUser(r).Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this tag"})
+		return false
+	}
+	return true
+}
+
+func (th *TagHandler) HandleCreateTag(w http.ResponseWriter, r *http.Request) {
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err := validateTag(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	tag := &store.Tag{
+		UserId: middleware.GetUser(r).Id,
+		Name:   req.Name,
+		Color:  req.Color,
+	}
+	created, err := th.tagStore.CreateTag(tag)
+	if err != nil {
+		if isUniqueViolation(err) {
+			_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "a tag with this name already exists"})
+			return
+		}
+		th.logger.Printf("ERROR: CreateTag: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create tag"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"tag": created})
+}
+
+func (th *TagHandler) HandleListTags(w http.ResponseWriter, r *http.Request) {
+	tags, err := th.tagStore.ListTags(middleware.GetUser(r).Id)
+	if err != nil {
+		th.logger.Printf("ERROR: ListTags: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"tags": tags})
+}
+
+func (th *TagHandler) HandleGetTagById(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tag id"})
+		return
+	}
+	if !th.authorizeTag(w, r, id) {
+		return
+	}
+	tag, err := th.tagStore.GetTagById(id)
+	if err != nil {
+		th.logger.Printf("ERROR: GetTagById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if tag == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "tag not found"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"tag": tag})
+}
+
+func (th *TagHandler) HandleUpdateTag(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tag id"})
+		return
+	}
+	if !th.authorizeTag(w, r, id) {
+		return
+	}
+	var req tagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err := validateTag(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	tag, err := th.tagStore.GetTagById(id)
+	if err != nil || tag == nil {
+		th.logger.Printf("ERROR: GetTagById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	tag.Name, tag.Color = req.Name, req.Color
+	if err := th.tagStore.UpdateTag(tag); err != nil {
+		if isUniqueViolation(err) {
+			_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "a tag with this name already exists"})
+			return
+		}
+		th.logger.Printf("ERROR: UpdateTag: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update tag"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"tag": tag})
+}
+
+func (th *TagHandler) HandleDeleteTag(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid tag id"})
+		return
+	}
+	if !th.authorizeTag(w, r, id) {
+		return
+	}
+	if err := th.tagStore.DeleteTag(id); err != nil {
+		th.logger.Printf("ERROR: DeleteTag: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete tag"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
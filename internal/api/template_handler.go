@@ -0,0 +1,372 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+type templateRequest struct {
+	Title       string                `json:"title"`
+	Description string                `json:"description"`
+	Entries     []store.TemplateEntry `json:"entries"`
+}
+
+type TemplateHandler struct {
+	templateStore store.TemplateStore
+	workoutStore  store.WorkoutStore
+	events        EventPublisher
+	logger        *log.Logger
+}
+
+func NewTemplateHandler(templateStore store.TemplateStore, workoutStore store.WorkoutStore, events EventPublisher, logger *log.Logger) *TemplateHandler {
+	return &TemplateHandler{
+		templateStore: templateStore,
+		workoutStore:  workoutStore,
+		events:        events,
+		logger:        logger,
+	}
+}
+
+func validateTemplate(req *templateRequest) error {
+	if req.Title == "" {
+		return errors.New("title cannot be empty")
+	}
+	if err := validateTitle(req.Title); err != nil {
+		return err
+	}
+	for _, entry := range req.Entries {
+		if entry.ExerciseName == "" {
+			return errors.New("exercise_name cannot be empty")
+		}
+		if entry.TargetSets <= 0 {
+			return errors.New("target_sets must be positive")
+		}
+		if (entry.RepRangeMax == nil) == (entry.TargetDurationSeconds == nil) {
+			return errors.New("each entry needs either rep_range_max or target_duration_seconds")
+		}
+		if entry.RepRangeMin != nil && entry.RepRangeMax != nil && *entry.RepRangeMin > *entry.RepRangeMax {
+			return errors.New("rep_range_min cannot exceed rep_range_max")
+		}
+		if entry.TargetWeight != nil && entry.TargetPercent1RM != nil {
+			return errors.New("use either target_weight or target_percent_1rm, not both")
+		}
+		if entry.TargetPercent1RM != nil && (*entry.TargetPercent1RM <= 0 || *entry.TargetPercent1RM > 150) {
+			return errors.New("target_percent_1rm must be between 0 and 150")
+		}
+	}
+	return nil
+}
+
+// authorizeTemplate writes the error response itself and returns false when
+// the current user may not touch the template.
+func (th *TemplateHandler) authorizeTemplate(w http.ResponseWriter, r *http.Request, id int64) bool {
+	currentUser := middleware.GetUser(r)
+	owner, err := th.templateStore.GetTemplateOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+			return false
+		}
+		th.logger.Printf("ERROR: GetTemplateOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if owner != currentUser.Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this template"})
+		return false
+	}
+	return true
+}
+
+func (th *TemplateHandler) HandleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		th.logger.Printf("ERROR: decoding template request: %v", err)
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err := validateTemplate(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	if err := normalizeTemplateEntries(req.Entries, inputSystem(middleware.GetUser(r))); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	template := &store.WorkoutTemplate{
+		UserId:      middleware.GetUser(r).Id,
+		Title:       req.Title,
+		Description: req.Description,
+		Entries:     req.Entries,
+	}
+	created, err := th.templateStore.CreateTemplate(template)
+	if err != nil {
+		th.logger.Printf("ERROR: CreateTemplate: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create template"})
+		return
+	}
+	displayTemplate(created, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"template": created})
+}
+
+func (th *TemplateHandler) HandleListTemplates(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	templates, err := th.templateStore.ListTemplates(middleware.GetUser(r).Id)
+	if err != nil {
+		th.logger.Printf("ERROR: ListTemplates: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	for i := range templates {
+		displayTemplate(&templates[i], system)
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"templates": templates})
+}
+
+func (th *TemplateHandler) HandleGetTemplateById(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+	if !th.authorizeTemplate(w, r, id) {
+		return
+	}
+	template, err := th.templateStore.GetTemplateById(id)
+	if err != nil {
+		th.logger.Printf("ERROR: GetTemplateById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if template == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	displayTemplate(template, system)
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleUpdateTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+	if !th.authorizeTemplate(w, r, id) {
+		return
+	}
+	var req templateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		th.logger.Printf("ERROR: decoding template request: %v", err)
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err := validateTemplate(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	if err := normalizeTemplateEntries(req.Entries, inputSystem(middleware.GetUser(r))); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	template := &store.WorkoutTemplate{
+		Id:          int(id),
+		UserId:      middleware.GetUser(r).Id,
+		Title:       req.Title,
+		Description: req.Description,
+		Entries:     req.Entries,
+	}
+	if err := th.templateStore.UpdateTemplate(template); err != nil {
+		th.logger.Printf("ERROR: UpdateTemplate: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update template"})
+		return
+	}
+	displayTemplate(template, system)
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"template": template})
+}
+
+func (th *TemplateHandler) HandleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+	if !th.authorizeTemplate(w, r, id) {
+		return
+	}
+	err = th.templateStore.DeleteTemplate(id)
+	if errors.Is(err, store.ErrTemplateInUse) {
+		_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "template is used by a program and cannot be deleted"})
+		return
+	}
+	if err != nil {
+		th.logger.Printf("ERROR: DeleteTemplate: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to delete template"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleStartTemplate instantiates a new workout from the template so the user
+// only has to adjust what differs in today's session.
+func (th *TemplateHandler) HandleStartTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid template id"})
+		return
+	}
+	if !th.authorizeTemplate(w, r, id) {
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	template, err := th.templateStore.GetTemplateById(id)
+	if err != nil {
+		th.logger.Printf("ERROR: GetTemplateById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if template == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "template not found"})
+		return
+	}
+	weightUnit := units.WeightUnit(system)
+	if system == units.Original {
+		weightUnit = units.WeightUnit(inputSystem(middleware.GetUser(r)))
+	}
+	// Recommendations follow each entry's rep range; the query may pick the
+	// strategy and override its other settings.
+	recommendations := []recommendationView{}
+	recommended := map[string]bool{}
+	for _, entry := range template.Entries {
+		if entry.TargetDurationSeconds != nil || recommended[strings.ToLower(entry.ExerciseName)] {
+			continue
+		}
+		recommended[strings.ToLower(entry.ExerciseName)] = true
+		repMin, repMax := 0, 0
+		if entry.RepRangeMin != nil {
+			repMin = *entry.RepRangeMin
+		}
+		if entry.RepRangeMax != nil {
+			repMax = *entry.RepRangeMax
+		}
+		config, err := readProgressionConfig(r, weightUnit, repMin, repMax)
+		if err != nil {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		recommendation, err := recommend(th.workoutStore, template.UserId, entry.ExerciseName, weightUnit, config)
+		if err != nil {
+			th.logger.Printf("ERROR: ListRecentPerformances: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if recommendation != nil {
+			recommendations = append(recommendations, *recommendation)
+		}
+	}
+	oneRepMax := map[string]float64{}
+	for _, entry := range template.Entries {
+		if entry.TargetPercent1RM == nil {
+			continue
+		}
+		estimate, err := th.workoutStore.GetEstimatedOneRepMax(template.UserId, entry.ExerciseName)
+		if err != nil {
+			th.logger.Printf("ERROR: GetEstimatedOneRepMax: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		if estimate != nil {
+			oneRepMax[strings.ToLower(entry.ExerciseName)] = *estimate
+		}
+	}
+	workout, err := th.workoutStore.CreateWorkout(template.NewWorkout(oneRepMax))
+	if err != nil {
+		th.logger.Printf("ERROR: CreateWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to start workout"})
+		return
+	}
+	publishWorkout(th.events, th.logger, middleware.GetUser(r), realtime.EventWorkoutCreated, workout)
+	displayWorkout(workout, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": workout, "recommendations": recommendations})
+}
+
+func (th *TemplateHandler) HandleSaveWorkoutAsTemplate(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	owner, err := th.workoutStore.GetWorkoutOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+			return
+		}
+		th.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if owner != currentUser.Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this workout"})
+		return
+	}
+	workout, err := th.workoutStore.GetWorkOutById(id)
+	if err != nil || workout == nil {
+		th.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	template := store.TemplateFromWorkout(workout)
+	var req struct {
+		Title string `json:"title"`
+	}
+	// The body is optional; it only allows renaming the template.
+	if err := json.NewDecoder(r.Body).Decode(&req); err == nil && req.Title != "" {
+		if err := validateTitle(req.Title); err != nil {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		template.Title = req.Title
+	}
+	created, err := th.templateStore.CreateTemplate(template)
+	if err != nil {
+		th.logger.Printf("ERROR: CreateTemplate: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create template"})
+		return
+	}
+	displayTemplate(created, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"template": created})
+}
@@ -0,0 +1,30 @@
+package api
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTemplateTitle(t *testing.T) {
+	tests := []struct {
+		name  string
+		title string
+		valid bool
+	}{
+		{"empty", "", false},
+		{"at the limit in multi-byte characters", strings.Repeat("é", maxTitleLength), true},
+		{"over the limit", strings.Repeat("a", maxTitleLength+1), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTemplate(&templateRequest{Title: tt.title})
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.Error(t, err)
+		})
+	}
+}
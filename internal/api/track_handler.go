@@ -0,0 +1,201 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Numeez/go-zenith/internal/heartrate"
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/tracks"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	maxImportBytes = 20 << 20
+	// Points closer than this to the simplified line are dropped before the
+	// track is stored.
+	trackToleranceMeters = 3.0
+)
+
+func exerciseNameForActivity(activity string) string {
+	switch strings.ToLower(activity) {
+	case "running", "run", "trail_running":
+		return "Running"
+	case "biking", "cycling", "ride", "road_biking", "mountain_biking":
+		return "Cycling"
+	case "walking", "walk", "hiking", "hike":
+		return "Walking"
+	case "swimming", "swim":
+		return "Swimming"
+	case "":
+		return "Cardio"
+	}
+	first, size := utf8.DecodeRuneInString(activity)
+	return strings.ToUpper(string(first)) + strings.ToLower(activity[size:])
+}
+
+// truncateTitle cuts title to maxTitleLength characters, never splitting one.
+func truncateTitle(title string) string {
+	if runes := []rune(title); len(runes) > maxTitleLength {
+		return string(runes[:maxTitleLength])
+	}
+	return title
+}
+
+func readImportFile(r *http.Request) ([]byte, string, error) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			return nil, "", errors.New("multipart upload must include a file field")
+		}
+		defer func() {
+			_ = file.Close()
+		}()
+		data, err := io.ReadAll(file)
+		return data, header.Filename, err
+	}
+	data, err := io.ReadAll(r.Body)
+	return data, "", err
+}
+
+// HandleImportWorkout turns an uploaded GPX or TCX file into a workout with a
+// single distance-based entry and keeps a simplified copy of the track.
+func (wh *WorkOutHandler) HandleImportWorkout(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportBytes)
+	data, filename, err := readImportFile(r)
+	if err != nil {
+		wh.logger.Printf("ERROR: reading import: %v", err)
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "unable to read uploaded file"})
+		return
+	}
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format == "" {
+		head := data
+		if len(head) > 1024 {
+			head = head[:1024]
+		}
+		if format, err = tracks.DetectFormat(filename, head); err != nil {
+			_ = utils.WriteJson(w, http.StatusUnsupportedMediaType, utils.Envelope{"error": err.Error()})
+			return
+		}
+	}
+	track, err := tracks.Parse(bytes.NewReader(data), format)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	splitUnit := tracks.MetersPerKilometer
+	if r.URL.Query().Get("split") == "mi" {
+		splitUnit = tracks.MetersPerMile
+	}
+	summary := tracks.Summarize(track.Points, splitUnit)
+
+	exercise := exerciseNameForActivity(track.Activity)
+	title := track.Name
+	if title == "" {
+		title = fmt.Sprintf("%s %s", exercise, track.Points[0].Time.Format("2006-01-02"))
+	}
+	title = truncateTitle(title)
+	movingSeconds := int(math.Round(summary.MovingSeconds))
+	distance := math.Round(summary.DistanceMeters*100) / 100
+	elevation := math.Round(summary.ElevationGainMeters*100) / 100
+	speed := math.Round(summary.AvgSpeedMps*1000) / 1000
+	// Files are recorded in meters; the distance is treated as entered in the
+	// user's preferred unit so "original" displays match what they expect.
+	workout := &store.Workout{
+		UserId:          middleware.GetUser(r).Id,
+		Title:           title,
+		DurationMinutes: int(math.Ceil(summary.ElapsedSeconds / 60)),
+		CreatedAt:       track.Points[0].Time,
+		Entries: []store.WorkoutEntry{
+			{
+				ExerciseName:        exercise,
+				Sets:                1,
+				DurationSeconds:     &movingSeconds,
+				DistanceMeters:      &distance,
+				DistanceUnit:        units.DistanceUnit(inputSystem(middleware.GetUser(r))),
+				ElevationGainMeters: &elevation,
+				AvgHeartRate:        summary.AvgHeartRate,
+				MaxHeartRate:        summary.MaxHeartRate,
+				AvgSpeedMps:         &speed,
+				OrderIndex:          1,
+			},
+		},
+	}
+	if err := wh.estimateCalories(workout, middleware.GetUser(r)); err != nil {
+		wh.logger.Printf("ERROR: estimating calories: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workout"})
+		return
+	}
+	stored := &store.WorkoutTrack{
+		SourceFormat: format,
+		Points:       tracks.Simplify(track.Points, trackToleranceMeters),
+		Summary:      summary,
+	}
+	// Heart rate recorded alongside the track is kept at full resolution for
+	// zone analysis, independent of the simplified track.
+	var samples []heartrate.Sample
+	for _, p := range track.Points {
+		if p.HeartRate != nil {
+			samples = append(samples, heartrate.Sample{Time: p.Time, BPM: *p.HeartRate})
+		}
+	}
+	created, err := wh.workoutStore.ImportWorkout(workout, stored, samples)
+	if err != nil {
+		wh.logger.Printf("ERROR: ImportWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to import workout"})
+		return
+	}
+	publishWorkout(wh.events, wh.logger, middleware.GetUser(r), realtime.EventWorkoutCreated, created)
+	displayWorkout(created, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": created, "track_summary": summary})
+}
+
+func (wh *WorkOutHandler) HandleGetWorkoutTrack(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	track, err := wh.workoutStore.GetWorkoutTrack(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetWorkoutTrack: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if track == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout has no track"})
+		return
+	}
+	feature := tracks.GeoJSON(track.Points, map[string]any{
+		"workout_id":    track.WorkoutId,
+		"source_format": track.SourceFormat,
+		"summary":       track.Summary,
+	})
+	js, err := json.Marshal(feature)
+	if err != nil {
+		wh.logger.Printf("ERROR: encoding track: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	w.Header().Set("Content-Type", "application/geo+json")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(append(js, '\n'))
+}
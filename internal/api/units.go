@@ -0,0 +1,184 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+// displaySystem picks the units for a response: the ?units= override wins
+// over the user's preference. "original" shows values as they were entered.
+func displaySystem(r *http.Request, user *store.User) (string, error) {
+	system := r.URL.Query().Get("units")
+	if system == "" {
+		system = user.UnitSystem
+	}
+	if system == "" {
+		return units.Metric, nil
+	}
+	if !units.ValidSystem(system) && system != units.Original {
+		return "", errors.New("units must be metric, imperial or original")
+	}
+	return system, nil
+}
+
+// inputSystem is the unit system assumed for values written without an
+// explicit unit.
+func inputSystem(user *store.User) string {
+	if units.ValidSystem(user.UnitSystem) {
+		return user.UnitSystem
+	}
+	return units.Metric
+}
+
+func convertWeight(weight *float64, convert func(float64) float64) *float64 {
+	if weight == nil {
+		return nil
+	}
+	converted := convert(*weight)
+	return &converted
+}
+
+// toKilograms returns a converter from a validated weight unit.
+func toKilograms(unit string) (func(float64) float64, error) {
+	if !units.ValidWeightUnit(unit) {
+		return nil, fmt.Errorf("weight_unit must be %s or %s", units.Kilogram, units.Pound)
+	}
+	return func(v float64) float64 {
+		kg, _ := units.ToKilograms(v, unit)
+		return kg
+	}, nil
+}
+
+// normalizeEntries converts weights and distances given in the entries' own
+// units (or the defaults of system) into kg and meters. New pointers are used
+// throughout because entries may share them with their set logs.
+func normalizeEntries(entries []store.WorkoutEntry, system string) error {
+	for i := range entries {
+		entry := &entries[i]
+		if entry.WeightUnit == "" {
+			entry.WeightUnit = units.WeightUnit(system)
+		}
+		toKg, err := toKilograms(entry.WeightUnit)
+		if err != nil {
+			return err
+		}
+		entry.Weight = convertWeight(entry.Weight, toKg)
+		for j := range entry.SetLog {
+			entry.SetLog[j].Weight = convertWeight(entry.SetLog[j].Weight, toKg)
+		}
+		switch {
+		case entry.Distance != nil:
+			if entry.DistanceUnit == "" {
+				entry.DistanceUnit = units.DistanceUnit(system)
+			}
+			meters, err := units.ToMeters(*entry.Distance, entry.DistanceUnit)
+			if err != nil {
+				return err
+			}
+			entry.DistanceMeters = &meters
+			entry.Distance = nil
+		case entry.DistanceMeters != nil:
+			entry.DistanceUnit = units.Meter
+		default:
+			entry.DistanceUnit = ""
+		}
+	}
+	return nil
+}
+
+// displayEntries converts canonical entries into the units of system and
+// labels them. For units.Original each entry keeps the units it was entered
+// in.
+func displayEntries(entries []store.WorkoutEntry, system string) {
+	for i := range entries {
+		entry := &entries[i]
+		weightUnit := units.WeightUnit(system)
+		if system == units.Original && entry.WeightUnit != "" {
+			weightUnit = entry.WeightUnit
+		}
+		fromKg := func(kg float64) float64 { return units.FromKilograms(kg, weightUnit) }
+		entry.Weight = convertWeight(entry.Weight, fromKg)
+		for j := range entry.SetLog {
+			entry.SetLog[j].Weight = convertWeight(entry.SetLog[j].Weight, fromKg)
+		}
+		entry.WeightUnit = weightUnit
+		if entry.DistanceMeters == nil {
+			entry.Distance, entry.DistanceUnit = nil, ""
+			continue
+		}
+		distanceUnit := units.DistanceUnit(system)
+		if system == units.Original && entry.DistanceUnit != "" {
+			distanceUnit = entry.DistanceUnit
+		}
+		distance := units.FromMeters(*entry.DistanceMeters, distanceUnit)
+		entry.Distance, entry.DistanceUnit = &distance, distanceUnit
+	}
+}
+
+func displayWorkout(workout *store.Workout, system string) {
+	if workout == nil {
+		return
+	}
+	displayEntries(workout.Entries, system)
+	if workout.Groups != nil {
+		workout.Groups = store.BuildEntryGroups(workout.Entries)
+	}
+}
+
+func normalizeTemplateEntries(entries []store.TemplateEntry, system string) error {
+	for i := range entries {
+		entry := &entries[i]
+		if entry.WeightUnit == "" {
+			entry.WeightUnit = units.WeightUnit(system)
+		}
+		toKg, err := toKilograms(entry.WeightUnit)
+		if err != nil {
+			return err
+		}
+		entry.TargetWeight = convertWeight(entry.TargetWeight, toKg)
+	}
+	return nil
+}
+
+func displayTemplate(template *store.WorkoutTemplate, system string) {
+	if template == nil {
+		return
+	}
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		unit := units.WeightUnit(system)
+		if system == units.Original && entry.WeightUnit != "" {
+			unit = entry.WeightUnit
+		}
+		entry.TargetWeight = convertWeight(entry.TargetWeight, func(kg float64) float64 { return units.FromKilograms(kg, unit) })
+		entry.WeightUnit = unit
+	}
+}
+
+// displayProgram converts the program's weekly increment from kg into the
+// units of system.
+func displayProgram(program *store.Program, system string) {
+	unit := units.WeightUnit(system)
+	if system == units.Original && program.WeightUnit != "" {
+		unit = program.WeightUnit
+	}
+	program.WeeklyIncrement = units.FromKilograms(program.WeeklyIncrement, unit)
+	program.WeightUnit = unit
+}
+
+// readDisplaySystem writes the error response itself and returns false when
+// the ?units= override is invalid.
+func readDisplaySystem(w http.ResponseWriter, r *http.Request) (string, bool) {
+	system, err := displaySystem(r, middleware.GetUser(r))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return "", false
+	}
+	return system, true
+}
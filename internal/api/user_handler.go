@@ -6,16 +6,21 @@ import (
 	"log"
 	"net/http"
 	"regexp"
+	"time"
 
+	"github.com/Numeez/go-zenith/internal/middleware"
 	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
 	"github.com/Numeez/go-zenith/internal/utils"
 )
 
 type registerUserStruct struct {
-	Username string `json:"username"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
-	Bio      string `json:"bio"`
+	Username   string `json:"username"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Bio        string `json:"bio"`
+	Timezone   string `json:"timezone"`
+	UnitSystem string `json:"unit_system"`
 }
 
 type UserHandler struct {
@@ -48,6 +53,14 @@ func (h *UserHandler) validateRegisterUser(req *registerUserStruct) error {
 	if req.Password == "" {
 		return errors.New("password cannot be empty")
 	}
+	if req.Timezone != "" {
+		if _, err := time.LoadLocation(req.Timezone); err != nil {
+			return errors.New("invalid timezone")
+		}
+	}
+	if req.UnitSystem != "" && !units.ValidSystem(req.UnitSystem) {
+		return errors.New("unit_system must be metric or imperial")
+	}
 	return nil
 
 }
@@ -65,8 +78,10 @@ func (h *UserHandler) HandlerRegisterUser(w http.ResponseWriter, r *http.Request
 		return
 	}
 	user := &store.User{
-		Username: request.Username,
-		Email:    request.Email,
+		Username:   request.Username,
+		Email:      request.Email,
+		Timezone:   request.Timezone,
+		UnitSystem: request.UnitSystem,
 	}
 	if request.Bio != "" {
 		user.Bio = request.Bio
@@ -86,3 +101,68 @@ func (h *UserHandler) HandlerRegisterUser(w http.ResponseWriter, r *http.Request
 	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"user": createdUser})
 
 }
+
+// HandleUpdateProfile changes the optional profile fields of the current user.
+// Omitted fields are left untouched. Body weight is given either in kg or as
+// body_weight in weight_unit, which defaults to the user's unit system.
+func (h *UserHandler) HandleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Bio          *string  `json:"bio"`
+		Timezone     *string  `json:"timezone"`
+		BodyWeightKg *float64 `json:"body_weight_kg"`
+		BodyWeight   *float64 `json:"body_weight"`
+		WeightUnit   string   `json:"weight_unit"`
+		UnitSystem   *string  `json:"unit_system"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	user := *middleware.GetUser(r)
+	if request.Bio != nil {
+		user.Bio = *request.Bio
+	}
+	if request.Timezone != nil {
+		if _, err := time.LoadLocation(*request.Timezone); err != nil || *request.Timezone == "" {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid timezone"})
+			return
+		}
+		user.Timezone = *request.Timezone
+	}
+	if request.UnitSystem != nil {
+		if !units.ValidSystem(*request.UnitSystem) {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "unit_system must be metric or imperial"})
+			return
+		}
+		user.UnitSystem = *request.UnitSystem
+	}
+	bodyWeightKg := request.BodyWeightKg
+	if request.BodyWeight != nil {
+		if bodyWeightKg != nil {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "send body_weight or body_weight_kg, not both"})
+			return
+		}
+		if request.WeightUnit == "" {
+			request.WeightUnit = units.WeightUnit(inputSystem(&user))
+		}
+		toKg, err := toKilograms(request.WeightUnit)
+		if err != nil {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+			return
+		}
+		bodyWeightKg = convertWeight(request.BodyWeight, toKg)
+	}
+	if bodyWeightKg != nil {
+		if *bodyWeightKg < 20 || *bodyWeightKg > 400 {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "body weight must be between 20 and 400 kg"})
+			return
+		}
+		user.BodyWeightKg = bodyWeightKg
+	}
+	if err := h.store.UpdateUser(&user); err != nil {
+		h.logger.Printf("ERROR: UpdateUser: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"user": user})
+}
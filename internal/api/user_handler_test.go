@@ -0,0 +1,63 @@
+package api
+
+import (
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUserStore remembers the last user it was asked to save.
+type fakeUserStore struct {
+	store.UserStore
+	saved *store.User
+}
+
+func (fs *fakeUserStore) UpdateUser(user *store.User) error {
+	copied := *user
+	fs.saved = &copied
+	return nil
+}
+
+func TestHandleUpdateProfileBodyWeight(t *testing.T) {
+	tests := []struct {
+		name       string
+		system     string
+		body       string
+		wantStatus int
+		wantKg     float64
+	}{
+		{"kg field", units.Imperial, `{"body_weight_kg":80}`, http.StatusOK, 80},
+		{"explicit unit", units.Metric, `{"body_weight":176.3698097,"weight_unit":"lb"}`, http.StatusOK, 80},
+		{"user's unit system", units.Imperial, `{"body_weight":200}`, http.StatusOK, 200 * units.KilogramsPerPound},
+		{"new unit system", units.Metric, `{"unit_system":"imperial","body_weight":200}`, http.StatusOK, 200 * units.KilogramsPerPound},
+		{"unknown unit", units.Metric, `{"body_weight":80,"weight_unit":"st"}`, http.StatusBadRequest, 0},
+		{"out of range", units.Imperial, `{"body_weight":40}`, http.StatusBadRequest, 0},
+		{"both fields", units.Metric, `{"body_weight":80,"body_weight_kg":80}`, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			users := &fakeUserStore{}
+			handler := NewUserHandler(users, log.New(io.Discard, "", 0))
+			r := httptest.NewRequest(http.MethodPatch, "/users/me", strings.NewReader(tt.body))
+			r = middleware.SetUser(r, &store.User{Id: 1, UnitSystem: tt.system})
+			w := httptest.NewRecorder()
+
+			handler.HandleUpdateProfile(w, r)
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			if tt.wantStatus != http.StatusOK {
+				assert.Nil(t, users.saved)
+				return
+			}
+			assert.InDelta(t, tt.wantKg, *users.saved.BodyWeightKg, 1e-6)
+		})
+	}
+}
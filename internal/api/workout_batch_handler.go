@@ -0,0 +1,261 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	batchCreate = "create"
+	batchUpdate = "update"
+	batchDelete = "delete"
+
+	batchAtomic     = "atomic"
+	batchBestEffort = "best_effort"
+
+	maxBatchBytes = 10 << 20
+)
+
+// errBatchAborted stops an atomic batch at its first failed operation.
+var errBatchAborted = errors.New("batch aborted")
+
+// batchOperation is one queued change. Update and delete name their workout
+// by id, or by ref, the client_id of a create earlier in the same batch.
+// Version, when set, must be the workout's current version.
+type batchOperation struct {
+	ClientId string          `json:"client_id"`
+	Op       string          `json:"op"`
+	Id       int64           `json:"id,omitempty"`
+	Ref      string          `json:"ref,omitempty"`
+	Version  int             `json:"version,omitempty"`
+	Workout  json.RawMessage `json:"workout,omitempty"`
+}
+
+type batchResult struct {
+	ClientId string         `json:"client_id"`
+	Op       string         `json:"op"`
+	Status   int            `json:"status"`
+	Id       int            `json:"id,omitempty"`
+	Workout  *store.Workout `json:"workout,omitempty"`
+	Error    string         `json:"error,omitempty"`
+}
+
+func failedResult(op batchOperation, status int, message string) batchResult {
+	return batchResult{ClientId: op.ClientId, Op: op.Op, Status: status, Error: message}
+}
+
+// batchRun carries what the operations of one batch share.
+type batchRun struct {
+	batch   store.WorkoutBatch
+	user    *store.User
+	created map[string]int64
+	// events are published once the batch is committed.
+	events []func()
+}
+
+// target resolves the workout an update or delete applies to and checks that
+// the user owns it.
+func (run *batchRun) target(op batchOperation) (*store.Workout, *batchResult) {
+	id := op.Id
+	if op.Ref != "" {
+		ref, ok := run.created[op.Ref]
+		if !ok {
+			result := failedResult(op, http.StatusBadRequest, fmt.Sprintf("ref %q is not a create earlier in the batch", op.Ref))
+			return nil, &result
+		}
+		id = ref
+	}
+	if id <= 0 {
+		result := failedResult(op, http.StatusBadRequest, "id or ref is required")
+		return nil, &result
+	}
+	workout, err := run.batch.GetWorkout(id)
+	if err != nil {
+		result := failedResult(op, http.StatusInternalServerError, "internal server error")
+		return nil, &result
+	}
+	if workout == nil {
+		result := failedResult(op, http.StatusNotFound, "workout not found")
+		return nil, &result
+	}
+	if workout.UserId != run.user.Id {
+		result := failedResult(op, http.StatusForbidden, "you are not authorized to access this workout")
+		return nil, &result
+	}
+	if op.Version != 0 && workout.Version != op.Version {
+		result := failedResult(op, http.StatusConflict, store.ErrVersionConflict.Error())
+		return nil, &result
+	}
+	return workout, nil
+}
+
+// writeFailure maps an error from a batch write to a result.
+func (wh *WorkOutHandler) writeFailure(op batchOperation, err error) batchResult {
+	switch {
+	case errors.Is(err, store.ErrUnknownTag):
+		return failedResult(op, http.StatusBadRequest, "tag_ids contains unknown tags")
+	case errors.Is(err, store.ErrInvalidEntry):
+		return failedResult(op, http.StatusBadRequest, err.Error())
+	case errors.Is(err, store.ErrVersionConflict):
+		return failedResult(op, http.StatusConflict, err.Error())
+	case errors.Is(err, sql.ErrNoRows):
+		return failedResult(op, http.StatusNotFound, "workout not found")
+	}
+	wh.logger.Printf("ERROR: batch %s: %v", op.Op, err)
+	return failedResult(op, http.StatusInternalServerError, "internal server error")
+}
+
+func (wh *WorkOutHandler) runBatchOperation(run *batchRun, op batchOperation) batchResult {
+	switch op.Op {
+	case batchCreate:
+		var request workoutCreateRequest
+		if err := json.Unmarshal(op.Workout, &request); err != nil {
+			return failedResult(op, http.StatusBadRequest, "workout must be a workout object")
+		}
+		workout, err := request.workout(run.user)
+		if err != nil {
+			return failedResult(op, http.StatusBadRequest, err.Error())
+		}
+		if request.CaloriesBurned == nil {
+			if err := wh.estimateCalories(workout, run.user); err != nil {
+				return wh.writeFailure(op, err)
+			}
+		}
+		if err := run.batch.CreateWorkout(workout); err != nil {
+			return wh.writeFailure(op, err)
+		}
+		run.created[op.ClientId] = int64(workout.Id)
+		run.events = append(run.events, func() {
+			publishWorkout(wh.events, wh.logger, run.user, realtime.EventWorkoutCreated, workout)
+		})
+		return batchResult{ClientId: op.ClientId, Op: op.Op, Status: http.StatusCreated, Id: workout.Id, Workout: workout}
+	case batchUpdate:
+		workout, failed := run.target(op)
+		if failed != nil {
+			return *failed
+		}
+		var request workoutUpdateRequest
+		if err := json.Unmarshal(op.Workout, &request); err != nil {
+			return failedResult(op, http.StatusBadRequest, "workout must be a workout object")
+		}
+		if err := request.apply(workout, inputSystem(run.user)); err != nil {
+			return failedResult(op, http.StatusBadRequest, err.Error())
+		}
+		if request.estimatesCalories(workout) {
+			if err := wh.estimateCalories(workout, run.user); err != nil {
+				return wh.writeFailure(op, err)
+			}
+		}
+		if err := run.batch.UpdateWorkout(workout); err != nil {
+			return wh.writeFailure(op, err)
+		}
+		run.events = append(run.events, func() {
+			publishWorkout(wh.events, wh.logger, run.user, realtime.EventWorkoutUpdated, workout)
+		})
+		return batchResult{ClientId: op.ClientId, Op: op.Op, Status: http.StatusOK, Id: workout.Id, Workout: workout}
+	case batchDelete:
+		workout, failed := run.target(op)
+		if failed != nil {
+			return *failed
+		}
+		if err := run.batch.DeleteWorkout(int64(workout.Id), workout.Version); err != nil {
+			return wh.writeFailure(op, err)
+		}
+		run.events = append(run.events, func() {
+			publishEvent(wh.events, wh.logger, run.user.Id, realtime.EventWorkoutDeleted, utils.Envelope{"id": workout.Id})
+		})
+		return batchResult{ClientId: op.ClientId, Op: op.Op, Status: http.StatusNoContent, Id: workout.Id}
+	}
+	return failedResult(op, http.StatusBadRequest, "op must be create, update or delete")
+}
+
+// HandleBatchWorkouts runs a list of create, update and delete operations
+// in one transaction and reports a result for each, in order. In "atomic"
+// mode, the default, the first failure undoes the whole batch: the response
+// carries that operation's status and every other operation is reported as
+// 424. In "best_effort" mode each failed operation is undone on its own and
+// the rest are kept.
+func (wh *WorkOutHandler) HandleBatchWorkouts(w http.ResponseWriter, r *http.Request) {
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	var req struct {
+		Mode       string           `json:"mode"`
+		Operations []batchOperation `json:"operations"`
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchBytes)
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if req.Mode == "" {
+		req.Mode = batchAtomic
+	}
+	if req.Mode != batchAtomic && req.Mode != batchBestEffort {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "mode must be atomic or best_effort"})
+		return
+	}
+	if len(req.Operations) == 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "operations must not be empty"})
+		return
+	}
+	if len(req.Operations) > wh.maxBatchOperations {
+		_ = utils.WriteJson(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": fmt.Sprintf("a batch may hold at most %d operations", wh.maxBatchOperations)})
+		return
+	}
+	clientIds := map[string]bool{}
+	for _, op := range req.Operations {
+		if op.ClientId == "" || clientIds[op.ClientId] {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "every operation needs a unique client_id"})
+			return
+		}
+		clientIds[op.ClientId] = true
+	}
+
+	run := &batchRun{user: middleware.GetUser(r), created: map[string]int64{}}
+	results := make([]batchResult, len(req.Operations))
+	failed := -1
+	err := wh.workoutStore.RunBatch(run.user.Id, func(batch store.WorkoutBatch) error {
+		run.batch = batch
+		for i, op := range req.Operations {
+			results[i] = wh.runBatchOperation(run, op)
+			if results[i].Status >= http.StatusBadRequest && req.Mode == batchAtomic {
+				failed = i
+				return errBatchAborted
+			}
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errBatchAborted) {
+		wh.logger.Printf("ERROR: RunBatch: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+
+	status := http.StatusOK
+	if failed >= 0 {
+		status = results[failed].Status
+		for i, op := range req.Operations {
+			if i != failed {
+				results[i] = failedResult(op, http.StatusFailedDependency, fmt.Sprintf("not applied because operation %d failed", failed))
+			}
+		}
+	} else {
+		for _, publish := range run.events {
+			publish()
+		}
+	}
+	for _, result := range results {
+		displayWorkout(result.Workout, system)
+	}
+	_ = utils.WriteJson(w, status, utils.Envelope{"mode": req.Mode, "results": results})
+}
@@ -0,0 +1,224 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorkoutBatch writes straight into the store's map; RunBatch puts the
+// map back when the batch fails, like a rolled back transaction.
+type fakeWorkoutBatch struct {
+	fs *fakeWorkoutStore
+}
+
+func (fs *fakeWorkoutStore) RunBatch(userID int, fn func(batch store.WorkoutBatch) error) error {
+	saved := make(map[int64]*store.Workout, len(fs.workouts))
+	for id, workout := range fs.workouts {
+		saved[id] = copyWorkout(workout)
+	}
+	if err := fn(&fakeWorkoutBatch{fs: fs}); err != nil {
+		fs.workouts = saved
+		return err
+	}
+	return nil
+}
+
+func (b *fakeWorkoutBatch) GetWorkout(id int64) (*store.Workout, error) {
+	return b.fs.GetWorkOutById(id)
+}
+
+func (b *fakeWorkoutBatch) CreateWorkout(workout *store.Workout) error {
+	for _, entry := range workout.Entries {
+		if err := store.ValidateEntry(entry); err != nil {
+			return err
+		}
+	}
+	var last int64
+	for id := range b.fs.workouts {
+		last = max(last, id)
+	}
+	workout.Id = int(last) + 1
+	workout.Version = 1
+	b.fs.workouts[last+1] = copyWorkout(workout)
+	return nil
+}
+
+func (b *fakeWorkoutBatch) UpdateWorkout(workout *store.Workout) error {
+	stored, ok := b.fs.workouts[int64(workout.Id)]
+	if !ok {
+		return fmt.Errorf("updating workout %d: %w", workout.Id, store.ErrVersionConflict)
+	}
+	if stored.Version != workout.Version {
+		return store.ErrVersionConflict
+	}
+	workout.Version++
+	b.fs.workouts[int64(workout.Id)] = copyWorkout(workout)
+	return nil
+}
+
+func (b *fakeWorkoutBatch) DeleteWorkout(id int64, expectedVersion int) error {
+	stored, ok := b.fs.workouts[id]
+	if !ok || stored.Version != expectedVersion {
+		return store.ErrVersionConflict
+	}
+	delete(b.fs.workouts, id)
+	return nil
+}
+
+func liftWorkout(id, userID int) *store.Workout {
+	return &store.Workout{
+		Id:             id,
+		UserId:         userID,
+		Title:          "Push",
+		CaloriesBurned: 300,
+		Version:        1,
+		Entries: []store.WorkoutEntry{
+			{Id: id * 10, ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(5), WeightUnit: "kg", OrderIndex: 1},
+		},
+	}
+}
+
+const (
+	validCreate   = `{"client_id":"a","op":"create","workout":{"title":"Legs","calories_burned":250,"entries":[{"exercise_name":"Squat","sets":5,"reps":5,"order_index":1}]}}`
+	invalidCreate = `{"client_id":"b","op":"create","workout":{"title":"Core","calories_burned":50,"entries":[{"exercise_name":"Plank","sets":1,"reps":1,"duration_seconds":60,"order_index":1}]}}`
+	renameByRef   = `{"client_id":"c","op":"update","ref":"a","workout":{"title":"Leg Day"}}`
+	deleteOwned   = `{"client_id":"d","op":"delete","id":1}`
+	deleteOthers  = `{"client_id":"e","op":"delete","id":2}`
+)
+
+type batchResponse struct {
+	Mode    string        `json:"mode"`
+	Results []batchResult `json:"results"`
+}
+
+func runBatchRequest(t *testing.T, workouts *fakeWorkoutStore, maxOperations int, body string) (int, batchResponse) {
+	t.Helper()
+	handler := newTestWorkOutHandler(workouts)
+	handler.maxBatchOperations = maxOperations
+	r := httptest.NewRequest(http.MethodPost, "/workouts/batch", strings.NewReader(body))
+	r = middleware.SetUser(r, &store.User{Id: 1})
+	w := httptest.NewRecorder()
+	handler.HandleBatchWorkouts(w, r)
+	var response batchResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response), w.Body.String())
+	return w.Code, response
+}
+
+func statuses(results []batchResult) []int {
+	codes := make([]int, 0, len(results))
+	for _, result := range results {
+		codes = append(codes, result.Status)
+	}
+	return codes
+}
+
+func TestHandleBatchWorkouts(t *testing.T) {
+	tests := []struct {
+		name         string
+		operations   []string
+		mode         string
+		wantStatus   int
+		wantResults  []int
+		wantWorkouts []int64
+	}{
+		{
+			name:         "atomic success",
+			operations:   []string{validCreate, renameByRef, deleteOwned},
+			wantStatus:   http.StatusOK,
+			wantResults:  []int{http.StatusCreated, http.StatusOK, http.StatusNoContent},
+			wantWorkouts: []int64{2, 3},
+		},
+		{
+			name:         "atomic failure rolls back",
+			operations:   []string{validCreate, deleteOwned, invalidCreate, renameByRef},
+			wantStatus:   http.StatusBadRequest,
+			wantResults:  []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusBadRequest, http.StatusFailedDependency},
+			wantWorkouts: []int64{1, 2},
+		},
+		{
+			name:         "atomic forbidden",
+			operations:   []string{deleteOwned, deleteOthers},
+			wantStatus:   http.StatusForbidden,
+			wantResults:  []int{http.StatusFailedDependency, http.StatusForbidden},
+			wantWorkouts: []int64{1, 2},
+		},
+		{
+			name:         "best effort keeps the rest",
+			operations:   []string{validCreate, invalidCreate, deleteOthers, deleteOwned},
+			mode:         batchBestEffort,
+			wantStatus:   http.StatusOK,
+			wantResults:  []int{http.StatusCreated, http.StatusBadRequest, http.StatusForbidden, http.StatusNoContent},
+			wantWorkouts: []int64{2, 3},
+		},
+		{
+			name:         "ref to a later create",
+			operations:   []string{renameByRef, validCreate},
+			mode:         batchBestEffort,
+			wantStatus:   http.StatusOK,
+			wantResults:  []int{http.StatusBadRequest, http.StatusCreated},
+			wantWorkouts: []int64{1, 2, 3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts := newFakeWorkoutStore(liftWorkout(1, 1), liftWorkout(2, 2))
+			body := fmt.Sprintf(`{"mode":%q,"operations":[%s]}`, tt.mode, strings.Join(tt.operations, ","))
+
+			status, response := runBatchRequest(t, workouts, 100, body)
+
+			assert.Equal(t, tt.wantStatus, status)
+			assert.Equal(t, tt.wantResults, statuses(response.Results))
+			var ids []int64
+			for id := range workouts.workouts {
+				ids = append(ids, id)
+			}
+			assert.ElementsMatch(t, tt.wantWorkouts, ids)
+		})
+	}
+}
+
+func TestHandleBatchWorkoutsUpdatesByRef(t *testing.T) {
+	workouts := newFakeWorkoutStore(liftWorkout(1, 1))
+	body := fmt.Sprintf(`{"operations":[%s,%s]}`, validCreate, renameByRef)
+
+	status, response := runBatchRequest(t, workouts, 100, body)
+
+	require.Equal(t, http.StatusOK, status)
+	require.Len(t, response.Results, 2)
+	assert.Equal(t, response.Results[0].Id, response.Results[1].Id)
+	assert.Equal(t, "Leg Day", workouts.workouts[int64(response.Results[0].Id)].Title)
+}
+
+func TestHandleBatchWorkoutsLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		max        int
+		body       string
+		wantStatus int
+	}{
+		{"at the limit", 2, fmt.Sprintf(`{"operations":[%s,%s]}`, validCreate, deleteOwned), http.StatusOK},
+		{"over the limit", 1, fmt.Sprintf(`{"operations":[%s,%s]}`, validCreate, deleteOwned), http.StatusRequestEntityTooLarge},
+		{"no operations", 1, `{"operations":[]}`, http.StatusBadRequest},
+		{"unknown mode", 1, fmt.Sprintf(`{"mode":"eventual","operations":[%s]}`, deleteOwned), http.StatusBadRequest},
+		{"repeated client id", 2, fmt.Sprintf(`{"operations":[%s,%s]}`, deleteOwned, deleteOwned), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts := newFakeWorkoutStore(liftWorkout(1, 1))
+			status, _ := runBatchRequest(t, workouts, tt.max, tt.body)
+			assert.Equal(t, tt.wantStatus, status)
+			if tt.wantStatus != http.StatusOK {
+				assert.Len(t, workouts.workouts, 1, "nothing is written")
+			}
+		})
+	}
+}
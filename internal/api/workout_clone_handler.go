@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+type entryAdjustmentRequest struct {
+	EntryId    int     `json:"entry_id"`
+	Weight     float64 `json:"weight"`
+	WeightUnit string  `json:"weight_unit"`
+	Reps       int     `json:"reps"`
+	Sets       int     `json:"sets"`
+}
+
+// readAdjustments keys the requested progressions by source entry id, with
+// weights converted to kg.
+func readAdjustments(requests []entryAdjustmentRequest, workout *store.Workout, system string) (map[int]store.EntryAdjustment, error) {
+	known := make(map[int]bool, len(workout.Entries))
+	for _, entry := range workout.Entries {
+		known[entry.Id] = true
+	}
+	adjustments := make(map[int]store.EntryAdjustment, len(requests))
+	for _, req := range requests {
+		if !known[req.EntryId] {
+			return nil, fmt.Errorf("entry %d is not part of this workout", req.EntryId)
+		}
+		if _, ok := adjustments[req.EntryId]; ok {
+			return nil, fmt.Errorf("entry %d is adjusted more than once", req.EntryId)
+		}
+		if req.WeightUnit == "" {
+			req.WeightUnit = units.WeightUnit(system)
+		}
+		toKg, err := toKilograms(req.WeightUnit)
+		if err != nil {
+			return nil, err
+		}
+		adjustments[req.EntryId] = store.EntryAdjustment{Weight: toKg(req.Weight), Reps: req.Reps, Sets: req.Sets}
+	}
+	return adjustments, nil
+}
+
+// HandleCloneWorkout repeats a workout as a new one, optionally progressing
+// entries, e.g. {"adjustments": [{"entry_id": 3, "weight": 2.5}]}.
+func (wh *WorkOutHandler) HandleCloneWorkout(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	var request struct {
+		Title       string                   `json:"title"`
+		Adjustments []entryAdjustmentRequest `json:"adjustments"`
+	}
+	// The body is optional.
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && !errors.Is(err, io.EOF) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	request.Title = strings.TrimSpace(request.Title)
+	if err := validateTitle(request.Title); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	source, err := wh.workoutStore.GetWorkOutById(id)
+	if err != nil || source == nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	adjustments, err := readAdjustments(request.Adjustments, source, inputSystem(currentUser))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	clone := source.Clone(adjustments)
+	clone.UserId = currentUser.Id
+	if request.Title != "" {
+		clone.Title = request.Title
+	}
+	if err := wh.estimateCalories(clone, currentUser); err != nil {
+		wh.logger.Printf("ERROR: estimating calories: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to clone workout"})
+		return
+	}
+	created, err := wh.workoutStore.CreateWorkout(clone)
+	if err != nil {
+		wh.logger.Printf("ERROR: CreateWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to clone workout"})
+		return
+	}
+	publishWorkout(wh.events, wh.logger, currentUser, realtime.EventWorkoutCreated, created)
+	displayWorkout(created, system)
+	_ = utils.WriteJson(w, http.StatusCreated, utils.Envelope{"workout": created})
+}
+
+// HandleGetLastPerformance returns the latest logged entry of ?exercise= so
+// the next session can be pre-filled.
+func (wh *WorkOutHandler) HandleGetLastPerformance(w http.ResponseWriter, r *http.Request) {
+	exercise := strings.TrimSpace(r.URL.Query().Get("exercise"))
+	if exercise == "" {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "exercise is required"})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	last, err := wh.workoutStore.GetLastPerformance(middleware.GetUser(r).Id, exercise)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetLastPerformance: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if last == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "exercise has not been logged yet"})
+		return
+	}
+	entries := []store.WorkoutEntry{last.Entry}
+	displayEntries(entries, system)
+	last.Entry = entries[0]
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"last": last})
+}
@@ -0,0 +1,306 @@
+package api
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+func readEntryParam(r *http.Request) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, "entryId"))
+}
+
+func findEntry(entries []store.WorkoutEntry, id int) int {
+	for i, entry := range entries {
+		if entry.Id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// readEntryWorkout loads the workout named in the URL for the entry
+// endpoints, writing the error response itself when it cannot.
+func (wh *WorkOutHandler) readEntryWorkout(w http.ResponseWriter, r *http.Request) (*store.Workout, string, bool) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return nil, "", false
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return nil, "", false
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return nil, "", false
+	}
+	workout, err := wh.workoutStore.GetWorkOutById(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil, "", false
+	}
+	if workout == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return nil, "", false
+	}
+	return workout, system, true
+}
+
+// readEntry decodes an entry from the body in the user's input units and
+// checks it on its own; group rules are checked with the whole workout.
+func readEntry(w http.ResponseWriter, r *http.Request) (*store.WorkoutEntry, bool) {
+	var entry store.WorkoutEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return nil, false
+	}
+	entries := []store.WorkoutEntry{entry}
+	if err := store.ValidateSetLogs(entries); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if err := store.ValidateEntry(entry); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	if err := normalizeEntries(entries, inputSystem(middleware.GetUser(r))); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil, false
+	}
+	return &entries[0], true
+}
+
+// saveEntries stores the workout after its entries were changed in memory.
+// Entries are renumbered from 1 in slice order so order_index has no gaps,
+// and only the entries that differ from the stored ones are written. It
+// returns the reloaded workout, or nil after writing the error response.
+func (wh *WorkOutHandler) saveEntries(w http.ResponseWriter, r *http.Request, workout *store.Workout) *store.Workout {
+	for i := range workout.Entries {
+		workout.Entries[i].OrderIndex = i + 1
+	}
+	if err := store.ValidateEntryGroups(workout.Entries); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil
+	}
+	currentUser := middleware.GetUser(r)
+	if workout.CaloriesEstimated || workout.CaloriesBurned == 0 {
+		if err := wh.estimateCalories(workout, currentUser); err != nil {
+			wh.logger.Printf("ERROR: estimating calories: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return nil
+		}
+	}
+	err := wh.workoutStore.PatchWorkout(workout)
+	if writeVersionError(w, r, err) {
+		return nil
+	}
+	if errors.Is(err, store.ErrInvalidEntry) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return nil
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: PatchWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update workout"})
+		return nil
+	}
+	updated, err := wh.workoutStore.GetWorkOutById(int64(workout.Id))
+	if err != nil || updated == nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return nil
+	}
+	publishWorkout(wh.events, wh.logger, currentUser, realtime.EventWorkoutUpdated, updated)
+	return updated
+}
+
+// writeEntry answers with one entry of the workout and the workout's new
+// ETag.
+func writeEntry(w http.ResponseWriter, status int, workout *store.Workout, entryID int, system string) {
+	displayWorkout(workout, system)
+	w.Header().Set("ETag", workoutETag(workout.Version, system))
+	i := findEntry(workout.Entries, entryID)
+	if i < 0 {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "entry not found"})
+		return
+	}
+	_ = utils.WriteJson(w, status, utils.Envelope{"entry": workout.Entries[i]})
+}
+
+func (wh *WorkOutHandler) HandleListEntries(w http.ResponseWriter, r *http.Request) {
+	workout, system, ok := wh.readEntryWorkout(w, r)
+	if !ok {
+		return
+	}
+	if notModified(w, r, workout.Version, system) {
+		return
+	}
+	displayWorkout(workout, system)
+	entries := workout.Entries
+	if entries == nil {
+		entries = []store.WorkoutEntry{}
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"entries": entries})
+}
+
+func (wh *WorkOutHandler) HandleGetEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, err := readEntryParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid entry id"})
+		return
+	}
+	workout, system, ok := wh.readEntryWorkout(w, r)
+	if !ok {
+		return
+	}
+	if findEntry(workout.Entries, entryID) >= 0 && notModified(w, r, workout.Version, system) {
+		return
+	}
+	writeEntry(w, http.StatusOK, workout, entryID, system)
+}
+
+// HandleCreateEntry adds an entry to the workout. A positive order_index
+// inserts it at that position; otherwise it is appended.
+func (wh *WorkOutHandler) HandleCreateEntry(w http.ResponseWriter, r *http.Request) {
+	workout, system, ok := wh.readEntryWorkout(w, r)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, workout.Version, system) {
+		return
+	}
+	entry, ok := readEntry(w, r)
+	if !ok {
+		return
+	}
+	entry.Id = 0
+	position := len(workout.Entries)
+	if entry.OrderIndex > 0 && entry.OrderIndex <= len(workout.Entries) {
+		position = entry.OrderIndex - 1
+	}
+	entries := make([]store.WorkoutEntry, 0, len(workout.Entries)+1)
+	entries = append(entries, workout.Entries[:position]...)
+	entries = append(entries, *entry)
+	entries = append(entries, workout.Entries[position:]...)
+	workout.Entries = entries
+	updated := wh.saveEntries(w, r, workout)
+	if updated == nil {
+		return
+	}
+	writeEntry(w, http.StatusCreated, updated, workout.Entries[position].Id, system)
+}
+
+// HandleUpdateEntry replaces one entry. It keeps its position; use the
+// reorder endpoint to move it.
+func (wh *WorkOutHandler) HandleUpdateEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, err := readEntryParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid entry id"})
+		return
+	}
+	workout, system, ok := wh.readEntryWorkout(w, r)
+	if !ok {
+		return
+	}
+	i := findEntry(workout.Entries, entryID)
+	if i < 0 {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "entry not found"})
+		return
+	}
+	if !checkIfMatch(w, r, workout.Version, system) {
+		return
+	}
+	entry, ok := readEntry(w, r)
+	if !ok {
+		return
+	}
+	entry.Id = entryID
+	workout.Entries[i] = *entry
+	updated := wh.saveEntries(w, r, workout)
+	if updated == nil {
+		return
+	}
+	writeEntry(w, http.StatusOK, updated, entryID, system)
+}
+
+func (wh *WorkOutHandler) HandleDeleteEntry(w http.ResponseWriter, r *http.Request) {
+	entryID, err := readEntryParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid entry id"})
+		return
+	}
+	workout, system, ok := wh.readEntryWorkout(w, r)
+	if !ok {
+		return
+	}
+	i := findEntry(workout.Entries, entryID)
+	if i < 0 {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "entry not found"})
+		return
+	}
+	if !checkIfMatch(w, r, workout.Version, system) {
+		return
+	}
+	workout.Entries = append(workout.Entries[:i], workout.Entries[i+1:]...)
+	updated := wh.saveEntries(w, r, workout)
+	if updated == nil {
+		return
+	}
+	w.Header().Set("ETag", workoutETag(updated.Version, system))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// HandleReorderEntries puts the entries in the order of entry_ids, which must
+// name every entry of the workout exactly once.
+func (wh *WorkOutHandler) HandleReorderEntries(w http.ResponseWriter, r *http.Request) {
+	workout, system, ok := wh.readEntryWorkout(w, r)
+	if !ok {
+		return
+	}
+	if !checkIfMatch(w, r, workout.Version, system) {
+		return
+	}
+	var req struct {
+		EntryIds []int `json:"entry_ids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid request payload"})
+		return
+	}
+	if len(req.EntryIds) != len(workout.Entries) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("entry_ids must list all %d entries", len(workout.Entries))})
+		return
+	}
+	entries := make([]store.WorkoutEntry, 0, len(workout.Entries))
+	seen := map[int]bool{}
+	for _, id := range req.EntryIds {
+		i := findEntry(workout.Entries, id)
+		if i < 0 || seen[id] {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": fmt.Sprintf("entry_ids must list every entry once; %d is unknown or repeated", id)})
+			return
+		}
+		seen[id] = true
+		entries = append(entries, workout.Entries[i])
+	}
+	workout.Entries = entries
+	updated := wh.saveEntries(w, r, workout)
+	if updated == nil {
+		return
+	}
+	displayWorkout(updated, system)
+	w.Header().Set("ETag", workoutETag(updated.Version, system))
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"entries": updated.Entries})
+}
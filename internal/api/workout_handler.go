@@ -4,44 +4,232 @@ import (
 	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
+	"time"
+	"unicode/utf8"
 
+	"github.com/Numeez/go-zenith/internal/calories"
 	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
 	"github.com/Numeez/go-zenith/internal/store"
 	"github.com/Numeez/go-zenith/internal/utils"
 )
 
 type WorkOutHandler struct {
-	workoutStore store.WorkoutStore
-	logger       *log.Logger
+	workoutStore     store.WorkoutStore
+	exerciseStore    store.ExerciseStore
+	measurementStore store.MeasurementStore
+	events           EventPublisher
+	// maxBatchOperations caps the operations of one POST /workouts/batch.
+	maxBatchOperations int
+	logger             *log.Logger
 }
 
-func NewWorkOutHandler(store store.WorkoutStore, logger *log.Logger) *WorkOutHandler {
+func NewWorkOutHandler(store store.WorkoutStore, exerciseStore store.ExerciseStore, measurementStore store.MeasurementStore, events EventPublisher, maxBatchOperations int, logger *log.Logger) *WorkOutHandler {
 	return &WorkOutHandler{
-		workoutStore: store,
-		logger:       logger,
+		workoutStore:       store,
+		exerciseStore:      exerciseStore,
+		measurementStore:   measurementStore,
+		events:             events,
+		maxBatchOperations: maxBatchOperations,
+		logger:             logger,
 	}
 }
 
+func (wh *WorkOutHandler) estimateCalories(workout *store.Workout, user *store.User) error {
+	return estimateCalories(wh.exerciseStore, wh.measurementStore, workout, user)
+}
+
+// estimateCalories fills in CaloriesBurned from the catalog MET values of the
+// workout's exercises and the user's latest body weight.
+func estimateCalories(exerciseStore store.ExerciseStore, measurementStore store.MeasurementStore, workout *store.Workout, user *store.User) error {
+	names := make([]string, 0, len(workout.Entries))
+	for _, entry := range workout.Entries {
+		names = append(names, entry.ExerciseName)
+	}
+	mets, err := exerciseStore.GetMETValues(names)
+	if err != nil {
+		return err
+	}
+	latest, err := measurementStore.GetLatestBodyWeight(user.Id)
+	if err != nil {
+		return err
+	}
+	bodyWeight := calories.DefaultBodyWeightKg
+	if latest != nil {
+		bodyWeight = *latest
+	}
+	workout.CaloriesBurned = calories.Estimate(workout, mets, bodyWeight)
+	workout.CaloriesEstimated = true
+	return nil
+}
+
+// authorizeWorkout writes the error response itself and returns false when
+// the current user does not own the workout.
+func (wh *WorkOutHandler) authorizeWorkout(w http.ResponseWriter, r *http.Request, id int64) bool {
+	owner, err := wh.workoutStore.GetWorkoutOwner(id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+			return false
+		}
+		wh.logger.Printf("ERROR: GetWorkoutOwner: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return false
+	}
+	if owner != middleware.GetUser(r).Id {
+		_ = utils.WriteJson(w, http.StatusForbidden, utils.Envelope{"error": "you are not authorized to access this workout"})
+		return false
+	}
+	return true
+}
+
+// maxClockSkew is how far ahead of the server's clock a client may date a
+// workout.
+const maxClockSkew = 5 * time.Minute
+
+// workoutCreateRequest is a new workout as clients send it. CaloriesBurned
+// shadows the embedded field so an omitted value can be told apart from an
+// explicit zero.
+type workoutCreateRequest struct {
+	store.Workout
+	CaloriesBurned *int `json:"calories_burned"`
+}
+
+// workout validates the request and returns the workout to store for user,
+// with entries converted from the user's units. Calories are left for the
+// caller to estimate when they were omitted. CreatedAt, when sent, dates a
+// workout logged earlier, such as one queued offline.
+func (req *workoutCreateRequest) workout(user *store.User) (*store.Workout, error) {
+	workout := req.Workout
+	if workout.CreatedAt.After(time.Now().Add(maxClockSkew)) {
+		return nil, errors.New("created_at cannot be in the future")
+	}
+	if err := validateEntries(workout.Entries); err != nil {
+		return nil, err
+	}
+	if err := normalizeEntries(workout.Entries, inputSystem(user)); err != nil {
+		return nil, err
+	}
+	workout.UserId = user.Id
+	workout.CaloriesEstimated = false
+	if req.CaloriesBurned != nil {
+		workout.CaloriesBurned = *req.CaloriesBurned
+	}
+	return &workout, nil
+}
+
+// workoutUpdateRequest changes only the fields that are sent; entries, when
+// sent, replace all of the workout's entries.
+type workoutUpdateRequest struct {
+	Title           *string              `json:"title"`
+	Description     *string              `json:"description"`
+	DurationMinutes *int                 `json:"duration_minutes"`
+	CaloriesBurned  *int                 `json:"calories_burned"`
+	Entries         []store.WorkoutEntry `json:"entries"`
+	TagIds          []int                `json:"tag_ids"`
+}
+
+// apply copies the request onto workout, converting entries from the units
+// of system.
+func (req *workoutUpdateRequest) apply(workout *store.Workout, system string) error {
+	if req.Entries != nil {
+		if err := validateEntries(req.Entries); err != nil {
+			return err
+		}
+		if err := normalizeEntries(req.Entries, system); err != nil {
+			return err
+		}
+		workout.Entries = req.Entries
+		workout.Groups = store.BuildEntryGroups(req.Entries)
+	}
+	if req.Title != nil {
+		workout.Title = *req.Title
+	}
+	if req.Description != nil {
+		workout.Description = *req.Description
+	}
+	if req.DurationMinutes != nil {
+		workout.DurationMinutes = *req.DurationMinutes
+	}
+	if req.CaloriesBurned != nil {
+		workout.CaloriesBurned = *req.CaloriesBurned
+		workout.CaloriesEstimated = false
+	}
+	if req.TagIds != nil {
+		workout.TagIds = req.TagIds
+	}
+	return nil
+}
+
+// estimatesCalories reports whether the workout's calories should be
+// estimated again after the update: a value the user supplied earlier is
+// kept, estimates follow the edits.
+func (req *workoutUpdateRequest) estimatesCalories(workout *store.Workout) bool {
+	return req.CaloriesBurned == nil && (workout.CaloriesEstimated || workout.CaloriesBurned == 0)
+}
+
+// maxTitleLength is the size of the title column of workouts and templates,
+// in characters.
+const maxTitleLength = 50
+
+func validateTitle(title string) error {
+	if utf8.RuneCountInString(title) > maxTitleLength {
+		return fmt.Errorf("title must be at most %d characters", maxTitleLength)
+	}
+	return nil
+}
+
+func validateEntries(entries []store.WorkoutEntry) error {
+	if err := store.ValidateEntryGroups(entries); err != nil {
+		return err
+	}
+	if err := store.ValidateSetLogs(entries); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := store.ValidateEntry(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (wh *WorkOutHandler) HandleGetWorkOutById(w http.ResponseWriter, r *http.Request) {
 	id, err := utils.ReadIdParam(r)
 	if err != nil {
 		http.NotFound(w, r)
 	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
 	workout, err := wh.workoutStore.GetWorkOutById(id)
 	if err != nil {
 		wh.logger.Print(err.Error())
 		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": err})
 		return
 	}
+	if workout == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return
+	}
+	if notModified(w, r, workout.Version, system) {
+		return
+	}
+	displayWorkout(workout, system)
 	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": workout})
 
 }
 
 func (wh *WorkOutHandler) HandleCreateWorkOut(w http.ResponseWriter, r *http.Request) {
-	var workout store.Workout
-	if err := json.NewDecoder(r.Body).Decode(&workout); err != nil {
+	var request workoutCreateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		wh.logger.Print(err.Error())
 		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"})
 		return
@@ -51,13 +239,35 @@ func (wh *WorkOutHandler) HandleCreateWorkOut(w http.ResponseWriter, r *http.Req
 		_ = utils.WriteJson(w, http.StatusUnauthorized, utils.Envelope{"error": "user should be logged in"})
 		return
 	}
-	workout.UserId = currentUser.Id
-	createdWorkout, err := wh.workoutStore.CreateWorkout(&workout)
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	workout, err := request.workout(currentUser)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if request.CaloriesBurned == nil {
+		if err := wh.estimateCalories(workout, currentUser); err != nil {
+			wh.logger.Printf("ERROR: estimating calories: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"})
+			return
+		}
+	}
+	createdWorkout, err := wh.workoutStore.CreateWorkout(workout)
 	if err != nil {
+		if errors.Is(err, store.ErrUnknownTag) {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "tag_ids contains unknown tags"})
+			return
+		}
 		wh.logger.Print(err.Error())
 		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to create workout"})
 		return
 	}
+	publishWorkout(wh.events, wh.logger, currentUser, realtime.EventWorkoutCreated, createdWorkout)
+	displayWorkout(createdWorkout, system)
+	w.Header().Set("ETag", workoutETag(createdWorkout.Version, system))
 	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": createdWorkout})
 }
 
@@ -70,6 +280,10 @@ func (wh *WorkOutHandler) HandlerUpdateWorkoutById(w http.ResponseWriter, r *htt
 		}
 		return
 	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
 	existingWorkout, err := wh.workoutStore.GetWorkOutById(id)
 	if err != nil {
 		wh.logger.Printf("ERROR: GetWorkoutById: %v", err)
@@ -85,31 +299,13 @@ func (wh *WorkOutHandler) HandlerUpdateWorkoutById(w http.ResponseWriter, r *htt
 		}
 		return
 	}
-	type updateRequest struct {
-		Title           *string              `json:"title"`
-		Description     *string              `json:"description"`
-		DurationMinutes *int                 `json:"duration_minutes"`
-		CaloriesBurned  *int                 `json:"calories_burned"`
-		Entries         []store.WorkoutEntry `json:"entries"`
-	}
-	var request updateRequest
+	var request workoutUpdateRequest
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 	}
-	if request.Title != nil {
-		existingWorkout.Title = *request.Title
-	}
-	if request.Description != nil {
-		existingWorkout.Description = *request.Description
-	}
-	if request.DurationMinutes != nil {
-		existingWorkout.DurationMinutes = *request.DurationMinutes
-	}
-	if request.CaloriesBurned != nil {
-		existingWorkout.CaloriesBurned = *request.CaloriesBurned
-	}
-	if request.Entries != nil {
-		existingWorkout.Entries = request.Entries
+	if err := request.apply(existingWorkout, inputSystem(middleware.GetUser(r))); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
 	}
 	currentUser := middleware.GetUser(r)
 	if currentUser == nil || currentUser == store.AnonymousUser {
@@ -130,12 +326,32 @@ func (wh *WorkOutHandler) HandlerUpdateWorkoutById(w http.ResponseWriter, r *htt
 		return
 
 	}
+	if !checkIfMatch(w, r, existingWorkout.Version, system) {
+		return
+	}
+	if request.estimatesCalories(existingWorkout) {
+		if err := wh.estimateCalories(existingWorkout, currentUser); err != nil {
+			wh.logger.Printf("ERROR: estimating calories: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
 	err = wh.workoutStore.UpdateWorkout(existingWorkout)
+	if errors.Is(err, store.ErrUnknownTag) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "tag_ids contains unknown tags"})
+		return
+	}
+	if writeVersionError(w, r, err) {
+		return
+	}
 	if err != nil {
 		wh.logger.Printf("Update workout failed: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	publishWorkout(wh.events, wh.logger, currentUser, realtime.EventWorkoutUpdated, existingWorkout)
+	displayWorkout(existingWorkout, system)
+	w.Header().Set("ETag", workoutETag(existingWorkout.Version, system))
 	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": existingWorkout})
 
 }
@@ -165,10 +381,28 @@ func (wh *WorkOutHandler) HandlerDeleteWorkout(w http.ResponseWriter, r *http.Re
 		return
 
 	}
-	if err := wh.workoutStore.DeleteWorkout(id); err != nil {
+	// If-Match holds the ETag of the workout as displayed in some system.
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	version, err := wh.workoutStore.GetWorkoutVersion(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetWorkoutVersion: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !checkIfMatch(w, r, version, system) {
+		return
+	}
+	if err := wh.workoutStore.DeleteWorkout(id, version); err != nil {
+		if writeVersionError(w, r, err) {
+			return
+		}
 		wh.logger.Print(err.Error())
 		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": err})
 		return
 	}
+	publishEvent(wh.events, wh.logger, currentUser.Id, realtime.EventWorkoutDeleted, utils.Envelope{"id": id})
 	_ = utils.WriteJson(w, http.StatusNoContent, utils.Envelope{"message": "Workout Deleted"})
 }
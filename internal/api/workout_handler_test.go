@@ -0,0 +1,44 @@
+package api
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutCreateRequestCreatedAt(t *testing.T) {
+	yesterday := time.Now().Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	tests := []struct {
+		name      string
+		createdAt string
+		want      time.Time
+		wantErr   string
+	}{
+		{"omitted", "", time.Time{}, ""},
+		{"logged offline", yesterday.Format(time.RFC3339), yesterday, ""},
+		{"in the future", time.Now().Add(time.Hour).UTC().Format(time.RFC3339), time.Time{}, "created_at cannot be in the future"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"title":"Legs","entries":[{"exercise_name":"Squat","sets":5,"reps":5,"order_index":1}]`
+			if tt.createdAt != "" {
+				body += `,"created_at":"` + tt.createdAt + `"`
+			}
+			var request workoutCreateRequest
+			require.NoError(t, json.Unmarshal([]byte(body+"}"), &request))
+
+			workout, err := request.workout(&store.User{Id: 1})
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.True(t, tt.want.Equal(workout.CreatedAt), "got %v", workout.CreatedAt)
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	defaultWorkoutPageSize = 20
+	maxWorkoutPageSize     = 100
+)
+
+// readTagIds accepts ?tag=1&tag=2 as well as ?tag=1,2.
+func readTagIds(r *http.Request) ([]int, error) {
+	var ids []int
+	for _, value := range r.URL.Query()["tag"] {
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(part))
+			if err != nil || id < 1 {
+				return nil, errors.New("tag must be a list of tag ids")
+			}
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// readWorkoutFilter parses the listing query shared by the workout listing and
+// other filtered workout views: tag, match, from, to, limit and offset.
+func readWorkoutFilter(r *http.Request, user *store.User) (store.WorkoutFilter, error) {
+	filter := store.WorkoutFilter{UserId: user.Id}
+	tagIds, err := readTagIds(r)
+	if err != nil {
+		return filter, err
+	}
+	filter.TagIds = tagIds
+	switch r.URL.Query().Get("match") {
+	case "", "any":
+	case "all":
+		filter.MatchAllTags = true
+	default:
+		return filter, errors.New("match must be any or all")
+	}
+	loc, err := userLocation(r, user)
+	if err != nil {
+		return filter, err
+	}
+	if r.URL.Query().Get("from") != "" {
+		from, err := readDateQuery(r, "from", time.Time{})
+		if err != nil {
+			return filter, err
+		}
+		start := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, loc)
+		filter.From = &start
+	}
+	if r.URL.Query().Get("to") != "" {
+		to, err := readDateQuery(r, "to", time.Time{})
+		if err != nil {
+			return filter, err
+		}
+		end := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+		filter.To = &end
+	}
+	if filter.From != nil && filter.To != nil && !filter.To.After(*filter.From) {
+		return filter, errors.New("invalid date range")
+	}
+	if filter.Limit, err = utils.ReadIntQuery(r, "limit", defaultWorkoutPageSize); err != nil || filter.Limit < 1 || filter.Limit > maxWorkoutPageSize {
+		return filter, errors.New("limit must be between 1 and 100")
+	}
+	if filter.Offset, err = utils.ReadIntQuery(r, "offset", 0); err != nil || filter.Offset < 0 {
+		return filter, errors.New("offset cannot be negative")
+	}
+	return filter, nil
+}
+
+// HandleListWorkouts pages through the user's workouts, newest first, with
+// optional tag and date filters, and totals the matching workouts per tag.
+func (wh *WorkOutHandler) HandleListWorkouts(w http.ResponseWriter, r *http.Request) {
+	filter, err := readWorkoutFilter(r, middleware.GetUser(r))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	list, err := wh.workoutStore.ListWorkouts(filter)
+	if err != nil {
+		wh.logger.Printf("ERROR: ListWorkouts: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"workouts":      list.Workouts,
+		"total":         list.Total,
+		"limit":         filter.Limit,
+		"offset":        filter.Offset,
+		"tag_summaries": list.TagSummaries,
+	})
+}
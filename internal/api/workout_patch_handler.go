@@ -0,0 +1,306 @@
+package api
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/jsonpatch"
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	mergePatchType = "application/merge-patch+json"
+	jsonPatchType  = "application/json-patch+json"
+	maxPatchBytes  = 1 << 20
+	// entryIdPrefix lets a JSON Patch address an entry by id rather than by
+	// position, as in /entries/id:42/notes.
+	entryIdPrefix = "id:"
+)
+
+// workoutDocument is the part of a workout a PATCH can change. Entries are
+// shown in the units they were entered in, with their distance as distance
+// and distance_unit only.
+type workoutDocument struct {
+	Title           string               `json:"title"`
+	Description     string               `json:"description"`
+	DurationMinutes int                  `json:"duration_minutes"`
+	CaloriesBurned  int                  `json:"calories_burned"`
+	TagIds          []int                `json:"tag_ids"`
+	Entries         []store.WorkoutEntry `json:"entries"`
+}
+
+func newWorkoutDocument(workout *store.Workout) workoutDocument {
+	document := workoutDocument{
+		Title:           workout.Title,
+		Description:     workout.Description,
+		DurationMinutes: workout.DurationMinutes,
+		CaloriesBurned:  workout.CaloriesBurned,
+		TagIds:          workout.TagIds,
+		Entries:         copyEntries(workout.Entries),
+	}
+	if document.TagIds == nil {
+		document.TagIds = []int{}
+	}
+	if document.Entries == nil {
+		document.Entries = []store.WorkoutEntry{}
+	}
+	displayEntries(document.Entries, units.Original)
+	for i := range document.Entries {
+		document.Entries[i].DistanceMeters = nil
+	}
+	return document
+}
+
+// resolveEntryPointer rewrites /entries/id:N pointers to the entry's current
+// position in doc; other pointers are returned unchanged.
+func resolveEntryPointer(pointer string, doc any) (string, error) {
+	tokens, err := jsonpatch.ParsePointer(pointer)
+	if err != nil || len(tokens) < 2 || tokens[0] != "entries" || !strings.HasPrefix(tokens[1], entryIdPrefix) {
+		return pointer, nil
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(tokens[1], entryIdPrefix))
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid entry reference %q", jsonpatch.ErrInvalidPatch, tokens[1])
+	}
+	members, _ := doc.(map[string]any)
+	entries, _ := members["entries"].([]any)
+	for i, entry := range entries {
+		if fields, ok := entry.(map[string]any); ok && fields["id"] == float64(id) {
+			rest := pointer[len("/entries/")+len(tokens[1]):]
+			return "/entries/" + strconv.Itoa(i) + rest, nil
+		}
+	}
+	return "", fmt.Errorf("workout has no entry %d", id)
+}
+
+// applyWorkoutPatch applies body, a patch of the given media type, to doc.
+// Errors about the patch itself wrap jsonpatch.ErrInvalidPatch.
+func applyWorkoutPatch(doc any, mediaType string, body []byte) (any, error) {
+	if mediaType == mergePatchType {
+		var patch any
+		if err := json.Unmarshal(body, &patch); err != nil {
+			return nil, fmt.Errorf("%w: %v", jsonpatch.ErrInvalidPatch, err)
+		}
+		return jsonpatch.MergePatch(doc, patch), nil
+	}
+	ops, err := jsonpatch.Decode(body)
+	if err != nil {
+		return nil, err
+	}
+	for i, op := range ops {
+		if op.Path, err = resolveEntryPointer(op.Path, doc); err == nil && op.From != "" {
+			op.From, err = resolveEntryPointer(op.From, doc)
+		}
+		if err == nil {
+			doc, err = op.Apply(doc)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// patchedEntries turns the entries of a patched document back into canonical
+// entries, numbered from 1 in document order. Entries the patch left as they
+// were keep their stored values, so that converting them back and forth
+// cannot alter them; the others are normalized from their own units. Unknown
+// and repeated ids become new entries.
+func patchedEntries(stored []store.WorkoutEntry, original, patched []store.WorkoutEntry, system string) ([]store.WorkoutEntry, error) {
+	before := make(map[int][]byte, len(original))
+	canonical := make(map[int]store.WorkoutEntry, len(stored))
+	for i, entry := range original {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		before[entry.Id] = data
+		canonical[entry.Id] = stored[i]
+	}
+	seen := map[int]bool{}
+	entries := make([]store.WorkoutEntry, len(patched))
+	for i, entry := range patched {
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return nil, err
+		}
+		_, known := canonical[entry.Id]
+		switch {
+		case !known || seen[entry.Id]:
+			entry.Id = 0
+		case bytes.Equal(data, before[entry.Id]):
+			entry = copyEntries([]store.WorkoutEntry{canonical[entry.Id]})[0]
+			entry.OrderIndex = i + 1
+			entries[i] = entry
+			seen[entry.Id] = true
+			continue
+		}
+		seen[entry.Id] = true
+		entry.OrderIndex = i + 1
+		entries[i] = entry
+		if err := normalizeEntries(entries[i:i+1], system); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// HandlePatchWorkout applies a JSON Merge Patch (RFC 7396) or a JSON Patch
+// (RFC 6902), chosen by Content-Type, to the workout's title, description,
+// duration_minutes, calories_burned, tag_ids and entries. The patch applies
+// in full or not at all, and entries it does not touch are not rewritten;
+// entry order follows their position in the patched document.
+func (wh *WorkOutHandler) HandlePatchWorkout(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchType && mediaType != jsonPatchType {
+		w.Header().Set("Accept-Patch", mergePatchType+", "+jsonPatchType)
+		_ = utils.WriteJson(w, http.StatusUnsupportedMediaType, utils.Envelope{"error": fmt.Sprintf("Content-Type must be %s or %s", mergePatchType, jsonPatchType)})
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxPatchBytes)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "unable to read the patch"})
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkOutById(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if workout == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return
+	}
+	if !checkIfMatch(w, r, workout.Version, system) {
+		return
+	}
+
+	original := newWorkoutDocument(workout)
+	data, err := json.Marshal(original)
+	if err != nil {
+		wh.logger.Printf("ERROR: encoding workout document: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	var doc any
+	if err := json.Unmarshal(data, &doc); err != nil {
+		wh.logger.Printf("ERROR: decoding workout document: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	doc, err = applyWorkoutPatch(doc, mediaType, body)
+	if errors.Is(err, jsonpatch.ErrInvalidPatch) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": err.Error()})
+		return
+	}
+	var patched workoutDocument
+	if data, err = json.Marshal(doc); err == nil {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&patched)
+	}
+	if err == nil {
+		for _, entry := range patched.Entries {
+			if entry.DistanceMeters != nil {
+				err = errors.New("entries take distance and distance_unit, not distance_meters")
+				break
+			}
+		}
+	}
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": fmt.Sprintf("patched workout is invalid: %v", err)})
+		return
+	}
+	if err := validateEntries(patched.Entries); err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	entries, err := patchedEntries(workout.Entries, original.Entries, patched.Entries, inputSystem(currentUser))
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": err.Error()})
+		return
+	}
+
+	workout.Title = patched.Title
+	workout.Description = patched.Description
+	workout.DurationMinutes = patched.DurationMinutes
+	workout.TagIds = patched.TagIds
+	if workout.TagIds == nil {
+		workout.TagIds = []int{}
+	}
+	workout.Entries = entries
+	if patched.CaloriesBurned != original.CaloriesBurned {
+		workout.CaloriesBurned = patched.CaloriesBurned
+		workout.CaloriesEstimated = false
+	} else if workout.CaloriesEstimated || workout.CaloriesBurned == 0 {
+		// As with PUT, estimates follow the edits.
+		if err := wh.estimateCalories(workout, currentUser); err != nil {
+			wh.logger.Printf("ERROR: estimating calories: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+	}
+
+	err = wh.workoutStore.PatchWorkout(workout)
+	if errors.Is(err, store.ErrUnknownTag) {
+		_ = utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "tag_ids contains unknown tags"})
+		return
+	}
+	if errors.Is(err, store.ErrInvalidEntry) {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	if writeVersionError(w, r, err) {
+		return
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout not found"})
+		return
+	}
+	if err != nil {
+		wh.logger.Printf("ERROR: PatchWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to update workout"})
+		return
+	}
+	updated, err := wh.workoutStore.GetWorkOutById(id)
+	if err != nil || updated == nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	publishWorkout(wh.events, wh.logger, currentUser, realtime.EventWorkoutUpdated, updated)
+	displayWorkout(updated, system)
+	w.Header().Set("ETag", workoutETag(updated.Version, system))
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": updated})
+}
@@ -0,0 +1,144 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeWorkoutStore keeps workouts in memory and hands out copies, as the
+// database would.
+type fakeWorkoutStore struct {
+	store.WorkoutStore
+	workouts map[int64]*store.Workout
+}
+
+func newFakeWorkoutStore(workouts ...*store.Workout) *fakeWorkoutStore {
+	fs := &fakeWorkoutStore{workouts: map[int64]*store.Workout{}}
+	for _, workout := range workouts {
+		fs.workouts[int64(workout.Id)] = workout
+	}
+	return fs
+}
+
+func copyWorkout(workout *store.Workout) *store.Workout {
+	data, _ := json.Marshal(workout)
+	copied := &store.Workout{}
+	_ = json.Unmarshal(data, copied)
+	return copied
+}
+
+func (fs *fakeWorkoutStore) GetWorkoutOwner(id int64) (int, error) {
+	workout, ok := fs.workouts[id]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+	return workout.UserId, nil
+}
+
+func (fs *fakeWorkoutStore) GetWorkOutById(id int64) (*store.Workout, error) {
+	workout, ok := fs.workouts[id]
+	if !ok {
+		return nil, nil
+	}
+	return copyWorkout(workout), nil
+}
+
+func (fs *fakeWorkoutStore) PatchWorkout(workout *store.Workout) error {
+	stored, ok := fs.workouts[int64(workout.Id)]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	if stored.Version != workout.Version {
+		return store.ErrVersionConflict
+	}
+	for _, entry := range workout.Entries {
+		if err := store.ValidateEntry(entry); err != nil {
+			return err
+		}
+	}
+	patched := copyWorkout(workout)
+	patched.Version++
+	fs.workouts[int64(workout.Id)] = patched
+	return nil
+}
+
+func newTestWorkOutHandler(workouts *fakeWorkoutStore) *WorkOutHandler {
+	return NewWorkOutHandler(workouts, nil, nil, &fakePublisher{}, 100, log.New(io.Discard, "", 0))
+}
+
+func patchRequest(id string, body string) *http.Request {
+	r := httptest.NewRequest(http.MethodPatch, "/workouts/"+id, strings.NewReader(body))
+	r.Header.Set("Content-Type", mergePatchType)
+	routeContext := chi.NewRouteContext()
+	routeContext.URLParams.Add("id", id)
+	r = r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeContext))
+	return middleware.SetUser(r, &store.User{Id: 1})
+}
+
+func runWorkout() *store.Workout {
+	meters := 5000.0
+	return &store.Workout{
+		Id:             7,
+		UserId:         1,
+		Title:          "Run",
+		CaloriesBurned: 400,
+		Version:        1,
+		Entries: []store.WorkoutEntry{
+			{Id: 1, ExerciseName: "Running", Sets: 1, DurationSeconds: IntPtr(1800), DistanceMeters: &meters, DistanceUnit: "km", OrderIndex: 1},
+		},
+	}
+}
+
+// IntPtr mirrors the store test helper.
+func IntPtr(value int) *int {
+	return &value
+}
+
+func TestWorkoutDocumentShowsOneDistance(t *testing.T) {
+	document := newWorkoutDocument(runWorkout())
+	require.Len(t, document.Entries, 1)
+	assert.Nil(t, document.Entries[0].DistanceMeters)
+	assert.Equal(t, 5.0, *document.Entries[0].Distance)
+	assert.Equal(t, "km", document.Entries[0].DistanceUnit)
+}
+
+func TestHandlePatchWorkoutEntries(t *testing.T) {
+	tests := []struct {
+		name       string
+		patch      string
+		wantStatus int
+		wantMeters float64
+	}{
+		{"distance in its unit", `{"entries":[{"id":1,"exercise_name":"Running","sets":1,"duration_seconds":1800,"distance":10,"distance_unit":"km","order_index":1}]}`, http.StatusOK, 10000},
+		{"title only", `{"title":"Long Run"}`, http.StatusOK, 5000},
+		{"distance in meters", `{"entries":[{"id":1,"exercise_name":"Running","sets":1,"duration_seconds":1800,"distance_meters":8000,"order_index":1}]}`, http.StatusUnprocessableEntity, 5000},
+		{"reps and duration", `{"entries":[{"id":1,"exercise_name":"Running","sets":1,"reps":5,"duration_seconds":1800,"order_index":1}]}`, http.StatusBadRequest, 5000},
+		{"neither reps nor duration", `{"entries":[{"id":1,"exercise_name":"Running","sets":1,"order_index":1}]}`, http.StatusBadRequest, 5000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workouts := newFakeWorkoutStore(runWorkout())
+			w := httptest.NewRecorder()
+
+			newTestWorkOutHandler(workouts).HandlePatchWorkout(w, patchRequest("7", tt.patch))
+
+			assert.Equal(t, tt.wantStatus, w.Code, w.Body.String())
+			stored := workouts.workouts[7]
+			require.Len(t, stored.Entries, 1)
+			assert.InDelta(t, tt.wantMeters, *stored.Entries[0].DistanceMeters, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+func readRevisionParam(r *http.Request) (int, error) {
+	return strconv.Atoi(chi.URLParam(r, "rev"))
+}
+
+// readRevisionPair reads ?from= and ?to=; by default the latest revision is
+// compared with the one before it.
+func readRevisionPair(r *http.Request, latest int) (int, int, error) {
+	to, err := utils.ReadIntQuery(r, "to", latest)
+	if err != nil || to < 1 || to > latest {
+		return 0, 0, errors.New("to must be an existing revision")
+	}
+	from, err := utils.ReadIntQuery(r, "from", max(to-1, 1))
+	if err != nil || from < 1 || from > latest {
+		return 0, 0, errors.New("from must be an existing revision")
+	}
+	return from, to, nil
+}
+
+// HandleListRevisions lists the workout's revisions, newest first, without
+// their snapshots.
+func (wh *WorkOutHandler) HandleListRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	revisions, err := wh.workoutStore.ListRevisions(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: ListRevisions: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"revisions": revisions})
+}
+
+func (wh *WorkOutHandler) HandleGetRevision(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	rev, err := readRevisionParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid revision"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	revision, err := wh.workoutStore.GetRevision(id, rev)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetRevision: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if revision == nil {
+		_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "revision not found"})
+		return
+	}
+	displayWorkout(revision.Snapshot, system)
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"revision": revision})
+}
+
+// HandleDiffRevisions compares two revisions of the workout, e.g.
+// ?from=2&to=5, in the caller's display units.
+func (wh *WorkOutHandler) HandleDiffRevisions(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	revisions, err := wh.workoutStore.ListRevisions(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: ListRevisions: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	from, to, err := readRevisionPair(r, revisions[0].Revision)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": err.Error()})
+		return
+	}
+	var snapshots [2]*store.Workout
+	for i, rev := range []int{from, to} {
+		revision, err := wh.workoutStore.GetRevision(id, rev)
+		if err != nil || revision == nil {
+			wh.logger.Printf("ERROR: GetRevision: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		displayWorkout(revision.Snapshot, system)
+		snapshots[i] = revision.Snapshot
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"from": from,
+		"to":   to,
+		"diff": store.DiffWorkouts(snapshots[0], snapshots[1]),
+	})
+}
+
+// HandleRevertWorkout rolls the workout back to a revision. The revert is
+// itself recorded as the newest revision.
+func (wh *WorkOutHandler) HandleRevertWorkout(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	rev, err := readRevisionParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid revision"})
+		return
+	}
+	if !wh.authorizeWorkout(w, r, id) {
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	version, err := wh.workoutStore.GetWorkoutVersion(id)
+	if err != nil {
+		wh.logger.Printf("ERROR: GetWorkoutVersion: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	if !checkIfMatch(w, r, version, system) {
+		return
+	}
+	if err := wh.workoutStore.RevertWorkout(id, rev, version); err != nil {
+		if writeVersionError(w, r, err) {
+			return
+		}
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "revision not found"})
+			return
+		}
+		wh.logger.Printf("ERROR: RevertWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to revert workout"})
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkOutById(id)
+	if err != nil || workout == nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	publishWorkout(wh.events, wh.logger, middleware.GetUser(r), realtime.EventWorkoutUpdated, workout)
+	displayWorkout(workout, system)
+	w.Header().Set("ETag", workoutETag(workout.Version, system))
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+// HandleListTrash pages through the user's deleted workouts, most recently
+// deleted first. They stay restorable until the trash is purged.
+func (wh *WorkOutHandler) HandleListTrash(w http.ResponseWriter, r *http.Request) {
+	limit, err := utils.ReadIntQuery(r, "limit", defaultWorkoutPageSize)
+	if err != nil || limit < 1 || limit > maxWorkoutPageSize {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "limit must be between 1 and 100"})
+		return
+	}
+	offset, err := utils.ReadIntQuery(r, "offset", 0)
+	if err != nil || offset < 0 {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "offset cannot be negative"})
+		return
+	}
+	list, err := wh.workoutStore.ListTrashedWorkouts(middleware.GetUser(r).Id, limit, offset)
+	if err != nil {
+		wh.logger.Printf("ERROR: ListTrashedWorkouts: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{
+		"workouts": list.Workouts,
+		"total":    list.Total,
+		"limit":    limit,
+		"offset":   offset,
+	})
+}
+
+func (wh *WorkOutHandler) HandleRestoreWorkout(w http.ResponseWriter, r *http.Request) {
+	id, err := utils.ReadIdParam(r)
+	if err != nil {
+		_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "invalid workout id"})
+		return
+	}
+	system, ok := readDisplaySystem(w, r)
+	if !ok {
+		return
+	}
+	currentUser := middleware.GetUser(r)
+	if err := wh.workoutStore.RestoreWorkout(id, currentUser.Id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			_ = utils.WriteJson(w, http.StatusNotFound, utils.Envelope{"error": "workout is not in the trash"})
+			return
+		}
+		wh.logger.Printf("ERROR: RestoreWorkout: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "failed to restore workout"})
+		return
+	}
+	workout, err := wh.workoutStore.GetWorkOutById(id)
+	if err != nil || workout == nil {
+		wh.logger.Printf("ERROR: GetWorkOutById: %v", err)
+		_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+		return
+	}
+	publishWorkout(wh.events, wh.logger, currentUser, realtime.EventWorkoutRestored, workout)
+	displayWorkout(workout, system)
+	w.Header().Set("ETag", workoutETag(workout.Version, system))
+	_ = utils.WriteJson(w, http.StatusOK, utils.Envelope{"workout": workout})
+}
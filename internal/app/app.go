@@ -1,28 +1,85 @@
 package app
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"time"
 
 	"github.com/Numeez/go-zenith/internal/api"
 	"github.com/Numeez/go-zenith/internal/middleware"
+	"github.com/Numeez/go-zenith/internal/realtime"
 	"github.com/Numeez/go-zenith/internal/store"
 	"github.com/Numeez/go-zenith/migrations"
 )
 
+// Config holds the settings main reads from its flags.
+type Config struct {
+	// SessionIdleTimeout is how long an open workout session may go without
+	// activity before it expires.
+	SessionIdleTimeout time.Duration
+	// TrashRetention is how long deleted workouts can be restored before
+	// they are purged.
+	TrashRetention time.Duration
+	// RequireIfMatch makes workout writes fail with 428 unless they carry
+	// an If-Match header.
+	RequireIfMatch bool
+	// MaxBatchOperations is the most operations one batch request may hold.
+	MaxBatchOperations int
+	// IdempotencyWindow is how long the response to a write sent with an
+	// Idempotency-Key is replayed to retries.
+	IdempotencyWindow time.Duration
+}
+
 type Application struct {
-	Logger         *log.Logger
-	WorkOutHandler *api.WorkOutHandler
-	UserHandler    *api.UserHandler
-	TokenHandler   *api.TokenHandler
-	Middleware     middleware.UserMiddleware
-	DB             *sql.DB
+	Logger             *log.Logger
+	WorkOutHandler     *api.WorkOutHandler
+	UserHandler        *api.UserHandler
+	TokenHandler       *api.TokenHandler
+	StatsHandler       *api.StatsHandler
+	TemplateHandler    *api.TemplateHandler
+	ProgramHandler     *api.ProgramHandler
+	ExerciseHandler    *api.ExerciseHandler
+	MeasurementHandler *api.MeasurementHandler
+	TagHandler         *api.TagHandler
+	SessionHandler     *api.SessionHandler
+	EventHandler       *api.EventHandler
+	GoalHandler        *api.GoalHandler
+	SyncHandler        *api.SyncHandler
+	Events             *realtime.Hub
+	Middleware         middleware.UserMiddleware
+	Idempotency        middleware.IdempotencyMiddleware
+	DB                 *sql.DB
+	Config             Config
+	sessionStore       store.SessionStore
+	workoutStore       store.WorkoutStore
+	idempotencyStore   store.IdempotencyStore
 }
 
-func NewApplication() (*Application, error) {
+// Validate rejects settings the server cannot run with.
+func (config Config) Validate() error {
+	if config.MaxBatchOperations < 1 {
+		return fmt.Errorf("max batch operations must be at least 1, got %d", config.MaxBatchOperations)
+	}
+	if config.TrashRetention <= 0 {
+		return fmt.Errorf("trash retention must be positive, got %s", config.TrashRetention)
+	}
+	if config.SessionIdleTimeout <= 0 {
+		return fmt.Errorf("session idle timeout must be positive, got %s", config.SessionIdleTimeout)
+	}
+	if config.IdempotencyWindow <= 0 {
+		return fmt.Errorf("idempotency window must be positive, got %s", config.IdempotencyWindow)
+	}
+	return nil
+}
+
+func NewApplication(config Config) (*Application, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
 	logger := log.New(os.Stdout, "", log.Ldate|log.Ltime)
 	db, err := store.Open()
 	if err != nil {
@@ -36,22 +93,142 @@ func NewApplication() (*Application, error) {
 	workoutStore := store.NewPostgresWorkoutStore(db)
 	userStore := store.NewPostgresUserStore(db)
 	tokenStore := store.NewPostgresTokenStore(db)
-	workOutHandler := api.NewWorkOutHandler(workoutStore, logger)
+	templateStore := store.NewPostgresTemplateStore(db)
+	programStore := store.NewPostgresProgramStore(db)
+	exerciseStore := store.NewPostgresExerciseStore(db)
+	measurementStore := store.NewPostgresMeasurementStore(db)
+	tagStore := store.NewPostgresTagStore(db)
+	sessionStore := store.NewPostgresSessionStore(db)
+	goalStore := store.NewPostgresGoalStore(db)
+	syncStore := store.NewPostgresSyncStore(db)
+	idempotencyStore := store.NewPostgresIdempotencyStore(db)
+	events := realtime.NewHub(realtime.DefaultHistory, realtime.DefaultBuffer)
+	workOutHandler := api.NewWorkOutHandler(workoutStore, exerciseStore, measurementStore, events, config.MaxBatchOperations, logger)
 	userHandler := api.NewUserHandler(userStore, logger)
 	tokenHandler := api.NewTokenHandler(tokenStore, userStore, logger)
+	statsHandler := api.NewStatsHandler(workoutStore, logger)
+	templateHandler := api.NewTemplateHandler(templateStore, workoutStore, events, logger)
+	programHandler := api.NewProgramHandler(programStore, templateStore, workoutStore, logger)
+	exerciseHandler := api.NewExerciseHandler(exerciseStore, workoutStore, logger)
+	measurementHandler := api.NewMeasurementHandler(measurementStore, workoutStore, logger)
+	tagHandler := api.NewTagHandler(tagStore, logger)
+	sessionHandler := api.NewSessionHandler(sessionStore, templateStore, exerciseStore, measurementStore, events, logger)
+	eventHandler := api.NewEventHandler(events, logger)
+	goalHandler := api.NewGoalHandler(goalStore, logger)
+	syncHandler := api.NewSyncHandler(syncStore, workoutStore, templateStore, measurementStore, exerciseStore, events, logger)
 	userMiddleWare := middleware.UserMiddleware{
 		UserStore: userStore,
 	}
 	return &Application{
-		Logger:         logger,
-		WorkOutHandler: workOutHandler,
-		UserHandler:    userHandler,
-		TokenHandler:   tokenHandler,
-		Middleware:     userMiddleWare,
-		DB:             db,
+		Logger:             logger,
+		WorkOutHandler:     workOutHandler,
+		UserHandler:        userHandler,
+		TokenHandler:       tokenHandler,
+		StatsHandler:       statsHandler,
+		TemplateHandler:    templateHandler,
+		ProgramHandler:     programHandler,
+		ExerciseHandler:    exerciseHandler,
+		MeasurementHandler: measurementHandler,
+		TagHandler:         tagHandler,
+		SessionHandler:     sessionHandler,
+		EventHandler:       eventHandler,
+		GoalHandler:        goalHandler,
+		SyncHandler:        syncHandler,
+		Events:             events,
+		Middleware:         userMiddleWare,
+		Idempotency:        middleware.IdempotencyMiddleware{Store: idempotencyStore, Window: config.IdempotencyWindow, Logger: logger},
+		DB:                 db,
+		Config:             config,
+		sessionStore:       sessionStore,
+		workoutStore:       workoutStore,
+		idempotencyStore:   idempotencyStore,
 	}, nil
 }
 
+// ExpireIdleSessions expires abandoned workout sessions once a minute until
+// ctx is done.
+func (app *Application) ExpireIdleSessions(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		expired, err := app.sessionStore.ExpireIdleSessions(time.Now().Add(-app.Config.SessionIdleTimeout))
+		if err != nil {
+			app.Logger.Printf("ERROR: ExpireIdleSessions: %v", err)
+		}
+		for _, session := range expired {
+			if err := app.Events.Publish(session.UserId, realtime.EventSessionExpired, map[string]any{"id": session.Id}); err != nil {
+				app.Logger.Printf("ERROR: publishing %s: %v", realtime.EventSessionExpired, err)
+			}
+		}
+		if len(expired) > 0 {
+			app.Logger.Printf("expired %d idle workout sessions", len(expired))
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeTrash permanently deletes workouts that have been in the trash longer
+// than the retention period, once an hour until ctx is done.
+func (app *Application) PurgeTrash(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		purged, err := app.workoutStore.PurgeTrash(time.Now().Add(-app.Config.TrashRetention))
+		if err != nil {
+			app.Logger.Printf("ERROR: PurgeTrash: %v", err)
+		}
+		if purged > 0 {
+			app.Logger.Printf("purged %d workouts from the trash", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// PurgeIdempotencyKeys deletes the responses kept for Idempotency-Key
+// retries once they are older than the window, once an hour until ctx is
+// done.
+func (app *Application) PurgeIdempotencyKeys(ctx context.Context) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+	for {
+		purged, err := app.idempotencyStore.PurgeKeys(time.Now().Add(-app.Config.IdempotencyWindow))
+		if err != nil {
+			app.Logger.Printf("ERROR: PurgeKeys: %v", err)
+		}
+		if purged > 0 {
+			app.Logger.Printf("purged %d idempotency keys", purged)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// EvictIdleStreams frees the event history of users who have had no
+// connection for the retention period, once a minute until ctx is done.
+func (app *Application) EvictIdleStreams(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		app.Events.Evict(time.Now().Add(-realtime.DefaultRetention))
+	}
+}
+
 func (app *Application) HealthCheck(w http.ResponseWriter, r *http.Request) {
 	fmt.Fprintf(w, "Server is running\n")
 }
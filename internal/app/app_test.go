@@ -0,0 +1,45 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func validConfig() Config {
+	return Config{
+		MaxBatchOperations: 1,
+		TrashRetention:     time.Hour,
+		SessionIdleTimeout: time.Hour,
+		IdempotencyWindow:  time.Hour,
+	}
+}
+
+func TestConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(config *Config)
+		wantErr string
+	}{
+		{name: "valid", change: func(config *Config) {}},
+		{name: "no batch operations", change: func(config *Config) { config.MaxBatchOperations = 0 }, wantErr: "max batch operations must be at least 1, got 0"},
+		{name: "negative batch operations", change: func(config *Config) { config.MaxBatchOperations = -5 }, wantErr: "max batch operations must be at least 1, got -5"},
+		{name: "no trash retention", change: func(config *Config) { config.TrashRetention = 0 }, wantErr: "trash retention must be positive, got 0s"},
+		{name: "negative trash retention", change: func(config *Config) { config.TrashRetention = -time.Hour }, wantErr: "trash retention must be positive, got -1h0m0s"},
+		{name: "no session idle timeout", change: func(config *Config) { config.SessionIdleTimeout = 0 }, wantErr: "session idle timeout must be positive, got 0s"},
+		{name: "no idempotency window", change: func(config *Config) { config.IdempotencyWindow = 0 }, wantErr: "idempotency window must be positive, got 0s"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := validConfig()
+			tt.change(&config)
+			err := config.Validate()
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
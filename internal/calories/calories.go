@@ -0,0 +1,78 @@
+package calories
+
+import (
+	"math"
+	"strings"
+
+	"github.com/Numeez/go-zenith/internal/store"
+)
+
+const (
+	// DefaultMET is used for exercises that are not in the catalog; it is
+	// roughly "vigorous resistance training".
+	DefaultMET = 5.0
+	// DefaultBodyWeightKg is used when the user has not recorded a weight.
+	DefaultBodyWeightKg = 70.0
+
+	secondsPerRep = 4
+	// Rest between sets is part of the session and already reflected in the
+	// compendium MET values for resistance training.
+	restSecondsPerSet = 60
+)
+
+// Estimate returns the kilocalories burned over a workout using
+// kcal/min = MET * 3.5 * kg / 200. Entry time comes from recorded durations
+// or, for rep-based work, from an estimate per set. When the workout's own
+// duration is longer than the entry time the gap is spread over the entries
+// in proportion to their time, since the reported duration is what the user
+// actually spent training.
+func Estimate(workout *store.Workout, mets map[string]float64, bodyWeightKg float64) int {
+	if bodyWeightKg <= 0 {
+		bodyWeightKg = DefaultBodyWeightKg
+	}
+	var entryMinutes, metMinutes float64
+	for _, entry := range workout.Entries {
+		minutes := EntryMinutes(entry)
+		met, ok := mets[strings.ToLower(entry.ExerciseName)]
+		if !ok {
+			met = DefaultMET
+		}
+		entryMinutes += minutes
+		metMinutes += met * minutes
+	}
+	sessionMinutes := float64(workout.DurationMinutes)
+	switch {
+	case entryMinutes == 0:
+		metMinutes = DefaultMET * sessionMinutes
+	case sessionMinutes > entryMinutes:
+		metMinutes *= sessionMinutes / entryMinutes
+	}
+	return int(math.Round(metMinutes * 3.5 * bodyWeightKg / 200))
+}
+
+// EntryMinutes is the time spent on an entry, preferring logged durations over
+// the per-set estimate.
+func EntryMinutes(entry store.WorkoutEntry) float64 {
+	if len(entry.SetLog) > 0 {
+		var seconds float64
+		for _, set := range entry.SetLog {
+			switch {
+			case set.DurationSeconds != nil:
+				seconds += float64(*set.DurationSeconds)
+			case set.Reps != nil:
+				seconds += float64(*set.Reps*secondsPerRep + restSecondsPerSet)
+			default:
+				seconds += restSecondsPerSet
+			}
+		}
+		return seconds / 60
+	}
+	sets := max(entry.Sets, 1)
+	switch {
+	case entry.DurationSeconds != nil:
+		return float64(sets**entry.DurationSeconds) / 60
+	case entry.Reps != nil:
+		return float64(sets*(*entry.Reps*secondsPerRep+restSecondsPerSet)) / 60
+	}
+	return float64(sets*restSecondsPerSet) / 60
+}
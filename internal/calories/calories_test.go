@@ -0,0 +1,52 @@
+package calories
+
+import (
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestEntryMinutes(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry store.WorkoutEntry
+		want  float64
+	}{
+		{"reps", store.WorkoutEntry{Sets: 3, Reps: intPtr(10)}, 5},
+		{"duration", store.WorkoutEntry{Sets: 2, DurationSeconds: intPtr(90)}, 3},
+		{"set log", store.WorkoutEntry{Sets: 2, SetLog: []store.WorkoutSet{
+			{Reps: intPtr(5)},
+			{DurationSeconds: intPtr(40)},
+		}}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.InDelta(t, tt.want, EntryMinutes(tt.entry), 0.001)
+		})
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	workout := &store.Workout{
+		DurationMinutes: 30,
+		Entries: []store.WorkoutEntry{
+			{ExerciseName: "Running", Sets: 1, DurationSeconds: intPtr(1200)},
+			{ExerciseName: "Unknown Stretch", Sets: 1, DurationSeconds: intPtr(600)},
+		},
+	}
+	mets := map[string]float64{"running": 10}
+	// (10*20 + 5*10) MET-minutes scaled from 30 entry minutes to 30 session
+	// minutes, at 80 kg: 250 * 3.5 * 80 / 200 = 350.
+	assert.Equal(t, 350, Estimate(workout, mets, 80))
+
+	workout.DurationMinutes = 60
+	assert.Equal(t, 700, Estimate(workout, mets, 80))
+
+	empty := &store.Workout{DurationMinutes: 60}
+	assert.Equal(t, 368, Estimate(empty, nil, 0))
+}
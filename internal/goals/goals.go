@@ -0,0 +1,198 @@
+// Package goals measures progress towards training goals and projects when
+// they will be reached.
+package goals
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// KindLift is a weight to lift on an exercise, e.g. squat 140 kg.
+	KindLift = "lift"
+	// KindFrequency is a number of workouts per period.
+	KindFrequency = "frequency"
+	// KindVolume is the weight moved (sets × reps × weight) per period,
+	// optionally for one exercise.
+	KindVolume = "volume"
+	// KindBodyweight is a body weight to reach, up or down.
+	KindBodyweight = "bodyweight"
+)
+
+const (
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+)
+
+const (
+	StatusInProgress = "in_progress"
+	StatusAchieved   = "achieved"
+	StatusMissed     = "missed"
+)
+
+// maxProjection bounds projections; a trend that needs longer is treated as
+// flat.
+const maxProjection = 5 * 365 * 24 * time.Hour
+
+func ValidKind(kind string) bool {
+	switch kind {
+	case KindLift, KindFrequency, KindVolume, KindBodyweight:
+		return true
+	}
+	return false
+}
+
+func ValidPeriod(period string) bool {
+	return period == PeriodWeek || period == PeriodMonth
+}
+
+// Periodic reports whether progress of the kind restarts every period.
+func Periodic(kind string) bool {
+	return kind == KindFrequency || kind == KindVolume
+}
+
+// PeriodBounds returns the week (starting Monday) or month containing now in
+// loc.
+func PeriodBounds(period string, now time.Time, loc *time.Location) (time.Time, time.Time) {
+	local := now.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	if period == PeriodMonth {
+		start := day.AddDate(0, 0, 1-day.Day())
+		return start, start.AddDate(0, 1, 0)
+	}
+	start := day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	return start, start.AddDate(0, 0, 7)
+}
+
+// Reached reports whether current has arrived at target. Only body weight can
+// be a goal to go down, reached from above when target is below start; every
+// other kind counts up.
+func Reached(kind string, start, current, target float64) bool {
+	if kind == KindBodyweight && target < start {
+		return current <= target
+	}
+	return current >= target
+}
+
+// Percent is how far current has moved from start towards target, between 0
+// and 100.
+func Percent(kind string, start, current, target float64) float64 {
+	if Reached(kind, start, current, target) {
+		return 100
+	}
+	if target == start {
+		return 0
+	}
+	percent := (current - start) / (target - start) * 100
+	return math.Round(math.Max(0, math.Min(100, percent))*10) / 10
+}
+
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+// ProjectTrend fits a least-squares line through points and returns when it
+// reaches target. It is nil without at least two points a day apart or when
+// the trend does not head towards target.
+func ProjectTrend(points []Point, target float64, now time.Time) *time.Time {
+	if len(points) < 2 || points[len(points)-1].Time.Sub(points[0].Time) < 24*time.Hour {
+		return nil
+	}
+	origin := points[0].Time
+	days := func(t time.Time) float64 { return t.Sub(origin).Hours() / 24 }
+	var sumX, sumY, sumXY, sumXX float64
+	for _, p := range points {
+		x := days(p.Time)
+		sumX += x
+		sumY += p.Value
+		sumXY += x * p.Value
+		sumXX += x * x
+	}
+	n := float64(len(points))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return nil
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	intercept := (sumY - slope*sumX) / n
+	last := points[len(points)-1].Value
+	if slope == 0 || (target-last)*slope <= 0 {
+		return nil
+	}
+	remaining := (target - (intercept + slope*days(now))) / slope
+	wait := time.Duration(math.Max(remaining, 0) * 24 * float64(time.Hour))
+	if wait > maxProjection {
+		return nil
+	}
+	at := now.Add(wait)
+	return &at
+}
+
+// ProjectPace extrapolates the pace so far in a period and returns when it
+// reaches target, or nil when that is not before the period ends.
+func ProjectPace(start, end, now time.Time, current, target float64) *time.Time {
+	elapsed := now.Sub(start)
+	if current <= 0 || elapsed <= 0 {
+		return nil
+	}
+	if current >= target {
+		return &now
+	}
+	perSecond := current / elapsed.Seconds()
+	at := now.Add(time.Duration((target - current) / perSecond * float64(time.Second)))
+	if !at.Before(end) {
+		return nil
+	}
+	return &at
+}
+
+// Target is a goal and its measured progress, independent of storage.
+type Target struct {
+	Kind       string
+	Period     string
+	Start      float64
+	Current    float64
+	Target     float64
+	Deadline   *time.Time
+	AchievedAt *time.Time
+	// History feeds the trend projection of lift and bodyweight goals.
+	History []Point
+}
+
+type Progress struct {
+	Status              string     `json:"status"`
+	PercentComplete     float64    `json:"percent_complete"`
+	ProjectedCompletion *time.Time `json:"projected_completion"`
+	PeriodStart         *time.Time `json:"period_start,omitempty"`
+	PeriodEnd           *time.Time `json:"period_end,omitempty"`
+}
+
+// Progress evaluates t at now. Periodic goals are measured against the
+// current period; a deadline is a date that ends at midnight in loc.
+func (t Target) Progress(now time.Time, loc *time.Location) Progress {
+	progress := Progress{Status: StatusInProgress}
+	if Periodic(t.Kind) {
+		start, end := PeriodBounds(t.Period, now, loc)
+		progress.PeriodStart, progress.PeriodEnd = &start, &end
+		progress.PercentComplete = Percent(t.Kind, 0, t.Current, t.Target)
+		if t.Current >= t.Target {
+			progress.Status = StatusAchieved
+		}
+		progress.ProjectedCompletion = ProjectPace(start, end, now, t.Current, t.Target)
+	} else {
+		progress.PercentComplete = Percent(t.Kind, t.Start, t.Current, t.Target)
+		if t.AchievedAt != nil {
+			progress.Status = StatusAchieved
+		} else {
+			progress.ProjectedCompletion = ProjectTrend(t.History, t.Target, now)
+		}
+	}
+	if progress.Status != StatusAchieved && t.AchievedAt == nil && t.Deadline != nil {
+		deadline := time.Date(t.Deadline.Year(), t.Deadline.Month(), t.Deadline.Day(), 0, 0, 0, 0, loc).AddDate(0, 0, 1)
+		if !now.Before(deadline) {
+			progress.Status = StatusMissed
+		}
+	}
+	return progress
+}
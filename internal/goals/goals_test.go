@@ -0,0 +1,115 @@
+package goals
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		name                   string
+		kind                   string
+		start, current, target float64
+		want                   float64
+	}{
+		{"halfway up", KindLift, 100, 120, 140, 50},
+		{"reached", KindLift, 100, 145, 140, 100},
+		{"going backwards", KindLift, 100, 90, 140, 0},
+		{"losing weight", KindBodyweight, 90, 87, 80, 30},
+		{"lost enough", KindBodyweight, 90, 79.5, 80, 100},
+		{"gaining weight", KindBodyweight, 70, 72, 80, 20},
+		{"no start", KindFrequency, 0, 1, 4, 25},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Percent(tt.kind, tt.start, tt.current, tt.target))
+		})
+	}
+}
+
+func TestReached(t *testing.T) {
+	tests := []struct {
+		name                   string
+		kind                   string
+		start, current, target float64
+		want                   bool
+	}{
+		{"lift target above start", KindLift, 100, 140, 140, true},
+		{"lift short of target", KindLift, 100, 139, 140, false},
+		{"lift target below start", KindLift, 150, 150, 140, true},
+		{"lift below a target below start", KindLift, 150, 130, 140, false},
+		{"bodyweight down", KindBodyweight, 90, 80, 80, true},
+		{"bodyweight not down yet", KindBodyweight, 90, 81, 80, false},
+		{"bodyweight up", KindBodyweight, 70, 80, 80, true},
+		{"volume", KindVolume, 0, 5000, 4000, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Reached(tt.kind, tt.start, tt.current, tt.target))
+		})
+	}
+}
+
+func TestPeriodBounds(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	// Monday 03:00 UTC is still Sunday evening in New York.
+	now := time.Date(2025, 3, 17, 3, 0, 0, 0, time.UTC)
+	start, end := PeriodBounds(PeriodWeek, now, loc)
+	assert.Equal(t, time.Date(2025, 3, 10, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2025, 3, 17, 0, 0, 0, 0, loc), end)
+
+	start, end = PeriodBounds(PeriodMonth, now, loc)
+	assert.Equal(t, time.Date(2025, 3, 1, 0, 0, 0, 0, loc), start)
+	assert.Equal(t, time.Date(2025, 4, 1, 0, 0, 0, 0, loc), end)
+}
+
+func TestProjectTrend(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	// One kilo a week, perfectly linear.
+	points := []Point{
+		{Time: start, Value: 100},
+		{Time: start.AddDate(0, 0, 7), Value: 101},
+		{Time: start.AddDate(0, 0, 14), Value: 102},
+	}
+	now := start.AddDate(0, 0, 14)
+	projected := ProjectTrend(points, 110, now)
+	require.NotNil(t, projected)
+	assert.WithinDuration(t, start.AddDate(0, 0, 70), *projected, time.Minute)
+
+	assert.Nil(t, ProjectTrend(points, 90, now), "trend heads away from the target")
+	assert.Nil(t, ProjectTrend(points[:1], 110, now))
+}
+
+func TestProjectPace(t *testing.T) {
+	start := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 0, 7)
+	now := start.AddDate(0, 0, 2)
+	projected := ProjectPace(start, end, now, 2, 4)
+	require.NotNil(t, projected)
+	assert.Equal(t, start.AddDate(0, 0, 4), *projected)
+	assert.Nil(t, ProjectPace(start, end, now, 1, 4), "pace is too slow for this week")
+	assert.Nil(t, ProjectPace(start, end, now, 0, 4))
+}
+
+func TestTargetProgress(t *testing.T) {
+	now := time.Date(2025, 6, 2, 12, 0, 0, 0, time.UTC)
+	deadline := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+
+	lift := Target{Kind: KindLift, Start: 100, Current: 130, Target: 140, Deadline: &deadline}
+	progress := lift.Progress(now, time.UTC)
+	assert.Equal(t, StatusMissed, progress.Status)
+	assert.Equal(t, 75.0, progress.PercentComplete)
+
+	achievedAt := deadline.AddDate(0, 0, -3)
+	lift.AchievedAt = &achievedAt
+	assert.Equal(t, StatusAchieved, lift.Progress(now, time.UTC).Status)
+
+	weekly := Target{Kind: KindFrequency, Period: PeriodWeek, Current: 4, Target: 4}
+	progress = weekly.Progress(now, time.UTC)
+	assert.Equal(t, StatusAchieved, progress.Status)
+	assert.Equal(t, time.Date(2025, 6, 2, 0, 0, 0, 0, time.UTC), *progress.PeriodStart)
+}
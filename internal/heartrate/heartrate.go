@@ -0,0 +1,220 @@
+package heartrate
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	ModePercentMax = "percent_max"
+	ModeKarvonen   = "karvonen"
+
+	DefaultMaxHeartRate     = 190
+	DefaultRestingHeartRate = 60
+
+	// Gaps longer than this between samples are treated as sensor dropouts and
+	// do not count towards any zone.
+	maxSampleGap = 30 * time.Second
+)
+
+// zoneBounds are the lower bounds of the five zones as a fraction of max HR
+// (or of heart-rate reserve for the Karvonen model).
+var zoneBounds = [5]float64{0.5, 0.6, 0.7, 0.8, 0.9}
+
+type Sample struct {
+	Time time.Time `json:"time"`
+	BPM  int       `json:"bpm"`
+}
+
+type Settings struct {
+	MaxHeartRate     int    `json:"max_heart_rate"`
+	RestingHeartRate int    `json:"resting_heart_rate"`
+	ZoneModel        string `json:"zone_model"`
+}
+
+type Zone struct {
+	Zone    int     `json:"zone"`
+	MinBPM  int     `json:"min_bpm"`
+	MaxBPM  int     `json:"max_bpm"`
+	Seconds float64 `json:"seconds"`
+}
+
+type Analysis struct {
+	AvgBPM           int     `json:"avg_bpm"`
+	MaxBPM           int     `json:"max_bpm"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	BelowZoneSeconds float64 `json:"below_zone_seconds"`
+	Zones            []Zone  `json:"zones"`
+	TRIMP            float64 `json:"trimp"`
+}
+
+// Summary aggregates analyses over a period.
+type Summary struct {
+	Workouts         int     `json:"workouts"`
+	AvgBPM           int     `json:"avg_bpm"`
+	MaxBPM           int     `json:"max_bpm"`
+	DurationSeconds  float64 `json:"duration_seconds"`
+	BelowZoneSeconds float64 `json:"below_zone_seconds"`
+	Zones            []Zone  `json:"zones"`
+	TRIMP            float64 `json:"trimp"`
+}
+
+var ErrCorruptSamples = errors.New("heartrate: corrupt sample encoding")
+
+// WithDefaults fills in population defaults for settings the user has not
+// configured yet.
+func (s Settings) WithDefaults() Settings {
+	if s.MaxHeartRate <= 0 {
+		s.MaxHeartRate = DefaultMaxHeartRate
+	}
+	if s.RestingHeartRate <= 0 || s.RestingHeartRate >= s.MaxHeartRate {
+		s.RestingHeartRate = DefaultRestingHeartRate
+	}
+	if s.ZoneModel != ModeKarvonen {
+		s.ZoneModel = ModePercentMax
+	}
+	return s
+}
+
+func (s Settings) Zones() []Zone {
+	s = s.WithDefaults()
+	zones := make([]Zone, len(zoneBounds))
+	bpmAt := func(fraction float64) int {
+		if s.ZoneModel == ModeKarvonen {
+			return int(math.Round(float64(s.RestingHeartRate) + fraction*float64(s.MaxHeartRate-s.RestingHeartRate)))
+		}
+		return int(math.Round(fraction * float64(s.MaxHeartRate)))
+	}
+	for i, lower := range zoneBounds {
+		zones[i] = Zone{Zone: i + 1, MinBPM: bpmAt(lower), MaxBPM: s.MaxHeartRate}
+		if i+1 < len(zoneBounds) {
+			zones[i].MaxBPM = bpmAt(zoneBounds[i+1]) - 1
+		}
+	}
+	return zones
+}
+
+// Encode stores samples compactly: a start time plus, per sample, the varint
+// of the seconds since the previous sample and the zig-zag varint of the BPM
+// change. Typical one-second recordings take two bytes per sample.
+func Encode(samples []Sample) (time.Time, []byte) {
+	if len(samples) == 0 {
+		return time.Time{}, nil
+	}
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Time.Before(sorted[j].Time) })
+
+	start := sorted[0].Time.Truncate(time.Second)
+	buf := make([]byte, 0, len(sorted)*2)
+	previousTime, previousBPM := start, 0
+	for _, sample := range sorted {
+		at := sample.Time.Truncate(time.Second)
+		buf = binary.AppendUvarint(buf, uint64(at.Sub(previousTime)/time.Second))
+		buf = binary.AppendVarint(buf, int64(sample.BPM-previousBPM))
+		previousTime, previousBPM = at, sample.BPM
+	}
+	return start, buf
+}
+
+func Decode(start time.Time, data []byte) ([]Sample, error) {
+	var samples []Sample
+	at, bpm := start, 0
+	for len(data) > 0 {
+		delta, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, ErrCorruptSamples
+		}
+		data = data[n:]
+		change, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, ErrCorruptSamples
+		}
+		data = data[n:]
+		at = at.Add(time.Duration(delta) * time.Second)
+		bpm += int(change)
+		samples = append(samples, Sample{Time: at, BPM: bpm})
+	}
+	return samples, nil
+}
+
+// Analyze attributes the time between consecutive samples to the zone of the
+// earlier sample and computes Banister's TRIMP over the same intervals.
+func Analyze(samples []Sample, settings Settings) Analysis {
+	settings = settings.WithDefaults()
+	analysis := Analysis{Zones: settings.Zones()}
+	if len(samples) == 0 {
+		return analysis
+	}
+	reserve := float64(settings.MaxHeartRate - settings.RestingHeartRate)
+	var weightedSum float64
+	for i, sample := range samples {
+		if sample.BPM > analysis.MaxBPM {
+			analysis.MaxBPM = sample.BPM
+		}
+		if i+1 == len(samples) {
+			break
+		}
+		gap := samples[i+1].Time.Sub(sample.Time)
+		if gap <= 0 || gap > maxSampleGap {
+			continue
+		}
+		seconds := gap.Seconds()
+		analysis.DurationSeconds += seconds
+		weightedSum += float64(sample.BPM) * seconds
+
+		zoned := false
+		for z := len(analysis.Zones) - 1; z >= 0; z-- {
+			if sample.BPM >= analysis.Zones[z].MinBPM {
+				analysis.Zones[z].Seconds += seconds
+				zoned = true
+				break
+			}
+		}
+		if !zoned {
+			analysis.BelowZoneSeconds += seconds
+		}
+
+		ratio := math.Min(1, math.Max(0, (float64(sample.BPM)-float64(settings.RestingHeartRate))/reserve))
+		analysis.TRIMP += seconds / 60 * ratio * 0.64 * math.Exp(1.92*ratio)
+	}
+	if analysis.DurationSeconds > 0 {
+		analysis.AvgBPM = int(math.Round(weightedSum / analysis.DurationSeconds))
+	} else {
+		analysis.AvgBPM = samples[0].BPM
+	}
+	analysis.TRIMP = math.Round(analysis.TRIMP*10) / 10
+	return analysis
+}
+
+// Combine sums analyses that were computed with the same settings.
+func Combine(analyses []Analysis, settings Settings) *Summary {
+	if len(analyses) == 0 {
+		return nil
+	}
+	summary := &Summary{Zones: settings.Zones()}
+	var weightedSum float64
+	for _, analysis := range analyses {
+		summary.Workouts++
+		summary.DurationSeconds += analysis.DurationSeconds
+		summary.BelowZoneSeconds += analysis.BelowZoneSeconds
+		summary.TRIMP += analysis.TRIMP
+		weightedSum += float64(analysis.AvgBPM) * analysis.DurationSeconds
+		if analysis.MaxBPM > summary.MaxBPM {
+			summary.MaxBPM = analysis.MaxBPM
+		}
+		for i := range summary.Zones {
+			if i < len(analysis.Zones) {
+				summary.Zones[i].Seconds += analysis.Zones[i].Seconds
+			}
+		}
+	}
+	if summary.DurationSeconds > 0 {
+		summary.AvgBPM = int(math.Round(weightedSum / summary.DurationSeconds))
+	}
+	summary.TRIMP = math.Round(summary.TRIMP*10) / 10
+	return summary
+}
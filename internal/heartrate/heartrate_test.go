@@ -0,0 +1,65 @@
+package heartrate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	start := time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: start.Add(2 * time.Second), BPM: 118},
+		{Time: start, BPM: 120},
+		{Time: start.Add(1 * time.Second), BPM: 121},
+		{Time: start.Add(65 * time.Second), BPM: 165},
+	}
+	encodedStart, data := Encode(samples)
+	assert.Equal(t, start, encodedStart)
+	assert.LessOrEqual(t, len(data), 9)
+
+	decoded, err := Decode(encodedStart, data)
+	require.NoError(t, err)
+	require.Len(t, decoded, 4)
+	assert.Equal(t, Sample{Time: start, BPM: 120}, decoded[0])
+	assert.Equal(t, Sample{Time: start.Add(2 * time.Second), BPM: 118}, decoded[2])
+	assert.Equal(t, Sample{Time: start.Add(65 * time.Second), BPM: 165}, decoded[3])
+
+	_, err = Decode(start, []byte{0x80})
+	assert.ErrorIs(t, err, ErrCorruptSamples)
+}
+
+func TestZones(t *testing.T) {
+	percentMax := Settings{MaxHeartRate: 200, RestingHeartRate: 50}.Zones()
+	assert.Equal(t, Zone{Zone: 1, MinBPM: 100, MaxBPM: 119}, percentMax[0])
+	assert.Equal(t, Zone{Zone: 5, MinBPM: 180, MaxBPM: 200}, percentMax[4])
+
+	karvonen := Settings{MaxHeartRate: 200, RestingHeartRate: 50, ZoneModel: ModeKarvonen}.Zones()
+	assert.Equal(t, Zone{Zone: 1, MinBPM: 125, MaxBPM: 139}, karvonen[0])
+	assert.Equal(t, 185, karvonen[4].MinBPM)
+}
+
+func TestAnalyze(t *testing.T) {
+	start := time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)
+	samples := []Sample{
+		{Time: start, BPM: 90},
+		{Time: start.Add(10 * time.Second), BPM: 150},
+		{Time: start.Add(20 * time.Second), BPM: 185},
+		// A five minute gap is a dropout and is not attributed to any zone.
+		{Time: start.Add(320 * time.Second), BPM: 140},
+	}
+	analysis := Analyze(samples, Settings{MaxHeartRate: 200, RestingHeartRate: 50})
+	assert.Equal(t, 185, analysis.MaxBPM)
+	assert.Equal(t, 120, analysis.AvgBPM)
+	assert.Equal(t, 20.0, analysis.DurationSeconds)
+	assert.Equal(t, 10.0, analysis.BelowZoneSeconds)
+	assert.Equal(t, 10.0, analysis.Zones[2].Seconds)
+	assert.Greater(t, analysis.TRIMP, 0.0)
+
+	summary := Combine([]Analysis{analysis, analysis}, Settings{MaxHeartRate: 200, RestingHeartRate: 50})
+	assert.Equal(t, 2, summary.Workouts)
+	assert.Equal(t, 20.0, summary.Zones[2].Seconds)
+	assert.InDelta(t, analysis.TRIMP*2, summary.TRIMP, 0.1)
+}
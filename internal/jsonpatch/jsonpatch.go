@@ -0,0 +1,321 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+const (
+	OpAdd     = "add"
+	OpRemove  = "remove"
+	OpReplace = "replace"
+	OpMove    = "move"
+	OpCopy    = "copy"
+	OpTest    = "test"
+)
+
+// ErrInvalidPatch is wrapped by every error about the patch document itself,
+// as opposed to a well-formed patch that cannot be applied.
+var ErrInvalidPatch = errors.New("invalid patch")
+
+// Operation is one step of an RFC 6902 JSON Patch. Value is nil when the
+// member is absent and "null" when it is the JSON null.
+type Operation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// Decode reads a JSON Patch and checks that every operation has the members
+// it needs.
+func Decode(data []byte) ([]Operation, error) {
+	var ops []Operation
+	if err := json.Unmarshal(data, &ops); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	for i, op := range ops {
+		switch op.Op {
+		case OpAdd, OpReplace, OpTest:
+			if op.Value == nil {
+				return nil, fmt.Errorf("%w: operation %d needs a value", ErrInvalidPatch, i)
+			}
+		case OpMove, OpCopy:
+			if op.From == "" {
+				return nil, fmt.Errorf("%w: operation %d needs a from", ErrInvalidPatch, i)
+			}
+			if _, err := ParsePointer(op.From); err != nil {
+				return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+			}
+		case OpRemove:
+		default:
+			return nil, fmt.Errorf("%w: operation %d has unknown op %q", ErrInvalidPatch, i, op.Op)
+		}
+		if _, err := ParsePointer(op.Path); err != nil {
+			return nil, fmt.Errorf("%w: operation %d: %v", ErrInvalidPatch, i, err)
+		}
+	}
+	return ops, nil
+}
+
+// ParsePointer splits an RFC 6901 JSON Pointer into its unescaped reference
+// tokens. The empty pointer refers to the whole document.
+func ParsePointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return nil, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("pointer %q must start with /", pointer)
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// Apply runs the operations in order on doc, a value decoded by
+// encoding/json, and returns the patched document. doc may be modified even
+// when an operation fails, so callers wanting all-or-nothing semantics should
+// discard it on error.
+func Apply(doc any, ops []Operation) (any, error) {
+	for i, op := range ops {
+		var err error
+		if doc, err = op.Apply(doc); err != nil {
+			return nil, fmt.Errorf("operation %d (%s %s): %w", i, op.Op, op.Path, err)
+		}
+	}
+	return doc, nil
+}
+
+// Apply runs the single operation on doc.
+func (op Operation) Apply(doc any) (any, error) {
+	path, err := ParsePointer(op.Path)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+	}
+	var value any
+	if op.Value != nil {
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+	}
+	switch op.Op {
+	case OpAdd:
+		return add(doc, path, value)
+	case OpRemove:
+		doc, _, err := remove(doc, path)
+		return doc, err
+	case OpReplace:
+		if len(path) == 0 {
+			return value, nil
+		}
+		doc, _, err := remove(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case OpMove, OpCopy:
+		from, err := ParsePointer(op.From)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidPatch, err)
+		}
+		if op.Op == OpCopy {
+			value, err := get(doc, from)
+			if err != nil {
+				return nil, err
+			}
+			return add(doc, path, deepCopy(value))
+		}
+		if len(path) > len(from) && slices.Equal(path[:len(from)], from) {
+			return nil, errors.New("cannot move a value into one of its children")
+		}
+		doc, value, err := remove(doc, from)
+		if err != nil {
+			return nil, err
+		}
+		return add(doc, path, value)
+	case OpTest:
+		current, err := get(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !reflect.DeepEqual(current, value) {
+			return nil, errors.New("test failed")
+		}
+		return doc, nil
+	}
+	return nil, fmt.Errorf("%w: unknown op %q", ErrInvalidPatch, op.Op)
+}
+
+// arrayIndex resolves token within an array of length n. "-" names the
+// position after the last element and is only valid when appending.
+func arrayIndex(token string, n int, appending bool) (int, error) {
+	if token == "-" && appending {
+		return n, nil
+	}
+	if token == "" || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	i, err := strconv.Atoi(token)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	limit := n - 1
+	if appending {
+		limit = n
+	}
+	if i > limit {
+		return 0, fmt.Errorf("array index %d is out of range", i)
+	}
+	return i, nil
+}
+
+func get(doc any, path []string) (any, error) {
+	for _, token := range path {
+		switch node := doc.(type) {
+		case map[string]any:
+			child, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			doc = child
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = node[i]
+		default:
+			return nil, fmt.Errorf("cannot find %q in a scalar", token)
+		}
+	}
+	return doc, nil
+}
+
+// update walks to the container of the last token of path, which must not be
+// empty, and replaces it with what change returns. Containers are rebuilt on
+// the way back because changing an array may reallocate it.
+func update(doc any, path []string, change func(container any, token string) (any, error)) (any, error) {
+	if len(path) == 1 {
+		return change(doc, path[0])
+	}
+	switch node := doc.(type) {
+	case map[string]any:
+		child, ok := node[path[0]]
+		if !ok {
+			return nil, fmt.Errorf("member %q does not exist", path[0])
+		}
+		updated, err := update(child, path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[path[0]] = updated
+		return node, nil
+	case []any:
+		i, err := arrayIndex(path[0], len(node), false)
+		if err != nil {
+			return nil, err
+		}
+		updated, err := update(node[i], path[1:], change)
+		if err != nil {
+			return nil, err
+		}
+		node[i] = updated
+		return node, nil
+	}
+	return nil, fmt.Errorf("cannot find %q in a scalar", path[0])
+}
+
+func add(doc any, path []string, value any) (any, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	return update(doc, path, func(container any, token string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			node[token] = value
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), true)
+			if err != nil {
+				return nil, err
+			}
+			return slices.Insert(node, i, value), nil
+		}
+		return nil, fmt.Errorf("cannot add %q to a scalar", token)
+	})
+}
+
+// remove returns the document without the value at path, and that value.
+func remove(doc any, path []string) (any, any, error) {
+	if len(path) == 0 {
+		return nil, nil, errors.New("cannot remove the whole document")
+	}
+	var removed any
+	doc, err := update(doc, path, func(container any, token string) (any, error) {
+		switch node := container.(type) {
+		case map[string]any:
+			value, ok := node[token]
+			if !ok {
+				return nil, fmt.Errorf("member %q does not exist", token)
+			}
+			removed = value
+			delete(node, token)
+			return node, nil
+		case []any:
+			i, err := arrayIndex(token, len(node), false)
+			if err != nil {
+				return nil, err
+			}
+			removed = node[i]
+			return slices.Delete(node, i, i+1), nil
+		}
+		return nil, fmt.Errorf("cannot remove %q from a scalar", token)
+	})
+	return doc, removed, err
+}
+
+func deepCopy(value any) any {
+	switch node := value.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(node))
+		for key, child := range node {
+			out[key] = deepCopy(child)
+		}
+		return out
+	case []any:
+		out := make([]any, len(node))
+		for i, child := range node {
+			out[i] = deepCopy(child)
+		}
+		return out
+	}
+	return value
+}
+
+// MergePatch applies an RFC 7396 merge patch: objects are merged member by
+// member, null removes a member, and anything else replaces the target.
+func MergePatch(target, patch any) any {
+	members, ok := patch.(map[string]any)
+	if !ok {
+		return patch
+	}
+	out, ok := target.(map[string]any)
+	if !ok {
+		out = map[string]any{}
+	}
+	for key, value := range members {
+		if value == nil {
+			delete(out, key)
+			continue
+		}
+		out[key] = MergePatch(out[key], value)
+	}
+	return out
+}
@@ -0,0 +1,140 @@
+package jsonpatch
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func decodeJSON(t *testing.T, data string) any {
+	t.Helper()
+	var value any
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+func TestParsePointer(t *testing.T) {
+	tokens, err := ParsePointer("/entries/0/a~1b/m~0n")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"entries", "0", "a/b", "m~n"}, tokens)
+
+	tokens, err = ParsePointer("")
+	require.NoError(t, err)
+	assert.Empty(t, tokens)
+
+	_, err = ParsePointer("entries")
+	assert.Error(t, err)
+}
+
+func TestDecode(t *testing.T) {
+	_, err := Decode([]byte(`[{"op":"add","path":"/title","value":null}]`))
+	assert.NoError(t, err)
+
+	for _, patch := range []string{
+		`{"op":"add"}`,
+		`[{"op":"add","path":"/title"}]`,
+		`[{"op":"move","path":"/a"}]`,
+		`[{"op":"frobnicate","path":"/a"}]`,
+		`[{"op":"remove","path":"a"}]`,
+	} {
+		_, err := Decode([]byte(patch))
+		assert.ErrorIs(t, err, ErrInvalidPatch, patch)
+	}
+}
+
+func TestApply(t *testing.T) {
+	doc := `{"title":"Leg day","tags":[1,2],"entries":[{"id":1,"name":"squat"},{"id":2,"name":"lunge"},{"id":3,"name":"calf raise"}]}`
+	tests := []struct {
+		name  string
+		patch string
+		want  string
+	}{
+		{
+			name:  "replace a member",
+			patch: `[{"op":"replace","path":"/title","value":"Legs"}]`,
+			want:  `{"title":"Legs","tags":[1,2],"entries":[{"id":1,"name":"squat"},{"id":2,"name":"lunge"},{"id":3,"name":"calf raise"}]}`,
+		},
+		{
+			name:  "append and insert",
+			patch: `[{"op":"add","path":"/tags/-","value":3},{"op":"add","path":"/tags/0","value":0}]`,
+			want:  `{"title":"Leg day","tags":[0,1,2,3],"entries":[{"id":1,"name":"squat"},{"id":2,"name":"lunge"},{"id":3,"name":"calf raise"}]}`,
+		},
+		{
+			name:  "remove an element",
+			patch: `[{"op":"remove","path":"/entries/1"}]`,
+			want:  `{"title":"Leg day","tags":[1,2],"entries":[{"id":1,"name":"squat"},{"id":3,"name":"calf raise"}]}`,
+		},
+		{
+			name:  "move an element to the end",
+			patch: `[{"op":"move","from":"/entries/0","path":"/entries/-"}]`,
+			want:  `{"title":"Leg day","tags":[1,2],"entries":[{"id":2,"name":"lunge"},{"id":3,"name":"calf raise"},{"id":1,"name":"squat"}]}`,
+		},
+		{
+			name:  "replace the last element",
+			patch: `[{"op":"replace","path":"/entries/2/name","value":"seated calf raise"}]`,
+			want:  `{"title":"Leg day","tags":[1,2],"entries":[{"id":1,"name":"squat"},{"id":2,"name":"lunge"},{"id":3,"name":"seated calf raise"}]}`,
+		},
+		{
+			name:  "copy is independent of its source",
+			patch: `[{"op":"copy","from":"/entries/0","path":"/entries/-"},{"op":"replace","path":"/entries/3/name","value":"front squat"}]`,
+			want:  `{"title":"Leg day","tags":[1,2],"entries":[{"id":1,"name":"squat"},{"id":2,"name":"lunge"},{"id":3,"name":"calf raise"},{"id":1,"name":"front squat"}]}`,
+		},
+		{
+			name:  "passing test",
+			patch: `[{"op":"test","path":"/entries/0","value":{"name":"squat","id":1}}]`,
+			want:  doc,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ops, err := Decode([]byte(tt.patch))
+			require.NoError(t, err)
+			got, err := Apply(decodeJSON(t, doc), ops)
+			require.NoError(t, err)
+			assert.Equal(t, decodeJSON(t, tt.want), got)
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	doc := `{"title":"Leg day","tags":[1,2]}`
+	for _, patch := range []string{
+		`[{"op":"test","path":"/title","value":"Arms"}]`,
+		`[{"op":"replace","path":"/notes","value":""}]`,
+		`[{"op":"remove","path":"/tags/2"}]`,
+		`[{"op":"add","path":"/tags/01","value":5}]`,
+		`[{"op":"add","path":"/tags/3","value":5}]`,
+		`[{"op":"add","path":"/missing/a","value":5}]`,
+		`[{"op":"move","from":"/tags","path":"/tags/0"}]`,
+		`[{"op":"remove","path":""}]`,
+	} {
+		ops, err := Decode([]byte(patch))
+		require.NoError(t, err, patch)
+		_, err = Apply(decodeJSON(t, doc), ops)
+		assert.Error(t, err, patch)
+		assert.NotErrorIs(t, err, ErrInvalidPatch, patch)
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		target, patch, want string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+	}
+	for _, tt := range tests {
+		got := MergePatch(decodeJSON(t, tt.target), decodeJSON(t, tt.patch))
+		assert.Equal(t, decodeJSON(t, tt.want), got, "%s + %s", tt.target, tt.patch)
+	}
+}
@@ -0,0 +1,109 @@
+package measurements
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+const DateLayout = "2006-01-02"
+
+type Point struct {
+	Time  time.Time
+	Value float64
+}
+
+type TrendPoint struct {
+	Date          string  `json:"date"`
+	Value         float64 `json:"value"`
+	MovingAverage float64 `json:"moving_average"`
+}
+
+type Trend struct {
+	WindowDays int          `json:"window_days"`
+	Points     []TrendPoint `json:"points"`
+	// Change is the difference between the last and first moving average.
+	Change *float64 `json:"change"`
+	Min    *float64 `json:"min"`
+	Max    *float64 `json:"max"`
+}
+
+type day struct {
+	date  time.Time
+	value float64
+}
+
+// daily averages points that fall on the same calendar day in loc, so several
+// weigh-ins on one morning count once.
+func daily(points []Point, loc *time.Location) []day {
+	sums := make(map[time.Time]float64)
+	counts := make(map[time.Time]int)
+	for _, p := range points {
+		t := p.Time.In(loc)
+		date := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		sums[date] += p.Value
+		counts[date]++
+	}
+	days := make([]day, 0, len(sums))
+	for date, sum := range sums {
+		days = append(days, day{date: date, value: sum / float64(counts[date])})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].date.Before(days[j].date) })
+	return days
+}
+
+// Compute builds the trend of a metric with a trailing moving average over the
+// last windowDays calendar days. Days without a measurement are skipped rather
+// than interpolated, so the average is over the days that were measured.
+func Compute(points []Point, windowDays int, loc *time.Location) Trend {
+	if windowDays < 1 {
+		windowDays = 1
+	}
+	trend := Trend{WindowDays: windowDays, Points: []TrendPoint{}}
+	days := daily(points, loc)
+	start := 0
+	var sum float64
+	for i, d := range days {
+		sum += d.value
+		for days[start].date.AddDate(0, 0, windowDays).Compare(d.date) <= 0 {
+			sum -= days[start].value
+			start++
+		}
+		trend.Points = append(trend.Points, TrendPoint{
+			Date:          d.date.Format(DateLayout),
+			Value:         round(d.value),
+			MovingAverage: round(sum / float64(i-start+1)),
+		})
+		if trend.Min == nil || d.value < *trend.Min {
+			v := round(d.value)
+			trend.Min = &v
+		}
+		if trend.Max == nil || d.value > *trend.Max {
+			v := round(d.value)
+			trend.Max = &v
+		}
+	}
+	if len(trend.Points) > 0 {
+		change := round(trend.Points[len(trend.Points)-1].MovingAverage - trend.Points[0].MovingAverage)
+		trend.Change = &change
+	}
+	return trend
+}
+
+// Scale converts every value of the trend, e.g. into another unit. convert
+// must be linear so that the change converts like the values.
+func (t *Trend) Scale(convert func(float64) float64) {
+	for i := range t.Points {
+		t.Points[i].Value = round(convert(t.Points[i].Value))
+		t.Points[i].MovingAverage = round(convert(t.Points[i].MovingAverage))
+	}
+	for _, v := range []*float64{t.Change, t.Min, t.Max} {
+		if v != nil {
+			*v = round(convert(*v))
+		}
+	}
+}
+
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
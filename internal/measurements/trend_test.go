@@ -0,0 +1,44 @@
+package measurements
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompute(t *testing.T) {
+	start := time.Date(2025, 3, 1, 7, 0, 0, 0, time.UTC)
+	points := []Point{
+		{Time: start, Value: 80},
+		{Time: start.Add(2 * time.Hour), Value: 81},
+		{Time: start.AddDate(0, 0, 1), Value: 80},
+		{Time: start.AddDate(0, 0, 3), Value: 79},
+		{Time: start.AddDate(0, 0, 10), Value: 78},
+	}
+	trend := Compute(points, 7, time.UTC)
+	require.Len(t, trend.Points, 4)
+	assert.Equal(t, TrendPoint{Date: "2025-03-01", Value: 80.5, MovingAverage: 80.5}, trend.Points[0])
+	assert.Equal(t, 80.25, trend.Points[1].MovingAverage)
+	assert.Equal(t, 79.83, trend.Points[2].MovingAverage)
+	// The seven days ending on March 11 start after the March 4 weigh-in.
+	assert.Equal(t, 78.0, trend.Points[3].MovingAverage)
+	assert.Equal(t, -2.5, *trend.Change)
+	assert.Equal(t, 78.0, *trend.Min)
+	assert.Equal(t, 80.5, *trend.Max)
+}
+
+func TestComputeUsesLocalDays(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	points := []Point{
+		{Time: time.Date(2025, 3, 2, 2, 0, 0, 0, time.UTC), Value: 80},
+		{Time: time.Date(2025, 3, 1, 20, 0, 0, 0, time.UTC), Value: 82},
+	}
+	trend := Compute(points, 7, loc)
+	require.Len(t, trend.Points, 1)
+	assert.Equal(t, "2025-03-01", trend.Points[0].Date)
+
+	assert.Empty(t, Compute(nil, 7, time.UTC).Points)
+}
@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/Numeez/go-zenith/internal/utils"
+)
+
+const (
+	maxIdempotencyKeyLength = 255
+	// maxIdempotentBodyBytes is the largest body any route takes, that of an
+	// activity file import.
+	maxIdempotentBodyBytes = 20 << 20
+	// abandonedAfter is how long a request may hold its key without
+	// completing, longer than the server's write timeout, before a retry may
+	// take the key over.
+	abandonedAfter = time.Minute
+)
+
+// IdempotencyMiddleware lets clients retry writes safely. A POST, PUT, PATCH
+// or DELETE sent with an Idempotency-Key header runs once per user and key;
+// retries within Window get the stored response back.
+type IdempotencyMiddleware struct {
+	Store  store.IdempotencyStore
+	Window time.Duration
+	Logger *log.Logger
+}
+
+// fingerprint identifies a request by its method, target, the headers that
+// change what the body means or whether it applies, and the body, so a key
+// cannot be reused for a different request.
+func fingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	_, _ = io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	_, _ = io.WriteString(hash, "Content-Type: "+r.Header.Get("Content-Type")+"\n")
+	_, _ = io.WriteString(hash, "If-Match: "+r.Header.Get("If-Match")+"\n")
+	_, _ = hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response as it is written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Idempotent has to run after Authenticate. A retry with a different
+// payload fails with 422, and one that arrives while the first request is
+// still running fails with 409 and Retry-After. Server errors are not kept,
+// so retrying after one runs the request again.
+func (im *IdempotencyMiddleware) Idempotent(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+		key := r.Header.Get("Idempotency-Key")
+		user := GetUser(r)
+		if key == "" || user.IsAnonymous() {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "Idempotency-Key must be at most 255 characters"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxIdempotentBodyBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				_ = utils.WriteJson(w, http.StatusRequestEntityTooLarge, utils.Envelope{"error": "request body is too large"})
+				return
+			}
+			_ = utils.WriteJson(w, http.StatusBadRequest, utils.Envelope{"error": "unable to read the request body"})
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		now := time.Now()
+		digest := fingerprint(r, body)
+		reservation, existing, err := im.Store.ReserveKey(user.Id, key, digest, now.Add(-im.Window), now.Add(-abandonedAfter))
+		if err != nil {
+			im.Logger.Printf("ERROR: ReserveKey: %v", err)
+			_ = utils.WriteJson(w, http.StatusInternalServerError, utils.Envelope{"error": "internal server error"})
+			return
+		}
+		switch {
+		case existing == nil:
+		case existing.Fingerprint != digest:
+			_ = utils.WriteJson(w, http.StatusUnprocessableEntity, utils.Envelope{"error": "Idempotency-Key was already used for a different request"})
+			return
+		case !existing.Completed:
+			w.Header().Set("Retry-After", "1")
+			_ = utils.WriteJson(w, http.StatusConflict, utils.Envelope{"error": "a request with this Idempotency-Key is still in progress"})
+			return
+		default:
+			for name, values := range existing.Header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(existing.StatusCode)
+			_, _ = w.Write(existing.Body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+		if rec.status >= http.StatusInternalServerError {
+			err = im.Store.ReleaseKey(user.Id, key, reservation)
+		} else {
+			err = im.Store.CompleteKey(user.Id, key, reservation, rec.status, w.Header().Clone(), rec.body.Bytes())
+		}
+		if err != nil {
+			im.Logger.Printf("ERROR: storing the response for Idempotency-Key: %v", err)
+		}
+	})
+}
@@ -0,0 +1,218 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIdempotencyStore holds keys in memory and, like the database, ignores
+// completions and releases made with an outdated reservation.
+type fakeIdempotencyStore struct {
+	records      map[string]*store.IdempotencyRecord
+	reservations map[string]string
+	issued       int
+}
+
+func newFakeIdempotencyStore() *fakeIdempotencyStore {
+	return &fakeIdempotencyStore{records: map[string]*store.IdempotencyRecord{}, reservations: map[string]string{}}
+}
+
+func (fs *fakeIdempotencyStore) ReserveKey(userID int, key, fingerprint string, expiredBefore, abandonedBefore time.Time) (string, *store.IdempotencyRecord, error) {
+	id := fmt.Sprintf("%d/%s", userID, key)
+	if record, ok := fs.records[id]; ok {
+		return "", record, nil
+	}
+	fs.issued++
+	reservation := fmt.Sprintf("r%d", fs.issued)
+	fs.records[id] = &store.IdempotencyRecord{Fingerprint: fingerprint}
+	fs.reservations[id] = reservation
+	return reservation, nil, nil
+}
+
+func (fs *fakeIdempotencyStore) CompleteKey(userID int, key, reservation string, status int, header http.Header, body []byte) error {
+	id := fmt.Sprintf("%d/%s", userID, key)
+	if fs.reservations[id] != reservation {
+		return nil
+	}
+	record := fs.records[id]
+	record.Completed, record.StatusCode, record.Header, record.Body = true, status, header, body
+	return nil
+}
+
+func (fs *fakeIdempotencyStore) ReleaseKey(userID int, key, reservation string) error {
+	id := fmt.Sprintf("%d/%s", userID, key)
+	if fs.reservations[id] == reservation {
+		delete(fs.records, id)
+		delete(fs.reservations, id)
+	}
+	return nil
+}
+
+func (fs *fakeIdempotencyStore) PurgeKeys(createdBefore time.Time) (int64, error) {
+	return 0, nil
+}
+
+// countingHandler answers with status and counts the requests it ran.
+type countingHandler struct {
+	status int
+	runs   int
+}
+
+func (h *countingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.runs++
+	body, _ := io.ReadAll(r.Body)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(h.status)
+	_, _ = fmt.Fprintf(w, `{"run":%d,"body":%q}`, h.runs, body)
+}
+
+func idempotentRequest(key, body string, headers ...string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(body))
+	r.Header.Set("Idempotency-Key", key)
+	r.Header.Set("Content-Type", "application/json")
+	for i := 0; i+1 < len(headers); i += 2 {
+		r.Header.Set(headers[i], headers[i+1])
+	}
+	return SetUser(r, &store.User{Id: 1})
+}
+
+func newIdempotent(keys store.IdempotencyStore, next http.Handler) http.Handler {
+	im := &IdempotencyMiddleware{Store: keys, Window: time.Hour, Logger: log.New(io.Discard, "", 0)}
+	return im.Idempotent(next)
+}
+
+func TestIdempotentReplaysCompletedRequest(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newIdempotent(keys, next)
+
+	first := httptest.NewRecorder()
+	handler.ServeHTTP(first, idempotentRequest("k1", `{"title":"Legs"}`))
+	retry := httptest.NewRecorder()
+	handler.ServeHTTP(retry, idempotentRequest("k1", `{"title":"Legs"}`))
+
+	assert.Equal(t, 1, next.runs)
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+}
+
+func TestIdempotentRejectsDifferentRequest(t *testing.T) {
+	tests := []struct {
+		name  string
+		retry *http.Request
+	}{
+		{"other body", idempotentRequest("k1", `{"title":"Arms"}`)},
+		{"other content type", idempotentRequest("k1", `{"title":"Legs"}`, "Content-Type", "application/merge-patch+json")},
+		{"other If-Match", idempotentRequest("k1", `{"title":"Legs"}`, "If-Match", `"3-metric"`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newFakeIdempotencyStore()
+			next := &countingHandler{status: http.StatusCreated}
+			handler := newIdempotent(keys, next)
+			handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("k1", `{"title":"Legs"}`))
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, tt.retry)
+
+			assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+			assert.Equal(t, 1, next.runs)
+		})
+	}
+}
+
+func TestIdempotentConflictsWhileInProgress(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	next := &countingHandler{status: http.StatusCreated}
+	handler := newIdempotent(keys, next)
+	request := idempotentRequest("k1", `{"title":"Legs"}`)
+	_, _, err := keys.ReserveKey(1, "k1", fingerprint(request, []byte(`{"title":"Legs"}`)), time.Time{}, time.Time{})
+	require.NoError(t, err)
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, request)
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Zero(t, next.runs)
+}
+
+func TestIdempotentReleasesKeyOnServerError(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	next := &countingHandler{status: http.StatusInternalServerError}
+	handler := newIdempotent(keys, next)
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("k1", `{"title":"Legs"}`))
+	assert.Empty(t, keys.records, "the key is released")
+
+	next.status = http.StatusCreated
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest("k1", `{"title":"Legs"}`))
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 2, next.runs)
+	assert.True(t, keys.records["1/k1"].Completed)
+}
+
+func TestIdempotentKeepsClientErrors(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	next := &countingHandler{status: http.StatusBadRequest}
+	handler := newIdempotent(keys, next)
+
+	handler.ServeHTTP(httptest.NewRecorder(), idempotentRequest("k1", `{}`))
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, idempotentRequest("k1", `{}`))
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, 1, next.runs)
+}
+
+func TestIdempotentCompletesOnlyItsOwnReservation(t *testing.T) {
+	keys := newFakeIdempotencyStore()
+	// The request runs so long that a retry takes the key over.
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys.reservations["1/k1"] = "taken over"
+		w.WriteHeader(http.StatusCreated)
+	})
+
+	newIdempotent(keys, next).ServeHTTP(httptest.NewRecorder(), idempotentRequest("k1", `{}`))
+
+	assert.False(t, keys.records["1/k1"].Completed)
+}
+
+func TestIdempotentPassesThrough(t *testing.T) {
+	tests := []struct {
+		name    string
+		request *http.Request
+	}{
+		{"read", SetUser(httptest.NewRequest(http.MethodGet, "/workouts", nil), &store.User{Id: 1})},
+		{"no key", SetUser(httptest.NewRequest(http.MethodPost, "/workouts", strings.NewReader(`{}`)), &store.User{Id: 1})},
+		{"anonymous", SetUser(idempotentRequest("k1", `{}`), store.AnonymousUser)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := newFakeIdempotencyStore()
+			next := &countingHandler{status: http.StatusOK}
+			handler := newIdempotent(keys, next)
+
+			handler.ServeHTTP(httptest.NewRecorder(), tt.request)
+			handler.ServeHTTP(httptest.NewRecorder(), tt.request)
+
+			assert.Equal(t, 2, next.runs)
+			assert.Empty(t, keys.records)
+		})
+	}
+}
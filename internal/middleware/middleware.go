@@ -64,6 +64,18 @@ func (um *UserMiddleware) Authenticate(next http.Handler) http.Handler {
 
 }
 
+// TokenFromQuery lets clients that cannot set headers, such as EventSource and
+// browser WebSockets, send their bearer token as ?access_token=. It has to run
+// before Authenticate.
+func (um *UserMiddleware) TokenFromQuery(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
 func (um *UserMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := GetUser(r)
@@ -74,3 +86,15 @@ func (um *UserMiddleware) RequireUser(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	})
 }
+
+// RequireIfMatch rejects writes that do not say which version they were
+// based on, so a client cannot overwrite changes it never saw.
+func RequireIfMatch(next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-Match") == "" {
+			_ = utils.WriteJson(w, http.StatusPreconditionRequired, utils.Envelope{"error": "If-Match header is required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,197 @@
+package programs
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/store"
+)
+
+const DateLayout = "2006-01-02"
+
+const (
+	StatusCompleted = "completed"
+	StatusMissed    = "missed"
+	StatusPlanned   = "planned"
+)
+
+type PlannedSession struct {
+	Date        string               `json:"date"`
+	ProgramId   int                  `json:"program_id"`
+	EnrolmentId int                  `json:"enrolment_id"`
+	SessionId   int                  `json:"session_id"`
+	TemplateId  int                  `json:"template_id"`
+	Title       string               `json:"title"`
+	Week        int                  `json:"week"`
+	Deload      bool                 `json:"deload"`
+	Entries     []store.WorkoutEntry `json:"entries"`
+	Status      string               `json:"status"`
+	WorkoutId   *int                 `json:"workout_id,omitempty"`
+}
+
+type Day struct {
+	Date     string           `json:"date"`
+	Sessions []PlannedSession `json:"sessions"`
+}
+
+type Adherence struct {
+	Planned   int     `json:"planned"`
+	Completed int     `json:"completed"`
+	Missed    int     `json:"missed"`
+	Upcoming  int     `json:"upcoming"`
+	Percent   float64 `json:"percent"`
+}
+
+// CivilDate returns the calendar day of t in loc at midnight UTC.
+func CivilDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func weekSettings(program *store.Program, week int) store.ProgramWeek {
+	for _, w := range program.Weeks {
+		if w.WeekNumber == week {
+			return w
+		}
+	}
+	return store.ProgramWeek{WeekNumber: week}
+}
+
+// TargetWeight applies the program's progression rules to a template weight
+// in kg for the given 1-based week: wave percentages replace the base load,
+// the weekly increment (also kept in kg) accrues for every completed
+// non-deload week, and deload weeks scale the result down.
+func TargetWeight(program *store.Program, week int, base float64) float64 {
+	settings := weekSettings(program, week)
+	weight := base
+	if settings.IntensityPercent != nil {
+		weight = base * *settings.IntensityPercent / 100
+	}
+	progressed := 0
+	for w := 1; w < week; w++ {
+		if !weekSettings(program, w).IsDeload {
+			progressed++
+		}
+	}
+	weight += program.WeeklyIncrement * float64(progressed)
+	if settings.IsDeload {
+		weight = weight * program.DeloadPercent / 100
+	}
+	return math.Round(weight*2) / 2
+}
+
+// Plan resolves the sessions of an enrolment that fall between from and to
+// (inclusive civil dates). Templates are keyed by ID; oneRepMax is passed to
+// the template so percentage-based targets become concrete weights.
+func Plan(program *store.Program, enrolment store.ProgramEnrolment, templates map[int]*store.WorkoutTemplate, oneRepMax map[string]float64, from, to time.Time) []PlannedSession {
+	start := CivilDate(enrolment.StartDate, time.UTC)
+	var planned []PlannedSession
+	for week := 1; week <= program.DurationWeeks; week++ {
+		settings := weekSettings(program, week)
+		for _, session := range program.Sessions {
+			if session.WeekNumber != nil && *session.WeekNumber != week {
+				continue
+			}
+			date := start.AddDate(0, 0, (week-1)*7+session.DayNumber-1)
+			if date.Before(from) || date.After(to) {
+				continue
+			}
+			template, ok := templates[session.TemplateId]
+			if !ok {
+				continue
+			}
+			entries := template.NewWorkout(oneRepMax).Entries
+			for i := range entries {
+				if entries[i].Weight == nil {
+					continue
+				}
+				weight := TargetWeight(program, week, *entries[i].Weight)
+				entries[i].Weight = &weight
+			}
+			planned = append(planned, PlannedSession{
+				Date:        date.Format(DateLayout),
+				ProgramId:   program.Id,
+				EnrolmentId: enrolment.Id,
+				SessionId:   session.Id,
+				TemplateId:  session.TemplateId,
+				Title:       template.Title,
+				Week:        week,
+				Deload:      settings.IsDeload,
+				Entries:     entries,
+				Status:      StatusPlanned,
+			})
+		}
+	}
+	return planned
+}
+
+// Link matches logged workouts to planned sessions on the same local day,
+// preferring workouts started from the session's template, and marks sessions
+// before today without a workout as missed.
+func Link(sessions []PlannedSession, logged []store.WorkoutActivity, loc *time.Location, today time.Time) Adherence {
+	byDate := map[string][]store.WorkoutActivity{}
+	for _, workout := range logged {
+		date := CivilDate(workout.CreatedAt, loc).Format(DateLayout)
+		byDate[date] = append(byDate[date], workout)
+	}
+	used := map[int]bool{}
+	claim := func(session *PlannedSession, matchTemplate bool) {
+		for _, workout := range byDate[session.Date] {
+			if used[workout.WorkoutId] {
+				continue
+			}
+			if matchTemplate && (workout.TemplateId == nil || *workout.TemplateId != session.TemplateId) {
+				continue
+			}
+			used[workout.WorkoutId] = true
+			id := workout.WorkoutId
+			session.WorkoutId = &id
+			session.Status = StatusCompleted
+			return
+		}
+	}
+	for _, matchTemplate := range []bool{true, false} {
+		for i := range sessions {
+			if sessions[i].WorkoutId == nil {
+				claim(&sessions[i], matchTemplate)
+			}
+		}
+	}
+
+	todayDate := today.Format(DateLayout)
+	var adherence Adherence
+	for i := range sessions {
+		adherence.Planned++
+		switch {
+		case sessions[i].Status == StatusCompleted:
+			adherence.Completed++
+		case sessions[i].Date < todayDate:
+			sessions[i].Status = StatusMissed
+			adherence.Missed++
+		default:
+			adherence.Upcoming++
+		}
+	}
+	if due := adherence.Completed + adherence.Missed; due > 0 {
+		adherence.Percent = math.Round(float64(adherence.Completed)/float64(due)*1000) / 10
+	}
+	return adherence
+}
+
+// GroupByDay lays the sessions out on every calendar day between from and to,
+// including rest days without sessions.
+func GroupByDay(sessions []PlannedSession, from, to time.Time) []Day {
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].Date < sessions[j].Date })
+	var days []Day
+	next := 0
+	for date := from; !date.After(to); date = date.AddDate(0, 0, 1) {
+		day := Day{Date: date.Format(DateLayout), Sessions: []PlannedSession{}}
+		for next < len(sessions) && sessions[next].Date == day.Date {
+			day.Sessions = append(day.Sessions, sessions[next])
+			next++
+		}
+		days = append(days, day)
+	}
+	return days
+}
@@ -0,0 +1,82 @@
+package programs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func intPtr(value int) *int {
+	return &value
+}
+
+func TestTargetWeight(t *testing.T) {
+	program := &store.Program{
+		DurationWeeks:   4,
+		WeeklyIncrement: 2.5,
+		DeloadPercent:   60,
+		Weeks: []store.ProgramWeek{
+			{WeekNumber: 2, IntensityPercent: floatPtr(90)},
+			{WeekNumber: 4, IsDeload: true},
+		},
+	}
+	tests := []struct {
+		week int
+		want float64
+	}{
+		{week: 1, want: 100},
+		{week: 2, want: 92.5},
+		{week: 3, want: 105},
+		{week: 4, want: 64.5},
+	}
+	for _, test := range tests {
+		assert.Equal(t, test.want, TargetWeight(program, test.week, 100), "week %d", test.week)
+	}
+}
+
+func TestPlanAndLink(t *testing.T) {
+	program := &store.Program{
+		Id:            1,
+		DurationWeeks: 2,
+		DeloadPercent: 60,
+		Sessions: []store.ProgramSession{
+			{Id: 1, TemplateId: 10, DayNumber: 1},
+			{Id: 2, TemplateId: 11, WeekNumber: intPtr(2), DayNumber: 3},
+		},
+	}
+	templates := map[int]*store.WorkoutTemplate{
+		10: {Id: 10, Title: "Squat day", Entries: []store.TemplateEntry{{ExerciseName: "Squat", TargetSets: 5, RepRangeMax: intPtr(5), TargetWeight: floatPtr(100)}}},
+		11: {Id: 11, Title: "Bench day", Entries: []store.TemplateEntry{{ExerciseName: "Bench", TargetSets: 5, RepRangeMax: intPtr(5), TargetPercent1RM: floatPtr(80)}}},
+	}
+	enrolment := store.ProgramEnrolment{Id: 3, StartDate: time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC)}
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2025, 3, 31, 0, 0, 0, 0, time.UTC)
+
+	sessions := Plan(program, enrolment, templates, map[string]float64{"bench": 120}, from, to)
+	require.Len(t, sessions, 3)
+	assert.Equal(t, "2025-03-03", sessions[0].Date)
+	assert.Equal(t, "2025-03-10", sessions[1].Date)
+	assert.Equal(t, "2025-03-12", sessions[2].Date)
+	assert.Equal(t, 96.0, *sessions[2].Entries[0].Weight)
+
+	templateID := 10
+	logged := []store.WorkoutActivity{
+		{WorkoutId: 7, CreatedAt: time.Date(2025, 3, 3, 18, 0, 0, 0, time.UTC), TemplateId: &templateID},
+	}
+	adherence := Link(sessions, logged, time.UTC, time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, StatusCompleted, sessions[0].Status)
+	assert.Equal(t, StatusMissed, sessions[1].Status)
+	assert.Equal(t, StatusPlanned, sessions[2].Status)
+	assert.Equal(t, Adherence{Planned: 3, Completed: 1, Missed: 1, Upcoming: 1, Percent: 50}, adherence)
+
+	days := GroupByDay(sessions, from, to)
+	assert.Len(t, days, 31)
+	assert.Len(t, days[2].Sessions, 1)
+}
@@ -0,0 +1,268 @@
+// Package progression recommends the weight and reps for the next session of
+// an exercise from how the recent sessions went.
+package progression
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// StrategyDouble adds reps within the rep range and only adds weight once
+	// every working set reaches the top of the range.
+	StrategyDouble = "double_progression"
+	// StrategyLinear adds weight every session the target reps are hit.
+	StrategyLinear = "linear"
+	// StrategyRPE picks the weight that should land on the target RPE, based
+	// on the effort reported last time.
+	StrategyRPE = "rpe"
+)
+
+const (
+	DefaultRepMin      = 8
+	DefaultRepMax      = 12
+	DefaultTargetRPE   = 8.0
+	DefaultDeloadAfter = 3
+	// DefaultDeloadPercent is how much weight a deload takes off.
+	DefaultDeloadPercent = 10.0
+)
+
+// Set is one working set as performed. Failed marks a set the lifter did not
+// complete.
+type Set struct {
+	Reps   int
+	Weight float64
+	RPE    *float64
+	Failed bool
+}
+
+// Session is every working set of the exercise in one workout.
+type Session struct {
+	PerformedAt time.Time
+	Sets        []Set
+}
+
+// Config selects and tunes the strategy. Weights are in whatever unit the
+// sessions use; Increment is the smallest weight jump, e.g. 2.5 kg or 5 lb.
+type Config struct {
+	Strategy      string
+	RepMin        int
+	RepMax        int
+	TargetRPE     float64
+	Increment     float64
+	DeloadAfter   int
+	DeloadPercent float64
+}
+
+type Recommendation struct {
+	Strategy string  `json:"strategy"`
+	Weight   float64 `json:"weight"`
+	Reps     int     `json:"reps"`
+	Sets     int     `json:"sets"`
+	Deload   bool    `json:"deload"`
+	// Stalls counts the latest sessions in a row that did not improve.
+	Stalls   int       `json:"stalls"`
+	Reason   string    `json:"reason"`
+	BasedOn  time.Time `json:"based_on"`
+	Sessions int       `json:"sessions_considered"`
+}
+
+func ValidStrategy(strategy string) bool {
+	switch strategy {
+	case StrategyDouble, StrategyLinear, StrategyRPE:
+		return true
+	}
+	return false
+}
+
+// Validate fills in defaults and rejects inconsistent settings. Increment has
+// no default because it depends on the unit.
+func (c *Config) Validate() error {
+	if c.Strategy == "" {
+		c.Strategy = StrategyDouble
+	}
+	if c.RepMin == 0 && c.RepMax == 0 {
+		c.RepMin, c.RepMax = DefaultRepMin, DefaultRepMax
+	}
+	if c.RepMin == 0 {
+		c.RepMin = c.RepMax
+	}
+	if c.RepMax == 0 {
+		c.RepMax = c.RepMin
+	}
+	if c.TargetRPE == 0 {
+		c.TargetRPE = DefaultTargetRPE
+	}
+	if c.DeloadAfter == 0 {
+		c.DeloadAfter = DefaultDeloadAfter
+	}
+	if c.DeloadPercent == 0 {
+		c.DeloadPercent = DefaultDeloadPercent
+	}
+	switch {
+	case !ValidStrategy(c.Strategy):
+		return fmt.Errorf("strategy must be %s, %s or %s", StrategyDouble, StrategyLinear, StrategyRPE)
+	case c.RepMin < 1 || c.RepMax < c.RepMin || c.RepMax > 50:
+		return errors.New("rep range must be between 1 and 50 with rep_min <= rep_max")
+	case c.TargetRPE < 5 || c.TargetRPE > 10:
+		return errors.New("target_rpe must be between 5 and 10")
+	case c.Increment <= 0:
+		return errors.New("increment must be positive")
+	case c.DeloadAfter < 1:
+		return errors.New("deload_after must be at least 1")
+	case c.DeloadPercent <= 0 || c.DeloadPercent >= 50:
+		return errors.New("deload_percent must be between 0 and 50")
+	}
+	return nil
+}
+
+// top returns the heaviest weight of the session and the sets done with it.
+func (s Session) top() (float64, []Set) {
+	weight := math.Inf(-1)
+	for _, set := range s.Sets {
+		weight = math.Max(weight, set.Weight)
+	}
+	var sets []Set
+	for _, set := range s.Sets {
+		if set.Weight == weight {
+			sets = append(sets, set)
+		}
+	}
+	return weight, sets
+}
+
+func minReps(sets []Set) int {
+	reps := math.MaxInt
+	for _, set := range sets {
+		reps = min(reps, set.Reps)
+	}
+	return reps
+}
+
+func anyFailed(sets []Set, repMin int) bool {
+	for _, set := range sets {
+		if set.Failed || set.Reps < repMin {
+			return true
+		}
+	}
+	return false
+}
+
+// improved reports whether current beat previous on weight, or on reps at the
+// same weight, without failing a set.
+func improved(current, previous Session, repMin int) bool {
+	weight, sets := current.top()
+	previousWeight, previousSets := previous.top()
+	if anyFailed(sets, repMin) {
+		return false
+	}
+	if weight != previousWeight {
+		return weight > previousWeight
+	}
+	return totalReps(sets) > totalReps(previousSets)
+}
+
+func totalReps(sets []Set) int {
+	total := 0
+	for _, set := range sets {
+		total += set.Reps
+	}
+	return total
+}
+
+// Stalls counts how many of the latest sessions in a row did not improve on
+// the one before. Sessions are newest first.
+func Stalls(sessions []Session, repMin int) int {
+	stalls := 0
+	for i := 0; i+1 < len(sessions); i++ {
+		if improved(sessions[i], sessions[i+1], repMin) {
+			break
+		}
+		stalls++
+	}
+	return stalls
+}
+
+func roundTo(weight, increment float64) float64 {
+	rounded := math.Round(weight/increment) * increment
+	return math.Round(math.Max(rounded, 0)*1000) / 1000
+}
+
+// oneRepMax estimates the one-rep max with Epley, counting the reps that were
+// left in reserve.
+func oneRepMax(weight float64, reps int, rpe float64) float64 {
+	return weight * (1 + (float64(reps)+10-rpe)/30)
+}
+
+// Recommend suggests the next session from sessions, newest first, each with
+// at least one working set. It returns nil without any history.
+func Recommend(sessions []Session, config Config) *Recommendation {
+	if len(sessions) == 0 || len(sessions[0].Sets) == 0 {
+		return nil
+	}
+	last := sessions[0]
+	weight, topSets := last.top()
+	recommendation := &Recommendation{
+		Strategy: config.Strategy,
+		Weight:   weight,
+		Sets:     len(last.Sets),
+		Stalls:   Stalls(sessions, config.RepMin),
+		BasedOn:  last.PerformedAt,
+		Sessions: len(sessions),
+	}
+	if recommendation.Stalls >= config.DeloadAfter {
+		recommendation.Deload = true
+		recommendation.Weight = roundTo(weight*(1-config.DeloadPercent/100), config.Increment)
+		recommendation.Reps = config.RepMin
+		recommendation.Reason = fmt.Sprintf("no progress in %d sessions; deload by %g%% and build back up", recommendation.Stalls, config.DeloadPercent)
+		return recommendation
+	}
+	failed := anyFailed(topSets, config.RepMin)
+	reps := minReps(topSets)
+	switch config.Strategy {
+	case StrategyLinear:
+		recommendation.Reps = config.RepMax
+		if failed || reps < config.RepMax {
+			recommendation.Reason = fmt.Sprintf("repeat the weight until every set reaches %d reps", config.RepMax)
+		} else {
+			recommendation.Weight = roundTo(weight+config.Increment, config.Increment)
+			recommendation.Reason = "every set hit its reps; add weight"
+		}
+		return recommendation
+	case StrategyRPE:
+		if rpe := topRPE(topSets); rpe != nil && !failed {
+			target := oneRepMax(weight, reps, *rpe) / (1 + (float64(config.RepMax)+10-config.TargetRPE)/30)
+			recommendation.Weight = roundTo(target, config.Increment)
+			recommendation.Reps = config.RepMax
+			recommendation.Reason = fmt.Sprintf("last top set was %d reps at RPE %g; aim for RPE %g", reps, *rpe, config.TargetRPE)
+			return recommendation
+		}
+		// Without reported effort the double progression rules apply.
+	}
+	switch {
+	case failed:
+		recommendation.Reps = config.RepMin
+		recommendation.Reason = fmt.Sprintf("missed reps last time; repeat the weight aiming for %d reps", config.RepMin)
+	case reps >= config.RepMax:
+		recommendation.Weight = roundTo(weight+config.Increment, config.Increment)
+		recommendation.Reps = config.RepMin
+		recommendation.Reason = fmt.Sprintf("every set reached %d reps; add weight and restart at %d", config.RepMax, config.RepMin)
+	default:
+		recommendation.Reps = reps + 1
+		recommendation.Reason = fmt.Sprintf("add a rep to every set until reaching %d", config.RepMax)
+	}
+	return recommendation
+}
+
+// topRPE is the hardest effort reported on the sets, or nil when none was.
+func topRPE(sets []Set) *float64 {
+	var rpe *float64
+	for _, set := range sets {
+		if set.RPE != nil && (rpe == nil || *set.RPE > *rpe) {
+			rpe = set.RPE
+		}
+	}
+	return rpe
+}
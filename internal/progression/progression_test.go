@@ -0,0 +1,165 @@
+package progression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type performed struct {
+	weight float64
+	reps   []int
+}
+
+// sessions builds newest-first history three days apart, with one set per
+// listed reps at the session's weight.
+func sessions(start time.Time, history ...performed) []Session {
+	var out []Session
+	for i, h := range history {
+		session := Session{PerformedAt: start.AddDate(0, 0, -3*i)}
+		for _, reps := range h.reps {
+			session.Sets = append(session.Sets, Set{Reps: reps, Weight: h.weight})
+		}
+		out = append(out, session)
+	}
+	return out
+}
+
+func TestConfigValidate(t *testing.T) {
+	config := Config{Increment: 2.5}
+	require.NoError(t, config.Validate())
+	assert.Equal(t, StrategyDouble, config.Strategy)
+	assert.Equal(t, DefaultRepMin, config.RepMin)
+	assert.Equal(t, DefaultRepMax, config.RepMax)
+
+	config = Config{RepMax: 5, Increment: 2.5}
+	require.NoError(t, config.Validate())
+	assert.Equal(t, 5, config.RepMin)
+
+	assert.Error(t, (&Config{Strategy: "random", Increment: 2.5}).Validate())
+	assert.Error(t, (&Config{RepMin: 10, RepMax: 8, Increment: 2.5}).Validate())
+	assert.Error(t, (&Config{}).Validate())
+	assert.Error(t, (&Config{TargetRPE: 11, Increment: 2.5}).Validate())
+}
+
+func TestRecommend(t *testing.T) {
+	now := time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)
+	rpe := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name    string
+		config  Config
+		history []Session
+		weight  float64
+		reps    int
+		deload  bool
+	}{
+		{
+			name:    "double progression adds a rep",
+			config:  Config{RepMin: 8, RepMax: 12},
+			history: sessions(now, performed{60, []int{10, 9, 9}}, performed{60, []int{9, 9, 8}}),
+			weight:  60, reps: 10,
+		},
+		{
+			name:    "double progression adds weight at the top of the range",
+			config:  Config{RepMin: 8, RepMax: 12},
+			history: sessions(now, performed{60, []int{12, 12, 12}}, performed{60, []int{12, 11, 11}}),
+			weight:  62.5, reps: 8,
+		},
+		{
+			name:    "missed reps repeat the weight",
+			config:  Config{RepMin: 8, RepMax: 12},
+			history: sessions(now, performed{62.5, []int{8, 7, 6}}, performed{60, []int{12, 12, 12}}),
+			weight:  62.5, reps: 8,
+		},
+		{
+			name:    "linear adds weight when every set hits",
+			config:  Config{Strategy: StrategyLinear, RepMin: 5, RepMax: 5},
+			history: sessions(now, performed{100, []int{5, 5, 5}}, performed{97.5, []int{5, 5, 5}}),
+			weight:  102.5, reps: 5,
+		},
+		{
+			name:    "linear repeats after a short set",
+			config:  Config{Strategy: StrategyLinear, RepMin: 5, RepMax: 5},
+			history: sessions(now, performed{100, []int{5, 5, 4}}, performed{97.5, []int{5, 5, 5}}),
+			weight:  100, reps: 5,
+		},
+		{
+			name:   "rpe raises the weight after an easy set",
+			config: Config{Strategy: StrategyRPE, RepMin: 5, RepMax: 5, TargetRPE: 8},
+			history: []Session{{PerformedAt: now, Sets: []Set{
+				{Reps: 5, Weight: 100, RPE: rpe(6)},
+			}}},
+			weight: 105, reps: 5,
+		},
+		{
+			name:   "rpe lowers the weight after a grinder",
+			config: Config{Strategy: StrategyRPE, RepMin: 5, RepMax: 5, TargetRPE: 8},
+			history: []Session{{PerformedAt: now, Sets: []Set{
+				{Reps: 5, Weight: 100, RPE: rpe(10)},
+			}}},
+			weight: 95, reps: 5,
+		},
+		{
+			name:    "rpe without reported effort falls back to double progression",
+			config:  Config{Strategy: StrategyRPE, RepMin: 5, RepMax: 8},
+			history: sessions(now, performed{100, []int{6, 6}}),
+			weight:  100,
+			reps:    7,
+		},
+		{
+			name:   "failed sets count as missed",
+			config: Config{RepMin: 8, RepMax: 12},
+			history: []Session{{PerformedAt: now, Sets: []Set{
+				{Reps: 10, Weight: 60}, {Reps: 10, Weight: 60, Failed: true},
+			}}},
+			weight: 60, reps: 8,
+		},
+		{
+			name:   "stalls trigger a deload",
+			config: Config{RepMin: 5, RepMax: 5, DeloadAfter: 3},
+			history: sessions(now,
+				performed{100, []int{4, 4, 3}},
+				performed{100, []int{5, 4, 4}},
+				performed{100, []int{5, 5, 4}},
+				performed{100, []int{5, 5, 5}},
+			),
+			weight: 90, reps: 5, deload: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.Increment = 2.5
+			require.NoError(t, tt.config.Validate())
+			got := Recommend(tt.history, tt.config)
+			require.NotNil(t, got)
+			assert.Equal(t, tt.weight, got.Weight)
+			assert.Equal(t, tt.reps, got.Reps)
+			assert.Equal(t, tt.deload, got.Deload)
+			assert.Equal(t, tt.config.Strategy, got.Strategy)
+			assert.Equal(t, len(tt.history[0].Sets), got.Sets)
+			assert.NotEmpty(t, got.Reason)
+		})
+	}
+	assert.Nil(t, Recommend(nil, Config{Increment: 2.5}))
+}
+
+func TestStalls(t *testing.T) {
+	now := time.Date(2025, 5, 1, 18, 0, 0, 0, time.UTC)
+	assert.Equal(t, 0, Stalls(sessions(now, performed{60, []int{10, 10}}, performed{60, []int{10, 9}}), 8))
+	assert.Equal(t, 0, Stalls(sessions(now, performed{62.5, []int{8, 8}}, performed{60, []int{12, 12}}), 8))
+	assert.Equal(t, 1, Stalls(sessions(now, performed{60, []int{10, 9}}, performed{60, []int{10, 9}}), 8))
+	assert.Equal(t, 1, Stalls(sessions(now,
+		performed{62.5, []int{7, 7}},
+		performed{62.5, []int{8, 8}},
+		performed{60, []int{12, 12}},
+	), 8))
+	assert.Equal(t, 2, Stalls(sessions(now,
+		performed{60, []int{9, 9}},
+		performed{60, []int{10, 9}},
+		performed{60, []int{10, 9}},
+		performed{60, []int{9, 9}},
+	), 8))
+	assert.Equal(t, 0, Stalls(nil, 8))
+}
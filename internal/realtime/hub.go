@@ -0,0 +1,196 @@
+// Package realtime fans out changes to every connected client of a user over
+// Server-Sent Events or WebSocket.
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultHistory is how many recent events are kept per user so a client
+	// can resume after reconnecting.
+	DefaultHistory = 256
+	// DefaultBuffer is how many events may queue for one connection before it
+	// is dropped as too slow.
+	DefaultBuffer = 64
+	// DefaultRetention is how long the history of a user with no connections
+	// is kept for a client to resume from.
+	DefaultRetention = 10 * time.Minute
+)
+
+// ErrHistoryGap is returned when a client resumes from an event that is no
+// longer retained, e.g. after a server restart. It has to reload its state.
+var ErrHistoryGap = errors.New("events since the given id are no longer available")
+
+// Event types published by the API.
+const (
+	EventWorkoutCreated  = "workout.created"
+	EventWorkoutUpdated  = "workout.updated"
+	EventWorkoutDeleted  = "workout.deleted"
+	EventWorkoutRestored = "workout.restored"
+	EventSessionStarted  = "session.started"
+	EventSessionUpdated  = "session.updated"
+	EventSessionFinished = "session.finished"
+	EventSessionExpired  = "session.expired"
+	EventSessionDeleted  = "session.deleted"
+	// EventReset tells a client that events were lost and it has to reload.
+	EventReset = "reset"
+)
+
+type Event struct {
+	ID   uint64          `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Time time.Time       `json:"time"`
+}
+
+// Subscription receives a user's events on C until it is closed, either by
+// Close or by the hub when the connection falls too far behind.
+type Subscription struct {
+	C          <-chan Event
+	c          chan Event
+	hub        *Hub
+	userID     int
+	overflowed bool
+}
+
+// Overflowed reports, once C is closed, whether the hub dropped the
+// subscription because its buffer was full.
+func (s *Subscription) Overflowed() bool {
+	return s.overflowed
+}
+
+func (s *Subscription) Close() {
+	s.hub.mu.Lock()
+	defer s.hub.mu.Unlock()
+	s.hub.remove(s)
+}
+
+type userStream struct {
+	// Every event of the user with an id above since is in events.
+	since  uint64
+	events []Event
+	subs   map[*Subscription]struct{}
+	// lastUsed is when an event was last published, or a connection last
+	// opened or closed.
+	lastUsed time.Time
+}
+
+// Hub keeps the connections and recent events of every user in memory, so it
+// only reaches clients connected to the same process.
+type Hub struct {
+	mu      sync.Mutex
+	lastID  uint64
+	history int
+	buffer  int
+	users   map[int]*userStream
+}
+
+// NewHub creates a hub. Event ids start at the current time in microseconds so
+// ids handed out before a restart are always older than the new history and
+// resuming from them is reported as a gap rather than silently skipping.
+func NewHub(history, buffer int) *Hub {
+	return &Hub{
+		lastID:  uint64(time.Now().UnixMicro()),
+		history: history,
+		buffer:  buffer,
+		users:   map[int]*userStream{},
+	}
+}
+
+func (h *Hub) stream(userID int) *userStream {
+	stream, ok := h.users[userID]
+	if !ok {
+		stream = &userStream{since: h.lastID, subs: map[*Subscription]struct{}{}}
+		h.users[userID] = stream
+	}
+	stream.lastUsed = time.Now()
+	return stream
+}
+
+// Evict drops the streams of users without connections that have not been
+// used since idleBefore, and returns how many there were. A client resuming
+// from one of their events gets ErrHistoryGap.
+func (h *Hub) Evict(idleBefore time.Time) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	evicted := 0
+	for userID, stream := range h.users {
+		if len(stream.subs) == 0 && stream.lastUsed.Before(idleBefore) {
+			delete(h.users, userID)
+			evicted++
+		}
+	}
+	return evicted
+}
+
+// Publish records an event for the user and queues it on every subscription.
+// A subscription whose buffer is full is closed instead of blocking the
+// publisher; its client resumes from the last event it received.
+func (h *Hub) Publish(userID int, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.lastID++
+	event := Event{ID: h.lastID, Type: eventType, Data: payload, Time: time.Now().UTC()}
+	stream := h.stream(userID)
+	stream.events = append(stream.events, event)
+	if len(stream.events) > h.history {
+		stream.since = stream.events[0].ID
+		stream.events = append([]Event(nil), stream.events[1:]...)
+	}
+	for sub := range stream.subs {
+		select {
+		case sub.c <- event:
+		default:
+			sub.overflowed = true
+			h.remove(sub)
+		}
+	}
+	return nil
+}
+
+// Subscribe registers a connection of the user. With a non-zero lastEventID
+// the events published after it are returned to be sent first; ErrHistoryGap
+// is returned alongside a working subscription when some of them are gone.
+func (h *Hub) Subscribe(userID int, lastEventID uint64) (*Subscription, []Event, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	stream := h.stream(userID)
+	c := make(chan Event, h.buffer)
+	sub := &Subscription{C: c, c: c, hub: h, userID: userID}
+	stream.subs[sub] = struct{}{}
+	if lastEventID == 0 {
+		return sub, nil, nil
+	}
+	if lastEventID < stream.since || lastEventID > h.lastID {
+		return sub, nil, ErrHistoryGap
+	}
+	var missed []Event
+	for _, event := range stream.events {
+		if event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+	return sub, missed, nil
+}
+
+// remove must be called with h.mu held.
+func (h *Hub) remove(sub *Subscription) {
+	stream, ok := h.users[sub.userID]
+	if !ok {
+		return
+	}
+	if _, ok := stream.subs[sub]; !ok {
+		return
+	}
+	delete(stream.subs, sub)
+	close(sub.c)
+	stream.lastUsed = time.Now()
+}
@@ -0,0 +1,100 @@
+package realtime
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHubPublishSubscribe(t *testing.T) {
+	hub := NewHub(DefaultHistory, DefaultBuffer)
+	phone, _, err := hub.Subscribe(1, 0)
+	require.NoError(t, err)
+	watch, _, err := hub.Subscribe(1, 0)
+	require.NoError(t, err)
+	other, _, err := hub.Subscribe(2, 0)
+	require.NoError(t, err)
+
+	require.NoError(t, hub.Publish(1, EventWorkoutCreated, map[string]int{"id": 5}))
+	first := <-phone.C
+	assert.Equal(t, EventWorkoutCreated, first.Type)
+	assert.JSONEq(t, `{"id":5}`, string(first.Data))
+	assert.Equal(t, first, <-watch.C)
+	assert.Empty(t, other.C)
+
+	phone.Close()
+	_, open := <-phone.C
+	assert.False(t, open)
+	assert.False(t, phone.Overflowed())
+	phone.Close()
+}
+
+func TestHubResume(t *testing.T) {
+	hub := NewHub(3, DefaultBuffer)
+	for i := range 5 {
+		require.NoError(t, hub.Publish(1, EventWorkoutUpdated, i))
+	}
+	head, _, err := hub.Subscribe(1, 0)
+	require.NoError(t, err)
+	head.Close()
+
+	sub, missed, err := hub.Subscribe(1, hub.lastID-2)
+	require.NoError(t, err)
+	require.Len(t, missed, 2)
+	assert.Equal(t, hub.lastID-1, missed[0].ID)
+	assert.Equal(t, hub.lastID, missed[1].ID)
+	sub.Close()
+
+	_, missed, err = hub.Subscribe(1, hub.lastID)
+	assert.NoError(t, err)
+	assert.Empty(t, missed)
+
+	// Only three events are kept, so resuming from the first one is a gap.
+	_, _, err = hub.Subscribe(1, hub.lastID-4)
+	assert.ErrorIs(t, err, ErrHistoryGap)
+	// So is an id handed out by an earlier process.
+	_, _, err = hub.Subscribe(2, 42)
+	assert.ErrorIs(t, err, ErrHistoryGap)
+}
+
+func TestHubDropsSlowSubscriber(t *testing.T) {
+	hub := NewHub(DefaultHistory, 2)
+	slow, _, err := hub.Subscribe(1, 0)
+	require.NoError(t, err)
+	for i := range 3 {
+		require.NoError(t, hub.Publish(1, EventSessionUpdated, i))
+	}
+	received := 0
+	for range slow.C {
+		received++
+	}
+	assert.Equal(t, 2, received)
+	assert.True(t, slow.Overflowed())
+
+	_, missed, err := hub.Subscribe(1, hub.lastID-1)
+	require.NoError(t, err)
+	assert.Len(t, missed, 1, "the dropped client resumes from its last event")
+}
+
+func TestHubEvict(t *testing.T) {
+	hub := NewHub(DefaultHistory, DefaultBuffer)
+	require.NoError(t, hub.Publish(1, EventWorkoutCreated, 1))
+	first := hub.lastID
+	require.NoError(t, hub.Publish(1, EventWorkoutUpdated, 1))
+	connected, _, err := hub.Subscribe(2, 0)
+	require.NoError(t, err)
+
+	assert.Zero(t, hub.Evict(time.Now().Add(-time.Minute)), "recently used streams are kept")
+	assert.Equal(t, 1, hub.Evict(time.Now().Add(time.Minute)), "only the stream without connections goes")
+	assert.Len(t, hub.users, 1)
+
+	resumed, _, err := hub.Subscribe(1, first)
+	assert.ErrorIs(t, err, ErrHistoryGap, "the evicted history cannot be resumed")
+
+	resumed.Close()
+	connected.Close()
+	assert.Equal(t, 2, hub.Evict(time.Now().Add(time.Minute)))
+	assert.Empty(t, hub.users)
+}
@@ -0,0 +1,170 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// KeepAlive is how often an idle stream is pinged so proxies keep it open
+	// and dead clients are noticed.
+	KeepAlive    = 25 * time.Second
+	writeTimeout = 10 * time.Second
+)
+
+// sink writes events to one kind of connection.
+type sink interface {
+	send(event Event) error
+	keepAlive() error
+}
+
+// pump writes the backlog and then live events until the client goes away,
+// the subscription overflows or a write fails.
+func pump(done <-chan struct{}, sub *Subscription, backlog []Event, gap bool, s sink) error {
+	if gap {
+		if err := s.send(Event{Type: EventReset, Time: time.Now().UTC()}); err != nil {
+			return err
+		}
+	}
+	for _, event := range backlog {
+		if err := s.send(event); err != nil {
+			return err
+		}
+	}
+	ticker := time.NewTicker(KeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return nil
+			}
+			if err := s.send(event); err != nil {
+				return err
+			}
+		case <-ticker.C:
+			if err := s.keepAlive(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// LastEventID reads the id a client resumes from: the Last-Event-ID header an
+// EventSource sends on reconnect, or the last_event_id query parameter.
+func LastEventID(r *http.Request) (uint64, error) {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+type sseSink struct {
+	w  http.ResponseWriter
+	rc *http.ResponseController
+}
+
+func (s *sseSink) flush() error {
+	if err := s.rc.Flush(); err != nil {
+		return err
+	}
+	return s.rc.SetWriteDeadline(time.Time{})
+}
+
+func (s *sseSink) send(event Event) error {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if event.ID != 0 {
+		if _, err := fmt.Fprintf(s.w, "id: %d\n", event.ID); err != nil {
+			return err
+		}
+	}
+	data := event.Data
+	if len(data) == 0 {
+		data = json.RawMessage("{}")
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+func (s *sseSink) keepAlive() error {
+	_ = s.rc.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := fmt.Fprint(s.w, ": ping\n\n"); err != nil {
+		return err
+	}
+	return s.flush()
+}
+
+// ServeSSE streams the subscription as Server-Sent Events. When the hub drops
+// a slow connection the response simply ends; EventSource reconnects with
+// Last-Event-ID and resumes.
+func ServeSSE(w http.ResponseWriter, r *http.Request, sub *Subscription, backlog []Event, gap bool) error {
+	rc := http.NewResponseController(w)
+	// The server's timeouts are meant for ordinary requests.
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if _, err := fmt.Fprint(w, "retry: 2000\n\n"); err != nil {
+		return err
+	}
+	s := &sseSink{w: w, rc: rc}
+	if err := s.flush(); err != nil {
+		return err
+	}
+	return pump(r.Context().Done(), sub, backlog, gap, s)
+}
+
+type webSocketSink struct {
+	ws *WebSocket
+}
+
+func (s *webSocketSink) send(event Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.ws.WriteText(payload, writeTimeout)
+}
+
+func (s *webSocketSink) keepAlive() error {
+	return s.ws.Ping(writeTimeout)
+}
+
+// ServeWebSocket upgrades the request and streams the subscription as JSON
+// text messages. A connection dropped for being too slow is closed with
+// CloseTryAgainLater so the client reconnects with ?last_event_id=.
+func ServeWebSocket(w http.ResponseWriter, r *http.Request, sub *Subscription, backlog []Event, gap bool) error {
+	ws, err := Upgrade(w, r)
+	if err != nil {
+		return err
+	}
+	done := make(chan struct{})
+	go func() {
+		ws.ReadLoop(writeTimeout)
+		close(done)
+	}()
+	err = pump(done, sub, backlog, gap, &webSocketSink{ws: ws})
+	switch {
+	case err != nil:
+		_ = ws.Close(CloseGoingAway, "", writeTimeout)
+	case sub.Overflowed():
+		_ = ws.Close(CloseTryAgainLater, "connection too slow, reconnect", writeTimeout)
+	default:
+		_ = ws.Close(CloseNormal, "", writeTimeout)
+	}
+	<-done
+	return err
+}
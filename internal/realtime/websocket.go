@@ -0,0 +1,209 @@
+package realtime
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A minimal server side of RFC 6455: enough to push text messages, answer
+// pings and close cleanly. Messages from clients are read and discarded.
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opText  = 0x1
+	opClose = 0x8
+	opPing  = 0x9
+	opPong  = 0xA
+)
+
+// Close codes used by the stream.
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseTryAgainLater = 1013
+)
+
+const maxClientFrame = 1 << 16
+
+var errNotWebSocket = errors.New("not a websocket handshake")
+
+// IsWebSocketRequest reports whether r asks to upgrade to a WebSocket.
+func IsWebSocketRequest(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") && headerContains(r.Header, "Upgrade", "websocket")
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func acceptKey(key string) string {
+	sum := sha1.Sum([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+type WebSocket struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	mu   sync.Mutex
+}
+
+// Upgrade completes the handshake and takes over the connection. On error the
+// response has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*WebSocket, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != http.MethodGet || !IsWebSocketRequest(r) || key == "" {
+		http.Error(w, errNotWebSocket.Error(), http.StatusBadRequest)
+		return nil, errNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusUpgradeRequired)
+		return nil, errNotWebSocket
+	}
+	conn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, err
+	}
+	// The server's read and write timeouts are meant for ordinary requests.
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	_, err = fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", acceptKey(key))
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &WebSocket{conn: conn, rw: rw}, nil
+}
+
+// appendFrame appends an unmasked, unfragmented server frame.
+func appendFrame(buf []byte, opcode byte, payload []byte) []byte {
+	buf = append(buf, 0x80|opcode)
+	switch n := len(payload); {
+	case n < 126:
+		buf = append(buf, byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, 126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, 127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+	return append(buf, payload...)
+}
+
+func (ws *WebSocket) write(opcode byte, payload []byte, timeout time.Duration) error {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if err := ws.conn.SetWriteDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+	if _, err := ws.rw.Write(appendFrame(nil, opcode, payload)); err != nil {
+		return err
+	}
+	return ws.rw.Flush()
+}
+
+func (ws *WebSocket) WriteText(payload []byte, timeout time.Duration) error {
+	return ws.write(opText, payload, timeout)
+}
+
+func (ws *WebSocket) Ping(timeout time.Duration) error {
+	return ws.write(opPing, nil, timeout)
+}
+
+// Close sends a close frame with code and reason and closes the connection.
+func (ws *WebSocket) Close(code int, reason string, timeout time.Duration) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	_ = ws.write(opClose, append(payload, reason...), timeout)
+	return ws.conn.Close()
+}
+
+// readFrame reads one client frame and returns its opcode and unmasked
+// payload.
+func readFrame(r io.Reader) (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if !masked {
+		return 0, nil, errors.New("client frames must be masked")
+	}
+	if length > maxClientFrame {
+		return 0, nil, errors.New("client frame too large")
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return opcode, payload, nil
+}
+
+// ReadLoop answers pings and discards data until the client closes the
+// connection or it fails. It returns when the connection is done.
+func (ws *WebSocket) ReadLoop(timeout time.Duration) {
+	for {
+		opcode, payload, err := readFrame(ws.rw)
+		if err != nil {
+			_ = ws.conn.Close()
+			return
+		}
+		switch opcode {
+		case opPing:
+			if err := ws.write(opPong, payload, timeout); err != nil {
+				_ = ws.conn.Close()
+				return
+			}
+		case opClose:
+			_ = ws.Close(CloseNormal, "", timeout)
+			return
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package realtime
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// The example from RFC 6455, section 1.3.
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", acceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+func maskedFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	frame := []byte{0x80 | opcode, 0x80 | byte(len(payload))}
+	frame = append(frame, mask...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	return frame
+}
+
+func TestReadFrame(t *testing.T) {
+	opcode, payload, err := readFrame(bytes.NewReader(maskedFrame(opPing, []byte("hi"))))
+	require.NoError(t, err)
+	assert.Equal(t, byte(opPing), opcode)
+	assert.Equal(t, []byte("hi"), payload)
+
+	_, _, err = readFrame(bytes.NewReader(appendFrame(nil, opText, []byte("hi"))))
+	assert.Error(t, err, "unmasked client frames are rejected")
+
+	long := appendFrame(nil, opText, make([]byte, 300))
+	assert.Equal(t, []byte{0x81, 126, 0x01, 0x2C}, long[:4])
+}
+
+func TestServeWebSocket(t *testing.T) {
+	hub := NewHub(DefaultHistory, DefaultBuffer)
+	require.NoError(t, hub.Publish(1, EventWorkoutCreated, map[string]int{"id": 1}))
+	resumeFrom := hub.lastID
+	require.NoError(t, hub.Publish(1, EventWorkoutDeleted, map[string]int{"id": 1}))
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sub, backlog, err := hub.Subscribe(1, resumeFrom)
+		require.NoError(t, err)
+		defer sub.Close()
+		_ = ServeWebSocket(w, r, sub, backlog, false)
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	require.NoError(t, err)
+	defer func() {
+		_ = conn.Close()
+	}()
+	require.NoError(t, conn.SetDeadline(time.Now().Add(5*time.Second)))
+	_, err = conn.Write([]byte("GET / HTTP/1.1\r\nHost: test\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n" +
+		"Sec-WebSocket-Version: 13\r\nSec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n\r\n"))
+	require.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	response, err := http.ReadResponse(reader, nil)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusSwitchingProtocols, response.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", response.Header.Get("Sec-WebSocket-Accept"))
+
+	var header [2]byte
+	_, err = io.ReadFull(reader, header[:])
+	require.NoError(t, err)
+	assert.Equal(t, byte(0x80|opText), header[0])
+	payload := make([]byte, header[1])
+	_, err = io.ReadFull(reader, payload)
+	require.NoError(t, err)
+	var event Event
+	require.NoError(t, json.Unmarshal(payload, &event))
+	assert.Equal(t, EventWorkoutDeleted, event.Type, "only events after last_event_id are replayed")
+
+	_, err = conn.Write(maskedFrame(opClose, []byte{0x03, 0xE8}))
+	require.NoError(t, err)
+}
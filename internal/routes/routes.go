@@ -1,20 +1,109 @@
 package routes
 
 import (
+	"net/http"
+
 	"github.com/Numeez/go-zenith/internal/app"
+	"github.com/Numeez/go-zenith/internal/middleware"
 	"github.com/go-chi/chi/v5"
 )
 
 func SetupRoutes(app *app.Application) *chi.Mux {
 	router := chi.NewRouter()
+	// versioned guards writes to a workout that must name the version they
+	// replace when the server is configured to require it.
+	versioned := func(next http.HandlerFunc) http.HandlerFunc {
+		if app.Config.RequireIfMatch {
+			next = middleware.RequireIfMatch(next)
+		}
+		return app.Middleware.RequireUser(next)
+	}
 	router.Group(func(r chi.Router) {
 		r.Use(app.Middleware.Authenticate)
+		r.Use(app.Idempotency.Idempotent)
+		r.Get("/workouts", app.Middleware.RequireUser(app.WorkOutHandler.HandleListWorkouts))
+		r.Get("/workouts/last", app.Middleware.RequireUser(app.WorkOutHandler.HandleGetLastPerformance))
+		r.Get("/workouts/trash", app.Middleware.RequireUser(app.WorkOutHandler.HandleListTrash))
 		r.Get("/workouts/{id}", app.Middleware.RequireUser(app.WorkOutHandler.HandleGetWorkOutById))
 		r.Post("/workouts", app.Middleware.RequireUser(app.WorkOutHandler.HandleCreateWorkOut))
-		r.Put("/workouts/{id}", app.Middleware.RequireUser(app.WorkOutHandler.HandlerUpdateWorkoutById))
-		r.Delete("/workouts/{id}", app.Middleware.RequireUser(app.WorkOutHandler.HandlerDeleteWorkout))
+		r.Post("/workouts/batch", app.Middleware.RequireUser(app.WorkOutHandler.HandleBatchWorkouts))
+		r.Put("/workouts/{id}", versioned(app.WorkOutHandler.HandlerUpdateWorkoutById))
+		r.Patch("/workouts/{id}", versioned(app.WorkOutHandler.HandlePatchWorkout))
+		r.Delete("/workouts/{id}", versioned(app.WorkOutHandler.HandlerDeleteWorkout))
+		r.Post("/workouts/import", app.Middleware.RequireUser(app.WorkOutHandler.HandleImportWorkout))
+		r.Get("/workouts/{id}/track", app.Middleware.RequireUser(app.WorkOutHandler.HandleGetWorkoutTrack))
+		r.Post("/workouts/{id}/heart-rate", app.Middleware.RequireUser(app.WorkOutHandler.HandleUploadHeartRate))
+		r.Post("/workouts/{id}/save-as-template", app.Middleware.RequireUser(app.TemplateHandler.HandleSaveWorkoutAsTemplate))
+		r.Post("/workouts/{id}/clone", app.Middleware.RequireUser(app.WorkOutHandler.HandleCloneWorkout))
+		r.Post("/workouts/{id}/restore", app.Middleware.RequireUser(app.WorkOutHandler.HandleRestoreWorkout))
+		r.Get("/workouts/{id}/entries", app.Middleware.RequireUser(app.WorkOutHandler.HandleListEntries))
+		r.Post("/workouts/{id}/entries", versioned(app.WorkOutHandler.HandleCreateEntry))
+		r.Post("/workouts/{id}/entries/reorder", versioned(app.WorkOutHandler.HandleReorderEntries))
+		r.Get("/workouts/{id}/entries/{entryId}", app.Middleware.RequireUser(app.WorkOutHandler.HandleGetEntry))
+		r.Put("/workouts/{id}/entries/{entryId}", versioned(app.WorkOutHandler.HandleUpdateEntry))
+		r.Delete("/workouts/{id}/entries/{entryId}", versioned(app.WorkOutHandler.HandleDeleteEntry))
+		r.Get("/workouts/{id}/revisions", app.Middleware.RequireUser(app.WorkOutHandler.HandleListRevisions))
+		r.Get("/workouts/{id}/revisions/diff", app.Middleware.RequireUser(app.WorkOutHandler.HandleDiffRevisions))
+		r.Get("/workouts/{id}/revisions/{rev}", app.Middleware.RequireUser(app.WorkOutHandler.HandleGetRevision))
+		r.Post("/workouts/{id}/revisions/{rev}/revert", versioned(app.WorkOutHandler.HandleRevertWorkout))
+		r.Get("/search", app.Middleware.RequireUser(app.WorkOutHandler.HandleSearch))
+		r.Get("/sync", app.Middleware.RequireUser(app.SyncHandler.HandleGetChanges))
+		r.Post("/sync", app.Middleware.RequireUser(app.SyncHandler.HandlePushChanges))
+		r.Post("/sessions", app.Middleware.RequireUser(app.SessionHandler.HandleStartSession))
+		r.Get("/sessions/current", app.Middleware.RequireUser(app.SessionHandler.HandleGetCurrentSession))
+		r.Get("/sessions/{id}", app.Middleware.RequireUser(app.SessionHandler.HandleGetSessionById))
+		r.Delete("/sessions/{id}", app.Middleware.RequireUser(app.SessionHandler.HandleDeleteSession))
+		r.Post("/sessions/{id}/entries", app.Middleware.RequireUser(app.SessionHandler.HandleAddSessionEntry))
+		r.Post("/sessions/{id}/sets", app.Middleware.RequireUser(app.SessionHandler.HandleAddSessionSet))
+		r.Post("/sessions/{id}/rest", app.Middleware.RequireUser(app.SessionHandler.HandleStartRest))
+		r.Post("/sessions/{id}/pause", app.Middleware.RequireUser(app.SessionHandler.HandlePauseSession))
+		r.Post("/sessions/{id}/resume", app.Middleware.RequireUser(app.SessionHandler.HandleResumeSession))
+		r.Post("/sessions/{id}/finish", app.Middleware.RequireUser(app.SessionHandler.HandleFinishSession))
+		r.Get("/tags", app.Middleware.RequireUser(app.TagHandler.HandleListTags))
+		r.Post("/tags", app.Middleware.RequireUser(app.TagHandler.HandleCreateTag))
+		r.Get("/tags/{id}", app.Middleware.RequireUser(app.TagHandler.HandleGetTagById))
+		r.Put("/tags/{id}", app.Middleware.RequireUser(app.TagHandler.HandleUpdateTag))
+		r.Delete("/tags/{id}", app.Middleware.RequireUser(app.TagHandler.HandleDeleteTag))
+		r.Get("/templates", app.Middleware.RequireUser(app.TemplateHandler.HandleListTemplates))
+		r.Post("/templates", app.Middleware.RequireUser(app.TemplateHandler.HandleCreateTemplate))
+		r.Get("/templates/{id}", app.Middleware.RequireUser(app.TemplateHandler.HandleGetTemplateById))
+		r.Put("/templates/{id}", app.Middleware.RequireUser(app.TemplateHandler.HandleUpdateTemplate))
+		r.Delete("/templates/{id}", app.Middleware.RequireUser(app.TemplateHandler.HandleDeleteTemplate))
+		r.Post("/templates/{id}/start", app.Middleware.RequireUser(app.TemplateHandler.HandleStartTemplate))
+		r.Get("/programs", app.Middleware.RequireUser(app.ProgramHandler.HandleListPrograms))
+		r.Post("/programs", app.Middleware.RequireUser(app.ProgramHandler.HandleCreateProgram))
+		r.Get("/programs/{id}", app.Middleware.RequireUser(app.ProgramHandler.HandleGetProgramById))
+		r.Delete("/programs/{id}", app.Middleware.RequireUser(app.ProgramHandler.HandleDeleteProgram))
+		r.Post("/programs/{id}/enrol", app.Middleware.RequireUser(app.ProgramHandler.HandleEnrol))
+		r.Get("/users/me/schedule", app.Middleware.RequireUser(app.ProgramHandler.HandleGetSchedule))
+		r.Get("/exercises", app.Middleware.RequireUser(app.ExerciseHandler.HandleListExercises))
+		r.Get("/exercises/{id}", app.Middleware.RequireUser(app.ExerciseHandler.HandleGetExerciseById))
+		r.Get("/exercises/{id}/recommendation", app.Middleware.RequireUser(app.ExerciseHandler.HandleGetRecommendation))
+		r.Patch("/users/me", app.Middleware.RequireUser(app.UserHandler.HandleUpdateProfile))
+		r.Get("/users/me/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandleListMeasurements))
+		r.Post("/users/me/measurements", app.Middleware.RequireUser(app.MeasurementHandler.HandleCreateMeasurement))
+		r.Get("/users/me/measurements/trends", app.Middleware.RequireUser(app.MeasurementHandler.HandleGetMeasurementTrend))
+		r.Get("/users/me/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleGetMeasurementById))
+		r.Put("/users/me/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleUpdateMeasurement))
+		r.Delete("/users/me/measurements/{id}", app.Middleware.RequireUser(app.MeasurementHandler.HandleDeleteMeasurement))
+		r.Get("/users/me/goals", app.Middleware.RequireUser(app.GoalHandler.HandleListGoals))
+		r.Post("/users/me/goals", app.Middleware.RequireUser(app.GoalHandler.HandleCreateGoal))
+		r.Get("/users/me/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandleGetGoalById))
+		r.Delete("/users/me/goals/{id}", app.Middleware.RequireUser(app.GoalHandler.HandleDeleteGoal))
+		r.Get("/users/me/relative-strength", app.Middleware.RequireUser(app.MeasurementHandler.HandleGetRelativeStrength))
+		r.Put("/users/me/heart-rate-settings", app.Middleware.RequireUser(app.UserHandler.HandleUpdateHeartRateSettings))
+		r.Get("/users/me/summary", app.Middleware.RequireUser(app.StatsHandler.HandleGetSummary))
+		r.Get("/users/me/streaks", app.Middleware.RequireUser(app.StatsHandler.HandleGetStreaks))
+		r.Get("/users/me/calendar", app.Middleware.RequireUser(app.StatsHandler.HandleGetCalendar))
 
 	})
+	// Streaming clients may authenticate with ?access_token= since browsers
+	// cannot set headers on EventSource or WebSocket requests.
+	router.Group(func(r chi.Router) {
+		r.Use(app.Middleware.TokenFromQuery)
+		r.Use(app.Middleware.Authenticate)
+		r.Get("/events", app.Middleware.RequireUser(app.EventHandler.HandleStream))
+	})
 	router.Get("/health", app.HealthCheck)
 	router.Post("/users", app.UserHandler.HandlerRegisterUser)
 	router.Post("/tokens/authentication", app.TokenHandler.HandlerCreateToken)
@@ -0,0 +1,108 @@
+// Package sessions holds the state machine and timers of live workout
+// sessions, independent of how they are stored.
+package sessions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	StatusActive   = "active"
+	StatusPaused   = "paused"
+	StatusFinished = "finished"
+	StatusExpired  = "expired"
+)
+
+// Actions that move a session between states. ActionLog covers every change
+// to the session's content (entries, sets, rest timers) and leaves the status
+// unchanged.
+const (
+	ActionLog    = "log"
+	ActionPause  = "pause"
+	ActionResume = "resume"
+	ActionFinish = "finish"
+	ActionExpire = "expire"
+)
+
+var ErrInvalidTransition = errors.New("invalid session transition")
+
+// Next returns the status a session moves to when action is applied in
+// status. Finished and expired sessions are final.
+func Next(status, action string) (string, error) {
+	switch {
+	case status == StatusActive && action == ActionLog:
+		return StatusActive, nil
+	case status == StatusActive && action == ActionPause:
+		return StatusPaused, nil
+	case status == StatusPaused && action == ActionResume:
+		return StatusActive, nil
+	case (status == StatusActive || status == StatusPaused) && action == ActionFinish:
+		return StatusFinished, nil
+	case (status == StatusActive || status == StatusPaused) && action == ActionExpire:
+		return StatusExpired, nil
+	}
+	return "", fmt.Errorf("%w: cannot %s a session that is %s", ErrInvalidTransition, action, status)
+}
+
+// Open reports whether a session can still change.
+func Open(status string) bool {
+	return status == StatusActive || status == StatusPaused
+}
+
+// Clock tracks the training time of a session: the time since it started less
+// every pause, including one still in progress.
+type Clock struct {
+	StartedAt     time.Time
+	PausedAt      *time.Time
+	PausedSeconds int
+}
+
+func (c Clock) Elapsed(now time.Time) time.Duration {
+	paused := time.Duration(c.PausedSeconds) * time.Second
+	if c.PausedAt != nil && now.After(*c.PausedAt) {
+		paused += now.Sub(*c.PausedAt)
+	}
+	elapsed := now.Sub(c.StartedAt) - paused
+	if elapsed < 0 {
+		return 0
+	}
+	return elapsed
+}
+
+// Minutes rounds the elapsed training time to whole minutes, counting any
+// started session as at least one.
+func (c Clock) Minutes(now time.Time) int {
+	minutes := int(c.Elapsed(now).Round(time.Minute) / time.Minute)
+	return max(minutes, 1)
+}
+
+// Rest is a running rest timer. A zero TargetSeconds counts up without a
+// target.
+type Rest struct {
+	StartedAt     time.Time
+	TargetSeconds int
+}
+
+type RestStatus struct {
+	StartedAt        time.Time `json:"started_at"`
+	TargetSeconds    int       `json:"target_seconds,omitempty"`
+	ElapsedSeconds   int       `json:"elapsed_seconds"`
+	RemainingSeconds int       `json:"remaining_seconds"`
+	Overdue          bool      `json:"overdue"`
+}
+
+func (r Rest) Status(now time.Time) RestStatus {
+	elapsed := max(int(now.Sub(r.StartedAt)/time.Second), 0)
+	status := RestStatus{
+		StartedAt:      r.StartedAt,
+		TargetSeconds:  r.TargetSeconds,
+		ElapsedSeconds: elapsed,
+	}
+	if r.TargetSeconds > 0 {
+		status.RemainingSeconds = max(r.TargetSeconds-elapsed, 0)
+		status.Overdue = elapsed > r.TargetSeconds
+	}
+	return status
+}
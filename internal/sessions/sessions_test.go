@@ -0,0 +1,61 @@
+package sessions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNext(t *testing.T) {
+	tests := []struct {
+		status string
+		action string
+		want   string
+	}{
+		{StatusActive, ActionLog, StatusActive},
+		{StatusActive, ActionPause, StatusPaused},
+		{StatusPaused, ActionResume, StatusActive},
+		{StatusPaused, ActionFinish, StatusFinished},
+		{StatusActive, ActionExpire, StatusExpired},
+		{StatusPaused, ActionLog, ""},
+		{StatusActive, ActionResume, ""},
+		{StatusPaused, ActionPause, ""},
+		{StatusFinished, ActionResume, ""},
+		{StatusExpired, ActionFinish, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.status+" "+tt.action, func(t *testing.T) {
+			got, err := Next(tt.status, tt.action)
+			if tt.want == "" {
+				assert.ErrorIs(t, err, ErrInvalidTransition)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestClock(t *testing.T) {
+	start := time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)
+	clock := Clock{StartedAt: start, PausedSeconds: 300}
+	assert.Equal(t, 55*time.Minute, clock.Elapsed(start.Add(time.Hour)))
+	assert.Equal(t, 55, clock.Minutes(start.Add(time.Hour)))
+
+	pausedAt := start.Add(50 * time.Minute)
+	clock.PausedAt = &pausedAt
+	assert.Equal(t, 45*time.Minute, clock.Elapsed(start.Add(time.Hour)))
+	assert.Equal(t, 1, Clock{StartedAt: start}.Minutes(start.Add(10*time.Second)))
+}
+
+func TestRestStatus(t *testing.T) {
+	start := time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)
+	rest := Rest{StartedAt: start, TargetSeconds: 90}
+	assert.Equal(t, RestStatus{StartedAt: start, TargetSeconds: 90, ElapsedSeconds: 30, RemainingSeconds: 60}, rest.Status(start.Add(30*time.Second)))
+	overdue := rest.Status(start.Add(2 * time.Minute))
+	assert.True(t, overdue.Overdue)
+	assert.Zero(t, overdue.RemainingSeconds)
+	assert.False(t, Rest{StartedAt: start}.Status(start.Add(time.Hour)).Overdue)
+}
@@ -0,0 +1,96 @@
+package store
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type Exercise struct {
+	Id        int       `json:"id"`
+	Name      string    `json:"name"`
+	Category  string    `json:"category"`
+	MET       float64   `json:"met"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostgresExerciseStore struct {
+	db *sql.DB
+}
+
+func NewPostgresExerciseStore(db *sql.DB) *PostgresExerciseStore {
+	return &PostgresExerciseStore{
+		db: db,
+	}
+}
+
+type ExerciseStore interface {
+	ListExercises() ([]Exercise, error)
+	GetExerciseById(id int64) (*Exercise, error)
+	GetMETValues(names []string) (map[string]float64, error)
+}
+
+func (pe *PostgresExerciseStore) ListExercises() ([]Exercise, error) {
+	rows, err := pe.db.Query(`SELECT id,name,category,met,created_at FROM exercises ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var exercises []Exercise
+	for rows.Next() {
+		var exercise Exercise
+		if err := rows.Scan(&exercise.Id, &exercise.Name, &exercise.Category, &exercise.MET, &exercise.CreatedAt); err != nil {
+			return nil, err
+		}
+		exercises = append(exercises, exercise)
+	}
+	return exercises, rows.Err()
+}
+
+func (pe *PostgresExerciseStore) GetExerciseById(id int64) (*Exercise, error) {
+	exercise := &Exercise{}
+	query := `
+	SELECT id,name,category,met,created_at
+	FROM exercises
+	WHERE id = $1
+	`
+	err := pe.db.QueryRow(query, id).Scan(&exercise.Id, &exercise.Name, &exercise.Category, &exercise.MET, &exercise.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return exercise, nil
+}
+
+// GetMETValues looks up the catalog MET value of each exercise name, keyed by
+// the lower-cased name. Names missing from the catalog are left out.
+func (pe *PostgresExerciseStore) GetMETValues(names []string) (map[string]float64, error) {
+	lowered := make([]string, 0, len(names))
+	for _, name := range names {
+		lowered = append(lowered, strings.ToLower(name))
+	}
+	values := make(map[string]float64, len(names))
+	if len(lowered) == 0 {
+		return values, nil
+	}
+	rows, err := pe.db.Query(`SELECT lower(name), met FROM exercises WHERE lower(name) = ANY($1)`, lowered)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var name string
+		var met float64
+		if err := rows.Scan(&name, &met); err != nil {
+			return nil, err
+		}
+		values[name] = met
+	}
+	return values, rows.Err()
+}
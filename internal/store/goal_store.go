@@ -0,0 +1,327 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/goals"
+)
+
+// Goal is a target the user trains towards. Weights and volumes are in kg.
+// StartValue is the value when the goal was set and AchievedAt the first time
+// the target was reached.
+type Goal struct {
+	Id           int        `json:"id"`
+	UserId       int        `json:"user_id"`
+	Title        string     `json:"title"`
+	Kind         string     `json:"kind"`
+	ExerciseName string     `json:"exercise_name,omitempty"`
+	Period       string     `json:"period,omitempty"`
+	TargetValue  float64    `json:"target_value"`
+	StartValue   float64    `json:"start_value"`
+	CurrentValue float64    `json:"current_value"`
+	Deadline     *time.Time `json:"deadline"`
+	AchievedAt   *time.Time `json:"achieved_at"`
+	EvaluatedAt  *time.Time `json:"evaluated_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type PostgresGoalStore struct {
+	db *sql.DB
+}
+
+func NewPostgresGoalStore(db *sql.DB) *PostgresGoalStore {
+	return &PostgresGoalStore{
+		db: db,
+	}
+}
+
+type GoalStore interface {
+	CreateGoal(goal *Goal) (*Goal, error)
+	GetGoalById(id int64) (*Goal, error)
+	ListGoals(userID int) ([]Goal, error)
+	DeleteGoal(id int64) error
+	GetGoalOwner(id int64) (int, error)
+	GetGoalHistory(goal *Goal, since time.Time) ([]goals.Point, error)
+}
+
+// rowQueryer is satisfied by both *sql.DB and *sql.Tx.
+type rowQueryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+const goalColumns = `id,user_id,title,kind,exercise_name,period,target_value,start_value,current_value,deadline,achieved_at,evaluated_at,created_at`
+
+func scanGoal(row rowScanner, goal *Goal) error {
+	return row.Scan(&goal.Id, &goal.UserId, &goal.Title, &goal.Kind, &goal.ExerciseName, &goal.Period, &goal.TargetValue,
+		&goal.StartValue, &goal.CurrentValue, &goal.Deadline, &goal.AchievedAt, &goal.EvaluatedAt, &goal.CreatedAt)
+}
+
+func userLocation(q rowQueryer, userID int) (*time.Location, error) {
+	var timezone string
+	if err := q.QueryRow(`SELECT timezone FROM users WHERE id = $1`, userID).Scan(&timezone); err != nil {
+		return nil, err
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+// goalValue measures the goal's metric now. Periodic goals count the current
+// period in the user's timezone.
+func goalValue(q rowQueryer, goal *Goal, loc *time.Location, now time.Time) (float64, error) {
+	var value sql.NullFloat64
+	var err error
+	switch goal.Kind {
+	case goals.KindLift:
+		err = q.QueryRow(`
+		SELECT MAX(e.weight)
+		FROM workout_entries e
+		INNER JOIN workouts w ON w.id = e.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND lower(e.exercise_name) = lower($2)
+		`, goal.UserId, goal.ExerciseName).Scan(&value)
+	case goals.KindBodyweight:
+		err = q.QueryRow(`
+		SELECT COALESCE(
+			(SELECT weight_kg FROM body_measurements
+			 WHERE user_id = $1 AND weight_kg IS NOT NULL
+			 ORDER BY measured_at DESC LIMIT 1),
+			(SELECT body_weight_kg FROM users WHERE id = $1)
+		)
+		`, goal.UserId).Scan(&value)
+	case goals.KindFrequency:
+		start, end := goals.PeriodBounds(goal.Period, now, loc)
+		err = q.QueryRow(`
+		SELECT COUNT(*) FROM workouts WHERE user_id = $1 AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3
+		`, goal.UserId, start, end).Scan(&value)
+	case goals.KindVolume:
+		// Logged sets are counted individually, warm-ups excluded; entries
+		// without a set log count sets × reps × weight.
+		start, end := goals.PeriodBounds(goal.Period, now, loc)
+		err = q.QueryRow(`
+		SELECT SUM(COALESCE(
+			(SELECT SUM(s.reps * s.weight) FROM workout_sets s WHERE s.entry_id = e.id AND s.set_type <> 'warmup'),
+			e.sets * e.reps * e.weight))
+		FROM workout_entries e
+		INNER JOIN workouts w ON w.id = e.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+		  AND ($4 = '' OR lower(e.exercise_name) = lower($4))
+		`, goal.UserId, start, end, goal.ExerciseName).Scan(&value)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return value.Float64, nil
+}
+
+// evaluateGoals refreshes the progress of every goal of the user and stamps
+// newly reached ones. The stores call it inside the transactions that write
+// workouts and measurements so progress never lags behind the data.
+func evaluateGoals(tx *sql.Tx, userID int) error {
+	rows, err := tx.Query(`SELECT `+goalColumns+` FROM goals WHERE user_id = $1 ORDER BY id FOR UPDATE`, userID)
+	if err != nil {
+		return err
+	}
+	var list []Goal
+	for rows.Next() {
+		var goal Goal
+		if err := scanGoal(rows, &goal); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		list = append(list, goal)
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(list) == 0 {
+		return nil
+	}
+	loc, err := userLocation(tx, userID)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	for _, goal := range list {
+		current, err := goalValue(tx, &goal, loc, now)
+		if err != nil {
+			return err
+		}
+		start := goal.StartValue
+		if goals.Periodic(goal.Kind) {
+			start = 0
+		}
+		reached := goals.Reached(goal.Kind, start, current, goal.TargetValue)
+		_, err = tx.Exec(`
+		UPDATE goals
+		SET current_value = $1, evaluated_at = CURRENT_TIMESTAMP,
+		    achieved_at = CASE WHEN achieved_at IS NULL AND $2 THEN CURRENT_TIMESTAMP ELSE achieved_at END
+		WHERE id = $3
+		`, current, reached, goal.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateGoal starts the goal from the user's current value of its metric and
+// evaluates it right away.
+func (pg *PostgresGoalStore) CreateGoal(goal *Goal) (*Goal, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if !goals.Periodic(goal.Kind) {
+		loc, err := userLocation(tx, goal.UserId)
+		if err != nil {
+			return nil, err
+		}
+		if goal.StartValue, err = goalValue(tx, goal, loc, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	query := `
+	INSERT INTO goals(user_id,title,kind,exercise_name,period,target_value,start_value,deadline)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8)
+	RETURNING id
+	`
+	err = tx.QueryRow(query, goal.UserId, goal.Title, goal.Kind, goal.ExerciseName, goal.Period, goal.TargetValue, goal.StartValue, goal.Deadline).Scan(&goal.Id)
+	if err != nil {
+		return nil, err
+	}
+	if err := evaluateGoals(tx, goal.UserId); err != nil {
+		return nil, err
+	}
+	if err := scanGoal(tx.QueryRow(`SELECT `+goalColumns+` FROM goals WHERE id = $1`, goal.Id), goal); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+func (pg *PostgresGoalStore) GetGoalById(id int64) (*Goal, error) {
+	goal := &Goal{}
+	err := scanGoal(pg.db.QueryRow(`SELECT `+goalColumns+` FROM goals WHERE id = $1`, id), goal)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return goal, nil
+}
+
+// ListGoals re-evaluates the user's goals before returning them, since
+// periodic goals roll over without any write.
+func (pg *PostgresGoalStore) ListGoals(userID int) ([]Goal, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := evaluateGoals(tx, userID); err != nil {
+		return nil, err
+	}
+	rows, err := tx.Query(`SELECT `+goalColumns+` FROM goals WHERE user_id = $1 ORDER BY achieved_at IS NOT NULL, deadline NULLS LAST, id`, userID)
+	if err != nil {
+		return nil, err
+	}
+	list := []Goal{}
+	for rows.Next() {
+		var goal Goal
+		if err := scanGoal(rows, &goal); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		list = append(list, goal)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return list, tx.Commit()
+}
+
+func (pg *PostgresGoalStore) DeleteGoal(id int64) error {
+	result, err := pg.db.Exec(`DELETE FROM goals WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (pg *PostgresGoalStore) GetGoalOwner(id int64) (int, error) {
+	var userID int
+	err := pg.db.QueryRow(`SELECT user_id FROM goals WHERE id = $1`, id).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// GetGoalHistory returns the values the trend of a lift or bodyweight goal is
+// projected from: the heaviest weight of each workout or each weigh-in since
+// the given time. Periodic goals have none.
+func (pg *PostgresGoalStore) GetGoalHistory(goal *Goal, since time.Time) ([]goals.Point, error) {
+	var query string
+	args := []any{goal.UserId, since}
+	switch goal.Kind {
+	case goals.KindLift:
+		query = `
+		SELECT w.created_at, MAX(e.weight)
+		FROM workout_entries e
+		INNER JOIN workouts w ON w.id = e.workout_id
+		WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND lower(e.exercise_name) = lower($3) AND e.weight IS NOT NULL
+		GROUP BY w.id, w.created_at
+		ORDER BY w.created_at
+		`
+		args = append(args, goal.ExerciseName)
+	case goals.KindBodyweight:
+		query = `
+		SELECT measured_at, weight_kg
+		FROM body_measurements
+		WHERE user_id = $1 AND measured_at >= $2 AND weight_kg IS NOT NULL
+		ORDER BY measured_at
+		`
+	default:
+		return nil, nil
+	}
+	rows, err := pg.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var points []goals.Point
+	for rows.Next() {
+		var point goals.Point
+		if err := rows.Scan(&point.Time, &point.Value); err != nil {
+			return nil, err
+		}
+		points = append(points, point)
+	}
+	return points, rows.Err()
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/heartrate"
+)
+
+type PeriodSummary struct {
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	Workouts      int                `json:"workouts"`
+	TotalMinutes  int                `json:"total_minutes"`
+	TotalCalories int                `json:"total_calories"`
+	HeartRate     *heartrate.Summary `json:"heart_rate,omitempty"`
+}
+
+// SaveHeartRate stores or replaces the workout's heart-rate samples.
+func (pg *PostgresWorkout) SaveHeartRate(workoutID int64, samples []heartrate.Sample) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "workouts", workoutID); err != nil {
+		return err
+	}
+	if err := saveHeartRateTx(tx, workoutID, samples); err != nil {
+		return err
+	}
+	// The analysis is part of the workout, so its ETag and synced copies have
+	// to change.
+	if err := touchWorkouts(tx, `id = $1`, workoutID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func saveHeartRateTx(tx *sql.Tx, workoutID int64, samples []heartrate.Sample) error {
+	startedAt, encoded := heartrate.Encode(samples)
+	query := `
+	INSERT INTO workout_heart_rate (workout_id,started_at,sample_count,samples)
+	VALUES($1,$2,$3,$4)
+	ON CONFLICT (workout_id) DO UPDATE
+	SET started_at = EXCLUDED.started_at, sample_count = EXCLUDED.sample_count, samples = EXCLUDED.samples, created_at = CURRENT_TIMESTAMP
+	`
+	_, err := tx.Exec(query, workoutID, startedAt, len(samples), encoded)
+	return err
+}
+
+// loadHeartRate analyses the stored samples of the workouts against their
+// owners' current heart-rate settings.
+func (pg *PostgresWorkout) loadHeartRate(workouts ...*Workout) error {
+	byId := make(map[int]*Workout, len(workouts))
+	ids := make([]int, 0, len(workouts))
+	for _, workout := range workouts {
+		byId[workout.Id] = workout
+		ids = append(ids, workout.Id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `
+	SELECT h.workout_id, h.started_at, h.samples, u.max_heart_rate, u.resting_heart_rate, u.hr_zone_model
+	FROM workout_heart_rate h
+	INNER JOIN workouts w ON w.id = h.workout_id
+	INNER JOIN users u ON u.id = w.user_id
+	WHERE h.workout_id = ANY($1)
+	`
+	rows, err := pg.db.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var workoutID int
+		var startedAt time.Time
+		var encoded []byte
+		var maxHR, restingHR sql.NullInt64
+		var model string
+		if err := rows.Scan(&workoutID, &startedAt, &encoded, &maxHR, &restingHR, &model); err != nil {
+			return err
+		}
+		samples, err := heartrate.Decode(startedAt, encoded)
+		if err != nil {
+			return err
+		}
+		settings := heartrate.Settings{MaxHeartRate: int(maxHR.Int64), RestingHeartRate: int(restingHR.Int64), ZoneModel: model}
+		analysis := heartrate.Analyze(samples, settings)
+		byId[workoutID].HeartRate = &analysis
+	}
+	return rows.Err()
+}
+
+func (pg *PostgresWorkout) GetPeriodSummary(userID int, settings heartrate.Settings, from, to time.Time) (*PeriodSummary, error) {
+	summary := &PeriodSummary{From: from, To: to}
+	query := `
+	SELECT COUNT(*), COALESCE(SUM(duration_minutes), 0), COALESCE(SUM(calories_burned), 0)
+	FROM workouts
+	WHERE user_id = $1 AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3
+	`
+	err := pg.db.QueryRow(query, userID, from, to).Scan(&summary.Workouts, &summary.TotalMinutes, &summary.TotalCalories)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := pg.db.Query(`
+	SELECT h.started_at, h.samples
+	FROM workout_heart_rate h
+	INNER JOIN workouts w ON w.id = h.workout_id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND w.created_at < $3
+	`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var analyses []heartrate.Analysis
+	for rows.Next() {
+		var startedAt time.Time
+		var encoded []byte
+		if err := rows.Scan(&startedAt, &encoded); err != nil {
+			return nil, err
+		}
+		samples, err := heartrate.Decode(startedAt, encoded)
+		if err != nil {
+			return nil, err
+		}
+		analyses = append(analyses, heartrate.Analyze(samples, settings))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	summary.HeartRate = heartrate.Combine(analyses, settings)
+	return summary, nil
+}
@@ -0,0 +1,125 @@
+package store
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// IdempotencyRecord is what is kept of a write sent with an Idempotency-Key.
+// Fingerprint identifies the request; the response is empty until the
+// request completes.
+type IdempotencyRecord struct {
+	Fingerprint string
+	Completed   bool
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+}
+
+type PostgresIdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyStore(db *sql.DB) *PostgresIdempotencyStore {
+	return &PostgresIdempotencyStore{
+		db: db,
+	}
+}
+
+type IdempotencyStore interface {
+	ReserveKey(userID int, key, fingerprint string, expiredBefore, abandonedBefore time.Time) (string, *IdempotencyRecord, error)
+	CompleteKey(userID int, key, reservation string, status int, header http.Header, body []byte) error
+	ReleaseKey(userID int, key, reservation string) error
+	PurgeKeys(createdBefore time.Time) (int64, error)
+}
+
+// ReserveKey claims the key for a request with the given fingerprint and
+// returns the reservation to complete or release it with, or returns the
+// record already holding the key. Records created before expiredBefore, and
+// those still in progress since before abandonedBefore, no longer hold their
+// key.
+func (pi *PostgresIdempotencyStore) ReserveKey(userID int, key, fingerprint string, expiredBefore, abandonedBefore time.Time) (string, *IdempotencyRecord, error) {
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return "", nil, err
+	}
+	reservation := hex.EncodeToString(random)
+	query := `
+	INSERT INTO idempotency_keys(user_id,idempotency_key,fingerprint,reservation)
+	VALUES($1,$2,$3,$4)
+	ON CONFLICT (user_id, idempotency_key) DO UPDATE
+	SET fingerprint = EXCLUDED.fingerprint, reservation = EXCLUDED.reservation, status_code = NULL, response_header = NULL, response_body = NULL, created_at = CURRENT_TIMESTAMP
+	WHERE idempotency_keys.created_at < $5 OR (idempotency_keys.status_code IS NULL AND idempotency_keys.created_at < $6)
+	RETURNING user_id
+	`
+	var owner int
+	err := pi.db.QueryRow(query, userID, key, fingerprint, reservation, expiredBefore, abandonedBefore).Scan(&owner)
+	if err == nil {
+		return reservation, nil, nil
+	}
+	if err != sql.ErrNoRows {
+		return "", nil, err
+	}
+	record := &IdempotencyRecord{}
+	var status sql.NullInt64
+	var header []byte
+	query = `
+	SELECT fingerprint,status_code,response_header,response_body,created_at
+	FROM idempotency_keys
+	WHERE user_id = $1 AND idempotency_key = $2
+	`
+	err = pi.db.QueryRow(query, userID, key).Scan(&record.Fingerprint, &status, &header, &record.Body, &record.CreatedAt)
+	if err == sql.ErrNoRows {
+		// Released in between; claim it again.
+		return pi.ReserveKey(userID, key, fingerprint, expiredBefore, abandonedBefore)
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	record.Completed, record.StatusCode = status.Valid, int(status.Int64)
+	if header != nil {
+		if err := json.Unmarshal(header, &record.Header); err != nil {
+			return "", nil, err
+		}
+	}
+	return "", record, nil
+}
+
+// CompleteKey stores the response to the request that reserved the key. It
+// does nothing when the reservation has since been taken over.
+func (pi *PostgresIdempotencyStore) CompleteKey(userID int, key, reservation string, status int, header http.Header, body []byte) error {
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	query := `
+	UPDATE idempotency_keys
+	SET status_code = $4, response_header = $5, response_body = $6
+	WHERE user_id = $1 AND idempotency_key = $2 AND reservation = $3
+	`
+	_, err = pi.db.Exec(query, userID, key, reservation, status, encoded, body)
+	return err
+}
+
+// ReleaseKey frees the key for a request whose response is not kept, so a
+// retry runs it again. Like CompleteKey, it leaves alone a key that another
+// request has reserved since.
+func (pi *PostgresIdempotencyStore) ReleaseKey(userID int, key, reservation string) error {
+	_, err := pi.db.Exec(`DELETE FROM idempotency_keys WHERE user_id = $1 AND idempotency_key = $2 AND reservation = $3`, userID, key, reservation)
+	return err
+}
+
+// PurgeKeys deletes the records created before createdBefore and returns how
+// many there were.
+func (pi *PostgresIdempotencyStore) PurgeKeys(createdBefore time.Time) (int64, error) {
+	result, err := pi.db.Exec(`DELETE FROM idempotency_keys WHERE created_at < $1`, createdBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	MetricWeightKg       = "weight_kg"
+	MetricBodyFatPercent = "body_fat_percent"
+	MetricNeckCm         = "neck_cm"
+	MetricChestCm        = "chest_cm"
+	MetricWaistCm        = "waist_cm"
+	MetricHipsCm         = "hips_cm"
+	MetricArmsCm         = "arms_cm"
+	MetricThighsCm       = "thighs_cm"
+)
+
+type BodyMeasurement struct {
+	Id             int       `json:"id"`
+	UserId         int       `json:"user_id"`
+	MeasuredAt     time.Time `json:"measured_at"`
+	Unit           string    `json:"unit"`
+	WeightKg       *float64  `json:"weight_kg"`
+	BodyFatPercent *float64  `json:"body_fat_percent"`
+	NeckCm         *float64  `json:"neck_cm"`
+	ChestCm        *float64  `json:"chest_cm"`
+	WaistCm        *float64  `json:"waist_cm"`
+	HipsCm         *float64  `json:"hips_cm"`
+	ArmsCm         *float64  `json:"arms_cm"`
+	ThighsCm       *float64  `json:"thighs_cm"`
+	Notes          string    `json:"notes"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Metric returns the value recorded for one of the Metric* names, or nil when
+// the measurement does not include it or the name is unknown.
+func (m *BodyMeasurement) Metric(name string) *float64 {
+	switch name {
+	case MetricWeightKg:
+		return m.WeightKg
+	case MetricBodyFatPercent:
+		return m.BodyFatPercent
+	case MetricNeckCm:
+		return m.NeckCm
+	case MetricChestCm:
+		return m.ChestCm
+	case MetricWaistCm:
+		return m.WaistCm
+	case MetricHipsCm:
+		return m.HipsCm
+	case MetricArmsCm:
+		return m.ArmsCm
+	case MetricThighsCm:
+		return m.ThighsCm
+	}
+	return nil
+}
+
+// IsMeasurementMetric reports whether name is one of the Metric* names.
+func IsMeasurementMetric(name string) bool {
+	switch name {
+	case MetricWeightKg, MetricBodyFatPercent, MetricNeckCm, MetricChestCm, MetricWaistCm, MetricHipsCm, MetricArmsCm, MetricThighsCm:
+		return true
+	}
+	return false
+}
+
+type PostgresMeasurementStore struct {
+	db *sql.DB
+}
+
+func NewPostgresMeasurementStore(db *sql.DB) *PostgresMeasurementStore {
+	return &PostgresMeasurementStore{
+		db: db,
+	}
+}
+
+type MeasurementStore interface {
+	CreateMeasurement(measurement *BodyMeasurement) (*BodyMeasurement, error)
+	GetMeasurementById(id int64) (*BodyMeasurement, error)
+	GetMeasurementsByIds(ids []int64) (map[int64]*BodyMeasurement, error)
+	ListMeasurements(userID int, from, to time.Time) ([]BodyMeasurement, error)
+	UpdateMeasurement(measurement *BodyMeasurement) error
+	DeleteMeasurement(id int64) error
+	GetMeasurementOwner(id int64) (int, error)
+	GetLatestBodyWeight(userID int) (*float64, error)
+}
+
+const measurementColumns = `id,user_id,measured_at,unit,weight_kg,body_fat_percent,neck_cm,chest_cm,waist_cm,hips_cm,arms_cm,thighs_cm,COALESCE(notes,''),created_at,updated_at`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMeasurement(row rowScanner, m *BodyMeasurement) error {
+	return row.Scan(&m.Id, &m.UserId, &m.MeasuredAt, &m.Unit, &m.WeightKg, &m.BodyFatPercent, &m.NeckCm, &m.ChestCm,
+		&m.WaistCm, &m.HipsCm, &m.ArmsCm, &m.ThighsCm, &m.Notes, &m.CreatedAt, &m.UpdatedAt)
+}
+
+func (pm *PostgresMeasurementStore) CreateMeasurement(measurement *BodyMeasurement) (*BodyMeasurement, error) {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, measurement.UserId); err != nil {
+		return nil, err
+	}
+	if err := createMeasurementTx(tx, measurement); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return measurement, nil
+}
+
+func createMeasurementTx(tx *sql.Tx, measurement *BodyMeasurement) error {
+	query := `
+	INSERT INTO body_measurements(user_id,measured_at,unit,weight_kg,body_fat_percent,neck_cm,chest_cm,waist_cm,hips_cm,arms_cm,thighs_cm,notes)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+	RETURNING ` + measurementColumns
+	m := measurement
+	row := tx.QueryRow(query, m.UserId, m.MeasuredAt, m.Unit, m.WeightKg, m.BodyFatPercent, m.NeckCm, m.ChestCm,
+		m.WaistCm, m.HipsCm, m.ArmsCm, m.ThighsCm, m.Notes)
+	if err := scanMeasurement(row, measurement); err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, measurement.UserId); err != nil {
+		return err
+	}
+	return recordChange(tx, measurement.UserId, SyncMeasurement, int64(measurement.Id), false)
+}
+
+func (pm *PostgresMeasurementStore) GetMeasurementById(id int64) (*BodyMeasurement, error) {
+	return getMeasurement(pm.db, id)
+}
+
+func getMeasurement(db rowQueryer, id int64) (*BodyMeasurement, error) {
+	measurement := &BodyMeasurement{}
+	query := `SELECT ` + measurementColumns + ` FROM body_measurements WHERE id = $1`
+	err := scanMeasurement(db.QueryRow(query, id), measurement)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return measurement, nil
+}
+
+// GetMeasurementsByIds returns the measurements that exist among ids, by id.
+func (pm *PostgresMeasurementStore) GetMeasurementsByIds(ids []int64) (map[int64]*BodyMeasurement, error) {
+	measurements := make(map[int64]*BodyMeasurement, len(ids))
+	if len(ids) == 0 {
+		return measurements, nil
+	}
+	rows, err := pm.db.Query(`SELECT `+measurementColumns+` FROM body_measurements WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		measurement := &BodyMeasurement{}
+		if err := scanMeasurement(rows, measurement); err != nil {
+			return nil, err
+		}
+		measurements[int64(measurement.Id)] = measurement
+	}
+	return measurements, rows.Err()
+}
+
+// ListMeasurements returns the user's measurements taken in [from, to), oldest
+// first.
+func (pm *PostgresMeasurementStore) ListMeasurements(userID int, from, to time.Time) ([]BodyMeasurement, error) {
+	query := `
+	SELECT ` + measurementColumns + `
+	FROM body_measurements
+	WHERE user_id = $1 AND measured_at >= $2 AND measured_at < $3
+	ORDER BY measured_at, id
+	`
+	rows, err := pm.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var measurements []BodyMeasurement
+	for rows.Next() {
+		var measurement BodyMeasurement
+		if err := scanMeasurement(rows, &measurement); err != nil {
+			return nil, err
+		}
+		measurements = append(measurements, measurement)
+	}
+	return measurements, rows.Err()
+}
+
+func (pm *PostgresMeasurementStore) UpdateMeasurement(measurement *BodyMeasurement) error {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "body_measurements", int64(measurement.Id)); err != nil {
+		return err
+	}
+	if err := updateMeasurementTx(tx, measurement); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateMeasurementTx(tx *sql.Tx, measurement *BodyMeasurement) error {
+	query := `
+	UPDATE body_measurements
+	SET measured_at=$1,unit=$2,weight_kg=$3,body_fat_percent=$4,neck_cm=$5,chest_cm=$6,waist_cm=$7,hips_cm=$8,arms_cm=$9,thighs_cm=$10,notes=$11,updated_at=CURRENT_TIMESTAMP
+	WHERE id=$12
+	RETURNING user_id, updated_at
+	`
+	m := measurement
+	err := tx.QueryRow(query, m.MeasuredAt, m.Unit, m.WeightKg, m.BodyFatPercent, m.NeckCm, m.ChestCm,
+		m.WaistCm, m.HipsCm, m.ArmsCm, m.ThighsCm, m.Notes, m.Id).Scan(&measurement.UserId, &measurement.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, measurement.UserId); err != nil {
+		return err
+	}
+	return recordChange(tx, measurement.UserId, SyncMeasurement, int64(measurement.Id), false)
+}
+
+func (pm *PostgresMeasurementStore) DeleteMeasurement(id int64) error {
+	tx, err := pm.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "body_measurements", id); err != nil {
+		return err
+	}
+	if err := deleteMeasurementTx(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteMeasurementTx(tx *sql.Tx, id int64) error {
+	var userID int
+	err := tx.QueryRow(`DELETE FROM body_measurements WHERE id = $1 RETURNING user_id`, id).Scan(&userID)
+	if err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, userID); err != nil {
+		return err
+	}
+	return recordChange(tx, userID, SyncMeasurement, id, true)
+}
+
+func (pm *PostgresMeasurementStore) GetMeasurementOwner(id int64) (int, error) {
+	var userID int
+	err := pm.db.QueryRow(`SELECT user_id FROM body_measurements WHERE id = $1`, id).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// GetLatestBodyWeight returns the most recently measured body weight, falling
+// back to the weight on the user's profile. It is nil when neither is known.
+func (pm *PostgresMeasurementStore) GetLatestBodyWeight(userID int) (*float64, error) {
+	var weight sql.NullFloat64
+	query := `
+	SELECT COALESCE(
+		(SELECT weight_kg FROM body_measurements
+		 WHERE user_id = $1 AND weight_kg IS NOT NULL
+		 ORDER BY measured_at DESC LIMIT 1),
+		(SELECT body_weight_kg FROM users WHERE id = $1)
+	)
+	`
+	if err := pm.db.QueryRow(query, userID).Scan(&weight); err != nil {
+		return nil, err
+	}
+	if !weight.Valid {
+		return nil, nil
+	}
+	return &weight.Float64, nil
+}
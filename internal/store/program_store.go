@@ -0,0 +1,276 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+const (
+	EnrolmentActive    = "active"
+	EnrolmentCompleted = "completed"
+	EnrolmentCancelled = "cancelled"
+)
+
+type Program struct {
+	Id              int              `json:"id"`
+	UserId          int              `json:"user_id"`
+	Title           string           `json:"title"`
+	Description     string           `json:"description"`
+	DurationWeeks   int              `json:"duration_weeks"`
+	WeeklyIncrement float64          `json:"weekly_increment"`
+	WeightUnit      string           `json:"weight_unit"`
+	DeloadPercent   float64          `json:"deload_percent"`
+	IsPublic        bool             `json:"is_public"`
+	Weeks           []ProgramWeek    `json:"weeks"`
+	Sessions        []ProgramSession `json:"sessions"`
+	CreatedAt       time.Time        `json:"created_at"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+}
+
+// ProgramWeek overrides the progression for a single week: deload weeks scale
+// weights down by the program's DeloadPercent, and IntensityPercent sets the
+// week's load as a percentage of the template weight for wave loading.
+type ProgramWeek struct {
+	WeekNumber       int      `json:"week_number"`
+	IsDeload         bool     `json:"is_deload"`
+	IntensityPercent *float64 `json:"intensity_percent"`
+}
+
+type ProgramSession struct {
+	Id         int  `json:"id"`
+	TemplateId int  `json:"template_id"`
+	WeekNumber *int `json:"week_number"`
+	DayNumber  int  `json:"day_number"`
+}
+
+type ProgramEnrolment struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	ProgramId int       `json:"program_id"`
+	StartDate time.Time `json:"start_date"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PostgresProgramStore struct {
+	db *sql.DB
+}
+
+func NewPostgresProgramStore(db *sql.DB) *PostgresProgramStore {
+	return &PostgresProgramStore{
+		db: db,
+	}
+}
+
+type ProgramStore interface {
+	CreateProgram(program *Program) (*Program, error)
+	GetProgramById(id int64) (*Program, error)
+	ListPrograms(userID int) ([]Program, error)
+	DeleteProgram(id int64) error
+	GetProgramOwner(id int64) (int, error)
+	CreateEnrolment(enrolment *ProgramEnrolment) (*ProgramEnrolment, error)
+	ListActiveEnrolments(userID int) ([]ProgramEnrolment, error)
+}
+
+func (ps *PostgresProgramStore) CreateProgram(program *Program) (*Program, error) {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	query := `
+	INSERT INTO programs(user_id,title,description,duration_weeks,weekly_increment,weight_unit,deload_percent,is_public)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8)
+	RETURNING id,created_at,updated_at
+	`
+	err = tx.QueryRow(query, program.UserId, program.Title, program.Description, program.DurationWeeks,
+		program.WeeklyIncrement, program.WeightUnit, program.DeloadPercent, program.IsPublic).Scan(&program.Id, &program.CreatedAt, &program.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	for _, week := range program.Weeks {
+		_, err := tx.Exec(`
+		INSERT INTO program_weeks(program_id,week_number,is_deload,intensity_percent)
+		VALUES($1,$2,$3,$4)
+		`, program.Id, week.WeekNumber, week.IsDeload, week.IntensityPercent)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for i := range program.Sessions {
+		session := &program.Sessions[i]
+		err := tx.QueryRow(`
+		INSERT INTO program_sessions(program_id,template_id,week_number,day_number)
+		VALUES($1,$2,$3,$4)
+		RETURNING id
+		`, program.Id, session.TemplateId, session.WeekNumber, session.DayNumber).Scan(&session.Id)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return program, nil
+}
+
+func (ps *PostgresProgramStore) GetProgramById(id int64) (*Program, error) {
+	program := &Program{}
+	query := `
+	SELECT id,user_id,title,COALESCE(description,''),duration_weeks,weekly_increment,weight_unit,deload_percent,is_public,created_at,updated_at
+	FROM programs
+	WHERE id = $1
+	`
+	err := ps.db.QueryRow(query, id).Scan(&program.Id, &program.UserId, &program.Title, &program.Description, &program.DurationWeeks,
+		&program.WeeklyIncrement, &program.WeightUnit, &program.DeloadPercent, &program.IsPublic, &program.CreatedAt, &program.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	weeks, err := ps.db.Query(`
+	SELECT week_number,is_deload,intensity_percent
+	FROM program_weeks
+	WHERE program_id = $1
+	ORDER BY week_number
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = weeks.Close()
+	}()
+	for weeks.Next() {
+		var week ProgramWeek
+		if err := weeks.Scan(&week.WeekNumber, &week.IsDeload, &week.IntensityPercent); err != nil {
+			return nil, err
+		}
+		program.Weeks = append(program.Weeks, week)
+	}
+	if err := weeks.Err(); err != nil {
+		return nil, err
+	}
+
+	sessions, err := ps.db.Query(`
+	SELECT id,template_id,week_number,day_number
+	FROM program_sessions
+	WHERE program_id = $1
+	ORDER BY week_number NULLS FIRST, day_number, id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = sessions.Close()
+	}()
+	for sessions.Next() {
+		var session ProgramSession
+		if err := sessions.Scan(&session.Id, &session.TemplateId, &session.WeekNumber, &session.DayNumber); err != nil {
+			return nil, err
+		}
+		program.Sessions = append(program.Sessions, session)
+	}
+	return program, sessions.Err()
+}
+
+func (ps *PostgresProgramStore) ListPrograms(userID int) ([]Program, error) {
+	rows, err := ps.db.Query(`
+	SELECT id
+	FROM programs
+	WHERE user_id = $1 OR is_public
+	ORDER BY title, id
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	programs := make([]Program, 0, len(ids))
+	for _, id := range ids {
+		program, err := ps.GetProgramById(id)
+		if err != nil {
+			return nil, err
+		}
+		if program != nil {
+			programs = append(programs, *program)
+		}
+	}
+	return programs, nil
+}
+
+func (ps *PostgresProgramStore) DeleteProgram(id int64) error {
+	result, err := ps.db.Exec(`DELETE FROM programs WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	affectedRow, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRow == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (ps *PostgresProgramStore) GetProgramOwner(id int64) (int, error) {
+	var userID int
+	err := ps.db.QueryRow(`SELECT user_id FROM programs WHERE id = $1`, id).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+func (ps *PostgresProgramStore) CreateEnrolment(enrolment *ProgramEnrolment) (*ProgramEnrolment, error) {
+	query := `
+	INSERT INTO program_enrolments(user_id,program_id,start_date)
+	VALUES($1,$2,$3)
+	RETURNING id,status,created_at
+	`
+	err := ps.db.QueryRow(query, enrolment.UserId, enrolment.ProgramId, enrolment.StartDate).Scan(&enrolment.Id, &enrolment.Status, &enrolment.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return enrolment, nil
+}
+
+func (ps *PostgresProgramStore) ListActiveEnrolments(userID int) ([]ProgramEnrolment, error) {
+	query := `
+	SELECT id,user_id,program_id,start_date,status,created_at
+	FROM program_enrolments
+	WHERE user_id = $1 AND status = 'active'
+	ORDER BY start_date
+	`
+	rows, err := ps.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var enrolments []ProgramEnrolment
+	for rows.Next() {
+		var enrolment ProgramEnrolment
+		if err := rows.Scan(&enrolment.Id, &enrolment.UserId, &enrolment.ProgramId, &enrolment.StartDate, &enrolment.Status, &enrolment.CreatedAt); err != nil {
+			return nil, err
+		}
+		enrolments = append(enrolments, enrolment)
+	}
+	return enrolments, rows.Err()
+}
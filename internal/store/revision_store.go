@@ -0,0 +1,192 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+const (
+	// RevisionBaseline is the state a workout was in when revision history
+	// began; it has no author.
+	RevisionBaseline = "baseline"
+	RevisionCreate   = "create"
+	RevisionUpdate   = "update"
+	RevisionRevert   = "revert"
+)
+
+// WorkoutRevision is an immutable snapshot of a workout after a change.
+// Revisions are numbered from 1 per workout; RevertedFrom names the revision a
+// revert restored.
+type WorkoutRevision struct {
+	Id           int       `json:"id"`
+	WorkoutId    int       `json:"workout_id"`
+	Revision     int       `json:"revision"`
+	AuthorId     *int      `json:"author_id"`
+	Action       string    `json:"action"`
+	RevertedFrom *int      `json:"reverted_from,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	Snapshot     *Workout  `json:"snapshot,omitempty"`
+}
+
+// insertRevision stores snapshot as the workout's next revision.
+func insertRevision(tx *sql.Tx, snapshot *Workout, action string, revertedFrom *int, createdAt time.Time) error {
+	encoded, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+	query := `
+	INSERT INTO workout_revisions(workout_id,revision,author_id,action,reverted_from,snapshot,created_at)
+	SELECT $1, COALESCE(MAX(revision), 0) + 1, $2, $3, $4, $5, $6
+	FROM workout_revisions WHERE workout_id = $1
+	`
+	_, err = tx.Exec(query, snapshot.Id, snapshot.UserId, action, revertedFrom, encoded, createdAt)
+	return err
+}
+
+// recordRevision snapshots the workout as just written in tx.
+func recordRevision(tx *sql.Tx, workoutID int, action string, revertedFrom *int) error {
+	snapshot, err := loadWorkout(tx, int64(workoutID))
+	if err != nil {
+		return err
+	}
+	if snapshot == nil {
+		return sql.ErrNoRows
+	}
+	return insertRevision(tx, snapshot, action, revertedFrom, time.Now())
+}
+
+// lockWorkout locks the workout for a change that records a revision, so
+// revisions are numbered in the order the changes commit. It returns
+// sql.ErrNoRows when the workout does not exist or is in the trash.
+func lockWorkout(tx *sql.Tx, workoutID int64) error {
+	var id int64
+	return tx.QueryRow(`SELECT id FROM workouts WHERE id = $1 AND deleted_at IS NULL FOR UPDATE`, workoutID).Scan(&id)
+}
+
+// ListRevisions returns the workout's revisions without snapshots, newest
+// first.
+func (pg *PostgresWorkout) ListRevisions(workoutID int64) ([]WorkoutRevision, error) {
+	query := `
+	SELECT id,workout_id,revision,author_id,action,reverted_from,created_at
+	FROM workout_revisions
+	WHERE workout_id = $1
+	ORDER BY revision DESC
+	`
+	rows, err := pg.db.Query(query, workoutID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	revisions := []WorkoutRevision{}
+	for rows.Next() {
+		var revision WorkoutRevision
+		if err := rows.Scan(&revision.Id, &revision.WorkoutId, &revision.Revision, &revision.AuthorId, &revision.Action,
+			&revision.RevertedFrom, &revision.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, revision)
+	}
+	return revisions, rows.Err()
+}
+
+// GetRevision returns one revision with its snapshot, or nil when the workout
+// has no such revision.
+func (pg *PostgresWorkout) GetRevision(workoutID int64, revision int) (*WorkoutRevision, error) {
+	return getRevision(pg.db, workoutID, revision)
+}
+
+// getRevision derives the groups and paces of the snapshot, which baselines
+// do not store.
+func getRevision(db rowQueryer, workoutID int64, revision int) (*WorkoutRevision, error) {
+	found := &WorkoutRevision{}
+	var encoded []byte
+	query := `
+	SELECT id,workout_id,revision,author_id,action,reverted_from,created_at,snapshot
+	FROM workout_revisions
+	WHERE workout_id = $1 AND revision = $2
+	`
+	err := db.QueryRow(query, workoutID, revision).Scan(&found.Id, &found.WorkoutId, &found.Revision, &found.AuthorId,
+		&found.Action, &found.RevertedFrom, &found.CreatedAt, &encoded)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(encoded, &found.Snapshot); err != nil {
+		return nil, err
+	}
+	for i := range found.Snapshot.Entries {
+		found.Snapshot.Entries[i].PaceSecondsPerKm = found.Snapshot.Entries[i].pace()
+	}
+	found.Snapshot.Groups = BuildEntryGroups(found.Snapshot.Entries)
+	return found, nil
+}
+
+// RevertWorkout restores the workout's fields, entries and tags to a revision
+// and records that as a new revision, so history is never rewritten. Tags
+// deleted since are left off. It returns sql.ErrNoRows when the workout has no
+// such revision, and ErrVersionConflict when expectedVersion is non-zero and
+// the workout has moved on from it.
+func (pg *PostgresWorkout) RevertWorkout(workoutID int64, revision, expectedVersion int) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "workouts", workoutID); err != nil {
+		return err
+	}
+	if err := lockWorkout(tx, workoutID); err != nil {
+		return err
+	}
+	target, err := getRevision(tx, workoutID, revision)
+	if err != nil {
+		return err
+	}
+	if target == nil {
+		return sql.ErrNoRows
+	}
+	workout := target.Snapshot
+	workout.Id = int(workoutID)
+	tagIds := []int{}
+	if len(workout.TagIds) > 0 {
+		rows, err := tx.Query(`SELECT id FROM tags WHERE id = ANY($1) AND user_id = $2`, workout.TagIds, workout.UserId)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				_ = rows.Close()
+				return err
+			}
+			tagIds = append(tagIds, id)
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	workout.TagIds = tagIds
+	if err := updateWorkoutTx(tx, workout, expectedVersion); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, workout.Id, RevisionRevert, &revision); err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, workout.UserId); err != nil {
+		return err
+	}
+	if err := recordChange(tx, workout.UserId, SyncWorkout, workoutID, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,151 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const maxSearchTerms = 10
+
+// Matches are wrapped in these markers in highlighted snippets.
+const headlineOptions = `StartSel=<mark>, StopSel=</mark>, MaxWords=25, MinWords=8, MaxFragments=2, FragmentDelimiter=" … "`
+
+var ErrEmptySearch = errors.New("search query must contain at least one word")
+
+type EntryHit struct {
+	EntryId      int    `json:"entry_id"`
+	ExerciseName string `json:"exercise_name"`
+	Exercise     string `json:"exercise_highlight"`
+	Notes        string `json:"notes_highlight,omitempty"`
+}
+
+type SearchHit struct {
+	WorkoutId   int        `json:"workout_id"`
+	Title       string     `json:"title"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Rank        float64    `json:"rank"`
+	Highlight   string     `json:"title_highlight"`
+	Description string     `json:"description_highlight,omitempty"`
+	Entries     []EntryHit `json:"entries"`
+}
+
+type SearchResults struct {
+	Total int         `json:"total"`
+	Hits  []SearchHit `json:"hits"`
+}
+
+// prefixTerms turns free text into to_tsquery terms, each a prefix so "squa"
+// finds "squats". Anything that is not a letter or digit separates words,
+// which also keeps tsquery operators in the input from being interpreted.
+func prefixTerms(text string) ([]string, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return nil, ErrEmptySearch
+	}
+	if len(words) > maxSearchTerms {
+		words = words[:maxSearchTerms]
+	}
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return words, nil
+}
+
+// SearchWorkouts ranks the filtered workouts that match every word of text,
+// where a workout's title and description and all of its entries' exercise
+// names and notes count as one document. Each word is highlighted wherever it
+// occurs, so an entry is listed when it matches any of them.
+func (pg *PostgresWorkout) SearchWorkouts(filter WorkoutFilter, text string) (*SearchResults, error) {
+	terms, err := prefixTerms(text)
+	if err != nil {
+		return nil, err
+	}
+	everyWord, anyWord := strings.Join(terms, " & "), strings.Join(terms, " | ")
+	where, args := filter.where()
+	args = append(args, everyWord, anyWord)
+	queryArg := len(args) - 1
+	from := fmt.Sprintf(`
+	FROM workouts w
+	CROSS JOIN (SELECT to_tsquery('english', $%d) AS query, to_tsquery('english', $%d) AS words) q
+	LEFT JOIN LATERAL (
+		SELECT tsvector_agg(e.search_vector) AS vector
+		FROM workout_entries e
+		WHERE e.workout_id = w.id
+	) ev ON TRUE
+	CROSS JOIN LATERAL (SELECT w.search_vector || COALESCE(ev.vector, ''::tsvector) AS document) d
+	WHERE %s AND d.document @@ q.query
+	`, queryArg, queryArg+1, where)
+
+	results := &SearchResults{Hits: []SearchHit{}}
+	if err := pg.db.QueryRow(`SELECT COUNT(*) `+from, args...).Scan(&results.Total); err != nil {
+		return nil, err
+	}
+
+	pageArgs := append(append([]any{}, args...), filter.Limit, filter.Offset)
+	rows, err := pg.db.Query(fmt.Sprintf(`
+	SELECT w.id, w.title, w.created_at,
+	       ts_rank_cd(d.document, q.query) AS rank,
+	       ts_headline('english', w.title, q.words, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+	       CASE WHEN to_tsvector('english', COALESCE(w.description, '')) @@ q.words
+	            THEN ts_headline('english', w.description, q.words, '%s') ELSE '' END
+	`+from+`
+	ORDER BY rank DESC, w.created_at DESC, w.id DESC
+	LIMIT $%d OFFSET $%d
+	`, headlineOptions, len(args)+1, len(args)+2), pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	index := map[int]int{}
+	var ids []int
+	for rows.Next() {
+		var hit SearchHit
+		if err := rows.Scan(&hit.WorkoutId, &hit.Title, &hit.CreatedAt, &hit.Rank, &hit.Highlight, &hit.Description); err != nil {
+			return nil, err
+		}
+		hit.Entries = []EntryHit{}
+		index[hit.WorkoutId] = len(results.Hits)
+		ids = append(ids, hit.WorkoutId)
+		results.Hits = append(results.Hits, hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return results, nil
+	}
+
+	entryRows, err := pg.db.Query(fmt.Sprintf(`
+	SELECT e.workout_id, e.id, e.exercise_name,
+	       ts_headline('english', e.exercise_name, q.words, 'StartSel=<mark>, StopSel=</mark>, HighlightAll=true'),
+	       CASE WHEN to_tsvector('english', COALESCE(e.notes, '')) @@ q.words
+	            THEN ts_headline('english', e.notes, q.words, '%s') ELSE '' END
+	FROM workout_entries e
+	CROSS JOIN (SELECT to_tsquery('english', $2) AS words) q
+	WHERE e.workout_id = ANY($1) AND e.search_vector @@ q.words
+	ORDER BY e.workout_id, e.order_index
+	`, headlineOptions), ids, anyWord)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = entryRows.Close()
+	}()
+	for entryRows.Next() {
+		var workoutID int
+		var hit EntryHit
+		if err := entryRows.Scan(&workoutID, &hit.EntryId, &hit.ExerciseName, &hit.Exercise, &hit.Notes); err != nil {
+			return nil, err
+		}
+		workout := &results.Hits[index[workoutID]]
+		workout.Entries = append(workout.Entries, hit)
+	}
+	return results, entryRows.Err()
+}
@@ -0,0 +1,82 @@
+package store
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixTerms(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+		err  error
+	}{
+		{name: "single word", text: "squa", want: "squa:*"},
+		{name: "words are split", text: "Knee  hurt", want: "knee:* & hurt:*"},
+		{name: "operators are separators", text: "leg & !day | (pain)", want: "leg:* & day:* & pain:*"},
+		{name: "digits and accents", text: "5k Café", want: "5k:* & café:*"},
+		{name: "nothing searchable", text: " &|!: ", err: ErrEmptySearch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := prefixTerms(tt.text)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, strings.Join(got, " & "))
+		})
+	}
+	long, err := prefixTerms("a b c d e f g h i j k l")
+	assert.NoError(t, err)
+	assert.Equal(t, "a:* & b:* & c:* & d:* & e:* & f:* & g:* & h:* & i:* & j:*", strings.Join(long, " & "))
+}
+
+func TestSearchWorkoutsAcrossFields(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewPostgresWorkoutStore(db)
+	_, err := db.Exec(`INSERT INTO users (id,username,email,password_hash) VALUES (1,'lifter','lifter@example.com','x') ON CONFLICT DO NOTHING`)
+	require.NoError(t, err)
+	_, err = store.CreateWorkout(&Workout{
+		UserId: 1,
+		Title:  "Leg day",
+		Entries: []WorkoutEntry{
+			{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), Notes: "knee hurt", OrderIndex: 1},
+			{ExerciseName: "Lunge", Sets: 3, Reps: IntPtr(10), OrderIndex: 2},
+		},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name        string
+		text        string
+		wantHits    int
+		wantEntries []string
+	}{
+		{name: "title and notes", text: "leg knee", wantHits: 1, wantEntries: []string{"Squat"}},
+		{name: "title and exercise", text: "leg lunge", wantHits: 1, wantEntries: []string{"Lunge"}},
+		{name: "two entries", text: "squat lunge", wantHits: 1, wantEntries: []string{"Squat", "Lunge"}},
+		{name: "one word missing", text: "leg shoulder"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := store.SearchWorkouts(WorkoutFilter{UserId: 1, Limit: 10}, tt.text)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantHits, results.Total)
+			require.Len(t, results.Hits, tt.wantHits)
+			if tt.wantHits == 0 {
+				return
+			}
+			var entries []string
+			for _, entry := range results.Hits[0].Entries {
+				entries = append(entries, entry.ExerciseName)
+			}
+			assert.Equal(t, tt.wantEntries, entries)
+		})
+	}
+}
@@ -0,0 +1,484 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/sessions"
+	"github.com/Numeez/go-zenith/internal/units"
+)
+
+var (
+	// ErrSessionChanged is returned when a session was modified between being
+	// read and being finished.
+	ErrSessionChanged = errors.New("session changed while finishing")
+	ErrUnknownEntry   = errors.New("entry is not part of this session")
+)
+
+// Session is a workout in progress. Its entries use the same canonical units
+// as workout entries; each set records the rest taken before it.
+type Session struct {
+	Id                int            `json:"id"`
+	UserId            int            `json:"user_id"`
+	Title             string         `json:"title"`
+	Description       string         `json:"description"`
+	TemplateId        *int           `json:"template_id,omitempty"`
+	Status            string         `json:"status"`
+	StartedAt         time.Time      `json:"started_at"`
+	PausedAt          *time.Time     `json:"paused_at,omitempty"`
+	PausedSeconds     int            `json:"paused_seconds"`
+	RestStartedAt     *time.Time     `json:"-"`
+	RestTargetSeconds *int           `json:"-"`
+	LastActivityAt    time.Time      `json:"last_activity_at"`
+	EndedAt           *time.Time     `json:"ended_at,omitempty"`
+	WorkoutId         *int           `json:"workout_id,omitempty"`
+	Entries           []WorkoutEntry `json:"entries"`
+}
+
+func (s *Session) Clock() sessions.Clock {
+	return sessions.Clock{StartedAt: s.StartedAt, PausedAt: s.PausedAt, PausedSeconds: s.PausedSeconds}
+}
+
+// Rest returns the running rest timer, if any.
+func (s *Session) Rest() *sessions.Rest {
+	if s.RestStartedAt == nil {
+		return nil
+	}
+	rest := &sessions.Rest{StartedAt: *s.RestStartedAt}
+	if s.RestTargetSeconds != nil {
+		rest.TargetSeconds = *s.RestTargetSeconds
+	}
+	return rest
+}
+
+// Workout converts the session into the workout it is finished into, timed up
+// to endedAt. Entries without any logged set are dropped.
+func (s *Session) Workout(endedAt time.Time) *Workout {
+	workout := &Workout{
+		UserId:          s.UserId,
+		Title:           s.Title,
+		Description:     s.Description,
+		TemplateId:      s.TemplateId,
+		DurationMinutes: s.Clock().Minutes(endedAt),
+		Entries:         make([]WorkoutEntry, 0, len(s.Entries)),
+	}
+	for _, entry := range s.Entries {
+		if len(entry.SetLog) == 0 {
+			continue
+		}
+		entry.Id = 0
+		entry.OrderIndex = len(workout.Entries) + 1
+		entry.SetLog = append([]WorkoutSet{}, entry.SetLog...)
+		for i := range entry.SetLog {
+			entry.SetLog[i].Id = 0
+		}
+		entry.DeriveLegacyFields()
+		workout.Entries = append(workout.Entries, entry)
+	}
+	return workout
+}
+
+type PostgresSessionStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSessionStore(db *sql.DB) *PostgresSessionStore {
+	return &PostgresSessionStore{
+		db: db,
+	}
+}
+
+type SessionStore interface {
+	CreateSession(session *Session) (*Session, error)
+	GetSessionById(id int64) (*Session, error)
+	GetOpenSession(userID int) (*Session, error)
+	GetSessionOwner(id int64) (int, error)
+	AddSessionEntry(sessionID int64, entry *WorkoutEntry) error
+	AddSessionSet(sessionID int64, entry *WorkoutEntry, set *WorkoutSet, restSeconds *int) error
+	StartRest(sessionID int64, targetSeconds *int) error
+	TransitionSession(sessionID int64, action string) error
+	FinishSession(session *Session, workout *Workout, endedAt time.Time) error
+	DeleteSession(id int64) error
+	ExpireIdleSessions(idleSince time.Time) ([]Session, error)
+}
+
+func (ps *PostgresSessionStore) CreateSession(session *Session) (*Session, error) {
+	query := `
+	INSERT INTO workout_sessions(user_id,title,description,template_id)
+	VALUES($1,$2,$3,$4)
+	RETURNING id,status,started_at,paused_seconds,last_activity_at
+	`
+	err := ps.db.QueryRow(query, session.UserId, session.Title, session.Description, session.TemplateId).
+		Scan(&session.Id, &session.Status, &session.StartedAt, &session.PausedSeconds, &session.LastActivityAt)
+	if err != nil {
+		return nil, err
+	}
+	session.Entries = []WorkoutEntry{}
+	return session, nil
+}
+
+const sessionColumns = `id,user_id,title,description,template_id,status,started_at,paused_at,paused_seconds,
+	rest_started_at,rest_target_seconds,last_activity_at,ended_at,workout_id`
+
+func scanSession(row rowScanner, session *Session) error {
+	return row.Scan(&session.Id, &session.UserId, &session.Title, &session.Description, &session.TemplateId, &session.Status,
+		&session.StartedAt, &session.PausedAt, &session.PausedSeconds, &session.RestStartedAt, &session.RestTargetSeconds,
+		&session.LastActivityAt, &session.EndedAt, &session.WorkoutId)
+}
+
+func (ps *PostgresSessionStore) GetSessionById(id int64) (*Session, error) {
+	session := &Session{}
+	err := scanSession(ps.db.QueryRow(`SELECT `+sessionColumns+` FROM workout_sessions WHERE id = $1`, id), session)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return session, ps.loadSessionEntries(session)
+}
+
+// GetOpenSession returns the user's active or paused session, or nil.
+func (ps *PostgresSessionStore) GetOpenSession(userID int) (*Session, error) {
+	var id int64
+	err := ps.db.QueryRow(`SELECT id FROM workout_sessions WHERE user_id = $1 AND status IN ('active', 'paused')`, userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ps.GetSessionById(id)
+}
+
+func (ps *PostgresSessionStore) loadSessionEntries(session *Session) error {
+	session.Entries = []WorkoutEntry{}
+	rows, err := ps.db.Query(`
+	SELECT id,exercise_name,weight_unit,notes,order_index
+	FROM workout_session_entries
+	WHERE session_id = $1
+	ORDER BY order_index
+	`, session.Id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	byEntry := map[int]int{}
+	for rows.Next() {
+		var entry WorkoutEntry
+		if err := rows.Scan(&entry.Id, &entry.ExerciseName, &entry.WeightUnit, &entry.Notes, &entry.OrderIndex); err != nil {
+			return err
+		}
+		byEntry[entry.Id] = len(session.Entries)
+		session.Entries = append(session.Entries, entry)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(session.Entries) == 0 {
+		return nil
+	}
+	setRows, err := ps.db.Query(`
+	SELECT s.entry_id,s.id,s.set_number,s.set_type,s.reps,s.weight,s.duration_seconds,s.distance_meters,s.rpe,s.rir,s.rest_seconds
+	FROM workout_session_sets s
+	INNER JOIN workout_session_entries e ON e.id = s.entry_id
+	WHERE e.session_id = $1
+	ORDER BY s.entry_id, s.set_number
+	`, session.Id)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = setRows.Close()
+	}()
+	for setRows.Next() {
+		var entryID int
+		var set WorkoutSet
+		if err := setRows.Scan(&entryID, &set.Id, &set.SetNumber, &set.SetType, &set.Reps, &set.Weight, &set.DurationSeconds,
+			&set.DistanceMeters, &set.RPE, &set.RIR, &set.RestSeconds); err != nil {
+			return err
+		}
+		completed := true
+		set.Completed = &completed
+		entry := &session.Entries[byEntry[entryID]]
+		entry.SetLog = append(entry.SetLog, set)
+	}
+	return setRows.Err()
+}
+
+func (ps *PostgresSessionStore) GetSessionOwner(id int64) (int, error) {
+	var userID int
+	err := ps.db.QueryRow(`SELECT user_id FROM workout_sessions WHERE id = $1`, id).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// lockSession locks the session row for the rest of the transaction and
+// checks that action is allowed in its current status.
+func lockSession(tx *sql.Tx, sessionID int64, action string) (string, error) {
+	var status string
+	err := tx.QueryRow(`SELECT status FROM workout_sessions WHERE id = $1 FOR UPDATE`, sessionID).Scan(&status)
+	if err != nil {
+		return "", err
+	}
+	return sessions.Next(status, action)
+}
+
+func insertSessionEntry(tx *sql.Tx, sessionID int64, entry *WorkoutEntry) error {
+	if entry.WeightUnit == "" {
+		entry.WeightUnit = units.Kilogram
+	}
+	query := `
+	INSERT INTO workout_session_entries(session_id,exercise_name,weight_unit,notes,order_index)
+	VALUES($1,$2,$3,$4,(SELECT COALESCE(MAX(order_index), 0) + 1 FROM workout_session_entries WHERE session_id = $1))
+	RETURNING id,order_index
+	`
+	return tx.QueryRow(query, sessionID, entry.ExerciseName, entry.WeightUnit, entry.Notes).Scan(&entry.Id, &entry.OrderIndex)
+}
+
+func touchSession(tx *sql.Tx, sessionID int64) error {
+	_, err := tx.Exec(`UPDATE workout_sessions SET last_activity_at = CURRENT_TIMESTAMP WHERE id = $1`, sessionID)
+	return err
+}
+
+// AddSessionEntry starts a new exercise in an active session.
+func (ps *PostgresSessionStore) AddSessionEntry(sessionID int64, entry *WorkoutEntry) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := lockSession(tx, sessionID, sessions.ActionLog); err != nil {
+		return err
+	}
+	if err := insertSessionEntry(tx, sessionID, entry); err != nil {
+		return err
+	}
+	if err := touchSession(tx, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// AddSessionSet logs a performed set. With a zero entry.Id the set goes to the
+// session's last entry when it is the same exercise, otherwise to a new entry.
+// The running rest timer is stopped and recorded on the set, and a new one is
+// started with restSeconds as its target.
+func (ps *PostgresSessionStore) AddSessionSet(sessionID int64, entry *WorkoutEntry, set *WorkoutSet, restSeconds *int) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := lockSession(tx, sessionID, sessions.ActionLog); err != nil {
+		return err
+	}
+	if entry.Id == 0 {
+		var last WorkoutEntry
+		err := tx.QueryRow(`
+		SELECT id,exercise_name,weight_unit,order_index FROM workout_session_entries
+		WHERE session_id = $1 ORDER BY order_index DESC LIMIT 1
+		`, sessionID).Scan(&last.Id, &last.ExerciseName, &last.WeightUnit, &last.OrderIndex)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+		if err == nil && strings.EqualFold(last.ExerciseName, entry.ExerciseName) {
+			*entry = last
+		} else if err := insertSessionEntry(tx, sessionID, entry); err != nil {
+			return err
+		}
+	} else {
+		err := tx.QueryRow(`
+		SELECT exercise_name,weight_unit,order_index FROM workout_session_entries WHERE id = $1 AND session_id = $2
+		`, entry.Id, sessionID).Scan(&entry.ExerciseName, &entry.WeightUnit, &entry.OrderIndex)
+		if err == sql.ErrNoRows {
+			return ErrUnknownEntry
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if set.SetType == "" {
+		set.SetType = SetTypeWorking
+	}
+	query := `
+	INSERT INTO workout_session_sets(entry_id,set_number,set_type,reps,weight,duration_seconds,distance_meters,rpe,rir,rest_seconds)
+	VALUES($1,
+	       (SELECT COALESCE(MAX(set_number), 0) + 1 FROM workout_session_sets WHERE entry_id = $1),
+	       $2,$3,$4,$5,$6,$7,$8,
+	       (SELECT EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - rest_started_at)::int FROM workout_sessions WHERE id = $9))
+	RETURNING id,set_number,rest_seconds
+	`
+	err = tx.QueryRow(query, entry.Id, set.SetType, set.Reps, set.Weight, set.DurationSeconds, set.DistanceMeters, set.RPE, set.RIR, sessionID).
+		Scan(&set.Id, &set.SetNumber, &set.RestSeconds)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE workout_sessions
+	SET rest_started_at = CURRENT_TIMESTAMP, rest_target_seconds = $1, last_activity_at = CURRENT_TIMESTAMP
+	WHERE id = $2
+	`, restSeconds, sessionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// StartRest restarts the rest timer, counting down from targetSeconds when it
+// is set.
+func (ps *PostgresSessionStore) StartRest(sessionID int64, targetSeconds *int) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if _, err := lockSession(tx, sessionID, sessions.ActionLog); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+	UPDATE workout_sessions
+	SET rest_started_at = CURRENT_TIMESTAMP, rest_target_seconds = $1, last_activity_at = CURRENT_TIMESTAMP
+	WHERE id = $2
+	`, targetSeconds, sessionID)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// TransitionSession pauses or resumes a session. Resuming adds the pause to
+// paused_seconds and drops the rest timer, which would otherwise count the
+// pause as rest.
+func (ps *PostgresSessionStore) TransitionSession(sessionID int64, action string) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	next, err := lockSession(tx, sessionID, action)
+	if err != nil {
+		return err
+	}
+	var query string
+	switch action {
+	case sessions.ActionPause:
+		query = `
+		UPDATE workout_sessions
+		SET status = $1, paused_at = CURRENT_TIMESTAMP, last_activity_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		`
+	case sessions.ActionResume:
+		query = `
+		UPDATE workout_sessions
+		SET status = $1,
+		    paused_seconds = paused_seconds + EXTRACT(EPOCH FROM CURRENT_TIMESTAMP - paused_at)::int,
+		    paused_at = NULL, rest_started_at = NULL, rest_target_seconds = NULL,
+		    last_activity_at = CURRENT_TIMESTAMP
+		WHERE id = $2
+		`
+	default:
+		return sessions.ErrInvalidTransition
+	}
+	if _, err := tx.Exec(query, next, sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// FinishSession saves workout, built from session, and closes the session in
+// the same transaction. It fails with ErrSessionChanged when anything was
+// logged after session was read, so no set is lost.
+func (ps *PostgresSessionStore) FinishSession(session *Session, workout *Workout, endedAt time.Time) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, session.UserId); err != nil {
+		return err
+	}
+	next, err := lockSession(tx, int64(session.Id), sessions.ActionFinish)
+	if err != nil {
+		return err
+	}
+	var lastActivity time.Time
+	if err := tx.QueryRow(`SELECT last_activity_at FROM workout_sessions WHERE id = $1`, session.Id).Scan(&lastActivity); err != nil {
+		return err
+	}
+	if !lastActivity.Equal(session.LastActivityAt) {
+		return ErrSessionChanged
+	}
+	if err := createWorkoutTx(tx, workout); err != nil {
+		return err
+	}
+	clock := session.Clock()
+	pausedSeconds := int((endedAt.Sub(session.StartedAt) - clock.Elapsed(endedAt)) / time.Second)
+	_, err = tx.Exec(`
+	UPDATE workout_sessions
+	SET status = $1, ended_at = $2, paused_at = NULL, paused_seconds = $3, workout_id = $4,
+	    rest_started_at = NULL, rest_target_seconds = NULL, last_activity_at = CURRENT_TIMESTAMP
+	WHERE id = $5
+	`, next, endedAt, pausedSeconds, workout.Id, session.Id)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (ps *PostgresSessionStore) DeleteSession(id int64) error {
+	result, err := ps.db.Exec(`DELETE FROM workout_sessions WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// ExpireIdleSessions closes every open session without activity since
+// idleSince and returns their ids and owners.
+func (ps *PostgresSessionStore) ExpireIdleSessions(idleSince time.Time) ([]Session, error) {
+	rows, err := ps.db.Query(`
+	UPDATE workout_sessions
+	SET status = $1, ended_at = CURRENT_TIMESTAMP, rest_started_at = NULL, rest_target_seconds = NULL
+	WHERE status IN ('active', 'paused') AND last_activity_at < $2
+	RETURNING id, user_id, status
+	`, sessions.StatusExpired, idleSince)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var expired []Session
+	for rows.Next() {
+		var session Session
+		if err := rows.Scan(&session.Id, &session.UserId, &session.Status); err != nil {
+			return nil, err
+		}
+		expired = append(expired, session)
+	}
+	return expired, rows.Err()
+}
@@ -0,0 +1,49 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionWorkout(t *testing.T) {
+	start := time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)
+	session := &Session{
+		Id:            3,
+		UserId:        1,
+		Title:         "Leg Day",
+		StartedAt:     start,
+		PausedSeconds: 600,
+		Entries: []WorkoutEntry{
+			{Id: 7, ExerciseName: "Squat", WeightUnit: "kg", OrderIndex: 1, SetLog: []WorkoutSet{
+				{Id: 1, SetNumber: 1, SetType: SetTypeWarmup, Reps: IntPtr(5), Weight: FloatPtr(60)},
+				{Id: 2, SetNumber: 2, SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(100), RestSeconds: IntPtr(150)},
+				{Id: 3, SetNumber: 3, SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(100), RestSeconds: IntPtr(180)},
+			}},
+			{Id: 8, ExerciseName: "Lunge", OrderIndex: 2},
+			{Id: 9, ExerciseName: "Plank", OrderIndex: 3, SetLog: []WorkoutSet{
+				{Id: 4, SetNumber: 1, SetType: SetTypeWorking, DurationSeconds: IntPtr(60)},
+			}},
+		},
+	}
+
+	workout := session.Workout(start.Add(70 * time.Minute))
+	assert.Equal(t, "Leg Day", workout.Title)
+	assert.Equal(t, 60, workout.DurationMinutes)
+	require.Len(t, workout.Entries, 2, "entries without sets are dropped")
+
+	squat := workout.Entries[0]
+	assert.Zero(t, squat.Id)
+	assert.Equal(t, 2, squat.Sets)
+	assert.Equal(t, 100.0, *squat.Weight)
+	assert.Equal(t, 5, *squat.Reps)
+	assert.Zero(t, squat.SetLog[1].Id)
+	assert.Equal(t, 150, *squat.SetLog[1].RestSeconds)
+	assert.Equal(t, 2, session.Entries[0].SetLog[1].Id, "session must not change")
+
+	plank := workout.Entries[1]
+	assert.Equal(t, 2, plank.OrderIndex)
+	assert.Equal(t, 60, *plank.DurationSeconds)
+}
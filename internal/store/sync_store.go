@@ -0,0 +1,253 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// The kinds of record a client can sync. Entries travel inside their
+// workout.
+const (
+	SyncWorkout     = "workout"
+	SyncTemplate    = "template"
+	SyncMeasurement = "measurement"
+)
+
+// syncLockClass keys the advisory lock lockSyncFeed takes per user.
+const syncLockClass = 4901
+
+// SyncChange is the latest change to one record. Seq grows with every write
+// to any synced record; Deleted marks a tombstone.
+type SyncChange struct {
+	Entity    string    `json:"entity"`
+	EntityId  int64     `json:"id"`
+	UserId    int       `json:"-"`
+	Seq       int64     `json:"seq"`
+	Deleted   bool      `json:"deleted"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+type PostgresSyncStore struct {
+	db *sql.DB
+}
+
+func NewPostgresSyncStore(db *sql.DB) *PostgresSyncStore {
+	return &PostgresSyncStore{
+		db: db,
+	}
+}
+
+type SyncStore interface {
+	ListChanges(userID int, since int64, limit int) ([]SyncChange, error)
+	GetChange(entity string, id int64) (*SyncChange, error)
+	RunSync(userID int, fn func(tx SyncTx) error) error
+}
+
+// SyncTx reads and writes the user's synced records in one transaction that
+// holds the user's feed lock, so no other write to them lands between a read
+// and a write made through it. The writes behave like the stores' own;
+// deleting a workout ignores its version.
+type SyncTx interface {
+	GetChange(entity string, id int64) (*SyncChange, error)
+	GetWorkout(id int64) (*Workout, error)
+	CreateWorkout(workout *Workout) error
+	UpdateWorkout(workout *Workout) error
+	DeleteWorkout(id int64) error
+	GetTemplate(id int64) (*WorkoutTemplate, error)
+	CreateTemplate(template *WorkoutTemplate) error
+	UpdateTemplate(template *WorkoutTemplate) error
+	DeleteTemplate(id int64) error
+	GetMeasurement(id int64) (*BodyMeasurement, error)
+	CreateMeasurement(measurement *BodyMeasurement) error
+	UpdateMeasurement(measurement *BodyMeasurement) error
+	DeleteMeasurement(id int64) error
+}
+
+// lockSyncFeed locks the user's change feed until the transaction ends, so
+// that the user's changes become visible in seq order and a reader never
+// skips one that commits late. Every transaction that calls recordChange
+// takes it first, before any row lock: taken later, two transactions could
+// each hold a row the other waits for.
+func lockSyncFeed(tx *sql.Tx, userID int) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, $2)`, syncLockClass, userID)
+	return err
+}
+
+// lockOwnerSyncFeed takes lockSyncFeed for the owner of the row of table with
+// the id, and does nothing when there is no such row.
+func lockOwnerSyncFeed(tx *sql.Tx, table string, id int64) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock($1, user_id::int) FROM `+table+` WHERE id = $2`, syncLockClass, id)
+	return err
+}
+
+// recordChange gives the record the next change seq. The transaction must
+// hold lockSyncFeed for the user.
+func recordChange(tx *sql.Tx, userID int, entity string, id int64, deleted bool) error {
+	query := `
+	INSERT INTO sync_changes(entity,entity_id,user_id,seq,deleted,changed_at)
+	VALUES($1,$2,$3,nextval('sync_change_seq'),$4,CURRENT_TIMESTAMP)
+	ON CONFLICT (entity, entity_id) DO UPDATE
+	SET seq = EXCLUDED.seq, deleted = EXCLUDED.deleted, changed_at = EXCLUDED.changed_at
+	`
+	_, err := tx.Exec(query, entity, id, userID, deleted)
+	return err
+}
+
+// touchWorkouts moves on the version of the workouts matching where, whose
+// output changed through another record, and records a change for each. The
+// transaction must hold lockSyncFeed for their owners.
+func touchWorkouts(tx *sql.Tx, where string, args ...any) error {
+	rows, err := tx.Query(`UPDATE workouts SET version = version + 1 WHERE `+where+` RETURNING id, user_id, deleted_at IS NOT NULL`, args...)
+	if err != nil {
+		return err
+	}
+	type touched struct {
+		id      int64
+		userID  int
+		trashed bool
+	}
+	var workouts []touched
+	for rows.Next() {
+		var workout touched
+		if err := rows.Scan(&workout.id, &workout.userID, &workout.trashed); err != nil {
+			_ = rows.Close()
+			return err
+		}
+		workouts = append(workouts, workout)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, workout := range workouts {
+		if err := recordChange(tx, workout.userID, SyncWorkout, workout.id, workout.trashed); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListChanges returns up to limit of the user's changes with a seq above
+// since, in seq order. Each record appears once, with its latest change.
+func (ps *PostgresSyncStore) ListChanges(userID int, since int64, limit int) ([]SyncChange, error) {
+	query := `
+	SELECT entity,entity_id,user_id,seq,deleted,changed_at
+	FROM sync_changes
+	WHERE user_id = $1 AND seq > $2
+	ORDER BY seq
+	LIMIT $3
+	`
+	rows, err := ps.db.Query(query, userID, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	changes := []SyncChange{}
+	for rows.Next() {
+		var change SyncChange
+		if err := rows.Scan(&change.Entity, &change.EntityId, &change.UserId, &change.Seq, &change.Deleted, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// GetChange returns the latest change to the record, or nil when it has none.
+func (ps *PostgresSyncStore) GetChange(entity string, id int64) (*SyncChange, error) {
+	return getChange(ps.db, entity, id)
+}
+
+func getChange(db rowQueryer, entity string, id int64) (*SyncChange, error) {
+	change := &SyncChange{}
+	query := `
+	SELECT entity,entity_id,user_id,seq,deleted,changed_at
+	FROM sync_changes
+	WHERE entity = $1 AND entity_id = $2
+	`
+	err := db.QueryRow(query, entity, id).Scan(&change.Entity, &change.EntityId, &change.UserId, &change.Seq, &change.Deleted, &change.ChangedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+type postgresSyncTx struct {
+	tx *sql.Tx
+}
+
+// RunSync commits the writes made through tx when fn returns nil and rolls
+// all of them back otherwise.
+func (ps *PostgresSyncStore) RunSync(userID int, fn func(tx SyncTx) error) error {
+	tx, err := ps.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, userID); err != nil {
+		return err
+	}
+	if err := fn(&postgresSyncTx{tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (st *postgresSyncTx) GetChange(entity string, id int64) (*SyncChange, error) {
+	return getChange(st.tx, entity, id)
+}
+
+func (st *postgresSyncTx) GetWorkout(id int64) (*Workout, error) {
+	return loadWorkout(st.tx, id)
+}
+
+func (st *postgresSyncTx) CreateWorkout(workout *Workout) error {
+	return createWorkoutTx(st.tx, workout)
+}
+
+func (st *postgresSyncTx) UpdateWorkout(workout *Workout) error {
+	return saveWorkoutTx(st.tx, workout)
+}
+
+func (st *postgresSyncTx) DeleteWorkout(id int64) error {
+	return trashWorkoutTx(st.tx, id, 0)
+}
+
+func (st *postgresSyncTx) GetTemplate(id int64) (*WorkoutTemplate, error) {
+	return loadTemplate(st.tx, id)
+}
+
+func (st *postgresSyncTx) CreateTemplate(template *WorkoutTemplate) error {
+	return createTemplateTx(st.tx, template)
+}
+
+func (st *postgresSyncTx) UpdateTemplate(template *WorkoutTemplate) error {
+	return updateTemplateTx(st.tx, template)
+}
+
+func (st *postgresSyncTx) DeleteTemplate(id int64) error {
+	return deleteTemplateTx(st.tx, id)
+}
+
+func (st *postgresSyncTx) GetMeasurement(id int64) (*BodyMeasurement, error) {
+	return getMeasurement(st.tx, id)
+}
+
+func (st *postgresSyncTx) CreateMeasurement(measurement *BodyMeasurement) error {
+	return createMeasurementTx(st.tx, measurement)
+}
+
+func (st *postgresSyncTx) UpdateMeasurement(measurement *BodyMeasurement) error {
+	return updateMeasurementTx(st.tx, measurement)
+}
+
+func (st *postgresSyncTx) DeleteMeasurement(id int64) error {
+	return deleteMeasurementTx(st.tx, id)
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrUnknownTag is returned when a workout references a tag that does not
+// exist or belongs to another user.
+var ErrUnknownTag = errors.New("unknown tag")
+
+type Tag struct {
+	Id        int       `json:"id"`
+	UserId    int       `json:"user_id"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TagSummary totals the workouts carrying a tag.
+type TagSummary struct {
+	Tag           Tag `json:"tag"`
+	Workouts      int `json:"workouts"`
+	TotalMinutes  int `json:"total_minutes"`
+	TotalCalories int `json:"total_calories"`
+}
+
+type PostgresTagStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTagStore(db *sql.DB) *PostgresTagStore {
+	return &PostgresTagStore{
+		db: db,
+	}
+}
+
+type TagStore interface {
+	CreateTag(tag *Tag) (*Tag, error)
+	GetTagById(id int64) (*Tag, error)
+	ListTags(userID int) ([]Tag, error)
+	UpdateTag(tag *Tag) error
+	DeleteTag(id int64) error
+	GetTagOwner(id int64) (int, error)
+}
+
+func (pt *PostgresTagStore) CreateTag(tag *Tag) (*Tag, error) {
+	query := `
+	INSERT INTO tags(user_id,name,color)
+	VALUES($1,$2,$3)
+	RETURNING id,created_at
+	`
+	if err := pt.db.QueryRow(query, tag.UserId, tag.Name, tag.Color).Scan(&tag.Id, &tag.CreatedAt); err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (pt *PostgresTagStore) GetTagById(id int64) (*Tag, error) {
+	tag := &Tag{}
+	query := `SELECT id,user_id,name,color,created_at FROM tags WHERE id = $1`
+	err := pt.db.QueryRow(query, id).Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.Color, &tag.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (pt *PostgresTagStore) ListTags(userID int) ([]Tag, error) {
+	query := `SELECT id,user_id,name,color,created_at FROM tags WHERE user_id = $1 ORDER BY lower(name)`
+	rows, err := pt.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	tags := []Tag{}
+	for rows.Next() {
+		var tag Tag
+		if err := rows.Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+// UpdateTag renames or recolours the tag. Workouts embed their tags, so the
+// ones carrying it are recorded as changed in the same transaction.
+func (pt *PostgresTagStore) UpdateTag(tag *Tag) error {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "tags", int64(tag.Id)); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`UPDATE tags SET name=$1,color=$2 WHERE id=$3`, tag.Name, tag.Color, tag.Id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	if err := touchWorkouts(tx, `id IN (SELECT workout_id FROM workout_tags WHERE tag_id = $1)`, tag.Id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// DeleteTag removes the tag from the workouts carrying it, which are recorded
+// as changed before the links go.
+func (pt *PostgresTagStore) DeleteTag(id int64) error {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "tags", id); err != nil {
+		return err
+	}
+	if err := touchWorkouts(tx, `id IN (SELECT workout_id FROM workout_tags WHERE tag_id = $1)`, id); err != nil {
+		return err
+	}
+	result, err := tx.Exec(`DELETE FROM tags WHERE id=$1`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return tx.Commit()
+}
+
+func (pt *PostgresTagStore) GetTagOwner(id int64) (int, error) {
+	var userID int
+	err := pt.db.QueryRow(`SELECT user_id FROM tags WHERE id = $1`, id).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+type queryer interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}
+
+// insertWorkoutTags links the workout to TagIds, refusing tags the workout's
+// owner does not have, and reloads Tags.
+func insertWorkoutTags(tx *sql.Tx, workout *Workout) error {
+	if len(workout.TagIds) > 0 {
+		distinct := map[int]bool{}
+		for _, id := range workout.TagIds {
+			distinct[id] = true
+		}
+		result, err := tx.Exec(`
+		INSERT INTO workout_tags(workout_id,tag_id)
+		SELECT $1, t.id FROM tags t WHERE t.id = ANY($2) AND t.user_id = $3
+		`, workout.Id, workout.TagIds, workout.UserId)
+		if err != nil {
+			return err
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if int(inserted) != len(distinct) {
+			return ErrUnknownTag
+		}
+	}
+	return loadWorkoutTags(tx, workout)
+}
+
+func loadWorkoutTags(db queryer, workouts ...*Workout) error {
+	byId := make(map[int]*Workout, len(workouts))
+	ids := make([]int, 0, len(workouts))
+	for _, workout := range workouts {
+		workout.Tags = []Tag{}
+		workout.TagIds = []int{}
+		byId[workout.Id] = workout
+		ids = append(ids, workout.Id)
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	query := `
+	SELECT wt.workout_id,t.id,t.user_id,t.name,t.color,t.created_at
+	FROM tags t
+	INNER JOIN workout_tags wt ON wt.tag_id = t.id
+	WHERE wt.workout_id = ANY($1)
+	ORDER BY lower(t.name)
+	`
+	rows, err := db.Query(query, ids)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var workoutID int
+		var tag Tag
+		if err := rows.Scan(&workoutID, &tag.Id, &tag.UserId, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+			return err
+		}
+		workout := byId[workoutID]
+		workout.Tags = append(workout.Tags, tag)
+		workout.TagIds = append(workout.TagIds, tag.Id)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,416 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// ErrTemplateInUse is returned when deleting a template that a program still
+// schedules.
+var ErrTemplateInUse = errors.New("template is used by a program")
+
+type WorkoutTemplate struct {
+	Id          int             `json:"id"`
+	UserId      int             `json:"user_id"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Entries     []TemplateEntry `json:"entries"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type TemplateEntry struct {
+	Id                    int      `json:"id"`
+	ExerciseName          string   `json:"exercise_name"`
+	TargetSets            int      `json:"target_sets"`
+	RepRangeMin           *int     `json:"rep_range_min"`
+	RepRangeMax           *int     `json:"rep_range_max"`
+	TargetDurationSeconds *int     `json:"target_duration_seconds"`
+	TargetWeight          *float64 `json:"target_weight"`
+	WeightUnit            string   `json:"weight_unit,omitempty"`
+	TargetPercent1RM      *float64 `json:"target_percent_1rm"`
+	RestSeconds           *int     `json:"rest_seconds"`
+	Notes                 string   `json:"notes"`
+	OrderIndex            int      `json:"order_index"`
+}
+
+type PostgresTemplateStore struct {
+	db *sql.DB
+}
+
+func NewPostgresTemplateStore(db *sql.DB) *PostgresTemplateStore {
+	return &PostgresTemplateStore{
+		db: db,
+	}
+}
+
+type TemplateStore interface {
+	CreateTemplate(template *WorkoutTemplate) (*WorkoutTemplate, error)
+	GetTemplateById(id int64) (*WorkoutTemplate, error)
+	GetTemplatesByIds(ids []int64) (map[int64]*WorkoutTemplate, error)
+	ListTemplates(userID int) ([]WorkoutTemplate, error)
+	UpdateTemplate(template *WorkoutTemplate) error
+	DeleteTemplate(id int64) error
+	GetTemplateOwner(id int64) (int, error)
+	GetTemplateOwners(ids []int64) (map[int64]int, error)
+}
+
+func insertTemplateEntries(tx *sql.Tx, template *WorkoutTemplate) error {
+	query := `
+	INSERT INTO workout_template_entries (template_id,exercise_name,target_sets,rep_range_min,rep_range_max,target_duration_seconds,target_weight,weight_unit,target_percent_1rm,rest_seconds,notes,order_index)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12)
+	RETURNING id
+	`
+	for i := range template.Entries {
+		entry := &template.Entries[i]
+		if entry.WeightUnit == "" {
+			entry.WeightUnit = units.Kilogram
+		}
+		err := tx.QueryRow(query, template.Id, entry.ExerciseName, entry.TargetSets, entry.RepRangeMin, entry.RepRangeMax,
+			entry.TargetDurationSeconds, entry.TargetWeight, entry.WeightUnit, entry.TargetPercent1RM, entry.RestSeconds, entry.Notes, entry.OrderIndex).Scan(&entry.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pt *PostgresTemplateStore) CreateTemplate(template *WorkoutTemplate) (*WorkoutTemplate, error) {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, template.UserId); err != nil {
+		return nil, err
+	}
+	if err := createTemplateTx(tx, template); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return template, nil
+}
+
+func createTemplateTx(tx *sql.Tx, template *WorkoutTemplate) error {
+	query := `
+	INSERT INTO workout_templates(user_id,title,description)
+	VALUES($1,$2,$3)
+	RETURNING id,created_at,updated_at
+	`
+	err := tx.QueryRow(query, template.UserId, template.Title, template.Description).Scan(&template.Id, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if err := insertTemplateEntries(tx, template); err != nil {
+		return err
+	}
+	return recordChange(tx, template.UserId, SyncTemplate, int64(template.Id), false)
+}
+
+func (pt *PostgresTemplateStore) GetTemplateById(id int64) (*WorkoutTemplate, error) {
+	return loadTemplate(pt.db, id)
+}
+
+// GetTemplatesByIds returns the templates that exist among ids, by id.
+func (pt *PostgresTemplateStore) GetTemplatesByIds(ids []int64) (map[int64]*WorkoutTemplate, error) {
+	return loadTemplates(pt.db, ids)
+}
+
+func loadTemplate(db queryer, id int64) (*WorkoutTemplate, error) {
+	templates, err := loadTemplates(db, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	return templates[id], nil
+}
+
+// loadTemplates reads the templates with their entries in two queries.
+func loadTemplates(db queryer, ids []int64) (map[int64]*WorkoutTemplate, error) {
+	templates := make(map[int64]*WorkoutTemplate, len(ids))
+	if len(ids) == 0 {
+		return templates, nil
+	}
+	query := `
+	SELECT id,user_id,title,COALESCE(description,''),created_at,updated_at
+	FROM workout_templates
+	WHERE id = ANY($1)
+	`
+	rows, err := db.Query(query, ids)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		template := &WorkoutTemplate{}
+		if err := rows.Scan(&template.Id, &template.UserId, &template.Title, &template.Description, &template.CreatedAt, &template.UpdatedAt); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		templates[int64(template.Id)] = template
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(templates) == 0 {
+		return templates, nil
+	}
+	entryQuery := `
+	SELECT template_id,id,exercise_name,target_sets,rep_range_min,rep_range_max,target_duration_seconds,target_weight,weight_unit,target_percent_1rm,rest_seconds,COALESCE(notes,''),order_index
+	FROM workout_template_entries
+	WHERE template_id = ANY($1)
+	ORDER BY template_id, order_index
+	`
+	rows, err = db.Query(entryQuery, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var templateID int64
+		var entry TemplateEntry
+		if err := rows.Scan(
+			&templateID,
+			&entry.Id,
+			&entry.ExerciseName,
+			&entry.TargetSets,
+			&entry.RepRangeMin,
+			&entry.RepRangeMax,
+			&entry.TargetDurationSeconds,
+			&entry.TargetWeight,
+			&entry.WeightUnit,
+			&entry.TargetPercent1RM,
+			&entry.RestSeconds,
+			&entry.Notes,
+			&entry.OrderIndex,
+		); err != nil {
+			return nil, err
+		}
+		template := templates[templateID]
+		template.Entries = append(template.Entries, entry)
+	}
+	return templates, rows.Err()
+}
+
+func (pt *PostgresTemplateStore) ListTemplates(userID int) ([]WorkoutTemplate, error) {
+	query := `
+	SELECT id
+	FROM workout_templates
+	WHERE user_id = $1
+	ORDER BY title, id
+	`
+	rows, err := pt.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	loaded, err := loadTemplates(pt.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	templates := make([]WorkoutTemplate, 0, len(ids))
+	for _, id := range ids {
+		if template, ok := loaded[id]; ok {
+			templates = append(templates, *template)
+		}
+	}
+	return templates, nil
+}
+
+func (pt *PostgresTemplateStore) UpdateTemplate(template *WorkoutTemplate) error {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "workout_templates", int64(template.Id)); err != nil {
+		return err
+	}
+	if err := updateTemplateTx(tx, template); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func updateTemplateTx(tx *sql.Tx, template *WorkoutTemplate) error {
+	query := `
+	UPDATE workout_templates
+	SET title=$1,description=$2,updated_at=CURRENT_TIMESTAMP
+	WHERE id=$3
+	RETURNING user_id,created_at,updated_at
+	`
+	err := tx.QueryRow(query, template.Title, template.Description, template.Id).Scan(&template.UserId, &template.CreatedAt, &template.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM workout_template_entries WHERE template_id=$1", template.Id); err != nil {
+		return err
+	}
+	if err := insertTemplateEntries(tx, template); err != nil {
+		return err
+	}
+	return recordChange(tx, template.UserId, SyncTemplate, int64(template.Id), false)
+}
+
+func (pt *PostgresTemplateStore) DeleteTemplate(id int64) error {
+	tx, err := pt.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "workout_templates", id); err != nil {
+		return err
+	}
+	if err := deleteTemplateTx(tx, id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func deleteTemplateTx(tx *sql.Tx, id int64) error {
+	var userID int
+	err := tx.QueryRow(`DELETE FROM workout_templates WHERE id=$1 RETURNING user_id`, id).Scan(&userID)
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return ErrTemplateInUse
+	}
+	if err != nil {
+		return err
+	}
+	return recordChange(tx, userID, SyncTemplate, id, true)
+}
+
+func (pt *PostgresTemplateStore) GetTemplateOwner(id int64) (int, error) {
+	var userID int
+	query := `
+	SELECT user_id
+	FROM workout_templates
+	WHERE id = $1
+	`
+	err := pt.db.QueryRow(query, id).Scan(&userID)
+	if err != nil {
+		return 0, err
+	}
+	return userID, nil
+}
+
+// GetTemplateOwners maps each of ids that names a template to its owner;
+// unknown ids are left out.
+func (pt *PostgresTemplateStore) GetTemplateOwners(ids []int64) (map[int64]int, error) {
+	rows, err := pt.db.Query(`SELECT id, user_id FROM workout_templates WHERE id = ANY($1)`, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	owners := make(map[int64]int, len(ids))
+	for rows.Next() {
+		var id int64
+		var owner int
+		if err := rows.Scan(&id, &owner); err != nil {
+			return nil, err
+		}
+		owners[id] = owner
+	}
+	return owners, rows.Err()
+}
+
+// NewWorkout builds an unsaved workout pre-filled from the template. Targets
+// expressed as a percentage of a one-rep max are resolved with oneRepMax,
+// keyed by lower-cased exercise name and rounded to half a unit of the
+// template's weight unit; unknown maxes leave the weight empty.
+func (t *WorkoutTemplate) NewWorkout(oneRepMax map[string]float64) *Workout {
+	templateID := t.Id
+	workout := &Workout{
+		UserId:      t.UserId,
+		Title:       t.Title,
+		Description: t.Description,
+		TemplateId:  &templateID,
+		Entries:     make([]WorkoutEntry, 0, len(t.Entries)),
+	}
+	for _, te := range t.Entries {
+		entry := WorkoutEntry{
+			ExerciseName:    te.ExerciseName,
+			Sets:            te.TargetSets,
+			DurationSeconds: te.TargetDurationSeconds,
+			Weight:          te.TargetWeight,
+			WeightUnit:      te.WeightUnit,
+			Notes:           te.Notes,
+			OrderIndex:      te.OrderIndex,
+		}
+		if te.RepRangeMin != nil {
+			entry.Reps = te.RepRangeMin
+		} else {
+			entry.Reps = te.RepRangeMax
+		}
+		if te.TargetPercent1RM != nil {
+			if max, ok := oneRepMax[strings.ToLower(te.ExerciseName)]; ok {
+				weight := roundToHalfUnit(max**te.TargetPercent1RM/100, te.WeightUnit)
+				entry.Weight = &weight
+			}
+		}
+		workout.Entries = append(workout.Entries, entry)
+	}
+	return workout
+}
+
+// roundToHalfUnit rounds a weight in kg to the nearest half kg or half pound,
+// so targets land on loadable plates.
+func roundToHalfUnit(kg float64, unit string) float64 {
+	if unit != units.Pound {
+		return math.Round(kg*2) / 2
+	}
+	return math.Round(kg/units.KilogramsPerPound*2) / 2 * units.KilogramsPerPound
+}
+
+// TemplateFromWorkout captures the structure of a logged workout so it can be
+// repeated later.
+func TemplateFromWorkout(workout *Workout) *WorkoutTemplate {
+	template := &WorkoutTemplate{
+		UserId:      workout.UserId,
+		Title:       workout.Title,
+		Description: workout.Description,
+		Entries:     make([]TemplateEntry, 0, len(workout.Entries)),
+	}
+	for _, entry := range workout.Entries {
+		template.Entries = append(template.Entries, TemplateEntry{
+			ExerciseName:          entry.ExerciseName,
+			TargetSets:            entry.Sets,
+			RepRangeMax:           entry.Reps,
+			TargetDurationSeconds: entry.DurationSeconds,
+			TargetWeight:          entry.Weight,
+			WeightUnit:            entry.WeightUnit,
+			Notes:                 entry.Notes,
+			OrderIndex:            entry.OrderIndex,
+		})
+	}
+	return template
+}
@@ -0,0 +1,84 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/Numeez/go-zenith/internal/units"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTemplateNewWorkout(t *testing.T) {
+	template := &WorkoutTemplate{
+		Id:     7,
+		UserId: 1,
+		Title:  "Lower A",
+		Entries: []TemplateEntry{
+			{ExerciseName: "Squat", TargetSets: 5, RepRangeMin: IntPtr(3), RepRangeMax: IntPtr(5), TargetPercent1RM: FloatPtr(80), WeightUnit: units.Kilogram, OrderIndex: 1},
+			{ExerciseName: "Deadlift", TargetSets: 1, RepRangeMax: IntPtr(5), TargetPercent1RM: FloatPtr(85), WeightUnit: units.Pound, OrderIndex: 2},
+			{ExerciseName: "Leg Press", TargetSets: 3, RepRangeMax: IntPtr(12), TargetWeight: FloatPtr(120), WeightUnit: units.Kilogram, OrderIndex: 3},
+			{ExerciseName: "Plank", TargetSets: 2, TargetDurationSeconds: IntPtr(60), OrderIndex: 4},
+		},
+	}
+	workout := template.NewWorkout(map[string]float64{"squat": 141})
+
+	assert.Equal(t, 1, workout.UserId)
+	assert.Equal(t, "Lower A", workout.Title)
+	require.NotNil(t, workout.TemplateId)
+	assert.Equal(t, 7, *workout.TemplateId)
+	require.Len(t, workout.Entries, 4)
+
+	squat := workout.Entries[0]
+	assert.Equal(t, 5, squat.Sets)
+	assert.Equal(t, 3, *squat.Reps, "the low end of the rep range is the target")
+	assert.Equal(t, 113.0, *squat.Weight, "80% of 141 kg rounds to the nearest half kg")
+
+	deadlift := workout.Entries[1]
+	assert.Equal(t, 5, *deadlift.Reps)
+	assert.Nil(t, deadlift.Weight, "an unknown max leaves the weight empty")
+
+	assert.Equal(t, 120.0, *workout.Entries[2].Weight)
+	plank := workout.Entries[3]
+	assert.Nil(t, plank.Reps)
+	assert.Equal(t, 60, *plank.DurationSeconds)
+	assert.Equal(t, 4, plank.OrderIndex)
+}
+
+func TestRoundToHalfUnit(t *testing.T) {
+	assert.Equal(t, 112.5, roundToHalfUnit(112.6, units.Kilogram))
+	pounds := roundToHalfUnit(100, units.Pound) / units.KilogramsPerPound
+	assert.InDelta(t, 220.5, pounds, 1e-9)
+}
+
+func TestTemplateFromWorkout(t *testing.T) {
+	workout := &Workout{
+		Id:          3,
+		UserId:      2,
+		Title:       "Pull Day",
+		Description: "rows and chins",
+		Entries: []WorkoutEntry{
+			{Id: 9, ExerciseName: "Row", Sets: 4, Reps: IntPtr(8), Weight: FloatPtr(70), WeightUnit: units.Kilogram, Notes: "strict", OrderIndex: 1},
+			{Id: 10, ExerciseName: "Dead Hang", Sets: 2, DurationSeconds: IntPtr(45), OrderIndex: 2},
+		},
+	}
+	template := TemplateFromWorkout(workout)
+
+	assert.Zero(t, template.Id)
+	assert.Equal(t, 2, template.UserId)
+	assert.Equal(t, "Pull Day", template.Title)
+	assert.Equal(t, "rows and chins", template.Description)
+	require.Len(t, template.Entries, 2)
+
+	row := template.Entries[0]
+	assert.Zero(t, row.Id)
+	assert.Equal(t, 4, row.TargetSets)
+	assert.Nil(t, row.RepRangeMin)
+	assert.Equal(t, 8, *row.RepRangeMax)
+	assert.Equal(t, 70.0, *row.TargetWeight)
+	assert.Equal(t, "strict", row.Notes)
+
+	hang := template.Entries[1]
+	assert.Nil(t, hang.RepRangeMax)
+	assert.Equal(t, 45, *hang.TargetDurationSeconds)
+	assert.Equal(t, 2, hang.OrderIndex)
+}
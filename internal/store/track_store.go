@@ -0,0 +1,95 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/heartrate"
+	"github.com/Numeez/go-zenith/internal/tracks"
+)
+
+type WorkoutTrack struct {
+	WorkoutId    int            `json:"workout_id"`
+	SourceFormat string         `json:"source_format"`
+	Points       []tracks.Point `json:"points"`
+	Summary      tracks.Summary `json:"summary"`
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// pace derives seconds per kilometre for distance-based entries.
+func (e *WorkoutEntry) pace() *float64 {
+	if e.DistanceMeters == nil || *e.DistanceMeters <= 0 || e.DurationSeconds == nil {
+		return nil
+	}
+	pace := float64(*e.DurationSeconds) / *e.DistanceMeters * tracks.MetersPerKilometer
+	return &pace
+}
+
+// ImportWorkout stores a workout built from an uploaded activity file together
+// with its simplified track and heart-rate samples in one transaction.
+func (pg *PostgresWorkout) ImportWorkout(workout *Workout, track *WorkoutTrack, samples []heartrate.Sample) (*Workout, error) {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, workout.UserId); err != nil {
+		return nil, err
+	}
+	if err := createWorkoutTx(tx, workout); err != nil {
+		return nil, err
+	}
+	points, err := json.Marshal(track.Points)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := json.Marshal(track.Summary)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	INSERT INTO workout_tracks (workout_id,source_format,points,summary)
+	VALUES($1,$2,$3,$4)
+	RETURNING created_at
+	`
+	track.WorkoutId = workout.Id
+	if err := tx.QueryRow(query, workout.Id, track.SourceFormat, points, summary).Scan(&track.CreatedAt); err != nil {
+		return nil, err
+	}
+	if len(samples) > 0 {
+		if err := saveHeartRateTx(tx, int64(workout.Id), samples); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return workout, nil
+}
+
+func (pg *PostgresWorkout) GetWorkoutTrack(workoutID int64) (*WorkoutTrack, error) {
+	track := &WorkoutTrack{}
+	var points, summary []byte
+	query := `
+	SELECT workout_id,source_format,points,summary,created_at
+	FROM workout_tracks
+	WHERE workout_id = $1
+	`
+	err := pg.db.QueryRow(query, workoutID).Scan(&track.WorkoutId, &track.SourceFormat, &points, &summary, &track.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(points, &track.Points); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(summary, &track.Summary); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
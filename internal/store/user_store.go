@@ -6,6 +6,7 @@ import (
 	"errors"
 	"time"
 
+	"github.com/Numeez/go-zenith/internal/heartrate"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -15,13 +16,19 @@ type password struct {
 }
 
 type User struct {
-	Id           int       `json:"id"`
-	Username     string    `json:"username"`
-	Email        string    `json:"email"`
-	PasswordHash password  `json:"-"`
-	Bio          string    `json:"bio"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+	Id               int       `json:"id"`
+	Username         string    `json:"username"`
+	Email            string    `json:"email"`
+	PasswordHash     password  `json:"-"`
+	Bio              string    `json:"bio"`
+	Timezone         string    `json:"timezone"`
+	MaxHeartRate     *int      `json:"max_heart_rate"`
+	RestingHeartRate *int      `json:"resting_heart_rate"`
+	HRZoneModel      string    `json:"hr_zone_model"`
+	BodyWeightKg     *float64  `json:"body_weight_kg"`
+	UnitSystem       string    `json:"unit_system"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
 }
 
 var AnonymousUser = &User{}
@@ -45,15 +52,16 @@ type UserStore interface {
 	GetUserByName(string) (*User, error)
 	UpdateUser(*User) error
 	GetUserToken(scope, tokenPlainText string) (*User, error)
+	UpdateHeartRateSettings(userID int, settings heartrate.Settings) error
 }
 
 func (s *PostgresUserStore) CreateUser(user *User) (*User, error) {
 	query := `
-	INSERT INTO users (username,email,password_hash,bio)
-	VALUES ($1,$2,$3,$4)
-	RETURNING id,created_at,updated_at
+	INSERT INTO users (username,email,password_hash,bio,timezone,unit_system)
+	VALUES ($1,$2,$3,$4,COALESCE(NULLIF($5,''),'UTC'),COALESCE(NULLIF($6,''),'metric'))
+	RETURNING id,timezone,unit_system,hr_zone_model,created_at,updated_at
 	`
-	if err := s.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio).Scan(&user.Id, &user.CreatedAt, &user.UpdatedAt); err != nil {
+	if err := s.db.QueryRow(query, user.Username, user.Email, user.PasswordHash.hash, user.Bio, user.Timezone, user.UnitSystem).Scan(&user.Id, &user.Timezone, &user.UnitSystem, &user.HRZoneModel, &user.CreatedAt, &user.UpdatedAt); err != nil {
 		return nil, err
 	}
 	return user, nil
@@ -64,7 +72,7 @@ func (s *PostgresUserStore) GetUserByName(username string) (*User, error) {
 		PasswordHash: password{},
 	}
 	query := `
-	SELECT id,username,email,password_hash,bio,created_at,updated_at 
+	SELECT id,username,email,password_hash,bio,timezone,max_heart_rate,resting_heart_rate,hr_zone_model,body_weight_kg,unit_system,created_at,updated_at 
 	FROM users 
 	WHERE username = $1
 	`
@@ -74,6 +82,12 @@ func (s *PostgresUserStore) GetUserByName(username string) (*User, error) {
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.Timezone,
+		&user.MaxHeartRate,
+		&user.RestingHeartRate,
+		&user.HRZoneModel,
+		&user.BodyWeightKg,
+		&user.UnitSystem,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
@@ -90,11 +104,11 @@ func (s *PostgresUserStore) GetUserByName(username string) (*User, error) {
 func (s *PostgresUserStore) UpdateUser(user *User) error {
 	query := `
 		UPDATE users
-		SET username = $1, email = $2, bio = $3, updated_at = CURRENT_TIMESTAMP
-		WHERE id = $4
+		SET username = $1, email = $2, bio = $3, timezone = $4, body_weight_kg = $5, unit_system = $6, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $7
 		RETURNING updated_at
 	`
-	result, err := s.db.Exec(query, user.Username, user.Email, user.Bio, user.Id)
+	result, err := s.db.Exec(query, user.Username, user.Email, user.Bio, user.Timezone, user.BodyWeightKg, user.UnitSystem, user.Id)
 	if err != nil {
 		return err
 	}
@@ -108,6 +122,53 @@ func (s *PostgresUserStore) UpdateUser(user *User) error {
 	return nil
 }
 
+func (u *User) HeartRateSettings() heartrate.Settings {
+	settings := heartrate.Settings{ZoneModel: u.HRZoneModel}
+	if u.MaxHeartRate != nil {
+		settings.MaxHeartRate = *u.MaxHeartRate
+	}
+	if u.RestingHeartRate != nil {
+		settings.RestingHeartRate = *u.RestingHeartRate
+	}
+	return settings.WithDefaults()
+}
+
+// UpdateHeartRateSettings also moves on the version of the user's workouts
+// with heart-rate samples, whose zone analysis depends on the settings, and
+// records them as changed for sync.
+func (s *PostgresUserStore) UpdateHeartRateSettings(userID int, settings heartrate.Settings) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, userID); err != nil {
+		return err
+	}
+	query := `
+	UPDATE users
+	SET max_heart_rate = $1, resting_heart_rate = $2, hr_zone_model = $3, updated_at = CURRENT_TIMESTAMP
+	WHERE id = $4
+	`
+	result, err := tx.Exec(query, settings.MaxHeartRate, settings.RestingHeartRate, settings.ZoneModel, userID)
+	if err != nil {
+		return err
+	}
+	affectedRow, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRow == 0 {
+		return sql.ErrNoRows
+	}
+	if err := touchWorkouts(tx, `user_id = $1 AND id IN (SELECT workout_id FROM workout_heart_rate)`, userID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
 func (p *password) Set(plainTextPassword string) error {
 	hash, err := bcrypt.GenerateFromPassword([]byte(plainTextPassword), 12)
 	if err != nil {
@@ -136,7 +197,7 @@ func (p *password) Matches(plaintTextPassword string) (bool, error) {
 func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(plaintextPassword))
 	query := `
-  SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.created_at, u.updated_at
+  SELECT u.id, u.username, u.email, u.password_hash, u.bio, u.timezone, u.max_heart_rate, u.resting_heart_rate, u.hr_zone_model, u.body_weight_kg, u.unit_system, u.created_at, u.updated_at
   FROM users u
   INNER JOIN tokens t ON t.user_id = u.id
   WHERE t.hash = $1 AND t.scope = $2 and t.expiry > $3
@@ -152,6 +213,12 @@ func (s *PostgresUserStore) GetUserToken(scope, plaintextPassword string) (*User
 		&user.Email,
 		&user.PasswordHash.hash,
 		&user.Bio,
+		&user.Timezone,
+		&user.MaxHeartRate,
+		&user.RestingHeartRate,
+		&user.HRZoneModel,
+		&user.BodyWeightKg,
+		&user.UnitSystem,
 		&user.CreatedAt,
 		&user.UpdatedAt,
 	)
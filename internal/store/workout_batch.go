@@ -0,0 +1,91 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// WorkoutBatch makes several workout writes in one transaction. Each write
+// runs under its own savepoint, so one that fails leaves the transaction
+// usable and the others in place.
+type WorkoutBatch interface {
+	GetWorkout(id int64) (*Workout, error)
+	CreateWorkout(workout *Workout) error
+	UpdateWorkout(workout *Workout) error
+	DeleteWorkout(id int64, expectedVersion int) error
+}
+
+type postgresWorkoutBatch struct {
+	tx    *sql.Tx
+	steps int
+}
+
+// RunBatch commits the writes made through batch when fn returns nil and
+// rolls all of them back otherwise. The batch may only write the user's
+// workouts.
+func (pg *PostgresWorkout) RunBatch(userID int, fn func(batch WorkoutBatch) error) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, userID); err != nil {
+		return err
+	}
+	if err := fn(&postgresWorkoutBatch{tx: tx}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// step runs write under a savepoint and rolls back to it when write fails.
+func (b *postgresWorkoutBatch) step(write func(tx *sql.Tx) error) error {
+	b.steps++
+	savepoint := fmt.Sprintf("batch_step_%d", b.steps)
+	if _, err := b.tx.Exec("SAVEPOINT " + savepoint); err != nil {
+		return err
+	}
+	if err := write(b.tx); err != nil {
+		if _, rollbackErr := b.tx.Exec("ROLLBACK TO SAVEPOINT " + savepoint); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return err
+	}
+	_, err := b.tx.Exec("RELEASE SAVEPOINT " + savepoint)
+	return err
+}
+
+// GetWorkout sees the batch's own writes; it returns nil when the workout
+// does not exist or is in the trash.
+func (b *postgresWorkoutBatch) GetWorkout(id int64) (*Workout, error) {
+	var workout *Workout
+	err := b.step(func(tx *sql.Tx) error {
+		var err error
+		workout, err = loadWorkout(tx, id)
+		return err
+	})
+	return workout, err
+}
+
+func (b *postgresWorkoutBatch) CreateWorkout(workout *Workout) error {
+	return b.step(func(tx *sql.Tx) error {
+		return createWorkoutTx(tx, workout)
+	})
+}
+
+// UpdateWorkout behaves like PostgresWorkout.UpdateWorkout.
+func (b *postgresWorkoutBatch) UpdateWorkout(workout *Workout) error {
+	return b.step(func(tx *sql.Tx) error {
+		return saveWorkoutTx(tx, workout)
+	})
+}
+
+// DeleteWorkout behaves like PostgresWorkout.DeleteWorkout.
+func (b *postgresWorkoutBatch) DeleteWorkout(id int64, expectedVersion int) error {
+	return b.step(func(tx *sql.Tx) error {
+		return trashWorkoutTx(tx, id, expectedVersion)
+	})
+}
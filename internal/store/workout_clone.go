@@ -0,0 +1,97 @@
+package store
+
+import "time"
+
+// EntryAdjustment progresses one entry of a cloned workout: Weight (in kg) is
+// added to every weighted working set, Reps to every set with reps and Sets to
+// the number of sets.
+type EntryAdjustment struct {
+	Weight float64
+	Reps   int
+	Sets   int
+}
+
+// LastPerformance is the most recent logged entry of an exercise, used to
+// pre-fill the next session.
+type LastPerformance struct {
+	WorkoutId    int          `json:"workout_id"`
+	WorkoutTitle string       `json:"workout_title"`
+	PerformedAt  time.Time    `json:"performed_at"`
+	Entry        WorkoutEntry `json:"entry"`
+}
+
+func addFloat(value *float64, delta float64) *float64 {
+	if value == nil {
+		return nil
+	}
+	sum := max(*value+delta, 0)
+	return &sum
+}
+
+func addInt(value *int, delta int) *int {
+	if value == nil {
+		return nil
+	}
+	sum := max(*value+delta, 1)
+	return &sum
+}
+
+// Clone builds an unsaved copy of the workout and its entries, keyed
+// adjustments applied by source entry id. Everything that describes how the
+// session went rather than what was planned — ids, calories, completion, RPE,
+// RIR, rest, distance, speed and heart rate — is left out.
+func (w *Workout) Clone(adjustments map[int]EntryAdjustment) *Workout {
+	clone := &Workout{
+		UserId:          w.UserId,
+		Title:           w.Title,
+		Description:     w.Description,
+		DurationMinutes: w.DurationMinutes,
+		TemplateId:      w.TemplateId,
+		Entries:         make([]WorkoutEntry, 0, len(w.Entries)),
+		TagIds:          append([]int{}, w.TagIds...),
+	}
+	for _, source := range w.Entries {
+		adjust := adjustments[source.Id]
+		entry := WorkoutEntry{
+			ExerciseName:     source.ExerciseName,
+			Reps:             addInt(source.Reps, adjust.Reps),
+			Sets:             max(source.Sets+adjust.Sets, 1),
+			DurationSeconds:  source.DurationSeconds,
+			Weight:           addFloat(source.Weight, adjust.Weight),
+			WeightUnit:       source.WeightUnit,
+			Notes:            source.Notes,
+			OrderIndex:       source.OrderIndex,
+			GroupId:          source.GroupId,
+			GroupType:        source.GroupType,
+			GroupRounds:      source.GroupRounds,
+			GroupRestSeconds: source.GroupRestSeconds,
+		}
+		for _, set := range source.SetLog {
+			set.Id = 0
+			set.Completed, set.RPE, set.RIR, set.RestSeconds = nil, nil, nil, nil
+			if set.SetType != SetTypeWarmup {
+				set.Reps = addInt(set.Reps, adjust.Reps)
+				set.Weight = addFloat(set.Weight, adjust.Weight)
+			}
+			entry.SetLog = append(entry.SetLog, set)
+		}
+		if len(entry.SetLog) > 0 {
+			entry.SetLog = resizeSetLog(entry.SetLog, adjust.Sets)
+		}
+		clone.Entries = append(clone.Entries, entry)
+	}
+	return clone
+}
+
+// resizeSetLog adds copies of the last set, or drops sets from the end, while
+// always keeping at least one.
+func resizeSetLog(sets []WorkoutSet, delta int) []WorkoutSet {
+	if delta < 0 {
+		return sets[:max(len(sets)+delta, 1)]
+	}
+	last := sets[len(sets)-1]
+	for range delta {
+		sets = append(sets, last)
+	}
+	return sets
+}
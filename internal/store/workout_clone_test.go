@@ -0,0 +1,93 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkoutClone(t *testing.T) {
+	completed := true
+	rpe := 8.0
+	source := &Workout{
+		Id:              4,
+		UserId:          1,
+		Title:           "Push Day",
+		DurationMinutes: 60,
+		CaloriesBurned:  300,
+		TagIds:          []int{2},
+		Entries: []WorkoutEntry{
+			{Id: 10, ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(80), OrderIndex: 1},
+			{Id: 11, ExerciseName: "Dips", Sets: 2, OrderIndex: 2, SetLog: []WorkoutSet{
+				{Id: 1, SetType: SetTypeWarmup, Reps: IntPtr(5), Weight: FloatPtr(0)},
+				{Id: 2, SetType: SetTypeWorking, Reps: IntPtr(10), Weight: FloatPtr(10), Completed: &completed, RPE: &rpe},
+			}},
+			{Id: 12, ExerciseName: "Plank", Sets: 1, DurationSeconds: IntPtr(60), OrderIndex: 3},
+		},
+	}
+	clone := source.Clone(map[int]EntryAdjustment{
+		10: {Weight: 2.5},
+		11: {Reps: 1, Sets: 1},
+		12: {Reps: 1, Sets: -5},
+	})
+
+	assert.Zero(t, clone.Id)
+	assert.Zero(t, clone.CaloriesBurned)
+	assert.Equal(t, "Push Day", clone.Title)
+	assert.Equal(t, []int{2}, clone.TagIds)
+	require.Len(t, clone.Entries, 3)
+
+	bench := clone.Entries[0]
+	assert.Zero(t, bench.Id)
+	assert.Equal(t, 82.5, *bench.Weight)
+	assert.Equal(t, 8, *bench.Reps)
+	assert.Equal(t, 80.0, *source.Entries[0].Weight, "source must not change")
+
+	dips := clone.Entries[1]
+	require.Len(t, dips.SetLog, 3)
+	assert.Equal(t, 5, *dips.SetLog[0].Reps, "warm-ups are not progressed")
+	assert.Equal(t, 11, *dips.SetLog[1].Reps)
+	assert.Equal(t, 11, *dips.SetLog[2].Reps)
+	assert.Nil(t, dips.SetLog[1].Completed)
+	assert.Nil(t, dips.SetLog[1].RPE)
+	assert.Zero(t, dips.SetLog[1].Id)
+	assert.Equal(t, 10, *source.Entries[1].SetLog[1].Reps)
+
+	plank := clone.Entries[2]
+	assert.Nil(t, plank.Reps)
+	assert.Equal(t, 1, plank.Sets)
+	assert.Equal(t, 60, *plank.DurationSeconds)
+}
+
+func TestWorkoutCloneDropsRecordedMetrics(t *testing.T) {
+	source := &Workout{
+		Title: "Morning Run",
+		Entries: []WorkoutEntry{{
+			Id:                  5,
+			ExerciseName:        "Running",
+			Sets:                1,
+			DurationSeconds:     IntPtr(1800),
+			DistanceMeters:      FloatPtr(5000),
+			DistanceUnit:        "km",
+			ElevationGainMeters: FloatPtr(40),
+			AvgHeartRate:        IntPtr(150),
+			MaxHeartRate:        IntPtr(172),
+			AvgSpeedMps:         FloatPtr(2.78),
+			PaceSecondsPerKm:    FloatPtr(360),
+			Notes:               "easy",
+			OrderIndex:          1,
+		}},
+	}
+	clone := source.Clone(nil)
+	require.Len(t, clone.Entries, 1)
+
+	run := clone.Entries[0]
+	assert.Equal(t, WorkoutEntry{
+		ExerciseName:    "Running",
+		Sets:            1,
+		DurationSeconds: IntPtr(1800),
+		Notes:           "easy",
+		OrderIndex:      1,
+	}, run)
+}
@@ -0,0 +1,160 @@
+package store
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+)
+
+const (
+	EntryAdded    = "added"
+	EntryRemoved  = "removed"
+	EntryModified = "modified"
+)
+
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// EntryChange describes one entry that differs between two versions of a
+// workout. FromIndex and ToIndex are its positions in each version.
+type EntryChange struct {
+	Change       string        `json:"change"`
+	ExerciseName string        `json:"exercise_name"`
+	FromIndex    *int          `json:"from_index,omitempty"`
+	ToIndex      *int          `json:"to_index,omitempty"`
+	Fields       []FieldChange `json:"fields,omitempty"`
+}
+
+type WorkoutDiff struct {
+	Fields  []FieldChange `json:"fields"`
+	Entries []EntryChange `json:"entries"`
+}
+
+func deref[T any](value *T) any {
+	if value == nil {
+		return nil
+	}
+	return *value
+}
+
+func appendChange(changes []FieldChange, field string, from, to any) []FieldChange {
+	if reflect.DeepEqual(from, to) {
+		return changes
+	}
+	return append(changes, FieldChange{Field: field, From: from, To: to})
+}
+
+// comparableSets drops the ids, which change every time entries are
+// rewritten.
+func comparableSets(sets []WorkoutSet) []WorkoutSet {
+	if len(sets) == 0 {
+		return nil
+	}
+	out := make([]WorkoutSet, len(sets))
+	for i, set := range sets {
+		set.Id = 0
+		out[i] = set
+	}
+	return out
+}
+
+func diffEntry(from, to *WorkoutEntry) []FieldChange {
+	var changes []FieldChange
+	changes = appendChange(changes, "exercise_name", from.ExerciseName, to.ExerciseName)
+	changes = appendChange(changes, "sets", from.Sets, to.Sets)
+	changes = appendChange(changes, "reps", deref(from.Reps), deref(to.Reps))
+	changes = appendChange(changes, "weight", deref(from.Weight), deref(to.Weight))
+	changes = appendChange(changes, "weight_unit", from.WeightUnit, to.WeightUnit)
+	changes = appendChange(changes, "duration_seconds", deref(from.DurationSeconds), deref(to.DurationSeconds))
+	changes = appendChange(changes, "distance_meters", deref(from.DistanceMeters), deref(to.DistanceMeters))
+	changes = appendChange(changes, "notes", from.Notes, to.Notes)
+	changes = appendChange(changes, "group_id", deref(from.GroupId), deref(to.GroupId))
+	changes = appendChange(changes, "group_type", deref(from.GroupType), deref(to.GroupType))
+	fromSets, toSets := comparableSets(from.SetLog), comparableSets(to.SetLog)
+	if !reflect.DeepEqual(fromSets, toSets) {
+		changes = append(changes, FieldChange{Field: "set_log", From: fromSets, To: toSets})
+	}
+	return changes
+}
+
+// matchEntries pairs the entries of two versions by exercise name, keeping
+// their order (a longest common subsequence), and returns the pairs as index
+// pairs.
+func matchEntries(from, to []WorkoutEntry) [][2]int {
+	key := func(entry WorkoutEntry) string { return strings.ToLower(strings.TrimSpace(entry.ExerciseName)) }
+	lengths := make([][]int, len(from)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(to)+1)
+	}
+	for i := len(from) - 1; i >= 0; i-- {
+		for j := len(to) - 1; j >= 0; j-- {
+			if key(from[i]) == key(to[j]) {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else {
+				lengths[i][j] = max(lengths[i+1][j], lengths[i][j+1])
+			}
+		}
+	}
+	var pairs [][2]int
+	for i, j := 0, 0; i < len(from) && j < len(to); {
+		switch {
+		case key(from[i]) == key(to[j]):
+			pairs = append(pairs, [2]int{i, j})
+			i++
+			j++
+		case lengths[i+1][j] >= lengths[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return pairs
+}
+
+// DiffWorkouts lists what changed from one version of a workout to another.
+// Entries are matched by exercise name in order; unmatched ones are reported
+// as added or removed. Values are compared in whatever units the versions
+// hold.
+func DiffWorkouts(from, to *Workout) WorkoutDiff {
+	diff := WorkoutDiff{Fields: []FieldChange{}, Entries: []EntryChange{}}
+	diff.Fields = appendChange(diff.Fields, "title", from.Title, to.Title)
+	diff.Fields = appendChange(diff.Fields, "description", from.Description, to.Description)
+	diff.Fields = appendChange(diff.Fields, "duration_minutes", from.DurationMinutes, to.DurationMinutes)
+	diff.Fields = appendChange(diff.Fields, "calories_burned", from.CaloriesBurned, to.CaloriesBurned)
+	fromTags, toTags := slices.Sorted(slices.Values(from.TagIds)), slices.Sorted(slices.Values(to.TagIds))
+	if !slices.Equal(fromTags, toTags) {
+		diff.Fields = append(diff.Fields, FieldChange{Field: "tag_ids", From: fromTags, To: toTags})
+	}
+
+	matchedFrom := make([]bool, len(from.Entries))
+	matchedTo := make([]bool, len(to.Entries))
+	modified := map[int]EntryChange{}
+	for _, pair := range matchEntries(from.Entries, to.Entries) {
+		matchedFrom[pair[0]], matchedTo[pair[1]] = true, true
+		fields := diffEntry(&from.Entries[pair[0]], &to.Entries[pair[1]])
+		if len(fields) > 0 {
+			fromIndex, toIndex := pair[0], pair[1]
+			modified[toIndex] = EntryChange{Change: EntryModified, ExerciseName: to.Entries[toIndex].ExerciseName,
+				FromIndex: &fromIndex, ToIndex: &toIndex, Fields: fields}
+		}
+	}
+	for i := range from.Entries {
+		if !matchedFrom[i] {
+			index := i
+			diff.Entries = append(diff.Entries, EntryChange{Change: EntryRemoved, ExerciseName: from.Entries[i].ExerciseName, FromIndex: &index})
+		}
+	}
+	for j := range to.Entries {
+		if change, ok := modified[j]; ok {
+			diff.Entries = append(diff.Entries, change)
+		}
+		if !matchedTo[j] {
+			index := j
+			diff.Entries = append(diff.Entries, EntryChange{Change: EntryAdded, ExerciseName: to.Entries[j].ExerciseName, ToIndex: &index})
+		}
+	}
+	return diff
+}
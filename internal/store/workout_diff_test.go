@@ -0,0 +1,58 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffWorkouts(t *testing.T) {
+	from := &Workout{
+		Title:           "Push Day",
+		DurationMinutes: 60,
+		TagIds:          []int{2, 1},
+		Entries: []WorkoutEntry{
+			{Id: 1, ExerciseName: "Bench Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(80)},
+			{Id: 2, ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10), SetLog: []WorkoutSet{{Id: 5, SetNumber: 1, Reps: IntPtr(10)}}},
+			{Id: 3, ExerciseName: "Plank", Sets: 1, DurationSeconds: IntPtr(60)},
+		},
+	}
+	to := &Workout{
+		Title:           "Push Day",
+		DurationMinutes: 70,
+		TagIds:          []int{1, 2},
+		Entries: []WorkoutEntry{
+			{Id: 7, ExerciseName: "Overhead Press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(50)},
+			{Id: 8, ExerciseName: "bench press", Sets: 3, Reps: IntPtr(8), Weight: FloatPtr(82.5)},
+			{Id: 9, ExerciseName: "Dips", Sets: 3, Reps: IntPtr(10), SetLog: []WorkoutSet{{Id: 6, SetNumber: 1, Reps: IntPtr(10)}}},
+		},
+	}
+
+	diff := DiffWorkouts(from, to)
+	assert.Equal(t, []FieldChange{{Field: "duration_minutes", From: 60, To: 70}}, diff.Fields)
+	require.Len(t, diff.Entries, 3)
+
+	removed := diff.Entries[0]
+	assert.Equal(t, EntryRemoved, removed.Change)
+	assert.Equal(t, "Plank", removed.ExerciseName)
+	assert.Equal(t, 2, *removed.FromIndex)
+
+	added := diff.Entries[1]
+	assert.Equal(t, EntryAdded, added.Change)
+	assert.Equal(t, "Overhead Press", added.ExerciseName)
+	assert.Equal(t, 0, *added.ToIndex)
+
+	modified := diff.Entries[2]
+	assert.Equal(t, EntryModified, modified.Change)
+	assert.Equal(t, 0, *modified.FromIndex)
+	assert.Equal(t, 1, *modified.ToIndex)
+	assert.Equal(t, []FieldChange{
+		{Field: "exercise_name", From: "Bench Press", To: "bench press"},
+		{Field: "weight", From: 80.0, To: 82.5},
+	}, modified.Fields)
+
+	// Rewritten ids alone are not a change.
+	assert.Empty(t, DiffWorkouts(to, to).Entries)
+	assert.Empty(t, DiffWorkouts(from, from).Fields)
+}
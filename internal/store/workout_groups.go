@@ -0,0 +1,124 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+)
+
+const (
+	GroupSingle   = "single"
+	GroupSuperset = "superset"
+	GroupCircuit  = "circuit"
+	GroupEMOM     = "emom"
+	GroupAMRAP    = "amrap"
+)
+
+var groupMinEntries = map[string]int{
+	GroupSuperset: 2,
+	GroupCircuit:  2,
+	GroupEMOM:     1,
+	GroupAMRAP:    1,
+}
+
+// EntryGroup is the nested view of entries performed together. Entries that
+// are not part of a group are returned as a "single" group of one so clients
+// can render the workout from Groups alone.
+type EntryGroup struct {
+	GroupId     *int           `json:"group_id"`
+	Type        string         `json:"type"`
+	Rounds      *int           `json:"rounds"`
+	RestSeconds *int           `json:"rest_seconds"`
+	Entries     []WorkoutEntry `json:"entries"`
+}
+
+func sameIntPtr(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// ValidateEntryGroups checks that grouped entries agree on the group settings,
+// are contiguous in OrderIndex and have enough members for their group type.
+func ValidateEntryGroups(entries []WorkoutEntry) error {
+	ordered := make([]WorkoutEntry, len(entries))
+	copy(ordered, entries)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].OrderIndex < ordered[j].OrderIndex })
+
+	first := map[int]WorkoutEntry{}
+	counts := map[int]int{}
+	closed := map[int]bool{}
+	var previous *int
+	for _, entry := range ordered {
+		if previous != nil && !sameIntPtr(previous, entry.GroupId) {
+			closed[*previous] = true
+		}
+		previous = entry.GroupId
+		if entry.GroupId == nil {
+			if entry.GroupType != nil || entry.GroupRounds != nil || entry.GroupRestSeconds != nil {
+				return fmt.Errorf("entry %q sets group fields without a group_id", entry.ExerciseName)
+			}
+			continue
+		}
+		id := *entry.GroupId
+		if entry.GroupType == nil {
+			return fmt.Errorf("group %d is missing group_type", id)
+		}
+		if _, ok := groupMinEntries[*entry.GroupType]; !ok {
+			return fmt.Errorf("group %d has unknown group_type %q", id, *entry.GroupType)
+		}
+		if entry.GroupRounds != nil && *entry.GroupRounds <= 0 {
+			return fmt.Errorf("group %d must have positive group_rounds", id)
+		}
+		if entry.GroupRestSeconds != nil && *entry.GroupRestSeconds < 0 {
+			return fmt.Errorf("group %d cannot have negative group_rest_seconds", id)
+		}
+		if closed[id] {
+			return fmt.Errorf("entries of group %d must be consecutive", id)
+		}
+		if lead, ok := first[id]; ok {
+			if *lead.GroupType != *entry.GroupType || !sameIntPtr(lead.GroupRounds, entry.GroupRounds) || !sameIntPtr(lead.GroupRestSeconds, entry.GroupRestSeconds) {
+				return fmt.Errorf("entries of group %d disagree on group settings", id)
+			}
+		} else {
+			first[id] = entry
+		}
+		counts[id]++
+	}
+	for id, lead := range first {
+		if counts[id] < groupMinEntries[*lead.GroupType] {
+			return fmt.Errorf("a %s needs at least %d entries", *lead.GroupType, groupMinEntries[*lead.GroupType])
+		}
+		if *lead.GroupType == GroupEMOM && lead.GroupRounds == nil {
+			return fmt.Errorf("group %d: an emom needs group_rounds", id)
+		}
+	}
+	return nil
+}
+
+// BuildEntryGroups nests entries into groups in OrderIndex order.
+func BuildEntryGroups(entries []WorkoutEntry) []EntryGroup {
+	ordered := make([]WorkoutEntry, len(entries))
+	copy(ordered, entries)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].OrderIndex < ordered[j].OrderIndex })
+	var groups []EntryGroup
+	for _, entry := range ordered {
+		last := len(groups) - 1
+		if entry.GroupId != nil && last >= 0 && sameIntPtr(groups[last].GroupId, entry.GroupId) {
+			groups[last].Entries = append(groups[last].Entries, entry)
+			continue
+		}
+		group := EntryGroup{
+			GroupId:     entry.GroupId,
+			Type:        GroupSingle,
+			Rounds:      entry.GroupRounds,
+			RestSeconds: entry.GroupRestSeconds,
+			Entries:     []WorkoutEntry{entry},
+		}
+		if entry.GroupType != nil {
+			group.Type = *entry.GroupType
+		}
+		groups = append(groups, group)
+	}
+	return groups
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func grouped(name string, order, group int, groupType string, rounds *int) WorkoutEntry {
+	return WorkoutEntry{
+		ExerciseName: name,
+		Sets:         1,
+		Reps:         IntPtr(10),
+		OrderIndex:   order,
+		GroupId:      IntPtr(group),
+		GroupType:    &groupType,
+		GroupRounds:  rounds,
+	}
+}
+
+func single(name string, order int) WorkoutEntry {
+	return WorkoutEntry{ExerciseName: name, Sets: 3, Reps: IntPtr(8), OrderIndex: order}
+}
+
+func TestValidateEntryGroups(t *testing.T) {
+	strayRounds := single("Curl", 1)
+	strayRounds.GroupRounds = IntPtr(3)
+	untyped := single("Curl", 1)
+	untyped.GroupId = IntPtr(1)
+	restless := grouped("Dip", 2, 1, GroupSuperset, nil)
+	restless.GroupRestSeconds = IntPtr(-10)
+
+	tests := []struct {
+		name    string
+		entries []WorkoutEntry
+		wantErr string
+	}{
+		{
+			name: "superset and singles",
+			entries: []WorkoutEntry{
+				single("Bench", 1),
+				grouped("Curl", 2, 1, GroupSuperset, IntPtr(3)),
+				grouped("Dip", 3, 1, GroupSuperset, IntPtr(3)),
+				single("Plank", 4),
+			},
+		},
+		{
+			name:    "single entry emom",
+			entries: []WorkoutEntry{grouped("Burpee", 1, 1, GroupEMOM, IntPtr(10))},
+		},
+		{
+			name:    "group fields without group id",
+			entries: []WorkoutEntry{strayRounds},
+			wantErr: `entry "Curl" sets group fields without a group_id`,
+		},
+		{
+			name:    "missing group type",
+			entries: []WorkoutEntry{untyped},
+			wantErr: "group 1 is missing group_type",
+		},
+		{
+			name:    "unknown group type",
+			entries: []WorkoutEntry{grouped("Curl", 1, 1, "giant", nil), grouped("Dip", 2, 1, "giant", nil)},
+			wantErr: `group 1 has unknown group_type "giant"`,
+		},
+		{
+			name:    "zero rounds",
+			entries: []WorkoutEntry{grouped("Row", 1, 1, GroupAMRAP, IntPtr(0))},
+			wantErr: "group 1 must have positive group_rounds",
+		},
+		{
+			name:    "negative rest",
+			entries: []WorkoutEntry{grouped("Curl", 1, 1, GroupSuperset, nil), restless},
+			wantErr: "group 1 cannot have negative group_rest_seconds",
+		},
+		{
+			name: "members split by another entry",
+			entries: []WorkoutEntry{
+				grouped("Curl", 1, 1, GroupSuperset, nil),
+				single("Bench", 2),
+				grouped("Dip", 3, 1, GroupSuperset, nil),
+			},
+			wantErr: "entries of group 1 must be consecutive",
+		},
+		{
+			name:    "members disagree on rounds",
+			entries: []WorkoutEntry{grouped("Curl", 1, 1, GroupCircuit, IntPtr(3)), grouped("Dip", 2, 1, GroupCircuit, IntPtr(4))},
+			wantErr: "entries of group 1 disagree on group settings",
+		},
+		{
+			name:    "superset of one",
+			entries: []WorkoutEntry{grouped("Curl", 1, 1, GroupSuperset, nil)},
+			wantErr: "a superset needs at least 2 entries",
+		},
+		{
+			name:    "emom without rounds",
+			entries: []WorkoutEntry{grouped("Burpee", 1, 1, GroupEMOM, nil)},
+			wantErr: "group 1: an emom needs group_rounds",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEntryGroups(tt.entries)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestValidateEntryGroupsSortsByOrderIndex(t *testing.T) {
+	entries := []WorkoutEntry{
+		grouped("Dip", 3, 1, GroupSuperset, nil),
+		single("Bench", 1),
+		grouped("Curl", 2, 1, GroupSuperset, nil),
+	}
+	assert.NoError(t, ValidateEntryGroups(entries), "members are consecutive once ordered")
+}
+
+func TestBuildEntryGroups(t *testing.T) {
+	entries := []WorkoutEntry{
+		single("Plank", 5),
+		grouped("Dip", 3, 1, GroupSuperset, IntPtr(3)),
+		single("Bench", 1),
+		grouped("Curl", 2, 1, GroupSuperset, IntPtr(3)),
+		grouped("Burpee", 4, 2, GroupEMOM, IntPtr(10)),
+	}
+	groups := BuildEntryGroups(entries)
+	require.Len(t, groups, 4)
+
+	assert.Equal(t, GroupSingle, groups[0].Type)
+	assert.Nil(t, groups[0].GroupId)
+	require.Len(t, groups[0].Entries, 1)
+	assert.Equal(t, "Bench", groups[0].Entries[0].ExerciseName)
+
+	assert.Equal(t, GroupSuperset, groups[1].Type)
+	assert.Equal(t, 1, *groups[1].GroupId)
+	assert.Equal(t, 3, *groups[1].Rounds)
+	require.Len(t, groups[1].Entries, 2)
+	assert.Equal(t, "Curl", groups[1].Entries[0].ExerciseName)
+	assert.Equal(t, "Dip", groups[1].Entries[1].ExerciseName)
+
+	assert.Equal(t, GroupEMOM, groups[2].Type)
+	assert.Equal(t, 10, *groups[2].Rounds)
+
+	assert.Equal(t, GroupSingle, groups[3].Type)
+	assert.Equal(t, "Plank", groups[3].Entries[0].ExerciseName)
+}
+
+func TestBuildEntryGroupsKeepsSinglesApart(t *testing.T) {
+	groups := BuildEntryGroups([]WorkoutEntry{single("Bench", 1), single("Row", 2)})
+	require.Len(t, groups, 2)
+	assert.Len(t, groups[0].Entries, 1)
+	assert.Len(t, groups[1].Entries, 1)
+	assert.Empty(t, BuildEntryGroups(nil))
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// WorkoutFilter selects a user's workouts for listing. With MatchAllTags a
+// workout must carry every tag in TagIds, otherwise any one of them.
+type WorkoutFilter struct {
+	UserId       int
+	TagIds       []int
+	MatchAllTags bool
+	From         *time.Time
+	To           *time.Time
+	Limit        int
+	Offset       int
+}
+
+// WorkoutList is one page of workouts, without entries, plus totals over
+// every workout that matched the filter.
+type WorkoutList struct {
+	Workouts     []Workout    `json:"workouts"`
+	Total        int          `json:"total"`
+	TagSummaries []TagSummary `json:"tag_summaries"`
+}
+
+// where builds the WHERE clause shared by the page and summary queries.
+func (f WorkoutFilter) where() (string, []any) {
+	args := []any{f.UserId}
+	conditions := []string{"w.user_id = $1", "w.deleted_at IS NULL"}
+	if f.From != nil {
+		args = append(args, *f.From)
+		conditions = append(conditions, fmt.Sprintf("w.created_at >= $%d", len(args)))
+	}
+	if f.To != nil {
+		args = append(args, *f.To)
+		conditions = append(conditions, fmt.Sprintf("w.created_at < $%d", len(args)))
+	}
+	if len(f.TagIds) > 0 {
+		distinct := map[int]bool{}
+		for _, id := range f.TagIds {
+			distinct[id] = true
+		}
+		args = append(args, f.TagIds)
+		tagged := fmt.Sprintf("SELECT COUNT(DISTINCT wt.tag_id) FROM workout_tags wt WHERE wt.workout_id = w.id AND wt.tag_id = ANY($%d)", len(args))
+		if f.MatchAllTags {
+			conditions = append(conditions, fmt.Sprintf("(%s) = %d", tagged, len(distinct)))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s) > 0", tagged))
+		}
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+func (pg *PostgresWorkout) ListWorkouts(filter WorkoutFilter) (*WorkoutList, error) {
+	where, args := filter.where()
+	list := &WorkoutList{Workouts: []Workout{}, TagSummaries: []TagSummary{}}
+
+	if err := pg.db.QueryRow(`SELECT COUNT(*) FROM workouts w WHERE `+where, args...).Scan(&list.Total); err != nil {
+		return nil, err
+	}
+
+	pageArgs := append(append([]any{}, args...), filter.Limit, filter.Offset)
+	query := fmt.Sprintf(`
+	SELECT w.id,w.user_id,w.title,COALESCE(w.description,''),w.duration_minutes,COALESCE(w.calories_burned,0),w.calories_estimated,w.template_id,w.created_at,w.updated_at,w.version
+	FROM workouts w
+	WHERE %s
+	ORDER BY w.created_at DESC, w.id DESC
+	LIMIT $%d OFFSET $%d
+	`, where, len(args)+1, len(args)+2)
+	rows, err := pg.db.Query(query, pageArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	index := map[int]int{}
+	var ids []int
+	for rows.Next() {
+		var workout Workout
+		if err := rows.Scan(&workout.Id, &workout.UserId, &workout.Title, &workout.Description, &workout.DurationMinutes,
+			&workout.CaloriesBurned, &workout.CaloriesEstimated, &workout.TemplateId, &workout.CreatedAt, &workout.UpdatedAt, &workout.Version); err != nil {
+			return nil, err
+		}
+		workout.TagIds = []int{}
+		workout.Tags = []Tag{}
+		index[workout.Id] = len(list.Workouts)
+		ids = append(ids, workout.Id)
+		list.Workouts = append(list.Workouts, workout)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(ids) > 0 {
+		tagRows, err := pg.db.Query(`
+		SELECT wt.workout_id,t.id,t.user_id,t.name,t.color,t.created_at
+		FROM workout_tags wt
+		INNER JOIN tags t ON t.id = wt.tag_id
+		WHERE wt.workout_id = ANY($1)
+		ORDER BY lower(t.name)
+		`, ids)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = tagRows.Close()
+		}()
+		for tagRows.Next() {
+			var workoutID int
+			var tag Tag
+			if err := tagRows.Scan(&workoutID, &tag.Id, &tag.UserId, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+				return nil, err
+			}
+			workout := &list.Workouts[index[workoutID]]
+			workout.Tags = append(workout.Tags, tag)
+			workout.TagIds = append(workout.TagIds, tag.Id)
+		}
+		if err := tagRows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	summaryRows, err := pg.db.Query(`
+	SELECT t.id,t.user_id,t.name,t.color,t.created_at,
+	       COUNT(*), COALESCE(SUM(w.duration_minutes),0), COALESCE(SUM(w.calories_burned),0)
+	FROM workouts w
+	INNER JOIN workout_tags wt ON wt.workout_id = w.id
+	INNER JOIN tags t ON t.id = wt.tag_id
+	WHERE `+where+`
+	GROUP BY t.id
+	ORDER BY COUNT(*) DESC, lower(t.name)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = summaryRows.Close()
+	}()
+	for summaryRows.Next() {
+		var summary TagSummary
+		tag := &summary.Tag
+		if err := summaryRows.Scan(&tag.Id, &tag.UserId, &tag.Name, &tag.Color, &tag.CreatedAt,
+			&summary.Workouts, &summary.TotalMinutes, &summary.TotalCalories); err != nil {
+			return nil, err
+		}
+		list.TagSummaries = append(list.TagSummaries, summary)
+	}
+	return list, summaryRows.Err()
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkoutFilterWhere(t *testing.T) {
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		filter WorkoutFilter
+		want   string
+		args   int
+	}{
+		{
+			name:   "user only",
+			filter: WorkoutFilter{UserId: 7},
+			want:   "w.user_id = $1 AND w.deleted_at IS NULL",
+			args:   1,
+		},
+		{
+			name:   "any tag with date",
+			filter: WorkoutFilter{UserId: 7, From: &from, TagIds: []int{1, 2}},
+			want:   "w.user_id = $1 AND w.deleted_at IS NULL AND w.created_at >= $2 AND (SELECT COUNT(DISTINCT wt.tag_id) FROM workout_tags wt WHERE wt.workout_id = w.id AND wt.tag_id = ANY($3)) > 0",
+			args:   3,
+		},
+		{
+			name:   "all tags counts distinct ids",
+			filter: WorkoutFilter{UserId: 7, TagIds: []int{1, 2, 2}, MatchAllTags: true},
+			want:   "w.user_id = $1 AND w.deleted_at IS NULL AND (SELECT COUNT(DISTINCT wt.tag_id) FROM workout_tags wt WHERE wt.workout_id = w.id AND wt.tag_id = ANY($2)) = 2",
+			args:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			where, args := tt.filter.where()
+			assert.Equal(t, tt.want, where)
+			assert.Len(t, args, tt.args)
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"database/sql"
+	"reflect"
+)
+
+// PatchWorkout saves a workout like UpdateWorkout, but leaves alone the
+// entries that did not change. Entries are matched to the stored ones by Id:
+// unknown or repeated ids are inserted as new entries, stored entries that
+// are missing are deleted, and the rest are updated in place or only
+// renumbered. Version must be the workout's current version. An entry that
+// breaks the valid_workout_entry constraint fails with ErrInvalidEntry before
+// anything is written.
+func (pg *PostgresWorkout) PatchWorkout(workout *Workout) error {
+	for _, entry := range workout.Entries {
+		if err := ValidateEntry(entry); err != nil {
+			return err
+		}
+	}
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "workouts", int64(workout.Id)); err != nil {
+		return err
+	}
+	if err := lockWorkout(tx, int64(workout.Id)); err != nil {
+		return err
+	}
+	stored, err := loadWorkout(tx, int64(workout.Id))
+	if err != nil {
+		return err
+	}
+	if stored == nil {
+		return sql.ErrNoRows
+	}
+	if err := updateWorkoutFields(tx, workout, workout.Version); err != nil {
+		return err
+	}
+	if err := syncWorkoutEntries(tx, workout, stored.Entries); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, workout.Id, RevisionUpdate, nil); err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, workout.UserId); err != nil {
+		return err
+	}
+	if err := recordChange(tx, workout.UserId, SyncWorkout, int64(workout.Id), false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sameEntry ignores the position and the values derived when reading.
+func sameEntry(a, b WorkoutEntry) bool {
+	a.OrderIndex, b.OrderIndex = 0, 0
+	a.PaceSecondsPerKm, b.PaceSecondsPerKm = nil, nil
+	a.Distance, b.Distance = nil, nil
+	return reflect.DeepEqual(a, b)
+}
+
+func syncWorkoutEntries(tx *sql.Tx, workout *Workout, stored []WorkoutEntry) error {
+	existing := make(map[int]WorkoutEntry, len(stored))
+	for _, entry := range stored {
+		existing[entry.Id] = entry
+	}
+	for i := range workout.Entries {
+		entry := &workout.Entries[i]
+		old, ok := existing[entry.Id]
+		delete(existing, entry.Id)
+		switch {
+		case !ok:
+			if err := insertWorkoutEntry(tx, workout.Id, entry); err != nil {
+				return err
+			}
+		case sameEntry(old, *entry):
+			if old.OrderIndex == entry.OrderIndex {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE workout_entries SET order_index=$1 WHERE id=$2`, entry.OrderIndex, entry.Id); err != nil {
+				return err
+			}
+		default:
+			if err := updateWorkoutEntry(tx, workout.Id, entry); err != nil {
+				return err
+			}
+		}
+	}
+	for id := range existing {
+		if _, err := tx.Exec(`DELETE FROM workout_entries WHERE id=$1`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// updateWorkoutEntry rewrites one entry and its set log.
+func updateWorkoutEntry(tx *sql.Tx, workoutID int, entry *WorkoutEntry) error {
+	query := `
+	UPDATE workout_entries
+	SET exercise_name=$1,sets=$2,reps=$3,duration_seconds=$4,weight=$5,weight_unit=$6,notes=$7,order_index=$8,
+		group_id=$9,group_type=$10,group_rounds=$11,group_rest_seconds=$12,
+		distance_meters=$13,distance_unit=$14,elevation_gain_meters=$15,avg_heart_rate=$16,max_heart_rate=$17,avg_speed_mps=$18
+	WHERE id=$19 AND workout_id=$20
+	`
+	prepareEntry(entry)
+	_, err := tx.Exec(query, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.WeightUnit, entry.Notes, entry.OrderIndex,
+		entry.GroupId, entry.GroupType, entry.GroupRounds, entry.GroupRestSeconds,
+		entry.DistanceMeters, entry.DistanceUnit, entry.ElevationGainMeters, entry.AvgHeartRate, entry.MaxHeartRate, entry.AvgSpeedMps,
+		entry.Id, workoutID)
+	if err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM workout_sets WHERE entry_id=$1`, entry.Id); err != nil {
+		return err
+	}
+	return insertWorkoutSets(tx, entry)
+}
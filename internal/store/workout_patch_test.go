@@ -0,0 +1,25 @@
+package store
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSameEntry(t *testing.T) {
+	completed := true
+	stored := WorkoutEntry{Id: 3, ExerciseName: "Row", Sets: 2, Reps: IntPtr(10), Weight: FloatPtr(60), OrderIndex: 2,
+		SetLog: []WorkoutSet{{Id: 7, Reps: IntPtr(10), Weight: FloatPtr(60), Completed: &completed}}}
+
+	moved := stored
+	moved.OrderIndex = 1
+	assert.True(t, sameEntry(stored, moved))
+
+	edited := stored
+	edited.Notes = "paused"
+	assert.False(t, sameEntry(stored, edited))
+
+	heavier := stored
+	heavier.SetLog = []WorkoutSet{{Id: 7, Reps: IntPtr(10), Weight: FloatPtr(62.5), Completed: &completed}}
+	assert.False(t, sameEntry(stored, heavier))
+}
@@ -0,0 +1,191 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+const (
+	SetTypeWarmup  = "warmup"
+	SetTypeWorking = "working"
+	SetTypeDrop    = "drop"
+	SetTypeFailure = "failure"
+)
+
+type WorkoutSet struct {
+	Id              int      `json:"id"`
+	SetNumber       int      `json:"set_number"`
+	SetType         string   `json:"set_type"`
+	Reps            *int     `json:"reps"`
+	Weight          *float64 `json:"weight"`
+	DurationSeconds *int     `json:"duration_seconds"`
+	DistanceMeters  *float64 `json:"distance_meters"`
+	RPE             *float64 `json:"rpe"`
+	RIR             *int     `json:"rir"`
+	Completed       *bool    `json:"completed"`
+	RestSeconds     *int     `json:"rest_seconds,omitempty"`
+}
+
+func validSetType(setType string) bool {
+	switch setType {
+	case SetTypeWarmup, SetTypeWorking, SetTypeDrop, SetTypeFailure:
+		return true
+	}
+	return false
+}
+
+// ValidateSetLogs checks the per-set details of every entry before they reach
+// the valid_workout_set constraint, so clients get a readable error.
+func ValidateSetLogs(entries []WorkoutEntry) error {
+	for _, entry := range entries {
+		for i, set := range entry.SetLog {
+			if set.SetType != "" && !validSetType(set.SetType) {
+				return fmt.Errorf("%s set %d: unknown set_type %q", entry.ExerciseName, i+1, set.SetType)
+			}
+			if set.Reps == nil && set.DurationSeconds == nil {
+				return fmt.Errorf("%s set %d: needs reps or duration_seconds", entry.ExerciseName, i+1)
+			}
+			if set.RPE != nil && (*set.RPE < 1 || *set.RPE > 10) {
+				return fmt.Errorf("%s set %d: rpe must be between 1 and 10", entry.ExerciseName, i+1)
+			}
+			if set.RIR != nil && *set.RIR < 0 {
+				return fmt.Errorf("%s set %d: rir cannot be negative", entry.ExerciseName, i+1)
+			}
+		}
+	}
+	return nil
+}
+
+// DeriveLegacyFields fills the aggregate Sets/Reps/Weight/DurationSeconds from
+// the set log so clients that predate per-set logging keep working: Sets counts
+// the non-warm-up sets and the remaining fields describe the top set.
+func (e *WorkoutEntry) DeriveLegacyFields() {
+	if len(e.SetLog) == 0 {
+		return
+	}
+	var top *WorkoutSet
+	working := 0
+	for i := range e.SetLog {
+		set := &e.SetLog[i]
+		if set.SetType == SetTypeWarmup {
+			continue
+		}
+		working++
+		if top == nil || heavierSet(set, top) {
+			top = set
+		}
+	}
+	if top == nil {
+		top = &e.SetLog[0]
+		working = len(e.SetLog)
+	}
+	e.Sets = working
+	e.Weight = top.Weight
+	if top.Reps != nil {
+		e.Reps = top.Reps
+		e.DurationSeconds = nil
+	} else {
+		e.Reps = nil
+		e.DurationSeconds = top.DurationSeconds
+	}
+}
+
+func heavierSet(a, b *WorkoutSet) bool {
+	weightOf := func(s *WorkoutSet) float64 {
+		if s.Weight == nil {
+			return 0
+		}
+		return *s.Weight
+	}
+	valueOf := func(s *WorkoutSet) int {
+		if s.Reps != nil {
+			return *s.Reps
+		}
+		if s.DurationSeconds != nil {
+			return *s.DurationSeconds
+		}
+		return 0
+	}
+	if weightOf(a) != weightOf(b) {
+		return weightOf(a) > weightOf(b)
+	}
+	return valueOf(a) > valueOf(b)
+}
+
+func insertWorkoutSets(tx *sql.Tx, entry *WorkoutEntry) error {
+	query := `
+	INSERT INTO workout_sets (entry_id,set_number,set_type,reps,weight,duration_seconds,distance_meters,rpe,rir,completed,rest_seconds)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11)
+	RETURNING id
+	`
+	for i := range entry.SetLog {
+		set := &entry.SetLog[i]
+		set.SetNumber = i + 1
+		if set.SetType == "" {
+			set.SetType = SetTypeWorking
+		}
+		if set.Completed == nil {
+			completed := true
+			set.Completed = &completed
+		}
+		err := tx.QueryRow(query, entry.Id, set.SetNumber, set.SetType, set.Reps, set.Weight, set.DurationSeconds,
+			set.DistanceMeters, set.RPE, set.RIR, *set.Completed, set.RestSeconds).Scan(&set.Id)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadWorkoutSets attaches the set log of every entry of the workouts with a
+// single query.
+func loadWorkoutSets(db queryer, workouts ...*Workout) error {
+	var entries []*WorkoutEntry
+	for _, workout := range workouts {
+		for i := range workout.Entries {
+			entries = append(entries, &workout.Entries[i])
+		}
+	}
+	return loadEntrySets(db, entries...)
+}
+
+// loadEntrySets attaches the set logs of the entries, which may belong to
+// different workouts, with a single query.
+func loadEntrySets(db queryer, entries ...*WorkoutEntry) error {
+	byEntry := make(map[int]*WorkoutEntry, len(entries))
+	entryIds := make([]int, 0, len(entries))
+	for _, entry := range entries {
+		byEntry[entry.Id] = entry
+		entryIds = append(entryIds, entry.Id)
+	}
+	if len(entryIds) == 0 {
+		return nil
+	}
+	query := `
+	SELECT s.entry_id, s.id, s.set_number, s.set_type, s.reps, s.weight, s.duration_seconds, s.distance_meters, s.rpe, s.rir, s.completed, s.rest_seconds
+	FROM workout_sets s
+	WHERE s.entry_id = ANY($1)
+	ORDER BY s.entry_id, s.set_number
+	`
+	rows, err := db.Query(query, entryIds)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var entryID int
+		var completed bool
+		var set WorkoutSet
+		if err := rows.Scan(&entryID, &set.Id, &set.SetNumber, &set.SetType, &set.Reps, &set.Weight, &set.DurationSeconds,
+			&set.DistanceMeters, &set.RPE, &set.RIR, &completed, &set.RestSeconds); err != nil {
+			return err
+		}
+		set.Completed = &completed
+		if entry, ok := byEntry[entryID]; ok {
+			entry.SetLog = append(entry.SetLog, set)
+		}
+	}
+	return rows.Err()
+}
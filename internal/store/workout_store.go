@@ -2,27 +2,82 @@ package store
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Numeez/go-zenith/internal/heartrate"
+	"github.com/Numeez/go-zenith/internal/units"
 )
 
 type Workout struct {
-	Id              int            `json:"id"`
-	UserId          int            `json:"user_id"`
-	Title           string         `json:"title"`
-	Description     string         `json:"description"`
-	DurationMinutes int            `json:"duration_minutes"`
-	CaloriesBurned  int            `json:"calories_burned"`
-	Entries         []WorkoutEntry `json:"entries"`
+	Id                int                 `json:"id"`
+	UserId            int                 `json:"user_id"`
+	Title             string              `json:"title"`
+	Description       string              `json:"description"`
+	DurationMinutes   int                 `json:"duration_minutes"`
+	CaloriesBurned    int                 `json:"calories_burned"`
+	CaloriesEstimated bool                `json:"calories_estimated"`
+	TemplateId        *int                `json:"template_id,omitempty"`
+	Entries           []WorkoutEntry      `json:"entries"`
+	Groups            []EntryGroup        `json:"groups,omitempty"`
+	HeartRate         *heartrate.Analysis `json:"heart_rate,omitempty"`
+	TagIds            []int               `json:"tag_ids"`
+	Tags              []Tag               `json:"tags"`
+	CreatedAt         time.Time           `json:"created_at"`
+	UpdatedAt         time.Time           `json:"updated_at"`
+	DeletedAt         *time.Time          `json:"deleted_at,omitempty"`
+	// Version increases with every change; writes based on an older version
+	// fail with ErrVersionConflict.
+	Version int `json:"version"`
 }
 
+// ErrVersionConflict means the workout changed since the version a write was
+// based on.
+var ErrVersionConflict = errors.New("workout was modified by another request")
+
+// WorkoutEntry holds weights in kg and distances in meters. WeightUnit and
+// DistanceUnit record the units the values were entered in; the API converts
+// Weight and Distance to and from them at its boundary.
 type WorkoutEntry struct {
-	Id              int      `json:"id"`
-	ExerciseName    string   `json:"exercise_name"`
-	Reps            *int     `json:"reps"`
-	Sets            int      `json:"sets"`
-	DurationSeconds *int     `json:"duration_seconds"`
-	Weight          *float64 `json:"weight"`
-	Notes           string   `json:"notes"`
-	OrderIndex      int      `json:"order_index"`
+	Id                  int          `json:"id"`
+	ExerciseName        string       `json:"exercise_name"`
+	Reps                *int         `json:"reps"`
+	Sets                int          `json:"sets"`
+	DurationSeconds     *int         `json:"duration_seconds"`
+	Weight              *float64     `json:"weight"`
+	WeightUnit          string       `json:"weight_unit,omitempty"`
+	Notes               string       `json:"notes"`
+	OrderIndex          int          `json:"order_index"`
+	GroupId             *int         `json:"group_id,omitempty"`
+	GroupType           *string      `json:"group_type,omitempty"`
+	GroupRounds         *int         `json:"group_rounds,omitempty"`
+	GroupRestSeconds    *int         `json:"group_rest_seconds,omitempty"`
+	SetLog              []WorkoutSet `json:"set_log,omitempty"`
+	DistanceMeters      *float64     `json:"distance_meters,omitempty"`
+	Distance            *float64     `json:"distance,omitempty"`
+	DistanceUnit        string       `json:"distance_unit,omitempty"`
+	ElevationGainMeters *float64     `json:"elevation_gain_meters,omitempty"`
+	AvgHeartRate        *int         `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate        *int         `json:"max_heart_rate,omitempty"`
+	AvgSpeedMps         *float64     `json:"avg_speed_mps,omitempty"`
+	PaceSecondsPerKm    *float64     `json:"pace_seconds_per_km,omitempty"`
+}
+
+type WorkoutActivity struct {
+	WorkoutId       int
+	CreatedAt       time.Time
+	DurationMinutes int
+	CaloriesBurned  int
+	TemplateId      *int
+}
+
+// StrengthRecord is the heaviest logged weight and best Epley estimate of an
+// exercise across all of a user's workouts.
+type StrengthRecord struct {
+	ExerciseName       string  `json:"exercise_name"`
+	MaxWeight          float64 `json:"max_weight"`
+	EstimatedOneRepMax float64 `json:"estimated_1rm"`
 }
 
 type PostgresWorkout struct {
@@ -38,152 +93,482 @@ func NewPostgresWorkoutStore(db *sql.DB) *PostgresWorkout {
 type WorkoutStore interface {
 	CreateWorkout(workout *Workout) (*Workout, error)
 	GetWorkOutById(id int64) (*Workout, error)
+	GetWorkoutsByIds(ids []int64) (map[int64]*Workout, error)
 	UpdateWorkout(*Workout) error
-	DeleteWorkout(id int64) error
+	PatchWorkout(*Workout) error
+	RunBatch(userID int, fn func(batch WorkoutBatch) error) error
+	DeleteWorkout(id int64, expectedVersion int) error
+	GetWorkoutVersion(id int64) (int, error)
+	RestoreWorkout(id int64, userID int) error
+	ListTrashedWorkouts(userID, limit, offset int) (*WorkoutList, error)
+	PurgeTrash(deletedBefore time.Time) (int64, error)
+	ListRevisions(workoutID int64) ([]WorkoutRevision, error)
+	GetRevision(workoutID int64, revision int) (*WorkoutRevision, error)
+	RevertWorkout(workoutID int64, revision, expectedVersion int) error
 	GetWorkoutOwner(id int64) (int, error)
+	ListWorkoutActivity(userID int, from, to time.Time) ([]WorkoutActivity, error)
+	GetEstimatedOneRepMax(userID int, exerciseName string) (*float64, error)
+	ListStrengthRecords(userID int) ([]StrengthRecord, error)
+	GetLastPerformance(userID int, exerciseName string) (*LastPerformance, error)
+	ListRecentPerformances(userID int, exerciseName string, limit int) ([]LastPerformance, error)
+	ListWorkouts(filter WorkoutFilter) (*WorkoutList, error)
+	SearchWorkouts(filter WorkoutFilter, text string) (*SearchResults, error)
+	ImportWorkout(workout *Workout, track *WorkoutTrack, samples []heartrate.Sample) (*Workout, error)
+	GetWorkoutTrack(workoutID int64) (*WorkoutTrack, error)
+	SaveHeartRate(workoutID int64, samples []heartrate.Sample) error
+	GetPeriodSummary(userID int, settings heartrate.Settings, from, to time.Time) (*PeriodSummary, error)
 }
 
 func (pg *PostgresWorkout) CreateWorkout(workout *Workout) (*Workout, error) {
 	tx, err := pg.db.Begin()
+	if err != nil {
+		return nil, err
+	}
 	defer func() {
 		_ = tx.Rollback()
 	}()
-	if err != nil {
+	if err := lockSyncFeed(tx, workout.UserId); err != nil {
+		return nil, err
+	}
+	if err := createWorkoutTx(tx, workout); err != nil {
 		return nil, err
 	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return workout, nil
+}
+
+func createWorkoutTx(tx *sql.Tx, workout *Workout) error {
+	// A workout logged offline or imported keeps the time it took place.
+	var createdAt *time.Time
+	if !workout.CreatedAt.IsZero() {
+		createdAt = &workout.CreatedAt
+	}
 	query := `
-	INSERT INTO workouts(user_id,title,description,duration_minutes,calories_burned)
-	VALUES($1,$2,$3,$4,$5)
-	RETURNING id
+	INSERT INTO workouts(user_id,title,description,duration_minutes,calories_burned,calories_estimated,template_id,created_at)
+	VALUES($1,$2,$3,$4,$5,$6,$7,COALESCE($8::timestamptz, CURRENT_TIMESTAMP))
+	RETURNING id,created_at,updated_at,version
 	`
-	err = tx.QueryRow(query, workout.UserId, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned).Scan(&workout.Id)
+	err := tx.QueryRow(query, workout.UserId, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesEstimated, workout.TemplateId, createdAt).Scan(&workout.Id, &workout.CreatedAt, &workout.UpdatedAt, &workout.Version)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	for _, entry := range workout.Entries {
-		query := `
-		INSERT INTO workout_entries (workout_id,exercise_name,sets,reps,duration_seconds,weight,notes,order_index)
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8)
-		RETURNING id
-		`
-		err := tx.QueryRow(query, workout.Id, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex).Scan(&entry.Id)
-		if err != nil {
-			return nil, err
-		}
+	if err := insertWorkoutTags(tx, workout); err != nil {
+		return err
 	}
-	if err := tx.Commit(); err != nil {
+	if err := insertWorkoutEntries(tx, workout); err != nil {
+		return err
+	}
+	if err := recordRevision(tx, workout.Id, RevisionCreate, nil); err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, workout.UserId); err != nil {
+		return err
+	}
+	return recordChange(tx, workout.UserId, SyncWorkout, int64(workout.Id), false)
+}
+
+func (pg *PostgresWorkout) GetWorkOutById(id int64) (*Workout, error) {
+	workout, err := loadWorkout(pg.db, id)
+	if err != nil || workout == nil {
+		return nil, err
+	}
+	if err := pg.loadHeartRate(workout); err != nil {
 		return nil, err
 	}
 	return workout, nil
 }
 
-func (pg *PostgresWorkout) GetWorkOutById(id int64) (*Workout, error) {
-	workout := &Workout{}
+// GetWorkoutsByIds returns the workouts among ids that are not in the trash,
+// by id, reading all of them with a fixed number of queries.
+func (pg *PostgresWorkout) GetWorkoutsByIds(ids []int64) (map[int64]*Workout, error) {
+	workouts, err := loadWorkouts(pg.db, ids)
+	if err != nil {
+		return nil, err
+	}
+	loaded := make([]*Workout, 0, len(workouts))
+	for _, workout := range workouts {
+		loaded = append(loaded, workout)
+	}
+	if err := pg.loadHeartRate(loaded...); err != nil {
+		return nil, err
+	}
+	return workouts, nil
+}
+
+// loadWorkout reads the workout with its entries, sets and tags, or nil when
+// it does not exist or is in the trash.
+func loadWorkout(db queryer, id int64) (*Workout, error) {
+	workouts, err := loadWorkouts(db, []int64{id})
+	if err != nil {
+		return nil, err
+	}
+	return workouts[id], nil
+}
+
+// loadWorkouts reads the workouts among ids that are not in the trash, with
+// their entries, sets and tags, by id.
+func loadWorkouts(db queryer, ids []int64) (map[int64]*Workout, error) {
+	workouts := make(map[int64]*Workout, len(ids))
+	if len(ids) == 0 {
+		return workouts, nil
+	}
 	query := `
-	SELECT id,title,description,duration_minutes,calories_burned 
+	SELECT id,user_id,title,description,duration_minutes,calories_burned,calories_estimated,template_id,created_at,updated_at,version
 	 from workouts 
-	  WHERE id = $1
+	  WHERE id = ANY($1) AND deleted_at IS NULL
 	`
-	err := pg.db.QueryRow(query, id).Scan(&workout.Id, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned)
-	if err == sql.ErrNoRows {
-		return nil, nil
-	}
+	rows, err := db.Query(query, ids)
 	if err != nil {
 		return nil, err
 	}
+	loaded := make([]*Workout, 0, len(ids))
+	for rows.Next() {
+		workout := &Workout{}
+		if err := rows.Scan(&workout.Id, &workout.UserId, &workout.Title, &workout.Description, &workout.DurationMinutes, &workout.CaloriesBurned, &workout.CaloriesEstimated, &workout.TemplateId, &workout.CreatedAt, &workout.UpdatedAt, &workout.Version); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		workouts[int64(workout.Id)] = workout
+		loaded = append(loaded, workout)
+	}
+	_ = rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(loaded) == 0 {
+		return workouts, nil
+	}
 	entryQuery := `
-  SELECT id, exercise_name, sets, reps, duration_seconds, weight, notes, order_index
+  SELECT workout_id, id, exercise_name, sets, reps, duration_seconds, weight, weight_unit, notes, order_index,
+         group_id, group_type, group_rounds, group_rest_seconds,
+         distance_meters, distance_unit, elevation_gain_meters, avg_heart_rate, max_heart_rate, avg_speed_mps
   FROM workout_entries
-  WHERE workout_id = $1
-  ORDER BY order_index
+  WHERE workout_id = ANY($1)
+  ORDER BY workout_id, order_index
   `
-	entries, err := pg.db.Query(entryQuery, id)
-	defer func() {
-		_ = entries.Close()
-	}()
+	entries, err := db.Query(entryQuery, ids)
 	if err != nil {
 		return nil, err
 	}
 	for entries.Next() {
+		var workoutID int64
 		var entry WorkoutEntry
 		if err := entries.Scan(
+			&workoutID,
 			&entry.Id,
 			&entry.ExerciseName,
 			&entry.Sets,
 			&entry.Reps,
 			&entry.DurationSeconds,
 			&entry.Weight,
+			&entry.WeightUnit,
 			&entry.Notes,
 			&entry.OrderIndex,
+			&entry.GroupId,
+			&entry.GroupType,
+			&entry.GroupRounds,
+			&entry.GroupRestSeconds,
+			&entry.DistanceMeters,
+			&entry.DistanceUnit,
+			&entry.ElevationGainMeters,
+			&entry.AvgHeartRate,
+			&entry.MaxHeartRate,
+			&entry.AvgSpeedMps,
 		); err != nil {
+			_ = entries.Close()
 			return nil, err
 		}
-		workout.Entries = append(workout.Entries, entry)
+		entry.PaceSecondsPerKm = entry.pace()
+		if workout, ok := workouts[workoutID]; ok {
+			workout.Entries = append(workout.Entries, entry)
+		}
+	}
+	_ = entries.Close()
+	if err := entries.Err(); err != nil {
+		return nil, err
+	}
+	if err := loadWorkoutSets(db, loaded...); err != nil {
+		return nil, err
+	}
+	for _, workout := range loaded {
+		workout.Groups = BuildEntryGroups(workout.Entries)
+	}
+	if err := loadWorkoutTags(db, loaded...); err != nil {
+		return nil, err
 	}
 
-	return workout, nil
+	return workouts, nil
+}
+
+func insertWorkoutEntries(tx *sql.Tx, workout *Workout) error {
+	for i := range workout.Entries {
+		if err := insertWorkoutEntry(tx, workout.Id, &workout.Entries[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
+
+// prepareEntry fills in what an entry needs before it is written.
+func prepareEntry(entry *WorkoutEntry) {
+	entry.DeriveLegacyFields()
+	if entry.WeightUnit == "" {
+		entry.WeightUnit = units.Kilogram
+	}
+	if entry.DistanceUnit == "" {
+		entry.DistanceUnit = units.Meter
+	}
+}
+
+// ErrInvalidEntry mirrors the valid_workout_entry constraint.
+var ErrInvalidEntry = errors.New("entry needs either reps or duration_seconds, but not both")
+
+// ValidateEntry checks the valid_workout_entry constraint before the database
+// does, after the set log has filled in the legacy fields.
+func ValidateEntry(entry WorkoutEntry) error {
+	entry.DeriveLegacyFields()
+	if (entry.Reps == nil) == (entry.DurationSeconds == nil) {
+		return fmt.Errorf("%w: %q", ErrInvalidEntry, entry.ExerciseName)
+	}
+	return nil
+}
+
+func insertWorkoutEntry(tx *sql.Tx, workoutID int, entry *WorkoutEntry) error {
+	query := `
+	INSERT INTO workout_entries (workout_id,exercise_name,sets,reps,duration_seconds,weight,weight_unit,notes,order_index,group_id,group_type,group_rounds,group_rest_seconds,
+		distance_meters,distance_unit,elevation_gain_meters,avg_heart_rate,max_heart_rate,avg_speed_mps)
+	VALUES($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19)
+	RETURNING id
+	`
+	prepareEntry(entry)
+	err := tx.QueryRow(query, workoutID, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.WeightUnit, entry.Notes, entry.OrderIndex,
+		entry.GroupId, entry.GroupType, entry.GroupRounds, entry.GroupRestSeconds,
+		entry.DistanceMeters, entry.DistanceUnit, entry.ElevationGainMeters, entry.AvgHeartRate, entry.MaxHeartRate, entry.AvgSpeedMps).Scan(&entry.Id)
+	if err != nil {
+		return err
+	}
+	return insertWorkoutSets(tx, entry)
+}
+
+// UpdateWorkout replaces the workout's fields and entries, and its tags when
+// TagIds is non-nil, and records the result as a new revision. Version must
+// be the workout's current version; it is incremented on success.
 func (pg *PostgresWorkout) UpdateWorkout(workout *Workout) error {
 	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
 	defer func() {
 		_ = tx.Rollback()
 	}()
-	if err != nil {
+	if err := lockOwnerSyncFeed(tx, "workouts", int64(workout.Id)); err != nil {
 		return err
 	}
-	query := `
-	UPDATE workouts
-	SET title=$1,description=$2,duration_minutes=$3,calories_burned=$4
-	WHERE id=$5
-	`
-	result, err := tx.Exec(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.Id)
-	if err != nil {
+	if err := saveWorkoutTx(tx, workout); err != nil {
 		return err
 	}
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
+	return tx.Commit()
+}
+
+func saveWorkoutTx(tx *sql.Tx, workout *Workout) error {
+	if err := lockWorkout(tx, int64(workout.Id)); err != nil {
 		return err
 	}
-	if rowsAffected == 0 {
-		return sql.ErrNoRows
+	if err := updateWorkoutTx(tx, workout, workout.Version); err != nil {
+		return err
 	}
+	if err := recordRevision(tx, workout.Id, RevisionUpdate, nil); err != nil {
+		return err
+	}
+	if err := evaluateGoals(tx, workout.UserId); err != nil {
+		return err
+	}
+	return recordChange(tx, workout.UserId, SyncWorkout, int64(workout.Id), false)
+}
 
-	_, err = tx.Exec("DELETE from workout_entries WHERE id=$1", workout.Id)
+// updateWorkoutTx rewrites the workout when it is still at expectedVersion,
+// or at any version when expectedVersion is 0.
+func updateWorkoutTx(tx *sql.Tx, workout *Workout, expectedVersion int) error {
+	if err := updateWorkoutFields(tx, workout, expectedVersion); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE from workout_entries WHERE workout_id=$1", workout.Id); err != nil {
+		return err
+	}
+	return insertWorkoutEntries(tx, workout)
+}
+
+// updateWorkoutFields saves everything but the entries, bumping the version,
+// and the tags when TagIds is non-nil.
+func updateWorkoutFields(tx *sql.Tx, workout *Workout, expectedVersion int) error {
+	query := `
+	UPDATE workouts
+	SET title=$1,description=$2,duration_minutes=$3,calories_burned=$4,calories_estimated=$5,updated_at=CURRENT_TIMESTAMP,version=version+1
+	WHERE id=$6 AND deleted_at IS NULL AND ($7 = 0 OR version = $7)
+	RETURNING user_id,updated_at,version
+	`
+	err := tx.QueryRow(query, workout.Title, workout.Description, workout.DurationMinutes, workout.CaloriesBurned, workout.CaloriesEstimated, workout.Id, expectedVersion).Scan(&workout.UserId, &workout.UpdatedAt, &workout.Version)
+	if err == sql.ErrNoRows {
+		return versionConflict(tx, int64(workout.Id))
+	}
 	if err != nil {
 		return err
 	}
-	for _, entry := range workout.Entries {
-		query := `
-		INSERT INTO workout_entries (workout_id,exercise_name,sets,reps,duration_seconds,weight,notes,order_index)
-		VALUES($1,$2,$3,$4,$5,$6,$7,$8)
-		`
-		_, err := tx.Exec(query, workout.Id, entry.ExerciseName, entry.Sets, entry.Reps, entry.DurationSeconds, entry.Weight, entry.Notes, entry.OrderIndex)
-		if err != nil {
+	if workout.TagIds != nil {
+		if _, err := tx.Exec(`DELETE FROM workout_tags WHERE workout_id=$1`, workout.Id); err != nil {
+			return err
+		}
+		if err := insertWorkoutTags(tx, workout); err != nil {
 			return err
 		}
+	}
+	return nil
+}
 
+// versionConflict tells apart a workout that changed, ErrVersionConflict,
+// from one that is gone, sql.ErrNoRows.
+func versionConflict(db rowQueryer, id int64) error {
+	var exists bool
+	if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM workouts WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists); err != nil {
+		return err
+	}
+	if exists {
+		return ErrVersionConflict
 	}
+	return sql.ErrNoRows
+}
 
-	return nil
+// GetWorkoutVersion returns sql.ErrNoRows when the workout does not exist or
+// is in the trash.
+func (pg *PostgresWorkout) GetWorkoutVersion(id int64) (int, error) {
+	var version int
+	err := pg.db.QueryRow(`SELECT version FROM workouts WHERE id = $1 AND deleted_at IS NULL`, id).Scan(&version)
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
 }
 
-func (pg *PostgresWorkout) DeleteWorkout(id int64) error {
-	query := `DELETE FROM workouts WHERE id=$1`
+// DeleteWorkout moves the workout to the trash when it is still at
+// expectedVersion, or at any version when expectedVersion is 0. It disappears
+// from every read path but keeps its entries until RestoreWorkout or
+// PurgeTrash.
+func (pg *PostgresWorkout) DeleteWorkout(id int64, expectedVersion int) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockOwnerSyncFeed(tx, "workouts", id); err != nil {
+		return err
+	}
+	if err := trashWorkoutTx(tx, id, expectedVersion); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	result, err := pg.db.Exec(query, id)
+func trashWorkoutTx(tx *sql.Tx, id int64, expectedVersion int) error {
+	query := `
+	UPDATE workouts SET deleted_at=CURRENT_TIMESTAMP, version=version+1
+	WHERE id=$1 AND deleted_at IS NULL AND ($2 = 0 OR version = $2)
+	RETURNING user_id`
+	var userID int
+	err := tx.QueryRow(query, id, expectedVersion).Scan(&userID)
+	if err == sql.ErrNoRows {
+		return versionConflict(tx, id)
+	}
 	if err != nil {
 		return err
 	}
-	affectedRow, err := result.RowsAffected()
+	if err := evaluateGoals(tx, userID); err != nil {
+		return err
+	}
+	return recordChange(tx, userID, SyncWorkout, id, true)
+}
 
+// RestoreWorkout takes the workout out of the user's trash, and the owner's
+// goals then change with the workouts they count. It returns sql.ErrNoRows
+// when the user has no such workout in the trash.
+func (pg *PostgresWorkout) RestoreWorkout(id int64, userID int) error {
+	tx, err := pg.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+	if err := lockSyncFeed(tx, userID); err != nil {
+		return err
+	}
+	query := `UPDATE workouts SET deleted_at=NULL, version=version+1 WHERE id=$1 AND user_id=$2 AND deleted_at IS NOT NULL`
+	result, err := tx.Exec(query, id, userID)
+	if err != nil {
+		return err
+	}
+	restored, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
-	if affectedRow == 0 {
+	if restored == 0 {
 		return sql.ErrNoRows
 	}
+	if err := evaluateGoals(tx, userID); err != nil {
+		return err
+	}
+	if err := recordChange(tx, userID, SyncWorkout, id, false); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
 
-	return nil
+// ListTrashedWorkouts returns one page of the user's trash, most recently
+// deleted first, without entries.
+func (pg *PostgresWorkout) ListTrashedWorkouts(userID, limit, offset int) (*WorkoutList, error) {
+	list := &WorkoutList{Workouts: []Workout{}, TagSummaries: []TagSummary{}}
+	err := pg.db.QueryRow(`SELECT COUNT(*) FROM workouts WHERE user_id = $1 AND deleted_at IS NOT NULL`, userID).Scan(&list.Total)
+	if err != nil {
+		return nil, err
+	}
+	query := `
+	SELECT id,user_id,title,COALESCE(description,''),duration_minutes,COALESCE(calories_burned,0),calories_estimated,template_id,created_at,updated_at,deleted_at,version
+	FROM workouts
+	WHERE user_id = $1 AND deleted_at IS NOT NULL
+	ORDER BY deleted_at DESC, id DESC
+	LIMIT $2 OFFSET $3
+	`
+	rows, err := pg.db.Query(query, userID, limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	for rows.Next() {
+		var workout Workout
+		if err := rows.Scan(&workout.Id, &workout.UserId, &workout.Title, &workout.Description, &workout.DurationMinutes,
+			&workout.CaloriesBurned, &workout.CaloriesEstimated, &workout.TemplateId, &workout.CreatedAt, &workout.UpdatedAt, &workout.DeletedAt, &workout.Version); err != nil {
+			return nil, err
+		}
+		workout.TagIds = []int{}
+		workout.Tags = []Tag{}
+		list.Workouts = append(list.Workouts, workout)
+	}
+	return list, rows.Err()
+}
+
+// PurgeTrash permanently deletes workouts trashed before the given time,
+// cascading to their entries, and returns how many were removed.
+func (pg *PostgresWorkout) PurgeTrash(deletedBefore time.Time) (int64, error) {
+	result, err := pg.db.Exec(`DELETE FROM workouts WHERE deleted_at < $1`, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
 func (pg *PostgresWorkout) GetWorkoutOwner(workoutId int64) (int, error) {
@@ -191,7 +576,7 @@ func (pg *PostgresWorkout) GetWorkoutOwner(workoutId int64) (int, error) {
 	query := `
 	SELECT user_id
 	FROM workouts
-	WHERE id = $1
+	WHERE id = $1 AND deleted_at IS NULL
 	`
 	err := pg.db.QueryRow(query, workoutId).Scan(&userID)
 	if err != nil {
@@ -199,3 +584,155 @@ func (pg *PostgresWorkout) GetWorkoutOwner(workoutId int64) (int, error) {
 	}
 	return userID, nil
 }
+
+func (pg *PostgresWorkout) ListWorkoutActivity(userID int, from, to time.Time) ([]WorkoutActivity, error) {
+	query := `
+	SELECT id, created_at, duration_minutes, COALESCE(calories_burned, 0), template_id
+	FROM workouts
+	WHERE user_id = $1 AND deleted_at IS NULL AND created_at >= $2 AND created_at < $3
+	ORDER BY created_at
+	`
+	rows, err := pg.db.Query(query, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var activity []WorkoutActivity
+	for rows.Next() {
+		var a WorkoutActivity
+		if err := rows.Scan(&a.WorkoutId, &a.CreatedAt, &a.DurationMinutes, &a.CaloriesBurned, &a.TemplateId); err != nil {
+			return nil, err
+		}
+		activity = append(activity, a)
+	}
+	return activity, rows.Err()
+}
+
+// userLifts lists the weighted sets of the user's workouts as exercise_name,
+// weight and reps: each logged set but warm-ups, and for entries without a
+// set log the entry's own weight and reps.
+const userLifts = `
+	SELECT e.exercise_name, s.weight, s.reps
+	FROM workout_sets s
+	INNER JOIN workout_entries e ON e.id = s.entry_id
+	INNER JOIN workouts w ON w.id = e.workout_id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND s.set_type <> 'warmup'
+	  AND s.weight IS NOT NULL AND s.reps > 0
+	UNION ALL
+	SELECT e.exercise_name, e.weight, e.reps
+	FROM workout_entries e
+	INNER JOIN workouts w ON w.id = e.workout_id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND e.weight IS NOT NULL AND e.reps > 0
+	  AND NOT EXISTS (SELECT 1 FROM workout_sets s WHERE s.entry_id = e.id)
+`
+
+// epleyEstimate is the Epley one-rep max of a row of userLifts.
+const epleyEstimate = `CASE WHEN reps = 1 THEN weight ELSE weight * (1 + reps / 30.0) END`
+
+// GetEstimatedOneRepMax returns the best Epley estimate across every logged
+// set of the exercise, warm-ups excluded, or nil when the user has no
+// weighted history for it.
+func (pg *PostgresWorkout) GetEstimatedOneRepMax(userID int, exerciseName string) (*float64, error) {
+	var estimate sql.NullFloat64
+	query := `
+	WITH lifts AS (` + userLifts + `)
+	SELECT MAX(` + epleyEstimate + `)
+	FROM lifts
+	WHERE lower(exercise_name) = lower($2)
+	`
+	if err := pg.db.QueryRow(query, userID, exerciseName).Scan(&estimate); err != nil {
+		return nil, err
+	}
+	if !estimate.Valid {
+		return nil, nil
+	}
+	return &estimate.Float64, nil
+}
+
+// ListStrengthRecords returns the heaviest set and best Epley estimate of
+// each exercise the user lifted, computed like GetEstimatedOneRepMax.
+func (pg *PostgresWorkout) ListStrengthRecords(userID int) ([]StrengthRecord, error) {
+	query := `
+	WITH lifts AS (` + userLifts + `)
+	SELECT MIN(exercise_name), MAX(weight), MAX(` + epleyEstimate + `)
+	FROM lifts
+	GROUP BY lower(exercise_name)
+	ORDER BY 3 DESC
+	`
+	rows, err := pg.db.Query(query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var records []StrengthRecord
+	for rows.Next() {
+		var record StrengthRecord
+		if err := rows.Scan(&record.ExerciseName, &record.MaxWeight, &record.EstimatedOneRepMax); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+// GetLastPerformance returns the user's most recent entry of the exercise with
+// its set log, or nil when it has never been logged.
+func (pg *PostgresWorkout) GetLastPerformance(userID int, exerciseName string) (*LastPerformance, error) {
+	performances, err := pg.ListRecentPerformances(userID, exerciseName, 1)
+	if err != nil || len(performances) == 0 {
+		return nil, err
+	}
+	return &performances[0], nil
+}
+
+// ListRecentPerformances returns up to limit of the user's latest entries of
+// the exercise with their set logs, newest first.
+func (pg *PostgresWorkout) ListRecentPerformances(userID int, exerciseName string, limit int) ([]LastPerformance, error) {
+	query := `
+	SELECT w.id, w.title, w.created_at,
+	       e.id, e.exercise_name, e.sets, e.reps, e.duration_seconds, e.weight, e.weight_unit, e.notes, e.order_index,
+	       e.distance_meters, e.distance_unit, e.elevation_gain_meters, e.avg_heart_rate, e.max_heart_rate, e.avg_speed_mps
+	FROM workout_entries e
+	INNER JOIN workouts w ON w.id = e.workout_id
+	WHERE w.user_id = $1 AND w.deleted_at IS NULL AND lower(e.exercise_name) = lower($2)
+	ORDER BY w.created_at DESC, e.order_index
+	LIMIT $3
+	`
+	rows, err := pg.db.Query(query, userID, exerciseName, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = rows.Close()
+	}()
+	var performances []LastPerformance
+	for rows.Next() {
+		var last LastPerformance
+		entry := &last.Entry
+		err := rows.Scan(
+			&last.WorkoutId, &last.WorkoutTitle, &last.PerformedAt,
+			&entry.Id, &entry.ExerciseName, &entry.Sets, &entry.Reps, &entry.DurationSeconds, &entry.Weight, &entry.WeightUnit, &entry.Notes, &entry.OrderIndex,
+			&entry.DistanceMeters, &entry.DistanceUnit, &entry.ElevationGainMeters, &entry.AvgHeartRate, &entry.MaxHeartRate, &entry.AvgSpeedMps,
+		)
+		if err != nil {
+			return nil, err
+		}
+		entry.PaceSecondsPerKm = entry.pace()
+		performances = append(performances, last)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	entries := make([]*WorkoutEntry, 0, len(performances))
+	for i := range performances {
+		entries = append(entries, &performances[i].Entry)
+	}
+	if err := loadEntrySets(pg.db, entries...); err != nil {
+		return nil, err
+	}
+	return performances, nil
+}
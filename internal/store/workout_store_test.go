@@ -112,6 +112,74 @@ func TestCreateWorkout(t *testing.T) {
 
 }
 
+func TestCreateWorkoutWithSetLog(t *testing.T) {
+	db := setupTestDB(t)
+	store := NewPostgresWorkoutStore(db)
+	_, err := db.Exec(`INSERT INTO users (id,username,email,password_hash) VALUES (1,'lifter','lifter@example.com','x') ON CONFLICT DO NOTHING`)
+	require.NoError(t, err)
+
+	workout := &Workout{
+		UserId:          1,
+		Title:           "Pyramid",
+		DurationMinutes: 45,
+		Entries: []WorkoutEntry{
+			{
+				ExerciseName: "Bench Press",
+				OrderIndex:   1,
+				SetLog: []WorkoutSet{
+					{SetType: SetTypeWarmup, Reps: IntPtr(12), Weight: FloatPtr(60)},
+					{Reps: IntPtr(10), Weight: FloatPtr(100)},
+					{Reps: IntPtr(8), Weight: FloatPtr(110)},
+					{Reps: IntPtr(6), Weight: FloatPtr(120), RPE: FloatPtr(9.5)},
+				},
+			},
+		},
+	}
+	created, err := store.CreateWorkout(workout)
+	require.NoError(t, err)
+
+	retrieved, err := store.GetWorkOutById(int64(created.Id))
+	require.NoError(t, err)
+	require.Len(t, retrieved.Entries, 1)
+	entry := retrieved.Entries[0]
+	assert.Equal(t, 3, entry.Sets)
+	assert.Equal(t, 6, *entry.Reps)
+	assert.Equal(t, 120.0, *entry.Weight)
+	require.Len(t, entry.SetLog, 4)
+	assert.Equal(t, SetTypeWarmup, entry.SetLog[0].SetType)
+	assert.Equal(t, 9.5, *entry.SetLog[3].RPE)
+}
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry WorkoutEntry
+		valid bool
+	}{
+		{name: "reps", entry: WorkoutEntry{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5)}, valid: true},
+		{name: "duration", entry: WorkoutEntry{ExerciseName: "Plank", Sets: 1, DurationSeconds: IntPtr(60)}, valid: true},
+		{name: "neither", entry: WorkoutEntry{ExerciseName: "Squat", Sets: 3}},
+		{name: "both", entry: WorkoutEntry{ExerciseName: "Squat", Sets: 3, Reps: IntPtr(5), DurationSeconds: IntPtr(60)}},
+		{
+			name: "set log fills in reps",
+			entry: WorkoutEntry{ExerciseName: "Squat", SetLog: []WorkoutSet{
+				{SetType: SetTypeWorking, Reps: IntPtr(5), Weight: FloatPtr(100)},
+			}},
+			valid: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateEntry(tt.entry)
+			if tt.valid {
+				assert.NoError(t, err)
+				return
+			}
+			assert.ErrorIs(t, err, ErrInvalidEntry)
+		})
+	}
+}
+
 func IntPtr(value int) *int {
 	return &value
 }
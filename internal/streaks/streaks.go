@@ -0,0 +1,200 @@
+package streaks
+
+import (
+	"sort"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+const (
+	UnitDay  = "day"
+	UnitWeek = "week"
+)
+
+type Options struct {
+	Location *time.Location
+	// RestDays is how many consecutive days without a workout are tolerated
+	// before a daily streak is broken.
+	RestDays int
+	// WeeklyTarget switches to weekly streaks: a week counts when at least
+	// this many workouts were logged in it (weeks start on Monday).
+	WeeklyTarget int
+}
+
+type Streak struct {
+	Length    int    `json:"length"`
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+}
+
+type Result struct {
+	Unit            string `json:"unit"`
+	Current         Streak `json:"current"`
+	Longest         Streak `json:"longest"`
+	TotalWorkouts   int    `json:"total_workouts"`
+	TotalActiveDays int    `json:"total_active_days"`
+}
+
+type Activity struct {
+	At      time.Time
+	Minutes int
+}
+
+type Day struct {
+	Date    string `json:"date"`
+	Count   int    `json:"count"`
+	Minutes int    `json:"minutes"`
+	Level   int    `json:"level"`
+}
+
+// civilDate strips the clock from t in loc and returns that calendar day at
+// midnight UTC, so day arithmetic is not affected by DST transitions.
+func civilDate(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(a, b time.Time) int {
+	return int(b.Sub(a).Hours() / 24)
+}
+
+func weekStart(day time.Time) time.Time {
+	offset := (int(day.Weekday()) + 6) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+func Compute(timestamps []time.Time, now time.Time, opts Options) Result {
+	loc := opts.Location
+	if loc == nil {
+		loc = time.UTC
+	}
+	counts := map[time.Time]int{}
+	for _, ts := range timestamps {
+		counts[civilDate(ts, loc)]++
+	}
+	days := make([]time.Time, 0, len(counts))
+	for day := range counts {
+		days = append(days, day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+
+	result := Result{
+		Unit:            UnitDay,
+		TotalWorkouts:   len(timestamps),
+		TotalActiveDays: len(days),
+	}
+	today := civilDate(now, loc)
+	if opts.WeeklyTarget > 0 {
+		result.Unit = UnitWeek
+		result.Current, result.Longest = weeklyStreaks(days, counts, today, opts.WeeklyTarget)
+		return result
+	}
+	result.Current, result.Longest = dailyStreaks(days, today, opts.RestDays)
+	return result
+}
+
+func dailyStreaks(days []time.Time, today time.Time, restDays int) (current, longest Streak) {
+	if len(days) == 0 {
+		return
+	}
+	if restDays < 0 {
+		restDays = 0
+	}
+	run := Streak{Length: 1, StartDate: days[0].Format(dateLayout), EndDate: days[0].Format(dateLayout)}
+	longest = run
+	for i := 1; i < len(days); i++ {
+		if daysBetween(days[i-1], days[i])-1 > restDays {
+			run = Streak{StartDate: days[i].Format(dateLayout)}
+		}
+		run.Length++
+		run.EndDate = days[i].Format(dateLayout)
+		if run.Length > longest.Length {
+			longest = run
+		}
+	}
+	// The streak is still alive while today could be one of the tolerated
+	// rest days after the last workout.
+	if daysBetween(days[len(days)-1], today)-1 <= restDays {
+		current = run
+	}
+	return
+}
+
+func weeklyStreaks(days []time.Time, counts map[time.Time]int, today time.Time, target int) (current, longest Streak) {
+	perWeek := map[time.Time]int{}
+	for _, day := range days {
+		perWeek[weekStart(day)] += counts[day]
+	}
+	var weeks []time.Time
+	for week, count := range perWeek {
+		if count >= target {
+			weeks = append(weeks, week)
+		}
+	}
+	if len(weeks) == 0 {
+		return
+	}
+	sort.Slice(weeks, func(i, j int) bool { return weeks[i].Before(weeks[j]) })
+
+	endOf := func(week time.Time) string { return week.AddDate(0, 0, 6).Format(dateLayout) }
+	run := Streak{Length: 1, StartDate: weeks[0].Format(dateLayout), EndDate: endOf(weeks[0])}
+	longest = run
+	for i := 1; i < len(weeks); i++ {
+		if daysBetween(weeks[i-1], weeks[i]) != 7 {
+			run = Streak{StartDate: weeks[i].Format(dateLayout)}
+		}
+		run.Length++
+		run.EndDate = endOf(weeks[i])
+		if run.Length > longest.Length {
+			longest = run
+		}
+	}
+	// The current week is still in progress, so a run ending last week has
+	// not been broken yet.
+	if daysBetween(weeks[len(weeks)-1], weekStart(today)) <= 7 {
+		current = run
+	}
+	return
+}
+
+// Calendar returns one entry per day of year in loc, with a 0-4 intensity
+// level relative to the busiest day, in the shape heatmap widgets expect.
+func Calendar(year int, activity []Activity, loc *time.Location) []Day {
+	if loc == nil {
+		loc = time.UTC
+	}
+	type totals struct{ count, minutes int }
+	byDay := map[time.Time]*totals{}
+	maxCount := 0
+	for _, a := range activity {
+		day := civilDate(a.At, loc)
+		if day.Year() != year {
+			continue
+		}
+		t, ok := byDay[day]
+		if !ok {
+			t = &totals{}
+			byDay[day] = t
+		}
+		t.count++
+		t.minutes += a.Minutes
+		if t.count > maxCount {
+			maxCount = t.count
+		}
+	}
+
+	first := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	last := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	calendar := make([]Day, 0, daysBetween(first, last))
+	for day := first; day.Before(last); day = day.AddDate(0, 0, 1) {
+		entry := Day{Date: day.Format(dateLayout)}
+		if t, ok := byDay[day]; ok {
+			entry.Count = t.count
+			entry.Minutes = t.minutes
+			entry.Level = (4*t.count + maxCount - 1) / maxCount
+		}
+		calendar = append(calendar, entry)
+	}
+	return calendar
+}
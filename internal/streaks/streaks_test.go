@@ -0,0 +1,102 @@
+package streaks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func day(value string) time.Time {
+	t, _ := time.Parse(dateLayout, value)
+	return t.Add(18 * time.Hour)
+}
+
+func TestCompute(t *testing.T) {
+	now := day("2025-03-14")
+	tests := []struct {
+		name        string
+		timestamps  []time.Time
+		opts        Options
+		wantCurrent int
+		wantLongest int
+	}{
+		{
+			name:        "no workouts",
+			wantCurrent: 0,
+			wantLongest: 0,
+		},
+		{
+			name:        "consecutive days ending yesterday",
+			timestamps:  []time.Time{day("2025-03-11"), day("2025-03-12"), day("2025-03-13")},
+			wantCurrent: 3,
+			wantLongest: 3,
+		},
+		{
+			name:        "broken streak",
+			timestamps:  []time.Time{day("2025-03-01"), day("2025-03-02"), day("2025-03-03"), day("2025-03-10")},
+			wantCurrent: 0,
+			wantLongest: 3,
+		},
+		{
+			name:        "rest day allowance keeps streak alive",
+			timestamps:  []time.Time{day("2025-03-08"), day("2025-03-10"), day("2025-03-12")},
+			opts:        Options{RestDays: 1},
+			wantCurrent: 3,
+			wantLongest: 3,
+		},
+		{
+			name: "weekly target",
+			timestamps: []time.Time{
+				day("2025-02-24"), day("2025-02-26"),
+				day("2025-03-03"), day("2025-03-05"),
+				day("2025-03-10"),
+			},
+			opts:        Options{WeeklyTarget: 2},
+			wantCurrent: 2,
+			wantLongest: 2,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := Compute(test.timestamps, now, test.opts)
+			assert.Equal(t, test.wantCurrent, result.Current.Length)
+			assert.Equal(t, test.wantLongest, result.Longest.Length)
+		})
+	}
+}
+
+func TestComputeUsesLocation(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err)
+	// 02:00 UTC is still the previous evening in New York.
+	timestamps := []time.Time{
+		time.Date(2025, 3, 12, 23, 0, 0, 0, time.UTC),
+		time.Date(2025, 3, 14, 2, 0, 0, 0, time.UTC),
+	}
+	now := time.Date(2025, 3, 14, 12, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, 1, Compute(timestamps, now, Options{}).Longest.Length)
+	assert.Equal(t, 2, Compute(timestamps, now, Options{Location: loc}).Longest.Length)
+}
+
+func TestCalendar(t *testing.T) {
+	activity := []Activity{
+		{At: day("2024-02-29"), Minutes: 30},
+		{At: day("2024-02-29"), Minutes: 20},
+		{At: day("2024-03-01"), Minutes: 45},
+		{At: day("2023-12-31"), Minutes: 60},
+	}
+	calendar := Calendar(2024, activity, time.UTC)
+	require.Len(t, calendar, 366)
+
+	leapDay := calendar[59]
+	assert.Equal(t, "2024-02-29", leapDay.Date)
+	assert.Equal(t, 2, leapDay.Count)
+	assert.Equal(t, 50, leapDay.Minutes)
+	assert.Equal(t, 4, leapDay.Level)
+	assert.Equal(t, 2, calendar[60].Level)
+	assert.Equal(t, 0, calendar[0].Count)
+}
@@ -0,0 +1,165 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	FormatGPX = "gpx"
+	FormatTCX = "tcx"
+)
+
+var ErrUnknownFormat = errors.New("tracks: unknown file format, expected GPX or TCX")
+
+type Point struct {
+	Lat       float64   `json:"lat"`
+	Lon       float64   `json:"lon"`
+	Elevation *float64  `json:"ele,omitempty"`
+	Time      time.Time `json:"time"`
+	HeartRate *int      `json:"hr,omitempty"`
+}
+
+type Track struct {
+	Name     string
+	Activity string
+	Points   []Point
+}
+
+type gpxFile struct {
+	Metadata struct {
+		Name string `xml:"name"`
+	} `xml:"metadata"`
+	Tracks []struct {
+		Name     string `xml:"name"`
+		Type     string `xml:"type"`
+		Segments []struct {
+			Points []struct {
+				Lat       float64  `xml:"lat,attr"`
+				Lon       float64  `xml:"lon,attr"`
+				Elevation *float64 `xml:"ele"`
+				Time      string   `xml:"time"`
+				HeartRate *int     `xml:"extensions>TrackPointExtension>hr"`
+			} `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type tcxFile struct {
+	Activities []struct {
+		Sport string `xml:"Sport,attr"`
+		Id    string `xml:"Id"`
+		Laps  []struct {
+			Points []struct {
+				Time     string `xml:"Time"`
+				Position *struct {
+					Lat float64 `xml:"LatitudeDegrees"`
+					Lon float64 `xml:"LongitudeDegrees"`
+				} `xml:"Position"`
+				Altitude  *float64 `xml:"AltitudeMeters"`
+				HeartRate *struct {
+					Value int `xml:"Value"`
+				} `xml:"HeartRateBpm"`
+			} `xml:"Track>Trackpoint"`
+		} `xml:"Lap"`
+	} `xml:"Activities>Activity"`
+}
+
+// DetectFormat guesses the format from a file name and, failing that, from
+// the root element of the document.
+func DetectFormat(filename string, head []byte) (string, error) {
+	switch {
+	case strings.HasSuffix(strings.ToLower(filename), ".gpx"):
+		return FormatGPX, nil
+	case strings.HasSuffix(strings.ToLower(filename), ".tcx"):
+		return FormatTCX, nil
+	case bytes.Contains(head, []byte("<gpx")):
+		return FormatGPX, nil
+	case bytes.Contains(head, []byte("<TrainingCenterDatabase")):
+		return FormatTCX, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+func Parse(r io.Reader, format string) (*Track, error) {
+	switch format {
+	case FormatGPX:
+		return ParseGPX(r)
+	case FormatTCX:
+		return ParseTCX(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, strings.TrimSpace(value))
+}
+
+func ParseGPX(r io.Reader) (*Track, error) {
+	var doc gpxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("tracks: decoding gpx: %w", err)
+	}
+	track := &Track{Name: doc.Metadata.Name}
+	for _, trk := range doc.Tracks {
+		if track.Name == "" {
+			track.Name = trk.Name
+		}
+		if track.Activity == "" {
+			track.Activity = trk.Type
+		}
+		for _, segment := range trk.Segments {
+			for _, p := range segment.Points {
+				ts, err := parseTime(p.Time)
+				if err != nil {
+					return nil, fmt.Errorf("tracks: gpx point without a valid time: %w", err)
+				}
+				track.Points = append(track.Points, Point{Lat: p.Lat, Lon: p.Lon, Elevation: p.Elevation, Time: ts, HeartRate: p.HeartRate})
+			}
+		}
+	}
+	if len(track.Points) < 2 {
+		return nil, errors.New("tracks: gpx file has fewer than two track points")
+	}
+	return track, nil
+}
+
+func ParseTCX(r io.Reader) (*Track, error) {
+	var doc tcxFile
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("tracks: decoding tcx: %w", err)
+	}
+	track := &Track{}
+	for _, activity := range doc.Activities {
+		if track.Activity == "" {
+			track.Activity = activity.Sport
+		}
+		for _, lap := range activity.Laps {
+			for _, p := range lap.Points {
+				// Indoor samples carry no position and cannot be placed on the map.
+				if p.Position == nil {
+					continue
+				}
+				ts, err := parseTime(p.Time)
+				if err != nil {
+					return nil, fmt.Errorf("tracks: tcx point without a valid time: %w", err)
+				}
+				point := Point{Lat: p.Position.Lat, Lon: p.Position.Lon, Elevation: p.Altitude, Time: ts}
+				if p.HeartRate != nil {
+					hr := p.HeartRate.Value
+					point.HeartRate = &hr
+				}
+				track.Points = append(track.Points, point)
+			}
+		}
+	}
+	if len(track.Points) < 2 {
+		return nil, errors.New("tracks: tcx file has fewer than two positioned track points")
+	}
+	return track, nil
+}
@@ -0,0 +1,231 @@
+package tracks
+
+import (
+	"math"
+)
+
+const (
+	earthRadiusMeters = 6371008.8
+
+	MetersPerKilometer = 1000.0
+	MetersPerMile      = 1609.344
+
+	// Segments slower than this are treated as standing still when computing
+	// moving time.
+	movingSpeedMps = 0.5
+	// Elevation changes smaller than this are GPS noise and are ignored when
+	// accumulating gain and loss.
+	elevationThreshold = 2.0
+	profileSamples     = 100
+)
+
+type Split struct {
+	Index           int     `json:"index"`
+	DistanceMeters  float64 `json:"distance_meters"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	PaceSeconds     float64 `json:"pace_seconds"`
+	ElevationGain   float64 `json:"elevation_gain_meters"`
+}
+
+type ProfilePoint struct {
+	DistanceMeters float64 `json:"distance_meters"`
+	Elevation      float64 `json:"elevation_meters"`
+}
+
+type Summary struct {
+	DistanceMeters      float64        `json:"distance_meters"`
+	ElapsedSeconds      float64        `json:"elapsed_seconds"`
+	MovingSeconds       float64        `json:"moving_seconds"`
+	ElevationGainMeters float64        `json:"elevation_gain_meters"`
+	ElevationLossMeters float64        `json:"elevation_loss_meters"`
+	AvgSpeedMps         float64        `json:"avg_speed_mps"`
+	MaxSpeedMps         float64        `json:"max_speed_mps"`
+	AvgHeartRate        *int           `json:"avg_heart_rate,omitempty"`
+	MaxHeartRate        *int           `json:"max_heart_rate,omitempty"`
+	SplitUnitMeters     float64        `json:"split_unit_meters"`
+	Splits              []Split        `json:"splits"`
+	ElevationProfile    []ProfilePoint `json:"elevation_profile"`
+}
+
+func toRadians(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+// Haversine returns the great-circle distance between two points in meters.
+func Haversine(a, b Point) float64 {
+	lat1, lat2 := toRadians(a.Lat), toRadians(b.Lat)
+	dLat := lat2 - lat1
+	dLon := toRadians(b.Lon - a.Lon)
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusMeters * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// Summarize computes distance, timing, elevation and heart-rate figures for
+// the points, with splits every splitUnit meters (kilometres or miles).
+func Summarize(points []Point, splitUnit float64) Summary {
+	summary := Summary{SplitUnitMeters: splitUnit, Splits: []Split{}, ElevationProfile: []ProfilePoint{}}
+	if len(points) < 2 {
+		return summary
+	}
+	summary.ElapsedSeconds = points[len(points)-1].Time.Sub(points[0].Time).Seconds()
+
+	var hrSum, hrCount, hrMax int
+	var lastElevation *float64
+	cumulative := make([]float64, len(points))
+	split := Split{Index: 1}
+	for i, p := range points {
+		if p.HeartRate != nil {
+			hrSum += *p.HeartRate
+			hrCount++
+			if *p.HeartRate > hrMax {
+				hrMax = *p.HeartRate
+			}
+		}
+		if p.Elevation != nil {
+			if lastElevation == nil {
+				lastElevation = p.Elevation
+			} else if delta := *p.Elevation - *lastElevation; math.Abs(delta) >= elevationThreshold {
+				if delta > 0 {
+					summary.ElevationGainMeters += delta
+					split.ElevationGain += delta
+				} else {
+					summary.ElevationLossMeters -= delta
+				}
+				lastElevation = p.Elevation
+			}
+		}
+		if i == 0 {
+			continue
+		}
+		distance := Haversine(points[i-1], p)
+		seconds := p.Time.Sub(points[i-1].Time).Seconds()
+		summary.DistanceMeters += distance
+		cumulative[i] = summary.DistanceMeters
+		if seconds > 0 {
+			speed := distance / seconds
+			if speed >= movingSpeedMps {
+				summary.MovingSeconds += seconds
+				split.DurationSeconds += seconds
+				summary.MaxSpeedMps = math.Max(summary.MaxSpeedMps, speed)
+			}
+		}
+		split.DistanceMeters += distance
+		if splitUnit > 0 && split.DistanceMeters >= splitUnit {
+			split.PaceSeconds = split.DurationSeconds / split.DistanceMeters * splitUnit
+			summary.Splits = append(summary.Splits, split)
+			split = Split{Index: split.Index + 1}
+		}
+	}
+	if split.DistanceMeters > 0 {
+		split.PaceSeconds = split.DurationSeconds / split.DistanceMeters * splitUnit
+		summary.Splits = append(summary.Splits, split)
+	}
+	if summary.MovingSeconds > 0 {
+		summary.AvgSpeedMps = summary.DistanceMeters / summary.MovingSeconds
+	}
+	if hrCount > 0 {
+		avg := int(math.Round(float64(hrSum) / float64(hrCount)))
+		summary.AvgHeartRate = &avg
+		summary.MaxHeartRate = &hrMax
+	}
+	summary.ElevationProfile = elevationProfile(points, cumulative)
+	return summary
+}
+
+func elevationProfile(points []Point, cumulative []float64) []ProfilePoint {
+	profile := []ProfilePoint{}
+	step := 1
+	if len(points) > profileSamples {
+		step = len(points) / profileSamples
+	}
+	for i := 0; i < len(points); i += step {
+		if points[i].Elevation != nil {
+			profile = append(profile, ProfilePoint{DistanceMeters: cumulative[i], Elevation: *points[i].Elevation})
+		}
+	}
+	return profile
+}
+
+// Simplify reduces the track with the Ramer-Douglas-Peucker algorithm, keeping
+// every point that deviates more than toleranceMeters from the simplified line.
+func Simplify(points []Point, toleranceMeters float64) []Point {
+	if len(points) < 3 {
+		return points
+	}
+	keep := make([]bool, len(points))
+	keep[0], keep[len(points)-1] = true, true
+	type span struct{ first, last int }
+	stack := []span{{0, len(points) - 1}}
+	for len(stack) > 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		maxDistance, index := 0.0, -1
+		for i := s.first + 1; i < s.last; i++ {
+			if d := perpendicularDistance(points[i], points[s.first], points[s.last]); d > maxDistance {
+				maxDistance, index = d, i
+			}
+		}
+		if index >= 0 && maxDistance > toleranceMeters {
+			keep[index] = true
+			stack = append(stack, span{s.first, index}, span{index, s.last})
+		}
+	}
+	simplified := make([]Point, 0, len(points))
+	for i, p := range points {
+		if keep[i] {
+			simplified = append(simplified, p)
+		}
+	}
+	return simplified
+}
+
+// perpendicularDistance projects onto a local equirectangular plane, which is
+// accurate enough over the short spans a single workout covers.
+func perpendicularDistance(p, a, b Point) float64 {
+	cosLat := math.Cos(toRadians(a.Lat))
+	project := func(q Point) (float64, float64) {
+		return toRadians(q.Lon-a.Lon) * cosLat * earthRadiusMeters, toRadians(q.Lat-a.Lat) * earthRadiusMeters
+	}
+	px, py := project(p)
+	bx, by := project(b)
+	length := math.Hypot(bx, by)
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+	return math.Abs(bx*py-by*px) / length
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates [][]float64 `json:"coordinates"`
+}
+
+type GeoJSONFeature struct {
+	Type       string          `json:"type"`
+	Geometry   geoJSONGeometry `json:"geometry"`
+	Properties map[string]any  `json:"properties"`
+}
+
+// GeoJSON renders the points as a LineString feature. Coordinates follow the
+// GeoJSON [longitude, latitude, elevation] order.
+func GeoJSON(points []Point, properties map[string]any) GeoJSONFeature {
+	coordinates := make([][]float64, 0, len(points))
+	times := make([]string, 0, len(points))
+	for _, p := range points {
+		coordinate := []float64{p.Lon, p.Lat}
+		if p.Elevation != nil {
+			coordinate = append(coordinate, *p.Elevation)
+		}
+		coordinates = append(coordinates, coordinate)
+		times = append(times, p.Time.UTC().Format("2006-01-02T15:04:05Z"))
+	}
+	if properties == nil {
+		properties = map[string]any{}
+	}
+	properties["coordinate_times"] = times
+	return GeoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geoJSONGeometry{Type: "LineString", Coordinates: coordinates},
+		Properties: properties,
+	}
+}
@@ -0,0 +1,96 @@
+package tracks
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleGPX = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" xmlns="http://www.topografix.com/GPX/1/1" xmlns:gpxtpx="http://www.garmin.com/xmlschemas/TrackPointExtension/v1">
+ <trk>
+  <name>Morning Run</name>
+  <type>running</type>
+  <trkseg>
+   <trkpt lat="51.5000" lon="-0.1200"><ele>10</ele><time>2025-03-14T07:00:00Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>130</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+   </trkpt>
+   <trkpt lat="51.5045" lon="-0.1200"><ele>15</ele><time>2025-03-14T07:02:30Z</time>
+    <extensions><gpxtpx:TrackPointExtension><gpxtpx:hr>150</gpxtpx:hr></gpxtpx:TrackPointExtension></extensions>
+   </trkpt>
+   <trkpt lat="51.5090" lon="-0.1200"><ele>12</ele><time>2025-03-14T07:05:00Z</time></trkpt>
+  </trkseg>
+ </trk>
+</gpx>`
+
+const sampleTCX = `<?xml version="1.0" encoding="UTF-8"?>
+<TrainingCenterDatabase xmlns="http://www.garmin.com/xmlschemas/TrainingCenterDatabase/v2">
+ <Activities>
+  <Activity Sport="Biking">
+   <Id>2025-03-14T07:00:00Z</Id>
+   <Lap StartTime="2025-03-14T07:00:00Z">
+    <Track>
+     <Trackpoint><Time>2025-03-14T07:00:00Z</Time><Position><LatitudeDegrees>51.5</LatitudeDegrees><LongitudeDegrees>-0.12</LongitudeDegrees></Position><AltitudeMeters>10</AltitudeMeters><HeartRateBpm><Value>120</Value></HeartRateBpm></Trackpoint>
+     <Trackpoint><Time>2025-03-14T07:00:30Z</Time></Trackpoint>
+     <Trackpoint><Time>2025-03-14T07:01:00Z</Time><Position><LatitudeDegrees>51.51</LatitudeDegrees><LongitudeDegrees>-0.12</LongitudeDegrees></Position><AltitudeMeters>11</AltitudeMeters></Trackpoint>
+    </Track>
+   </Lap>
+  </Activity>
+ </Activities>
+</TrainingCenterDatabase>`
+
+func TestHaversine(t *testing.T) {
+	london := Point{Lat: 51.5074, Lon: -0.1278}
+	paris := Point{Lat: 48.8566, Lon: 2.3522}
+	assert.InDelta(t, 343_500, Haversine(london, paris), 1_000)
+	assert.Zero(t, Haversine(london, london))
+}
+
+func TestParseGPX(t *testing.T) {
+	track, err := ParseGPX(strings.NewReader(sampleGPX))
+	require.NoError(t, err)
+	assert.Equal(t, "Morning Run", track.Name)
+	assert.Equal(t, "running", track.Activity)
+	require.Len(t, track.Points, 3)
+	assert.Equal(t, 150, *track.Points[1].HeartRate)
+	assert.Nil(t, track.Points[2].HeartRate)
+
+	summary := Summarize(track.Points, MetersPerKilometer)
+	assert.InDelta(t, 1000.7, summary.DistanceMeters, 1)
+	assert.Equal(t, 300.0, summary.ElapsedSeconds)
+	assert.Equal(t, 300.0, summary.MovingSeconds)
+	assert.Equal(t, 5.0, summary.ElevationGainMeters)
+	assert.Equal(t, 3.0, summary.ElevationLossMeters)
+	assert.Equal(t, 140, *summary.AvgHeartRate)
+	require.Len(t, summary.Splits, 1)
+	assert.InDelta(t, 300, summary.Splits[0].PaceSeconds, 1)
+}
+
+func TestParseTCXSkipsPointsWithoutPosition(t *testing.T) {
+	format, err := DetectFormat("", []byte(sampleTCX))
+	require.NoError(t, err)
+	require.Equal(t, FormatTCX, format)
+
+	track, err := Parse(strings.NewReader(sampleTCX), format)
+	require.NoError(t, err)
+	assert.Equal(t, "Biking", track.Activity)
+	require.Len(t, track.Points, 2)
+	assert.Equal(t, 120, *track.Points[0].HeartRate)
+}
+
+func TestSimplifyKeepsCorners(t *testing.T) {
+	start := time.Date(2025, 3, 14, 7, 0, 0, 0, time.UTC)
+	var points []Point
+	for i := 0; i <= 10; i++ {
+		points = append(points, Point{Lat: 51.5 + float64(i)*0.0001, Lon: -0.12, Time: start.Add(time.Duration(i) * time.Second)})
+	}
+	for i := 1; i <= 10; i++ {
+		points = append(points, Point{Lat: 51.501, Lon: -0.12 + float64(i)*0.0001, Time: start.Add(time.Duration(10+i) * time.Second)})
+	}
+	simplified := Simplify(points, 1)
+	require.Len(t, simplified, 3)
+	assert.Equal(t, points[10], simplified[1])
+}
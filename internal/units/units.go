@@ -0,0 +1,122 @@
+package units
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	Metric   = "metric"
+	Imperial = "imperial"
+	// Original is a read-only choice that shows every value in the unit it
+	// was entered in.
+	Original = "original"
+
+	Kilogram = "kg"
+	Pound    = "lb"
+
+	Meter     = "m"
+	Kilometer = "km"
+	Mile      = "mi"
+
+	Centimeter = "cm"
+	Inch       = "in"
+
+	// Exact by definition, so converting back recovers the entered value.
+	KilogramsPerPound  = 0.45359237
+	MetersPerMile      = 1609.344
+	CentimetersPerInch = 2.54
+
+	// Canonical values are stored with ten decimal places; six are plenty to
+	// recover any value entered with up to four.
+	displayPrecision = 1e6
+)
+
+func ValidSystem(system string) bool {
+	return system == Metric || system == Imperial
+}
+
+func ValidWeightUnit(unit string) bool {
+	return unit == Kilogram || unit == Pound
+}
+
+func WeightUnit(system string) string {
+	if system == Imperial {
+		return Pound
+	}
+	return Kilogram
+}
+
+func DistanceUnit(system string) string {
+	if system == Imperial {
+		return Mile
+	}
+	return Kilometer
+}
+
+func LengthUnit(system string) string {
+	if system == Imperial {
+		return Inch
+	}
+	return Centimeter
+}
+
+func ToKilograms(value float64, unit string) (float64, error) {
+	switch unit {
+	case Kilogram:
+		return value, nil
+	case Pound:
+		return value * KilogramsPerPound, nil
+	}
+	return 0, fmt.Errorf("unknown weight unit %q", unit)
+}
+
+func FromKilograms(kg float64, unit string) float64 {
+	if unit == Pound {
+		return round(kg / KilogramsPerPound)
+	}
+	return round(kg)
+}
+
+func ToMeters(value float64, unit string) (float64, error) {
+	switch unit {
+	case Meter:
+		return value, nil
+	case Kilometer:
+		return value * 1000, nil
+	case Mile:
+		return value * MetersPerMile, nil
+	}
+	return 0, fmt.Errorf("unknown distance unit %q", unit)
+}
+
+func FromMeters(meters float64, unit string) float64 {
+	switch unit {
+	case Kilometer:
+		return round(meters / 1000)
+	case Mile:
+		return round(meters / MetersPerMile)
+	}
+	return round(meters)
+}
+
+func ToCentimeters(value float64, unit string) (float64, error) {
+	switch unit {
+	case Centimeter:
+		return value, nil
+	case Inch:
+		return value * CentimetersPerInch, nil
+	}
+	return 0, fmt.Errorf("unknown length unit %q", unit)
+}
+
+func FromCentimeters(cm float64, unit string) float64 {
+	if unit == Inch {
+		return round(cm / CentimetersPerInch)
+	}
+	return round(cm)
+}
+
+func round(v float64) float64 {
+	return math.Round(v*displayPrecision) / displayPrecision
+}
@@ -0,0 +1,49 @@
+package units
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeScale mimics the NUMERIC(18,10) columns canonical values are kept in.
+func storeScale(v float64) float64 {
+	return math.Round(v*1e10) / 1e10
+}
+
+func TestWeightRoundTrip(t *testing.T) {
+	for _, lb := range []float64{0.25, 45, 135.5, 315, 1002.75, 2204.6226} {
+		kg, err := ToKilograms(lb, Pound)
+		require.NoError(t, err)
+		assert.Equal(t, lb, FromKilograms(storeScale(kg), Pound))
+	}
+	kg, err := ToKilograms(100, Kilogram)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, kg)
+	assert.Equal(t, 220.462262, FromKilograms(100, Pound))
+
+	_, err = ToKilograms(10, "stone")
+	assert.Error(t, err)
+}
+
+func TestDistanceAndLengthRoundTrip(t *testing.T) {
+	meters, err := ToMeters(13.1, Mile)
+	require.NoError(t, err)
+	assert.Equal(t, 13.1, FromMeters(storeScale(meters), Mile))
+	assert.Equal(t, 21.082406, FromMeters(meters, Kilometer))
+
+	cm, err := ToCentimeters(32.5, Inch)
+	require.NoError(t, err)
+	assert.Equal(t, 82.55, cm)
+	assert.Equal(t, 32.5, FromCentimeters(cm, Inch))
+}
+
+func TestSystemDefaults(t *testing.T) {
+	assert.Equal(t, Pound, WeightUnit(Imperial))
+	assert.Equal(t, Kilogram, WeightUnit(Metric))
+	assert.Equal(t, Mile, DistanceUnit(Imperial))
+	assert.Equal(t, Centimeter, LengthUnit(""))
+	assert.False(t, ValidSystem(Original))
+}
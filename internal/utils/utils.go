@@ -34,3 +34,19 @@ func ReadIdParam(r *http.Request) (int64, error) {
 	}
 	return id, nil 
 }
+
+func ReadIntQuery(r *http.Request, key string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func ReadFloatQuery(r *http.Request, key string, defaultValue float64) (float64, error) {
+	value := r.URL.Query().Get(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseFloat(value, 64)
+}
@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"net/http"
 	"time"
+	_ "time/tzdata"
 
 	app "github.com/Numeez/go-zenith/internal/app"
 	router "github.com/Numeez/go-zenith/internal/routes"
@@ -12,13 +14,23 @@ import (
 
 func main() {
 	var port int
+	var config app.Config
 	flag.IntVar(&port, "port", 8080, "This is the port used to host the server")
+	flag.DurationVar(&config.SessionIdleTimeout, "session-idle-timeout", 3*time.Hour, "How long a workout session may be idle before it expires")
+	flag.DurationVar(&config.TrashRetention, "trash-retention", 30*24*time.Hour, "How long deleted workouts stay in the trash before they are purged")
+	flag.BoolVar(&config.RequireIfMatch, "require-if-match", false, "Reject workout writes that do not send If-Match")
+	flag.IntVar(&config.MaxBatchOperations, "max-batch-operations", 100, "The most operations a POST /workouts/batch request may hold")
+	flag.DurationVar(&config.IdempotencyWindow, "idempotency-window", 24*time.Hour, "How long responses to writes sent with an Idempotency-Key are replayed to retries")
 	flag.Parse()
-	application, err := app.NewApplication()
+	application, err := app.NewApplication(config)
 	if err != nil {
 		panic(err)
 	}
 	defer application.DB.Close()
+	go application.ExpireIdleSessions(context.Background())
+	go application.PurgeTrash(context.Background())
+	go application.PurgeIdempotencyKeys(context.Background())
+	go application.EvictIdleStreams(context.Background())
 	http.HandleFunc("/health", application.HealthCheck)
 	r := router.SetupRoutes(application)
 	server := &http.Server{
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+CREATE INDEX IF NOT EXISTS idx_workouts_user_created_at ON workouts(user_id, created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_user_created_at;
+ALTER TABLE users DROP COLUMN timezone;
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_templates(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ title VARCHAR(50) NOT NULL,
+ description TEXT,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS workout_template_entries(
+ id BIGSERIAL PRIMARY KEY,
+ template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE CASCADE,
+ exercise_name VARCHAR(255) NOT NULL,
+ target_sets INTEGER NOT NULL,
+ rep_range_min INTEGER,
+ rep_range_max INTEGER,
+ target_duration_seconds INTEGER,
+ target_weight DECIMAL(5,2),
+ target_percent_1rm DECIMAL(5,2),
+ rest_seconds INTEGER,
+ notes TEXT,
+ order_index INTEGER NOT NULL,
+ CONSTRAINT valid_template_entry CHECK(
+    (rep_range_max IS NOT NULL OR target_duration_seconds IS NOT NULL) AND
+    (rep_range_max IS NULL OR target_duration_seconds IS NULL) AND
+    (rep_range_min IS NULL OR rep_range_min <= rep_range_max) AND
+    (target_weight IS NULL OR target_percent_1rm IS NULL)
+ )
+);
+
+CREATE INDEX IF NOT EXISTS idx_workout_templates_user_id ON workout_templates(user_id);
+
+ALTER TABLE workouts
+ADD COLUMN template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN template_id;
+DROP TABLE workout_template_entries;
+DROP TABLE workout_templates;
+-- +goose StatementEnd
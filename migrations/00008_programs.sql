@@ -0,0 +1,52 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS programs(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ title VARCHAR(100) NOT NULL,
+ description TEXT,
+ duration_weeks INTEGER NOT NULL CHECK (duration_weeks > 0),
+ weekly_increment DECIMAL(5,2) NOT NULL DEFAULT 0,
+ deload_percent DECIMAL(5,2) NOT NULL DEFAULT 60,
+ is_public BOOLEAN NOT NULL DEFAULT FALSE,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS program_weeks(
+ program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+ week_number INTEGER NOT NULL CHECK (week_number > 0),
+ is_deload BOOLEAN NOT NULL DEFAULT FALSE,
+ intensity_percent DECIMAL(5,2),
+ PRIMARY KEY (program_id, week_number)
+);
+
+-- A NULL week_number schedules the session in every week of the program.
+CREATE TABLE IF NOT EXISTS program_sessions(
+ id BIGSERIAL PRIMARY KEY,
+ program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+ template_id BIGINT NOT NULL REFERENCES workout_templates(id) ON DELETE CASCADE,
+ week_number INTEGER CHECK (week_number > 0),
+ day_number INTEGER NOT NULL CHECK (day_number BETWEEN 1 AND 7)
+);
+
+CREATE TABLE IF NOT EXISTS program_enrolments(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ program_id BIGINT NOT NULL REFERENCES programs(id) ON DELETE CASCADE,
+ start_date DATE NOT NULL,
+ status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'completed', 'cancelled')),
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_program_sessions_program_id ON program_sessions(program_id);
+CREATE INDEX IF NOT EXISTS idx_program_enrolments_user_id ON program_enrolments(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE program_enrolments;
+DROP TABLE program_sessions;
+DROP TABLE program_weeks;
+DROP TABLE programs;
+-- +goose StatementEnd
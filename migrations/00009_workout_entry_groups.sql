@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+ADD COLUMN group_id INTEGER,
+ADD COLUMN group_type VARCHAR(20),
+ADD COLUMN group_rounds INTEGER,
+ADD COLUMN group_rest_seconds INTEGER,
+ADD CONSTRAINT valid_entry_group CHECK(
+    (group_id IS NULL) = (group_type IS NULL) AND
+    (group_type IS NULL OR group_type IN ('superset', 'circuit', 'emom', 'amrap')) AND
+    (group_rounds IS NULL OR group_rounds > 0) AND
+    (group_rest_seconds IS NULL OR group_rest_seconds >= 0)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+DROP CONSTRAINT valid_entry_group,
+DROP COLUMN group_rest_seconds,
+DROP COLUMN group_rounds,
+DROP COLUMN group_type,
+DROP COLUMN group_id;
+-- +goose StatementEnd
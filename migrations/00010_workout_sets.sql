@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_sets(
+ id BIGSERIAL PRIMARY KEY,
+ entry_id BIGINT NOT NULL REFERENCES workout_entries(id) ON DELETE CASCADE,
+ set_number INTEGER NOT NULL,
+ set_type VARCHAR(20) NOT NULL DEFAULT 'working',
+ reps INTEGER,
+ weight DECIMAL(5,2),
+ duration_seconds INTEGER,
+ distance_meters DECIMAL(9,2),
+ rpe DECIMAL(3,1),
+ rir INTEGER,
+ completed BOOLEAN NOT NULL DEFAULT TRUE,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ UNIQUE (entry_id, set_number),
+ CONSTRAINT valid_workout_set CHECK(
+    (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+    set_type IN ('warmup', 'working', 'drop', 'failure') AND
+    (rpe IS NULL OR rpe BETWEEN 1 AND 10) AND
+    (rir IS NULL OR rir >= 0)
+ )
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_sets;
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE workout_entries
+ADD COLUMN distance_meters DECIMAL(10,2),
+ADD COLUMN elevation_gain_meters DECIMAL(8,2),
+ADD COLUMN avg_heart_rate INTEGER,
+ADD COLUMN max_heart_rate INTEGER,
+ADD COLUMN avg_speed_mps DECIMAL(6,3);
+
+CREATE TABLE IF NOT EXISTS workout_tracks(
+ workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+ source_format VARCHAR(3) NOT NULL,
+ points JSONB NOT NULL,
+ summary JSONB NOT NULL,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_tracks;
+ALTER TABLE workout_entries
+DROP COLUMN avg_speed_mps,
+DROP COLUMN max_heart_rate,
+DROP COLUMN avg_heart_rate,
+DROP COLUMN elevation_gain_meters,
+DROP COLUMN distance_meters;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN max_heart_rate INTEGER CHECK (max_heart_rate BETWEEN 100 AND 250),
+ADD COLUMN resting_heart_rate INTEGER CHECK (resting_heart_rate BETWEEN 25 AND 120),
+ADD COLUMN hr_zone_model VARCHAR(20) NOT NULL DEFAULT 'percent_max' CHECK (hr_zone_model IN ('percent_max', 'karvonen'));
+
+-- Samples are delta-encoded varints, see internal/heartrate.Encode.
+CREATE TABLE IF NOT EXISTS workout_heart_rate(
+ workout_id BIGINT PRIMARY KEY REFERENCES workouts(id) ON DELETE CASCADE,
+ started_at TIMESTAMP WITH TIME ZONE NOT NULL,
+ sample_count INTEGER NOT NULL,
+ samples BYTEA NOT NULL,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_heart_rate;
+ALTER TABLE users
+DROP COLUMN hr_zone_model,
+DROP COLUMN resting_heart_rate,
+DROP COLUMN max_heart_rate;
+-- +goose StatementEnd
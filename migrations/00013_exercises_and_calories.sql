@@ -0,0 +1,58 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS exercises(
+ id BIGSERIAL PRIMARY KEY,
+ name VARCHAR(255) NOT NULL,
+ category VARCHAR(30) NOT NULL,
+ met DECIMAL(4,1) NOT NULL CHECK (met > 0),
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_exercises_lower_name ON exercises(lower(name));
+
+-- MET values follow the Compendium of Physical Activities.
+INSERT INTO exercises (name, category, met) VALUES
+ ('Bench Press', 'strength', 5.0),
+ ('Incline Bench Press', 'strength', 5.0),
+ ('Overhead Press', 'strength', 5.0),
+ ('Squat', 'strength', 6.0),
+ ('Front Squat', 'strength', 6.0),
+ ('Deadlift', 'strength', 6.0),
+ ('Romanian Deadlift', 'strength', 5.0),
+ ('Barbell Row', 'strength', 5.0),
+ ('Pull Up', 'strength', 8.0),
+ ('Chin Up', 'strength', 8.0),
+ ('Push Up', 'strength', 8.0),
+ ('Dip', 'strength', 8.0),
+ ('Lunge', 'strength', 4.0),
+ ('Leg Press', 'strength', 5.0),
+ ('Bicep Curl', 'strength', 3.5),
+ ('Tricep Extension', 'strength', 3.5),
+ ('Lateral Raise', 'strength', 3.5),
+ ('Plank', 'core', 3.8),
+ ('Crunch', 'core', 3.8),
+ ('Kettlebell Swing', 'conditioning', 9.8),
+ ('Burpee', 'conditioning', 8.0),
+ ('Jump Rope', 'conditioning', 11.8),
+ ('Rowing Machine', 'cardio', 7.0),
+ ('Running', 'cardio', 9.8),
+ ('Cycling', 'cardio', 7.5),
+ ('Walking', 'cardio', 3.5),
+ ('Swimming', 'cardio', 8.3),
+ ('Elliptical', 'cardio', 5.0),
+ ('Stretching', 'mobility', 2.3),
+ ('Yoga', 'mobility', 2.5)
+ON CONFLICT DO NOTHING;
+
+ALTER TABLE users
+ADD COLUMN body_weight_kg DECIMAL(5,2) CHECK (body_weight_kg > 0);
+
+ALTER TABLE workouts
+ADD COLUMN calories_estimated BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN calories_estimated;
+ALTER TABLE users DROP COLUMN body_weight_kg;
+DROP TABLE exercises;
+-- +goose StatementEnd
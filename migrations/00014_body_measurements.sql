@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Values are stored in kg and cm; unit records the system the user entered.
+CREATE TABLE IF NOT EXISTS body_measurements(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ measured_at TIMESTAMP WITH TIME ZONE NOT NULL,
+ unit VARCHAR(10) NOT NULL DEFAULT 'metric' CHECK (unit IN ('metric', 'imperial')),
+ weight_kg DECIMAL(6,2) CHECK (weight_kg > 0),
+ body_fat_percent DECIMAL(4,1) CHECK (body_fat_percent BETWEEN 1 AND 75),
+ neck_cm DECIMAL(5,1) CHECK (neck_cm > 0),
+ chest_cm DECIMAL(5,1) CHECK (chest_cm > 0),
+ waist_cm DECIMAL(5,1) CHECK (waist_cm > 0),
+ hips_cm DECIMAL(5,1) CHECK (hips_cm > 0),
+ arms_cm DECIMAL(5,1) CHECK (arms_cm > 0),
+ thighs_cm DECIMAL(5,1) CHECK (thighs_cm > 0),
+ notes TEXT,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ updated_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE INDEX IF NOT EXISTS idx_body_measurements_user_measured_at ON body_measurements(user_id, measured_at DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE body_measurements;
+-- +goose StatementEnd
@@ -0,0 +1,59 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users
+ADD COLUMN unit_system VARCHAR(10) NOT NULL DEFAULT 'metric' CHECK (unit_system IN ('metric', 'imperial'));
+
+-- Weights are stored in kg and distances in meters. The extra scale keeps
+-- pound and mile inputs exact so they convert back without loss; the unit
+-- columns record what the user entered.
+ALTER TABLE workout_entries
+ALTER COLUMN weight TYPE NUMERIC(18,10),
+ALTER COLUMN distance_meters TYPE NUMERIC(18,10),
+ADD COLUMN weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb')),
+ADD COLUMN distance_unit VARCHAR(2) NOT NULL DEFAULT 'm' CHECK (distance_unit IN ('m', 'km', 'mi'));
+
+ALTER TABLE workout_sets
+ALTER COLUMN weight TYPE NUMERIC(18,10),
+ALTER COLUMN distance_meters TYPE NUMERIC(18,10);
+
+ALTER TABLE workout_template_entries
+ALTER COLUMN target_weight TYPE NUMERIC(18,10),
+ADD COLUMN weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb'));
+
+ALTER TABLE body_measurements
+ALTER COLUMN weight_kg TYPE NUMERIC(18,10),
+ALTER COLUMN neck_cm TYPE NUMERIC(18,10),
+ALTER COLUMN chest_cm TYPE NUMERIC(18,10),
+ALTER COLUMN waist_cm TYPE NUMERIC(18,10),
+ALTER COLUMN hips_cm TYPE NUMERIC(18,10),
+ALTER COLUMN arms_cm TYPE NUMERIC(18,10),
+ALTER COLUMN thighs_cm TYPE NUMERIC(18,10);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE body_measurements
+ALTER COLUMN thighs_cm TYPE DECIMAL(5,1),
+ALTER COLUMN arms_cm TYPE DECIMAL(5,1),
+ALTER COLUMN hips_cm TYPE DECIMAL(5,1),
+ALTER COLUMN waist_cm TYPE DECIMAL(5,1),
+ALTER COLUMN chest_cm TYPE DECIMAL(5,1),
+ALTER COLUMN neck_cm TYPE DECIMAL(5,1),
+ALTER COLUMN weight_kg TYPE DECIMAL(6,2);
+
+ALTER TABLE workout_template_entries
+DROP COLUMN weight_unit,
+ALTER COLUMN target_weight TYPE DECIMAL(5,2);
+
+ALTER TABLE workout_sets
+ALTER COLUMN distance_meters TYPE DECIMAL(9,2),
+ALTER COLUMN weight TYPE DECIMAL(5,2);
+
+ALTER TABLE workout_entries
+DROP COLUMN distance_unit,
+DROP COLUMN weight_unit,
+ALTER COLUMN distance_meters TYPE DECIMAL(10,2),
+ALTER COLUMN weight TYPE DECIMAL(5,2);
+
+ALTER TABLE users DROP COLUMN unit_system;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS tags(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ name VARCHAR(50) NOT NULL,
+ color VARCHAR(7) NOT NULL DEFAULT '#808080' CHECK (color ~ '^#[0-9A-Fa-f]{6}$'),
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_tags_user_lower_name ON tags(user_id, lower(name));
+
+CREATE TABLE IF NOT EXISTS workout_tags(
+ workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+ tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+ PRIMARY KEY (workout_id, tag_id)
+);
+CREATE INDEX IF NOT EXISTS idx_workout_tags_tag_id ON workout_tags(tag_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_tags;
+DROP TABLE tags;
+-- +goose StatementEnd
@@ -0,0 +1,63 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS workout_sessions(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ title VARCHAR(255) NOT NULL,
+ description TEXT NOT NULL DEFAULT '',
+ template_id BIGINT REFERENCES workout_templates(id) ON DELETE SET NULL,
+ status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'paused', 'finished', 'expired')),
+ started_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ paused_at TIMESTAMP WITH TIME ZONE,
+ paused_seconds INTEGER NOT NULL DEFAULT 0,
+ rest_started_at TIMESTAMP WITH TIME ZONE,
+ rest_target_seconds INTEGER,
+ last_activity_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ ended_at TIMESTAMP WITH TIME ZONE,
+ workout_id BIGINT REFERENCES workouts(id) ON DELETE SET NULL
+);
+-- A user has at most one session in progress.
+CREATE UNIQUE INDEX IF NOT EXISTS idx_workout_sessions_open_user ON workout_sessions(user_id) WHERE status IN ('active', 'paused');
+CREATE INDEX IF NOT EXISTS idx_workout_sessions_open_activity ON workout_sessions(last_activity_at) WHERE status IN ('active', 'paused');
+
+CREATE TABLE IF NOT EXISTS workout_session_entries(
+ id BIGSERIAL PRIMARY KEY,
+ session_id BIGINT NOT NULL REFERENCES workout_sessions(id) ON DELETE CASCADE,
+ exercise_name VARCHAR(255) NOT NULL,
+ weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb')),
+ notes TEXT NOT NULL DEFAULT '',
+ order_index INTEGER NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS workout_session_sets(
+ id BIGSERIAL PRIMARY KEY,
+ entry_id BIGINT NOT NULL REFERENCES workout_session_entries(id) ON DELETE CASCADE,
+ set_number INTEGER NOT NULL,
+ set_type VARCHAR(20) NOT NULL DEFAULT 'working',
+ reps INTEGER,
+ weight NUMERIC(18,10),
+ duration_seconds INTEGER,
+ distance_meters NUMERIC(18,10),
+ rpe DECIMAL(3,1),
+ rir INTEGER,
+ rest_seconds INTEGER,
+ performed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ UNIQUE (entry_id, set_number),
+ CONSTRAINT valid_workout_session_set CHECK(
+    (reps IS NOT NULL OR duration_seconds IS NOT NULL) AND
+    set_type IN ('warmup', 'working', 'drop', 'failure') AND
+    (rpe IS NULL OR rpe BETWEEN 1 AND 10) AND
+    (rir IS NULL OR rir >= 0)
+ )
+);
+
+ALTER TABLE workout_sets ADD COLUMN rest_seconds INTEGER;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_sets DROP COLUMN rest_seconds;
+DROP TABLE workout_session_sets;
+DROP TABLE workout_session_entries;
+DROP TABLE workout_sessions;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS goals(
+ id BIGSERIAL PRIMARY KEY,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ title VARCHAR(255) NOT NULL,
+ kind VARCHAR(20) NOT NULL CHECK (kind IN ('lift', 'frequency', 'volume', 'bodyweight')),
+ exercise_name VARCHAR(255) NOT NULL DEFAULT '',
+ period VARCHAR(10) NOT NULL DEFAULT '' CHECK (period IN ('', 'week', 'month')),
+ -- Weights and volumes are in kg.
+ target_value NUMERIC(18,10) NOT NULL CHECK (target_value > 0),
+ start_value NUMERIC(18,10) NOT NULL DEFAULT 0,
+ current_value NUMERIC(18,10) NOT NULL DEFAULT 0,
+ deadline DATE,
+ achieved_at TIMESTAMP WITH TIME ZONE,
+ evaluated_at TIMESTAMP WITH TIME ZONE,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ CONSTRAINT valid_goal CHECK(
+    (kind = 'lift' AND exercise_name <> '' AND period = '') OR
+    (kind = 'bodyweight' AND exercise_name = '' AND period = '') OR
+    (kind IN ('frequency', 'volume') AND period <> '')
+ )
+);
+CREATE INDEX IF NOT EXISTS idx_goals_user_id ON goals(user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE goals;
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleted workouts stay in the trash until the retention period passes.
+ALTER TABLE workouts ADD COLUMN deleted_at TIMESTAMP WITH TIME ZONE;
+CREATE INDEX IF NOT EXISTS idx_workouts_trash ON workouts(user_id, deleted_at) WHERE deleted_at IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_workouts_trash;
+ALTER TABLE workouts DROP COLUMN deleted_at;
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every change to a workout keeps a full snapshot, entries included, in
+-- canonical units.
+CREATE TABLE IF NOT EXISTS workout_revisions(
+ id BIGSERIAL PRIMARY KEY,
+ workout_id BIGINT NOT NULL REFERENCES workouts(id) ON DELETE CASCADE,
+ revision INTEGER NOT NULL,
+ author_id BIGINT REFERENCES users(id) ON DELETE SET NULL,
+ action VARCHAR(10) NOT NULL CHECK (action IN ('create', 'update', 'revert')),
+ reverted_from INTEGER,
+ snapshot JSONB NOT NULL,
+ created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ UNIQUE (workout_id, revision)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE workout_revisions;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- version increases with every change so clients can detect concurrent edits.
+ALTER TABLE workouts ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workouts DROP COLUMN version;
+-- +goose StatementEnd
@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Every write to a synced record takes the next value of sync_change_seq, so
+-- a client that remembers the highest seq it has seen can ask for what
+-- changed since. Deleted records keep their row as a tombstone.
+CREATE SEQUENCE IF NOT EXISTS sync_change_seq;
+
+CREATE TABLE IF NOT EXISTS sync_changes(
+ entity VARCHAR(20) NOT NULL CHECK (entity IN ('workout', 'template', 'measurement')),
+ entity_id BIGINT NOT NULL,
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ seq BIGINT NOT NULL,
+ deleted BOOLEAN NOT NULL DEFAULT FALSE,
+ changed_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+ PRIMARY KEY (entity, entity_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_sync_changes_user_seq ON sync_changes(user_id, seq);
+
+INSERT INTO sync_changes(entity, entity_id, user_id, seq, deleted)
+SELECT 'workout', id, user_id, nextval('sync_change_seq'), deleted_at IS NOT NULL
+FROM workouts ORDER BY id;
+
+INSERT INTO sync_changes(entity, entity_id, user_id, seq)
+SELECT 'template', id, user_id, nextval('sync_change_seq')
+FROM workout_templates ORDER BY id;
+
+INSERT INTO sync_changes(entity, entity_id, user_id, seq)
+SELECT 'measurement', id, user_id, nextval('sync_change_seq')
+FROM body_measurements ORDER BY id;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE sync_changes;
+DROP SEQUENCE sync_change_seq;
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A write sent with an Idempotency-Key keeps its response here so retries of
+-- it can be answered without running it again. status_code is NULL while the
+-- first request is still in progress.
+CREATE TABLE IF NOT EXISTS idempotency_keys(
+ user_id BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+ idempotency_key VARCHAR(255) NOT NULL,
+ fingerprint VARCHAR(64) NOT NULL,
+ status_code INTEGER,
+ response_header JSONB,
+ response_body BYTEA,
+ created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+ PRIMARY KEY (user_id, idempotency_key)
+);
+
+CREATE INDEX IF NOT EXISTS idx_idempotency_keys_created_at ON idempotency_keys(created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE idempotency_keys;
+-- +goose StatementEnd
@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Deleting a template must not take sessions out of programs other users are
+-- enrolled in, so a template stays until no program schedules it. NO ACTION
+-- is checked at the end of the statement, which still lets a user's own
+-- programs and templates go together when the user is deleted.
+ALTER TABLE program_sessions
+ DROP CONSTRAINT program_sessions_template_id_fkey,
+ ADD CONSTRAINT program_sessions_template_id_fkey FOREIGN KEY (template_id) REFERENCES workout_templates(id) ON DELETE NO ACTION;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE program_sessions
+ DROP CONSTRAINT program_sessions_template_id_fkey,
+ ADD CONSTRAINT program_sessions_template_id_fkey FOREIGN KEY (template_id) REFERENCES workout_templates(id) ON DELETE CASCADE;
+-- +goose StatementEnd
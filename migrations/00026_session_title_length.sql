@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- A finished session's title becomes the title of its workout, so it is held
+-- to the same length.
+ALTER TABLE workout_sessions
+ALTER COLUMN title TYPE VARCHAR(50) USING left(title, 50);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE workout_sessions
+ALTER COLUMN title TYPE VARCHAR(255);
+-- +goose StatementEnd
@@ -0,0 +1,83 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Workouts that predate revision history get their current state as revision
+-- 1. Who made it and when is unknown, so it is a 'baseline' without an author.
+ALTER TABLE workout_revisions
+ DROP CONSTRAINT workout_revisions_action_check,
+ ADD CONSTRAINT workout_revisions_action_check CHECK (action IN ('baseline', 'create', 'update', 'revert'));
+
+INSERT INTO workout_revisions(workout_id, revision, author_id, action, snapshot)
+SELECT w.id, 1, NULL, 'baseline', json_build_object(
+ 'id', w.id,
+ 'user_id', w.user_id,
+ 'title', w.title,
+ 'description', w.description,
+ 'duration_minutes', w.duration_minutes,
+ 'calories_burned', w.calories_burned,
+ 'calories_estimated', w.calories_estimated,
+ 'template_id', w.template_id,
+ 'entries', COALESCE((
+  SELECT json_agg(json_build_object(
+   'id', e.id,
+   'exercise_name', e.exercise_name,
+   'reps', e.reps,
+   'sets', e.sets,
+   'duration_seconds', e.duration_seconds,
+   'weight', e.weight,
+   'weight_unit', e.weight_unit,
+   'notes', e.notes,
+   'order_index', e.order_index,
+   'group_id', e.group_id,
+   'group_type', e.group_type,
+   'group_rounds', e.group_rounds,
+   'group_rest_seconds', e.group_rest_seconds,
+   'set_log', (
+    SELECT json_agg(json_build_object(
+     'id', s.id,
+     'set_number', s.set_number,
+     'set_type', s.set_type,
+     'reps', s.reps,
+     'weight', s.weight,
+     'duration_seconds', s.duration_seconds,
+     'distance_meters', s.distance_meters,
+     'rpe', s.rpe,
+     'rir', s.rir,
+     'completed', s.completed,
+     'rest_seconds', s.rest_seconds
+    ) ORDER BY s.set_number)
+    FROM workout_sets s WHERE s.entry_id = e.id
+   ),
+   'distance_meters', e.distance_meters,
+   'distance_unit', e.distance_unit,
+   'elevation_gain_meters', e.elevation_gain_meters,
+   'avg_heart_rate', e.avg_heart_rate,
+   'max_heart_rate', e.max_heart_rate,
+   'avg_speed_mps', e.avg_speed_mps
+  ) ORDER BY e.order_index)
+  FROM workout_entries e WHERE e.workout_id = w.id
+ ), '[]'::json),
+ 'tag_ids', COALESCE((
+  SELECT json_agg(t.id ORDER BY lower(t.name))
+  FROM tags t INNER JOIN workout_tags wt ON wt.tag_id = t.id
+  WHERE wt.workout_id = w.id
+ ), '[]'::json),
+ 'tags', COALESCE((
+  SELECT json_agg(json_build_object('id', t.id, 'user_id', t.user_id, 'name', t.name, 'color', t.color, 'created_at', t.created_at) ORDER BY lower(t.name))
+  FROM tags t INNER JOIN workout_tags wt ON wt.tag_id = t.id
+  WHERE wt.workout_id = w.id
+ ), '[]'::json),
+ 'created_at', w.created_at,
+ 'updated_at', w.updated_at,
+ 'version', w.version
+)
+FROM workouts w
+WHERE NOT EXISTS (SELECT 1 FROM workout_revisions r WHERE r.workout_id = w.id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+UPDATE workout_revisions SET action = 'create' WHERE action = 'baseline';
+ALTER TABLE workout_revisions
+ DROP CONSTRAINT workout_revisions_action_check,
+ ADD CONSTRAINT workout_revisions_action_check CHECK (action IN ('create', 'update', 'revert'));
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Each reservation of a key gets a fresh token, so a request that lost its
+-- key to a retry after running too long cannot store or release the
+-- retry's response.
+ALTER TABLE idempotency_keys
+ADD COLUMN IF NOT EXISTS reservation VARCHAR(32);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys
+DROP COLUMN reservation;
+-- +goose StatementEnd
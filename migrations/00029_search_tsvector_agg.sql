@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Search matches a workout as one document: its own vector followed by
+-- those of all its entries, so the words of a query may be spread across
+-- the title and an entry's notes. The aggregate keeps the weights that
+-- rank title and exercise name matches above notes.
+CREATE AGGREGATE tsvector_agg(tsvector) (
+    SFUNC = tsvector_concat,
+    STYPE = tsvector,
+    INITCOND = ''
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP AGGREGATE tsvector_agg(tsvector);
+-- +goose StatementEnd
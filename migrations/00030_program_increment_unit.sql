@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The weekly increment is added to template weights, which are kept in kg,
+-- so it is stored in kg too, with the scale that keeps pound inputs exact.
+-- The unit column records what the user entered; earlier increments were
+-- always applied as kg. Body weight set on the profile gets the same scale.
+ALTER TABLE programs
+ALTER COLUMN weekly_increment TYPE NUMERIC(18,10),
+ADD COLUMN weight_unit VARCHAR(2) NOT NULL DEFAULT 'kg' CHECK (weight_unit IN ('kg', 'lb'));
+
+ALTER TABLE users
+ALTER COLUMN body_weight_kg TYPE NUMERIC(18,10);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE users
+ALTER COLUMN body_weight_kg TYPE DECIMAL(5,2);
+
+ALTER TABLE programs
+DROP COLUMN weight_unit,
+ALTER COLUMN weekly_increment TYPE DECIMAL(5,2);
+-- +goose StatementEnd